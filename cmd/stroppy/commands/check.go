@@ -0,0 +1,58 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/state"
+	"gopkg.in/inf.v0"
+)
+
+func newCheckCommand(settings *config.Settings) *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the total balance of accounts against the one stored after populating",
+
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.Validate)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			shellState := state.State{Settings: settings} //nolint
+			dbPayload, err := createPayload(&shellState)
+			if err != nil {
+				llog.Fatalf("failed to create payload: %v", err)
+			}
+
+			if err = dbPayload.Connect(); err != nil {
+				llog.Fatalf("failed to connect to cluster: %v", err)
+			}
+
+			// сохраненный после загрузки счетов баланс сверяется с текущим
+			var sum, balance *inf.Dec
+			if sum, err = dbPayload.Check(nil); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if balance, err = dbPayload.Check(sum); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			llog.Infof("Balance check passed: %v", balance)
+		},
+	}
+
+	checkCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.Journal,
+		"journal", settings.DatabaseSettings.Journal,
+		"Verify the transfer journal and per-bic aggregates against balances")
+
+	checkCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.MultiCurrency,
+		"multi-currency", settings.DatabaseSettings.MultiCurrency,
+		"Verify balances per currency instead of the total balance")
+
+	return checkCmd
+}
@@ -0,0 +1,75 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+)
+
+func newConfigCommand(loadedConfig *config.Config) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show the configuration assembled from the config file, STROPPY_* environment and flags",
+	}
+
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective value of every setting and where it came from",
+		Example: "STROPPY_DATABASE_WORKERS=64 ./stroppy config print --config stroppy.yaml " +
+			"--dbtype fdb",
+		Args: cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Flags().Visit(func(flag *pflag.Flag) {
+				if address, ok := flagAddress(flag); ok {
+					loadedConfig.SetFlag(address)
+				}
+			})
+
+			if loadedConfig.Path != "" {
+				fmt.Printf("config file: %s\n\n", loadedConfig.Path)
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+			fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE\tENVIRONMENT")
+
+			for _, field := range loadedConfig.Fields {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", field.Key, field, field.Source, field.Env)
+			}
+
+			_ = writer.Flush()
+		},
+	}
+
+	configCmd.AddCommand(printCmd)
+
+	return configCmd
+}
+
+// flagAddress - адрес переменной, к которой привязан флаг: стандартные значения pflag
+// либо сами являются указателем на переменную, либо хранят его первым полем
+func flagAddress(flag *pflag.Flag) (uintptr, bool) {
+	value := reflect.ValueOf(flag.Value)
+	if value.Kind() != reflect.Ptr {
+		return 0, false
+	}
+
+	if elem := value.Elem(); elem.Kind() == reflect.Struct {
+		if elem.NumField() == 0 || elem.Field(0).Kind() != reflect.Ptr {
+			return 0, false
+		}
+
+		return elem.Field(0).Pointer(), true
+	}
+
+	return value.Pointer(), true
+}
@@ -38,8 +38,8 @@ func newDeployCommand(settings *config.Settings) *cobra.Command {
 		Version:                "",
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		},
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			return initLogFacility(settings)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.ValidateDeployment)
 		},
 		PreRun: func(cmd *cobra.Command, args []string) {
 		},
@@ -108,7 +108,7 @@ func newDeployCommand(settings *config.Settings) *cobra.Command {
 	deployCmd.PersistentFlags().StringVar(
 		&deploySettings.AnsibleVerbosity,
 		"ansible-verbosity",
-		"",
+		deploySettings.AnsibleVerbosity,
 		"level of ansible stdout",
 	)
 
@@ -116,59 +116,67 @@ func newDeployCommand(settings *config.Settings) *cobra.Command {
 		&deploySettings.ForceReinstall,
 		"force-reinstall",
 		"f",
-		false,
+		deploySettings.ForceReinstall,
 		"force reinstall all componets",
 	)
 
 	deployCmd.PersistentFlags().StringVar(
 		&deploySettings.GrUser,
 		"grafana-user",
-		"stroppy",
+		deploySettings.GrUser,
 		"set user for grafana server",
 	)
 
 	deployCmd.PersistentFlags().StringVar(
 		&deploySettings.GrPassword,
 		"grafana-password",
-		"",
+		deploySettings.GrPassword,
 		"set password for grafana server",
 	)
+	markSensitive(deployCmd.PersistentFlags(), "grafana-password")
 
 	deployCmd.PersistentFlags().Uint16Var(
 		&deploySettings.GrPort,
 		"grafana-port",
-		3000, //nolint
+		deploySettings.GrPort,
 		"set port for grafana server",
 	)
 
 	deployCmd.PersistentFlags().Uint16Var(
 		&deploySettings.PromPort,
 		"prometheus-port",
-		30080, //nolint
+		deploySettings.PromPort,
 		"set port for prometheus server",
 	)
 
 	deployCmd.PersistentFlags().Uint16Var(
 		&deploySettings.PromSPort,
 		"prometheus-secure-port",
-		30443, //nolint
+		deploySettings.PromSPort,
 		"set https port for prometheus server",
 	)
 
 	deployCmd.PersistentFlags().BoolVar(
 		&deploySettings.AllWorkers,
 		"all-workers",
-		false,
+		deploySettings.AllWorkers,
 		"use all nodes as dbms workers (including control plane nodes)",
 	)
 
 	deployCmd.PersistentFlags().BoolVarP(
 		&settings.DatabaseSettings.Sharded,
 		"sharded", "",
-		false,
+		settings.DatabaseSettings.Sharded,
 		"Use to populate accounts in sharded MongoDB cluster. "+
 			"Default false - populate accounts in MongoDB replicasets cluster",
 	)
 
+	deployCmd.PersistentFlags().StringVar(
+		&settings.TestSettings.Scenario,
+		"scenario",
+		settings.TestSettings.Scenario,
+		"path to the test scenario file, default <dir>/third_party/tests/scenario.yaml",
+	)
+
 	return deployCmd
 }
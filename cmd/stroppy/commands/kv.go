@@ -0,0 +1,131 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	"context"
+	"net/http"
+	_ "net/http/pprof"
+
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/state"
+)
+
+func newKVCommand(settings *config.Settings) *cobra.Command {
+	kvCmd := &cobra.Command{
+		Use:     "kv",
+		Aliases: []string{"ycsb"},
+		Short:   "Run the generic key-value workload",
+		Example: "./stroppy kv --dbtype fdb --url fdb.cluster --workload a --records 1000000 -n 1000000",
+
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.ValidateKV)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if settings.EnableProfile {
+				go func() {
+					llog.Infoln(http.ListenAndServe("localhost:6060", nil))
+				}()
+			}
+
+			shellState := state.State{Settings: settings} //nolint
+			dbPayload, err := createPayload(&shellState)
+			if err != nil {
+				llog.Fatalf("failed to create payload: %v", err)
+			}
+
+			if err = dbPayload.Connect(); err != nil {
+				llog.Fatalf("failed to connect to cluster: %v", err)
+			}
+
+			// сбор статистики БД останавливается вместе с окончанием теста
+			statCtx, stopStatistics := context.WithCancel(context.Background())
+			defer stopStatistics()
+
+			if err = dbPayload.StartStatisticsCollect(
+				statCtx,
+				settings.DatabaseSettings.StatInterval,
+			); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if err = dbPayload.KV(&shellState); err != nil {
+				llog.Fatalf("%v", err)
+			}
+		},
+	}
+
+	kvCmd.PersistentFlags().IntVarP(&settings.DatabaseSettings.Count,
+		"count", "n", settings.DatabaseSettings.Count,
+		"Number of operations to run after loading records")
+
+	kvCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.KVWorkload,
+		"workload", settings.DatabaseSettings.KVWorkload,
+		"YCSB core workload 'a' (50% read, 50% update), 'b' (95% read, 5% update), 'c' (100% read), "+
+			"'d' (95% read latest, 5% insert), 'e' (95% scan, 5% insert), 'f' (50% read, 50% read-modify-write) "+
+			"or 'custom' with the proportion flags")
+
+	kvCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.KVRecords,
+		"records", settings.DatabaseSettings.KVRecords,
+		"Number of records to load before running operations")
+
+	kvCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.KVSkipLoad,
+		"skip-load", settings.DatabaseSettings.KVSkipLoad,
+		"Run operations against records loaded by a previous run with the same --records")
+
+	kvCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.KVValueSize,
+		"value-size", settings.DatabaseSettings.KVValueSize,
+		"Size of record values in bytes")
+
+	kvCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.KVValueSizeMax,
+		"value-size-max", settings.DatabaseSettings.KVValueSizeMax,
+		"Maximum size of record values in bytes, sizes are uniform between --value-size and this value if greater")
+
+	kvCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.KVMaxScanLength,
+		"max-scan-length", settings.DatabaseSettings.KVMaxScanLength,
+		"Maximum number of records read by a scan, scan lengths are uniform from 1")
+
+	kvCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.KVDistribution,
+		"distribution", settings.DatabaseSettings.KVDistribution,
+		"Distribution of record accesses: 'uniform', 'zipfian', 'hotspot', 'latest' or 'sequential' "+
+			"(default is the one of the workload, uniform for custom)")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.ZipfTheta,
+		"zipf-theta", settings.DatabaseSettings.ZipfTheta,
+		"Theta (0 < theta < 1) of zipfian and latest distributions as in YCSB, values closer to 1 concentrate accesses on fewer keys")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.HotspotKeys,
+		"hotspot-keys", settings.DatabaseSettings.HotspotKeys,
+		"Fraction of hot keys for hotspot distribution")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.HotspotOps,
+		"hotspot-ops", settings.DatabaseSettings.HotspotOps,
+		"Fraction of operations on hot keys for hotspot distribution")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.KVReadProportion,
+		"read-proportion", settings.DatabaseSettings.KVReadProportion,
+		"Proportion of reads for the custom workload")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.KVUpdateProportion,
+		"update-proportion", settings.DatabaseSettings.KVUpdateProportion,
+		"Proportion of updates for the custom workload")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.KVInsertProportion,
+		"insert-proportion", settings.DatabaseSettings.KVInsertProportion,
+		"Proportion of inserts for the custom workload")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.KVScanProportion,
+		"scan-proportion", settings.DatabaseSettings.KVScanProportion,
+		"Proportion of scans for the custom workload")
+
+	kvCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.KVReadModifyWriteProportion,
+		"rmw-proportion", settings.DatabaseSettings.KVReadModifyWriteProportion,
+		"Proportion of read-modify-writes for the custom workload")
+
+	return kvCmd
+}
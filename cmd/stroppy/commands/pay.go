@@ -5,6 +5,7 @@
 package commands
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof"
 	"time"
@@ -25,8 +26,8 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 		Aliases: []string{"transfer"},
 		Short:   "Run the payments workload",
 
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			return initLogFacility(settings)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.Validate)
 		},
 
 		Run: func(cmd *cobra.Command, args []string) {
@@ -37,14 +38,6 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 					llog.Infoln(http.ListenAndServe("localhost:6060", nil))
 				}()
 			}
-			if settings.TestSettings.UseCloudStroppy && settings.TestSettings.RunAsPod {
-				llog.Fatalf("use-cloud-stroppy and run-as-pod flags specified at the same time")
-			}
-
-			if settings.Local && settings.TestSettings.RunAsPod {
-				llog.Fatalf("--local and --run-as-pod flags specified at the same time")
-			}
-
 			if settings.TestSettings.UseCloudStroppy {
 				sh, err := deployment.LoadState(settings)
 				if err != nil {
@@ -64,7 +57,12 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 					llog.Fatalf("failed to connect to cluster: %v", err)
 				}
 
+				// сбор статистики БД останавливается вместе с окончанием теста
+				statCtx, stopStatistics := context.WithCancel(context.Background())
+				defer stopStatistics()
+
 				if err = dbPayload.StartStatisticsCollect(
+					statCtx,
 					settings.DatabaseSettings.StatInterval,
 				); err != nil {
 					llog.Fatalf("%v", err)
@@ -81,6 +79,7 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 				if err = dbPayload.Pay(&shellState); err != nil {
 					llog.Fatalf("%v", err)
 				}
+				stopStatistics()
 				endTime := (time.Now().UTC().UnixNano() / int64(time.Millisecond)) - 20000
 				llog.Infof("pay test start time: '%d', end time: '%d'", beginTime, endTime)
 
@@ -98,11 +97,49 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 
 	payCmd.PersistentFlags().IntVarP(&settings.DatabaseSettings.Count,
 		"count", "n", settings.DatabaseSettings.Count,
-		"Number of transfers to make")
+		"Number of transfers to make, 0 for no limit with --duration")
+
+	payCmd.PersistentFlags().DurationVar(&settings.DatabaseSettings.Duration,
+		"duration", settings.DatabaseSettings.Duration,
+		"Stop transfers after this time even if --count transfers are not made yet, for example 10m")
 
 	payCmd.PersistentFlags().BoolVarP(&settings.DatabaseSettings.Zipfian,
 		"zipfian", "z", settings.DatabaseSettings.Zipfian,
-		"Use zipfian distribution for payments")
+		"Use zipfian distribution for payments, same as --distribution zipfian")
+
+	payCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.Distribution,
+		"distribution", settings.DatabaseSettings.Distribution,
+		"Distribution of account accesses over bics and bans: "+
+			"'uniform', 'zipfian', 'hotspot', 'latest' or 'sequential'")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.ZipfTheta,
+		"zipf-theta", settings.DatabaseSettings.ZipfTheta,
+		"Theta (0 < theta < 1) of zipfian and latest distributions as in YCSB, values closer to 1 concentrate accesses on fewer keys")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.HotspotKeys,
+		"hotspot-keys", settings.DatabaseSettings.HotspotKeys,
+		"Fraction of hot keys for hotspot distribution")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.HotspotOps,
+		"hotspot-ops", settings.DatabaseSettings.HotspotOps,
+		"Fraction of operations on hot keys for hotspot distribution")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.MissRatio,
+		"miss-ratio", settings.DatabaseSettings.MissRatio,
+		"Fraction of account accesses to accounts that were not populated, requires --enumerate")
+
+	payCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.HotAccounts,
+		"hot-accounts", settings.DatabaseSettings.HotAccounts,
+		"Number of hot accounts taken from the first enumerated accounts, requires --enumerate")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.HotFraction,
+		"hot-fraction", settings.DatabaseSettings.HotFraction,
+		"Fraction of transfers with a hot account as the source or the destination")
+
+	payCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.ContentionTop,
+		"contention-top", settings.DatabaseSettings.ContentionTop,
+		"Record retries, aborts and latency per account and print this number of the most contended accounts, "+
+			"10 by default with --hot-accounts")
 
 	payCmd.PersistentFlags().BoolVarP(&settings.DatabaseSettings.Oracle,
 		"oracle", "o", settings.DatabaseSettings.Oracle,
@@ -116,14 +153,55 @@ func newPayCommand(settings *config.Settings) *cobra.Command {
 		"tx", "t", settings.DatabaseSettings.UseCustomTx,
 		"Use custom implementation of atomic transactions (workaround for dbs without built-in ACID transactions).")
 
+	payCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.Isolation,
+		"isolation", settings.DatabaseSettings.Isolation,
+		"Transaction isolation level for postgres and cockroach: "+
+			"'read committed', 'repeatable read' or 'serializable'")
+
+	payCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.Locking,
+		"locking", settings.DatabaseSettings.Locking,
+		"Account locking strategy for postgres and cockroach: 'update' (implicit row locks), "+
+			"'for-update' (SELECT FOR UPDATE) or 'optimistic' (compare-and-set)")
+
+	payCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.Journal,
+		"journal", settings.DatabaseSettings.Journal,
+		"Append journal entries and update per-bic aggregates in the transfer transaction, "+
+			"check verifies the journal against balances (builtin transactions only)")
+
+	payCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.History,
+		"history", settings.DatabaseSettings.History,
+		"Record every transfer in the history of both accounts in the transfer transaction (builtin transactions only)")
+
+	payCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.StatementWorkers,
+		"statement-workers", settings.DatabaseSettings.StatementWorkers,
+		"Number of workers reading account statements and scanning bank accounts while transfers run, "+
+			"implies --history")
+
+	payCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.StatementLength,
+		"statement-length", settings.DatabaseSettings.StatementLength,
+		"Number of last transfers fetched by an account statement")
+
+	payCmd.PersistentFlags().Float64Var(&settings.DatabaseSettings.BankScanShare,
+		"bank-scan-share", settings.DatabaseSettings.BankScanShare,
+		"Fraction of statement worker operations that scan all accounts of a bic instead of a statement")
+
+	payCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.ScanPageSize,
+		"scan-page-size", settings.DatabaseSettings.ScanPageSize,
+		"Number of accounts per page of a bank scan")
+
 	payCmd.PersistentFlags().StringVarP(&settings.TestSettings.KubernetesMasterAddress,
 		"kube-master-addr", "k",
 		settings.TestSettings.KubernetesMasterAddress,
 		"kubernetes master address")
 
+	payCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.MultiCurrency,
+		"multi-currency", settings.DatabaseSettings.MultiCurrency,
+		"Convert transfers between account currencies by the fx rate table inside the transaction, "+
+			"check verifies balances per currency (builtin transactions only)")
+
 	payCmd.PersistentFlags().BoolVarP(&settings.TestSettings.RunAsPod,
 		"run-as-pod", "",
-		false,
+		settings.TestSettings.RunAsPod,
 		"run stroppy as in pod statement")
 
 	return payCmd
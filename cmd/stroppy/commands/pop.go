@@ -5,6 +5,7 @@
 package commands
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof"
 	"time"
@@ -12,6 +13,7 @@ import (
 	llog "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"gitlab.com/picodata/stroppy/internal/deployment"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
 	"gitlab.com/picodata/stroppy/pkg/database/config"
 	"gitlab.com/picodata/stroppy/pkg/state"
 	"gitlab.com/picodata/stroppy/pkg/statistics"
@@ -25,8 +27,8 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 		Short:   "Create and populate the accounts database",
 		Example: "./stroppy pop -n 100000000",
 
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			return initLogFacility(settings)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.Validate)
 		},
 
 		Run: func(cmd *cobra.Command, args []string) {
@@ -38,14 +40,6 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 				}()
 			}
 
-			if settings.TestSettings.UseCloudStroppy && settings.TestSettings.RunAsPod {
-				llog.Fatalf("--use-cloud-stroppy and --run-as-pod flags specified at the same time")
-			}
-
-			if settings.Local && settings.TestSettings.RunAsPod {
-				llog.Fatalf("--local and --run-as-pod flags specified at the same time")
-			}
-
 			if settings.TestSettings.UseCloudStroppy {
 				sh, err := deployment.LoadState(settings)
 				if err != nil {
@@ -65,7 +59,11 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 					llog.Fatalf("failed to connec to to cluster: %v", err)
 				}
 
-				err = dbPayload.StartStatisticsCollect(settings.DatabaseSettings.StatInterval)
+				// сбор статистики БД останавливается вместе с окончанием теста
+				statCtx, stopStatistics := context.WithCancel(context.Background())
+				defer stopStatistics()
+
+				err = dbPayload.StartStatisticsCollect(statCtx, settings.DatabaseSettings.StatInterval)
 				if err != nil {
 					llog.Fatalf("get stat err %v", err)
 				}
@@ -74,6 +72,7 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 				if err = dbPayload.Pop(&shellState); err != nil {
 					llog.Fatalf("%v", err)
 				}
+				stopStatistics()
 				endTime := (time.Now().UTC().UnixNano() / int64(time.Millisecond)) - 20000
 				llog.Infof("Pop test start time: '%d', end time: '%d'", beginTime, endTime)
 
@@ -91,9 +90,26 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 		settings.DatabaseSettings.Count,
 		"Number of accounts to create")
 
+	popCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.SchemaTemplate,
+		"schema", settings.DatabaseSettings.SchemaTemplate,
+		"Path to the schema template used to bootstrap the database "+
+			"(default <dir>/<dbtype>/"+cluster.SchemaTemplateFileName+" if it exists)")
+
+	popCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.AccountPayload,
+		"account-payload", settings.DatabaseSettings.AccountPayload,
+		"Populate accounts with customer name, currency, status and padding attributes")
+
+	popCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.PaddingSize,
+		"padding-size", settings.DatabaseSettings.PaddingSize,
+		"Size in bytes of the padding attribute of accounts populated with --account-payload")
+
+	popCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.MultiCurrency,
+		"multi-currency", settings.DatabaseSettings.MultiCurrency,
+		"Populate accounts with a currency for multi-currency transfers")
+
 	popCmd.PersistentFlags().BoolVarP(&settings.TestSettings.RunAsPod,
 		"run-as-pod", "",
-		false,
+		settings.TestSettings.RunAsPod,
 		"run stroppy as in pod statement")
 
 	popCmd.PersistentFlags().StringVarP(&settings.TestSettings.KubernetesMasterAddress,
@@ -103,7 +119,7 @@ func newPopCommand(settings *config.Settings) *cobra.Command {
 
 	popCmd.PersistentFlags().BoolVarP(&settings.DatabaseSettings.Sharded,
 		"sharded", "",
-		false,
+		settings.DatabaseSettings.Sharded,
 		"Use to populate accounts in sharded MongoDB cluster. Default false - populate accounts in MongoDB replicasets cluster")
 
 	return popCmd
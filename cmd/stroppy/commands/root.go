@@ -5,18 +5,33 @@
 package commands
 
 import (
+	"os"
+	"strings"
+
+	llog "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/results"
 	"gitlab.com/picodata/stroppy/pkg/statistics"
 )
 
 func Execute() {
 	settings := config.DefaultSettings()
+
+	// файл конфигурации и переменные окружения читаются до объявления флагов,
+	// поэтому значения флагов по умолчанию уже берутся из них
+	configPath := configFlag(os.Args[1:])
+
+	loadedConfig, err := config.Load(settings, configPath)
+	if err != nil {
+		llog.Fatalf("%v", err)
+	}
+
 	dbSettings := settings.DatabaseSettings
 	statistics.StatsSetTotal(dbSettings.Count)
 
 	rootCmd := &cobra.Command{
-		Use:   "stroppy [pop|pay|deploy|shell]",
+		Use:   "stroppy [pop|pay|check|run|sweep|deploy|shell|config|runs]",
 		Short: "stroppy - a sample LWT application implementing an account ledger",
 		Long: `
 This program models an automatic banking system.  It implements 3 model
@@ -25,7 +40,7 @@ checking correctness. It collects client-side metrics for latency and
 bandwidth along the way.`,
 		Version: "0.10",
 		PersistentPreRunE: func(_ *cobra.Command, _ []string) (err error) {
-			if err = initLogFacility(settings); err != nil {
+			if err = initLogFormat(settings); err != nil {
 				return
 			}
 
@@ -35,8 +50,16 @@ bandwidth along the way.`,
 
 			return
 		},
+		// запуск с ошибкой завершается через llog.Fatal, до этой точки доходят только успешные
+		PersistentPostRun: func(_ *cobra.Command, _ []string) {
+			results.Finish(nil)
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&configPath, "config",
+		configPath,
+		"path to the config file, "+config.ConfigEnv+" by default")
+
 	rootCmd.PersistentFlags().BoolVar(&settings.DestroyOnExit, "auto-destroy",
 		settings.DestroyOnExit,
 		"specify this key if you want to destroy deployed cluster when stroppy complete its own work")
@@ -49,6 +72,10 @@ bandwidth along the way.`,
 		settings.Local,
 		"operate with local cluster")
 
+	rootCmd.PersistentFlags().BoolVar(&settings.Direct, "direct",
+		settings.Direct,
+		"connect to the database by --url only, without ssh, terraform and kubernetes")
+
 	rootCmd.PersistentFlags().StringVarP(&settings.ChaosParameter,
 		"chaos-parameter", "c",
 		settings.ChaosParameter, "specify chaos parameter of an free form")
@@ -67,6 +94,7 @@ bandwidth along the way.`,
 		"password", "p",
 		settings.DatabaseSettings.Password,
 		"Database password")
+	markSensitive(rootCmd.PersistentFlags(), "password")
 
 	rootCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.DBType,
 		"dbtype",
@@ -78,11 +106,26 @@ bandwidth along the way.`,
 		settings.DatabaseSettings.DBURL,
 		"Connection string, required flag")
 
+	rootCmd.PersistentFlags().StringSliceVar(&settings.DatabaseSettings.Endpoints,
+		"endpoints",
+		settings.DatabaseSettings.Endpoints,
+		"Additional connection strings of the same cluster, postgres and cockroach only")
+
+	rootCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.Routing,
+		"routing",
+		settings.DatabaseSettings.Routing,
+		"Routing policy between url and endpoints: 'round-robin', 'primary-failover' or 'read-replica'")
+
 	rootCmd.PersistentFlags().StringVar(&settings.WorkingDirectory,
 		"dir",
 		settings.WorkingDirectory,
 		"Working directory, if not specified used ./benchmark/deploy")
 
+	rootCmd.PersistentFlags().StringVar(&settings.ResultsDirectory,
+		"results-dir",
+		settings.ResultsDirectory,
+		"Directory of run results, results in the working directory by default")
+
 	rootCmd.PersistentFlags().Float64VarP(&settings.DatabaseSettings.BanRangeMultiplier,
 		"banRangeMultiplier", "r",
 		settings.DatabaseSettings.BanRangeMultiplier,
@@ -96,6 +139,22 @@ than we saved during DB population process (that is achieved if brm > 1).
 The recommended range of brm is from 1.01 to 1.1. 
 The default value of banRangeMultipluer is 1.1.`)
 
+	rootCmd.PersistentFlags().BoolVar(&settings.DatabaseSettings.Enumerate,
+		"enumerate",
+		settings.DatabaseSettings.Enumerate,
+		`enumerate accounts instead of drawing random BANs. Account index
+is mapped to a (BIC, BAN) pair one-to-one, so pop inserts no duplicates
+and pay picks existing accounts only, except for --miss-ratio of transfers.
+banRangeMultiplier is ignored. Must be set for both pop and pay.`)
+
+	rootCmd.PersistentFlags().Int64Var(&settings.DatabaseSettings.Seed,
+		"seed",
+		settings.DatabaseSettings.Seed,
+		`seed of generated accounts and transfers, current time by default.
+Each worker derives its own stream from the seed and the worker index,
+so a run with the same seed and workers count replays the same accounts
+or transfers against a fresh database.`)
+
 	rootCmd.PersistentFlags().IntVarP(&settings.DatabaseSettings.Workers,
 		"workers", "w",
 		settings.DatabaseSettings.Workers,
@@ -104,22 +163,79 @@ The default value of banRangeMultipluer is 1.1.`)
 	rootCmd.PersistentFlags().DurationVarP(&settings.DatabaseSettings.StatInterval,
 		"stat-interval", "s",
 		settings.DatabaseSettings.StatInterval,
-		"interval by seconds for gettings db stats. Only fdb yet.")
+		"interval of getting db stats with a unit, for example 10s")
 	rootCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.ConnectPoolSize,
 		"pool-size",
 		settings.DatabaseSettings.ConnectPoolSize,
 		"count of connection in db pool. Equal workers count by default.")
 
-	rootCmd.PersistentFlags().BoolVarP(&settings.TestSettings.UseCloudStroppy,
-		"enable-profilier", "",
-		false,
+	rootCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.Clients,
+		"clients",
+		settings.DatabaseSettings.Clients,
+		`number of stroppy clients running pop and pay together. Each client
+makes its part of count with its part of workers and a seed shifted by its
+index. Deploy creates a stroppy pod for every client, and --use-cloud-stroppy
+runs the steps in all of them and merges their statistics.`)
+
+	rootCmd.PersistentFlags().IntVar(&settings.DatabaseSettings.ClientIndex,
+		"client-index",
+		settings.DatabaseSettings.ClientIndex,
+		"index of this client from 0 to --clients - 1, client 0 prepares the database")
+
+	rootCmd.PersistentFlags().StringVar(&settings.DatabaseSettings.Barrier,
+		"barrier",
+		settings.DatabaseSettings.Barrier,
+		"empty directory shared by the clients of a run, they start together through its files")
+
+	rootCmd.PersistentFlags().BoolVar(&settings.TestSettings.UseCloudStroppy,
+		"use-cloud-stroppy",
+		settings.TestSettings.UseCloudStroppy,
+		"run the test in the stroppy pod of the deployed cluster")
+
+	rootCmd.PersistentFlags().BoolVar(&settings.EnableProfile,
+		"enable-profiler",
+		settings.EnableProfile,
+		"specify to use pprof for diagnostic")
+
+	rootCmd.PersistentFlags().BoolVar(&settings.EnableProfile,
+		"enable-profilier",
+		settings.EnableProfile,
 		"specify to use pprof for diagnostic")
+	_ = rootCmd.PersistentFlags().MarkDeprecated("enable-profilier", "use --enable-profiler")
 
 	rootCmd.AddCommand(newPopCommand(settings),
 		newPayCommand(settings),
+		newKVCommand(settings),
+		newCheckCommand(settings),
+		newRunCommand(settings),
+		newSweepCommand(settings),
 		newDeployCommand(settings),
 		newShellCommand(settings),
+		newConfigCommand(loadedConfig),
+		newRunsCommand(settings),
 		newVersionCommand())
 
-	_ = rootCmd.Execute()
+	// cobra уже вывел ошибку, остается завершиться с ненулевым кодом
+	if err = rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// configFlag - найти путь к файлу конфигурации среди аргументов до их разбора
+func configFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		if path := strings.TrimPrefix(arg, "--config="); path != arg {
+			return path
+		}
+
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return ""
 }
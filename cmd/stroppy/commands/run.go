@@ -0,0 +1,228 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/picodata/stroppy/internal/deployment"
+	"gitlab.com/picodata/stroppy/internal/payload"
+	"gitlab.com/picodata/stroppy/internal/scenario"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/engine/chaos"
+	"gitlab.com/picodata/stroppy/pkg/results"
+	"gitlab.com/picodata/stroppy/pkg/state"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+	"gopkg.in/inf.v0"
+)
+
+func newRunCommand(settings *config.Settings) *cobra.Command {
+	var reportFile string
+
+	runCmd := &cobra.Command{
+		Use:     "run <scenario>",
+		Short:   "Run the steps of a scenario file in order and save a run report with the timeline",
+		Example: "./stroppy run third_party/tests/scenario.yaml --dbtype postgres --url postgres://...",
+		Args:    cobra.ExactArgs(1),
+
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			settings.TestSettings.Scenario = args[0]
+
+			return initLogFacility(settings, cmd, settings.Validate)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if settings.EnableProfile {
+				go func() {
+					llog.Infoln(http.ListenAndServe("localhost:6060", nil))
+				}()
+			}
+
+			// шаги выполняются в поде stroppy развернутого кластера
+			if settings.TestSettings.UseCloudStroppy {
+				sh, err := deployment.LoadState(settings)
+				if err != nil {
+					llog.Fatalf("deployment load state failed: %v", err)
+				}
+
+				if err = sh.RunScenario(); err != nil {
+					llog.Fatalf("scenario failed: %v", err)
+				}
+
+				return
+			}
+
+			testScenario, err := scenario.Load(args[0], settings.DatabaseSettings)
+			if err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if err = testScenario.Validate(settings); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			testScenario.ReportFile = reportFile
+
+			shellState := state.State{Settings: settings} //nolint
+			dbPayload, chaosController, err := createPayloadWithChaos(&shellState)
+			if err != nil {
+				llog.Fatalf("failed to create payload: %v", err)
+			}
+
+			// хаос запускают шаги сценария, а не --chaos-parameter
+			dbPayload.DelegateChaos()
+
+			if err = dbPayload.Connect(); err != nil {
+				llog.Fatalf("failed to connect to cluster: %v", err)
+			}
+
+			// сбор статистики БД идет на протяжении всего сценария
+			statCtx, stopStatistics := context.WithCancel(context.Background())
+			defer stopStatistics()
+
+			if err = dbPayload.StartStatisticsCollect(
+				statCtx,
+				settings.DatabaseSettings.StatInterval,
+			); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			executor := &localExecutor{
+				payload: dbPayload,
+				chaos:   chaosController,
+				state:   &shellState,
+			}
+
+			if err = testScenario.Execute(
+				executor,
+				results.Dir(settings.WorkingDirectory),
+				settings.DatabaseSettings.DBType,
+			); err != nil {
+				llog.Fatalf("scenario failed: %v", err)
+			}
+		},
+	}
+
+	runCmd.PersistentFlags().StringVarP(&settings.TestSettings.KubernetesMasterAddress,
+		"kube-master-addr", "k",
+		settings.TestSettings.KubernetesMasterAddress,
+		"kubernetes master address")
+
+	runCmd.PersistentFlags().StringVar(&reportFile,
+		"report", "",
+		"path of the run report, <dbtype>_run_<scenario>_<date>.json in the run results directory by default")
+
+	return runCmd
+}
+
+// localExecutor - выполнение шагов сценария в этом процессе
+type localExecutor struct {
+	payload payload.Payload
+	chaos   chaos.Controller
+	state   *state.State
+
+	// итоги последнего шага pay для серии запусков
+	paySummary statistics.Summary
+}
+
+func (e *localExecutor) Pop(settings *config.DatabaseSettings) error {
+	e.payload.UpdateSettings(settings)
+	statistics.StatsInit()
+	statistics.StatsSetTotal(settings.Count)
+
+	if err := e.payload.Pop(e.state); err != nil {
+		return merry.Prepend(err, "failed to populate accounts")
+	}
+
+	// общий баланс сохраняется после загрузки для последующих проверок
+	balance, err := e.payload.Check(nil)
+	if err != nil {
+		return merry.Prepend(err, "failed to persist total balance")
+	}
+
+	llog.Infof("Total balance: %v", balance)
+
+	return nil
+}
+
+func (e *localExecutor) Pay(settings *config.DatabaseSettings) error {
+	var (
+		sum *inf.Dec
+		err error
+	)
+
+	e.payload.UpdateSettings(settings)
+	statistics.StatsInit()
+	statistics.StatsSetTotal(settings.Count)
+
+	if settings.Check {
+		if sum, err = e.payload.Check(nil); err != nil {
+			return merry.Prepend(err, "failed to fetch initial balance")
+		}
+
+		llog.Infof("Initial balance: %v", sum)
+	}
+
+	if err = e.payload.Pay(e.state); err != nil {
+		return merry.Prepend(err, "failed to make transfers")
+	}
+
+	e.paySummary = statistics.StatsLastSummary()
+
+	if settings.Check {
+		var balance *inf.Dec
+		if balance, err = e.payload.Check(sum); err != nil {
+			return merry.Prepend(err, "failed to check final balance")
+		}
+
+		llog.Infof("Final balance: %v", balance)
+	}
+
+	return nil
+}
+
+func (e *localExecutor) PaySummary() statistics.Summary {
+	return e.paySummary
+}
+
+func (e *localExecutor) StepSummary() *statistics.Summary {
+	summary := statistics.StatsLastSummary()
+
+	return &summary
+}
+
+func (e *localExecutor) Check(settings *config.DatabaseSettings) error {
+	e.payload.UpdateSettings(settings)
+
+	sum, err := e.payload.Check(nil)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch stored balance")
+	}
+
+	var balance *inf.Dec
+	if balance, err = e.payload.Check(sum); err != nil {
+		return merry.Prepend(err, "failed to check balance")
+	}
+
+	llog.Infof("Balance check passed: %v", balance)
+
+	return nil
+}
+
+func (e *localExecutor) StartChaos(chaosScenario string) error {
+	if err := e.chaos.ExecuteCommand(chaosScenario, e.state); err != nil {
+		return merry.Prepend(err, "failed to start chaos")
+	}
+
+	return nil
+}
+
+func (e *localExecutor) StopChaos() {
+	e.chaos.Stop()
+}
@@ -0,0 +1,141 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/results"
+)
+
+func newRunsCommand(settings *config.Settings) *cobra.Command {
+	runsCmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Browse results of past runs saved in the results directory",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List past runs from the oldest to the newest",
+		Args:  cobra.NoArgs,
+
+		Run: func(_ *cobra.Command, _ []string) {
+			runs, err := results.List(settings.ResultsRoot())
+			if err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if len(runs) == 0 {
+				fmt.Printf("no runs in %s\n", settings.ResultsRoot())
+
+				return
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+			fmt.Fprintln(writer, "ID\tCOMMAND\tDBTYPE\tSTART\tDURATION\tSTATUS\tREQUESTS\tT/SEC")
+
+			for _, run := range runs {
+				requests, throughput := "-", "-"
+				if run.Summary != nil {
+					requests = fmt.Sprintf("%d", run.Summary.Requests)
+					throughput = fmt.Sprintf("%.1f", run.Summary.Throughput)
+				}
+
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					run.ID, run.Command, run.DBType, run.Start.Format(time.Stamp),
+					run.Duration().Truncate(time.Second), run.Status, requests, throughput)
+			}
+
+			_ = writer.Flush()
+		},
+	}
+
+	var showSnapshot bool
+
+	showCmd := &cobra.Command{
+		Use:     "show <run id|last>",
+		Short:   "Show a run: its command, result, summary and files, an unique beginning of the id is enough",
+		Example: "./stroppy runs show last --snapshot",
+		Args:    cobra.ExactArgs(1),
+
+		Run: func(_ *cobra.Command, args []string) {
+			run, err := results.Find(settings.ResultsRoot(), args[0])
+			if err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if showSnapshot {
+				data, err := os.ReadFile(filepath.Join(run.Dir, results.ConfigFileName))
+				if err != nil {
+					llog.Fatalf("failed to read config snapshot: %v", err)
+				}
+
+				fmt.Print(string(data))
+
+				return
+			}
+
+			printRun(run)
+		},
+	}
+
+	showCmd.Flags().BoolVar(&showSnapshot, "snapshot", false,
+		"print the config snapshot of the run, it can be given back to --config to repeat the run")
+
+	runsCmd.AddCommand(listCmd, showCmd)
+
+	return runsCmd
+}
+
+func printRun(run *results.Run) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+
+	fmt.Fprintf(writer, "id\t%s\n", run.ID)
+	fmt.Fprintf(writer, "directory\t%s\n", run.Dir)
+	fmt.Fprintf(writer, "command\tstroppy %s\n", strings.Join(run.Args, " "))
+	fmt.Fprintf(writer, "database\t%s\n", run.DBType)
+	fmt.Fprintf(writer, "start\t%s\n", run.Start.Format(time.RFC3339))
+	fmt.Fprintf(writer, "duration\t%s\n", run.Duration().Truncate(time.Second))
+	fmt.Fprintf(writer, "status\t%s\n", run.Status)
+
+	if run.Error != "" {
+		fmt.Fprintf(writer, "error\t%s\n", run.Error)
+	}
+
+	if summary := run.Summary; summary != nil {
+		fmt.Fprintf(writer, "requests\t%d in %.1fs, %.1f per second\n",
+			summary.Requests, summary.Duration, summary.Throughput)
+		fmt.Fprintf(writer, "latency, ms\tmin %.2f, avg %.2f, p50 %.2f, p95 %.2f, p99 %.2f, p99.9 %.2f, max %.2f\n",
+			summary.LatencyMin*1000, summary.LatencyAvg*1000, summary.LatencyP50*1000, //nolint
+			summary.LatencyP95*1000, summary.LatencyP99*1000, summary.LatencyP999*1000, //nolint
+			summary.LatencyMax*1000) //nolint
+	}
+
+	_ = writer.Flush()
+
+	entries, err := os.ReadDir(run.Dir)
+	if err != nil {
+		llog.Fatalf("failed to read run directory: %v", err)
+	}
+
+	fmt.Println("\nfiles:")
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		fmt.Printf("  %-60s %10d\n", entry.Name(), info.Size())
+	}
+}
@@ -16,8 +16,8 @@ func newShellCommand(settings *config.Settings) (shellCmd *cobra.Command) {
 		Use:   "shell",
 		Short: "Open shell to already deployed cluster",
 
-		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-			return initLogFacility(settings)
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.ValidateDeployment)
 		},
 
 		Run: func(cmd *cobra.Command, args []string) {
@@ -36,5 +36,10 @@ func newShellCommand(settings *config.Settings) (shellCmd *cobra.Command) {
 		settings.DeploymentSettings.Provider,
 		"name of cloud provider")
 
+	shellCmd.PersistentFlags().StringVar(&settings.TestSettings.Scenario,
+		"scenario",
+		settings.TestSettings.Scenario,
+		"Path to the test scenario file, default <dir>/third_party/tests/scenario.yaml")
+
 	return
 }
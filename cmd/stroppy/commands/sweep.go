@@ -0,0 +1,144 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package commands
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gitlab.com/picodata/stroppy/internal/scenario"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/results"
+	"gitlab.com/picodata/stroppy/pkg/state"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+func newSweepCommand(settings *config.Settings) *cobra.Command {
+	sweepCmd := &cobra.Command{
+		Use:     "sweep <scenario>",
+		Short:   "Run a scenario for every combination of its matrix and save a table of results",
+		Example: "./stroppy sweep third_party/tests/sweep.yaml --dbtype postgres --url postgres://...",
+		Args:    cobra.ExactArgs(1),
+
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return initLogFacility(settings, cmd, settings.Validate)
+		},
+
+		Run: func(cmd *cobra.Command, args []string) {
+			// итоги собираются из статистики этого процесса
+			if settings.TestSettings.UseCloudStroppy {
+				llog.Fatalf("sweep runs the scenario in this process only, --use-cloud-stroppy is not supported")
+			}
+
+			if settings.EnableProfile {
+				go func() {
+					llog.Infoln(http.ListenAndServe("localhost:6060", nil))
+				}()
+			}
+
+			testScenario, err := scenario.Load(args[0], settings.DatabaseSettings)
+			if err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			if err = testScenario.Validate(settings); err != nil {
+				llog.Fatalf("%v", err)
+			}
+
+			sweeper := &sweeper{settings: settings}
+			defer sweeper.close()
+
+			if err = testScenario.Sweep(
+				sweeper.prepare,
+				results.Dir(settings.WorkingDirectory),
+				settings.DatabaseSettings.DBType,
+			); err != nil {
+				llog.Fatalf("sweep failed: %v", err)
+			}
+		},
+	}
+
+	sweepCmd.PersistentFlags().StringVarP(&settings.TestSettings.KubernetesMasterAddress,
+		"kube-master-addr", "k",
+		settings.TestSettings.KubernetesMasterAddress,
+		"kubernetes master address")
+
+	return sweepCmd
+}
+
+// sweeper - подготовка исполнителя к очередному сочетанию матрицы: подключение к БД
+// пересоздается, только если меняется размер пула соединений
+type sweeper struct {
+	settings *config.Settings
+
+	executor       *localExecutor
+	poolSize       int
+	stopStatistics context.CancelFunc
+}
+
+func (s *sweeper) close() {
+	if s.stopStatistics != nil {
+		s.stopStatistics()
+		s.stopStatistics = nil
+	}
+
+	if s.executor != nil {
+		s.executor.payload.Close()
+		s.executor = nil
+	}
+}
+
+func (s *sweeper) prepare(combination *scenario.Combination) (scenario.SweepExecutor, error) {
+	poolSize := combination.Settings.ConnectPoolSize
+	if poolSize == 0 {
+		poolSize = combination.Settings.Workers
+	}
+
+	if s.executor != nil && poolSize == s.poolSize {
+		s.executor.paySummary = statistics.Summary{} //nolint
+
+		return s.executor, nil
+	}
+
+	s.close()
+
+	// создание кластера дополняет параметры, поэтому каждому подключению своя копия
+	databaseSettings := *combination.Settings
+	settings := *s.settings
+	settings.DatabaseSettings = &databaseSettings
+
+	shellState := state.State{Settings: &settings} //nolint
+
+	dbPayload, chaosController, err := createPayloadWithChaos(&shellState)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create payload")
+	}
+
+	// хаос запускают шаги сценария, а не --chaos-parameter
+	dbPayload.DelegateChaos()
+
+	if err = dbPayload.Connect(); err != nil {
+		return nil, merry.Prepend(err, "failed to connect to cluster")
+	}
+
+	var statCtx context.Context
+
+	statCtx, s.stopStatistics = context.WithCancel(context.Background())
+	if err = dbPayload.StartStatisticsCollect(statCtx, databaseSettings.StatInterval); err != nil {
+		return nil, merry.Prepend(err, "failed to start statistics collect")
+	}
+
+	s.executor = &localExecutor{
+		payload: dbPayload,
+		chaos:   chaosController,
+		state:   &shellState,
+	}
+	s.poolSize = poolSize
+
+	return s.executor, nil
+}
@@ -10,15 +10,18 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"gitlab.com/picodata/stroppy/pkg/engine/ssh"
 	"gitlab.com/picodata/stroppy/pkg/engine/terraform"
 	"gitlab.com/picodata/stroppy/pkg/kubernetes"
+	"gitlab.com/picodata/stroppy/pkg/results"
 	"gitlab.com/picodata/stroppy/pkg/state"
 
 	"github.com/ansel1/merry"
 	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gitlab.com/picodata/stroppy/internal/payload"
 	"gitlab.com/picodata/stroppy/pkg/database/config"
 	"gitlab.com/picodata/stroppy/pkg/engine/chaos"
@@ -27,13 +30,24 @@ import (
 )
 
 func createPayload(shellState *state.State) (payload.Payload, error) {
+	dbPayload, _, err := createPayloadWithChaos(shellState)
+
+	return dbPayload, err
+}
+
+// createPayloadWithChaos - создать payload и контроллер хаоса для шагов сценария
+func createPayloadWithChaos(shellState *state.State) (payload.Payload, chaos.Controller, error) {
+	if shellState.Settings.Direct {
+		return createDirectPayload(shellState)
+	}
+
 	var (
 		sshClient ssh.Client
 		err       error
 	)
 
 	if sshClient, err = kubeengine.CreateSystemShell(shellState.Settings); err != nil {
-		return nil, merry.Prepend(err, "failed to create system shell")
+		return nil, nil, merry.Prepend(err, "failed to create system shell")
 	}
 
 	terraformProvider := terraform.CreateTerraform(
@@ -42,19 +56,19 @@ func createPayload(shellState *state.State) (payload.Payload, error) {
 		shellState.Settings.WorkingDirectory,
 	)
 	if err = terraformProvider.InitProvider(); err != nil {
-		return nil, merry.Prepend(err, "failed to init provider")
+		return nil, nil, merry.Prepend(err, "failed to init provider")
 	}
 
 	var kube *kubernetes.Kubernetes
 
 	if kube, err = kubernetes.CreateKubernetes(sshClient, shellState); err != nil {
-		return nil, merry.Prepend(err, "failed to create kubernetes")
+		return nil, nil, merry.Prepend(err, "failed to create kubernetes")
 	}
 
 	var dbCluster db.Cluster
 
 	if dbCluster, err = db.CreateCluster(sshClient, kube, shellState); err != nil {
-		return nil, merry.Prepend(err, "failed to create database cluster")
+		return nil, nil, merry.Prepend(err, "failed to create database cluster")
 	}
 
 	chaosController := chaos.CreateController(kube.Engine, shellState)
@@ -66,13 +80,34 @@ func createPayload(shellState *state.State) (payload.Payload, error) {
 		shellState.Settings,
 		chaosController,
 	); err != nil {
-		return nil, merry.Prepend(err, "failed to create payload")
+		return nil, nil, merry.Prepend(err, "failed to create payload")
 	}
 
-	return dbPayload, nil
+	return dbPayload, chaosController, nil
 }
 
-func initLogFacility(settings *config.Settings) (err error) {
+// createDirectPayload - создать payload с прямым подключением к БД по url: только драйвер
+// БД и контроллер без хаоса, без системной оболочки, провайдера и kubernetes
+func createDirectPayload(shellState *state.State) (payload.Payload, chaos.Controller, error) {
+	dbCluster, err := db.CreateDirectCluster(shellState)
+	if err != nil {
+		return nil, nil, merry.Prepend(err, "failed to create database cluster")
+	}
+
+	chaosController := chaos.CreateDummyController()
+
+	dbPayload, err := payload.CreatePayload(dbCluster, shellState.Settings, chaosController)
+	if err != nil {
+		return nil, nil, merry.Prepend(err, "failed to create payload")
+	}
+
+	llog.Infof("Direct connection to %s, no deployment objects are created", shellState.Settings.DatabaseSettings.DBType)
+
+	return dbPayload, chaosController, nil
+}
+
+// initLogFormat - формат и уровень журнала для команд, которые не начинают запуск
+func initLogFormat(settings *config.Settings) error {
 	formatter := new(llog.TextFormatter)
 
 	// Stackoverflow wisdom
@@ -81,37 +116,192 @@ func initLogFacility(settings *config.Settings) (err error) {
 	formatter.ForceColors = true
 	llog.SetFormatter(formatter)
 
-	var l llog.Level
-	if l, err = llog.ParseLevel(settings.LogLevel); err != nil {
+	level, err := llog.ParseLevel(settings.LogLevel)
+	if err != nil {
 		return merry.Prependf(err, "'%s' log level parse", settings.LogLevel)
 	}
-	llog.SetLevel(l)
+
+	llog.SetLevel(level)
+
+	return nil
+}
+
+// initLogFacility - проверить параметры команды validate и начать запуск: создать
+// каталог результатов со снимком параметров и писать журнал в него. Запуск с
+// неверными параметрами не начинается и не попадает в список запусков.
+func initLogFacility(settings *config.Settings, cmd *cobra.Command, validate func() error) (err error) {
+	if err = initLogFormat(settings); err != nil {
+		return
+	}
+
+	// флаги уже разобраны, дальнейшие ошибки не связаны с их синтаксисом
+	cmd.SilenceUsage = true
+
+	if err = validate(); err != nil {
+		return
+	}
 
 	if len(os.Args) < 2 {
 		err = fmt.Errorf("not enough arguments")
 		return
 	}
 
-	startDateTime := time.Now().Format(time.RFC3339)
-	// startDateTime := time.Now().Format("2009-11-10_23-00-00")
-	logFileName := fmt.Sprintf("%s_test_run_%s.log", os.Args[1], startDateTime)
+	var snapshot []byte
+	if snapshot, err = settings.Snapshot(); err != nil {
+		return
+	}
+
+	var run *results.Run
+	if run, err = results.Start(
+		settings.ResultsRoot(),
+		cmd.Name(),
+		maskArgs(cmd.Flags(), os.Args[1:]),
+		settings.DatabaseSettings.DBType,
+		snapshot,
+	); err != nil {
+		return
+	}
 
 	var logFileDescriptor *os.File
 	// по умолчанию файл создается без прав вообще, нужны права на чтение
 	var modePerm fs.FileMode = 444
-	logFileDescriptor, err = os.OpenFile(filepath.Join(settings.WorkingDirectory, logFileName),
+	logFileDescriptor, err = os.OpenFile(filepath.Join(run.Dir, results.LogFileName),
 		os.O_CREATE|os.O_APPEND|os.O_RDWR,
 		modePerm)
 	if err != nil {
 		err = merry.Prependf(
 			err,
 			"open log file '%s' in '%s' directory",
-			logFileName,
-			settings.WorkingDirectory,
+			results.LogFileName,
+			run.Dir,
 		)
 		return
 	}
 
 	llog.SetOutput(io.MultiWriter(os.Stdout, logFileDescriptor))
+	llog.Infof("Run %s, results are saved to %s", run.ID, run.Dir)
+
 	return
 }
+
+// sensitiveAnnotation - аннотация флагов, значения которых не сохраняются в описании запуска
+const sensitiveAnnotation = "stroppy_sensitive"
+
+// maskedArg - значение секретного флага в описании запуска
+const maskedArg = "******"
+
+// markSensitive - пометить флаги names как секретные
+func markSensitive(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		if err := flags.SetAnnotation(name, sensitiveAnnotation, []string{"true"}); err != nil {
+			llog.Fatalf("%v", err)
+		}
+	}
+}
+
+// isSensitive - значение флага секретно
+func isSensitive(flag *pflag.Flag) bool {
+	_, ok := flag.Annotations[sensitiveAnnotation]
+
+	return ok
+}
+
+// maskArgs - аргументы командной строки для описания запуска без паролей: значения
+// секретных флагов flags заменяются целиком, в остальных аргументах маскируются
+// пароли адресов. Значение флага может идти в том же аргументе после '=', сразу
+// после короткого имени или следующим аргументом.
+func maskArgs(flags *pflag.FlagSet, args []string) []string {
+	masked := make([]string, len(args))
+
+	// valueOf - флаг, значение которого ожидается следующим аргументом
+	var valueOf *pflag.Flag
+
+	for i, arg := range args {
+		switch {
+		case valueOf != nil:
+			masked[i] = maskValue(valueOf, arg)
+			valueOf = nil
+		case arg == "--":
+			// дальше только позиционные аргументы
+			for j := i; j < len(args); j++ {
+				masked[j] = config.MaskURL(args[j])
+			}
+
+			return masked
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+
+			flag := flags.Lookup(name)
+
+			switch {
+			case flag == nil && hasValue:
+				masked[i] = "--" + name + "=" + config.MaskURL(value)
+			case flag == nil:
+				masked[i] = arg
+			case hasValue:
+				masked[i] = "--" + name + "=" + maskValue(flag, value)
+			default:
+				masked[i] = arg
+				if flag.NoOptDefVal == "" {
+					valueOf = flag
+				}
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			masked[i], valueOf = maskShorthands(flags, arg)
+		default:
+			masked[i] = config.MaskURL(arg)
+		}
+	}
+
+	return masked
+}
+
+// maskShorthands - замаскировать группу коротких флагов вида -vp или -psecret. Если
+// значение последнего флага группы идет следующим аргументом, возвращается этот флаг.
+func maskShorthands(flags *pflag.FlagSet, arg string) (string, *pflag.Flag) {
+	shorthands := arg[1:]
+
+	for i := 0; i < len(shorthands); i++ {
+		flag := flags.ShorthandLookup(shorthands[i : i+1])
+		if flag == nil {
+			return config.MaskURL(arg), nil
+		}
+
+		// флаг без обязательного значения, например bool
+		if flag.NoOptDefVal != "" && (i+1 == len(shorthands) || shorthands[i+1] != '=') {
+			continue
+		}
+
+		prefix, value := arg[:i+2], shorthands[i+1:]
+		if value == "" {
+			return arg, flag
+		}
+
+		if strings.HasPrefix(value, "=") {
+			prefix, value = prefix+"=", value[1:]
+		}
+
+		return prefix + maskValue(flag, value), nil
+	}
+
+	return arg, nil
+}
+
+// maskValue - значение флага flag для описания запуска
+func maskValue(flag *pflag.Flag, value string) string {
+	if isSensitive(flag) {
+		return maskedArg
+	}
+
+	// адреса списка, например --endpoints, маскируются по отдельности
+	if strings.HasSuffix(flag.Value.Type(), "Slice") {
+		values := strings.Split(value, ",")
+		for i := range values {
+			values[i] = config.MaskURL(values[i])
+		}
+
+		return strings.Join(values, ",")
+	}
+
+	return config.MaskURL(value)
+}
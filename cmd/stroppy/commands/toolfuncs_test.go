@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"os"
+	"testing"
+
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/results"
+)
+
+func TestMaskArgs(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringP("password", "p", "", "")
+	flags.String("grafana-password", "", "")
+	flags.String("url", "", "")
+	flags.StringSlice("endpoints", nil, "")
+	flags.StringP("log-level", "v", "", "")
+	flags.BoolP("local", "l", false, "")
+	markSensitive(flags, "password", "grafana-password")
+
+	for _, test := range []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{name: "long", args: []string{"pay", "--password", "secret"}, expected: []string{"pay", "--password", "******"}},
+		{name: "long with =", args: []string{"--password=secret"}, expected: []string{"--password=******"}},
+		{name: "short", args: []string{"-p", "secret", "pay"}, expected: []string{"-p", "******", "pay"}},
+		{name: "short joined", args: []string{"-psecret"}, expected: []string{"-p******"}},
+		{name: "short with =", args: []string{"-p=secret"}, expected: []string{"-p=******"}},
+		{name: "short after bool", args: []string{"-lp", "secret"}, expected: []string{"-lp", "******"}},
+		{name: "another sensitive flag", args: []string{"--grafana-password", "secret"}, expected: []string{"--grafana-password", "******"}},
+		{
+			name:     "value looks like a flag",
+			args:     []string{"--password", "-secret", "--local"},
+			expected: []string{"--password", "******", "--local"},
+		},
+		{
+			name:     "plain values",
+			args:     []string{"-v", "debug", "--local", "pay", "-l"},
+			expected: []string{"-v", "debug", "--local", "pay", "-l"},
+		},
+		{
+			name:     "url",
+			args:     []string{"--url", "postgres://stroppy:secret@db/stroppy"},
+			expected: []string{"--url", "postgres://stroppy:xxxxx@db/stroppy"},
+		},
+		{
+			name:     "url with =",
+			args:     []string{"--url=postgres://stroppy:secret@db/stroppy"},
+			expected: []string{"--url=postgres://stroppy:xxxxx@db/stroppy"},
+		},
+		{
+			name:     "endpoints",
+			args:     []string{"--endpoints=postgres://stroppy:secret@a/stroppy,postgres://stroppy:secret@b/stroppy"},
+			expected: []string{"--endpoints=postgres://stroppy:xxxxx@a/stroppy,postgres://stroppy:xxxxx@b/stroppy"},
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"--dsn=postgres://stroppy:secret@db/stroppy"},
+			expected: []string{"--dsn=postgres://stroppy:xxxxx@db/stroppy"},
+		},
+		{
+			name:     "positional arguments only",
+			args:     []string{"--", "-p", "postgres://stroppy:secret@db/stroppy"},
+			expected: []string{"--", "-p", "postgres://stroppy:xxxxx@db/stroppy"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, maskArgs(flags, test.args))
+		})
+	}
+}
+
+func TestInitLogFacility(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		url  string
+		runs int
+	}{
+		{name: "invalid settings", url: "", runs: 0},
+		{name: "valid settings", url: "postgres://stroppy:secret@db/stroppy", runs: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			settings := config.DefaultSettings()
+			settings.ResultsDirectory = t.TempDir()
+
+			// запуск завершается до удаления его каталога
+			t.Cleanup(func() {
+				results.Finish(nil)
+				llog.SetOutput(os.Stderr)
+			})
+
+			settings.Direct = true
+			settings.DatabaseSettings.DBURL = test.url
+
+			err := initLogFacility(settings, &cobra.Command{Use: "pay"}, settings.Validate)
+			if test.runs == 0 {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			runs, err := results.List(settings.ResultsRoot())
+			require.NoError(t, err)
+			require.Len(t, runs, test.runs)
+
+			for _, run := range runs {
+				assert.Equal(t, "pay", run.Command)
+				assert.Equal(t, results.StatusRunning, run.Status)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/apple/foundationdb/bindings/go v0.0.0-20201114170605-569ab46bf638
 	github.com/bramvdbogaerde/go-scp v1.0.0
 	github.com/gocql/gocql v0.0.0-20210707082121-9a3953d1826d
+	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 	github.com/google/uuid v1.3.0
 	github.com/hashicorp/hcl/v2 v2.10.0
 	github.com/jackc/pgconn v1.9.0
@@ -22,6 +23,7 @@ require (
 	github.com/sirupsen/logrus v1.8.1
 	github.com/spenczar/tdigest v2.1.0+incompatible
 	github.com/spf13/cobra v1.5.0
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.8.3
 	github.com/tarantool/go-tarantool v0.0.0-20220113162241-9c9a68e09870
 	github.com/tidwall/gjson v1.8.1
@@ -33,6 +35,7 @@ require (
 	github.com/zclconf/go-cty v1.9.0
 	go.mongodb.org/mongo-driver v1.7.1
 	golang.org/x/crypto v0.12.0
+	golang.org/x/term v0.11.0
 	gopkg.in/inf.v0 v0.9.1
 	gopkg.in/yaml.v3 v3.0.1
 	helm.sh/helm/v3 v3.10.0
@@ -94,7 +97,6 @@ require (
 	github.com/google/gnostic v0.6.9 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/google/gofuzz v1.2.0 // indirect
-	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510 // indirect
 	github.com/gorilla/mux v1.8.0 // indirect
 	github.com/gosuri/uitable v0.0.4 // indirect
 	github.com/gregjones/httpcache v0.0.0-20180305231024-9cad4c3443a7 // indirect
@@ -150,7 +152,6 @@ require (
 	github.com/russross/blackfriday v1.5.2 // indirect
 	github.com/shopspring/decimal v1.2.0 // indirect
 	github.com/spf13/cast v1.4.1 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/tidwall/match v1.0.3 // indirect
 	github.com/tidwall/pretty v1.1.0 // indirect
 	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
@@ -169,7 +170,6 @@ require (
 	golang.org/x/oauth2 v0.7.0 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.11.0 // indirect
-	golang.org/x/term v0.11.0 // indirect
 	golang.org/x/text v0.12.0 // indirect
 	golang.org/x/time v0.1.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
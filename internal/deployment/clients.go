@@ -0,0 +1,216 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package deployment
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/internal/payload"
+	"gitlab.com/picodata/stroppy/pkg/engine/stroppy"
+)
+
+// период пересылки файлов этапов между подами клиентов
+const barrierRelayInterval = time.Second
+
+// clientResult - итог выполнения шага в поде клиента
+type clientResult struct {
+	client    int
+	beginTime int64
+	endTime   int64
+	err       error
+}
+
+// executeClients - выполнить команды клиентов в их подах одновременно. Каталоги согласования
+// подов не общие, поэтому пока клиенты работают, файлы этапов пересылаются между подами,
+// а при ошибке одного из клиентов остальным записывается файл abort.
+//
+//nolint:nonamedreturns // should be fixed in future
+func (sh *shell) executeClients(
+	commands [][]string,
+	barrier, name string,
+) (beginTime, endTime int64, err error) {
+	clients := len(commands)
+	results := make(chan clientResult, clients)
+
+	for client := range commands {
+		go func(client int) {
+			command := commands[client]
+			llog.Tracef("Stroppy client %d remote command '%s'", client, strings.Join(command, " "))
+
+			begin, end, err := sh.k.ExecuteClientCommand(
+				stroppy.ClientPodName(client),
+				"",
+				command,
+				sh.remoteLogPath(fmt.Sprintf("%s_client_%d", name, client)),
+				&sh.state,
+			)
+
+			results <- clientResult{client: client, beginTime: begin, endTime: end, err: err}
+		}(client)
+	}
+
+	llog.Infof("%d stroppy clients are started", clients)
+
+	relay := newBarrierRelay(sh, clients, barrier)
+	ticker := time.NewTicker(barrierRelayInterval)
+
+	defer ticker.Stop()
+
+	var errs []string
+
+	for finished := 0; finished < clients; {
+		select {
+		case result := <-results:
+			finished++
+
+			if beginTime == 0 || (result.beginTime != 0 && result.beginTime < beginTime) {
+				beginTime = result.beginTime
+			}
+
+			if result.endTime > endTime {
+				endTime = result.endTime
+			}
+
+			if result.err != nil {
+				llog.Errorf("stroppy client %d failed: %v", result.client, result.err)
+				errs = append(errs, fmt.Sprintf("client %d: %v", result.client, result.err))
+				relay.abort()
+
+				continue
+			}
+
+			llog.Infof("stroppy client %d finished", result.client)
+
+		case <-ticker.C:
+			relay.relay()
+		}
+	}
+
+	if len(errs) > 0 {
+		return beginTime, endTime, merry.Errorf("%d of %d stroppy clients failed: %s",
+			len(errs), clients, strings.Join(errs, "; "))
+	}
+
+	return beginTime, endTime, nil
+}
+
+// barrierRelay - пересылка файлов этапов между каталогами согласования подов клиентов
+type barrierRelay struct {
+	sh       *shell
+	clients  int
+	barrier  string
+	aborted  bool
+	received []map[string]bool
+}
+
+func newBarrierRelay(sh *shell, clients int, barrier string) *barrierRelay {
+	received := make([]map[string]bool, clients)
+	for client := range received {
+		received[client] = make(map[string]bool)
+	}
+
+	return &barrierRelay{
+		sh:       sh,
+		clients:  clients,
+		barrier:  barrier,
+		received: received,
+	}
+}
+
+// relay - собрать файлы этапов всех подов и дописать каждому поду недостающие
+func (relay *barrierRelay) relay() {
+	if relay.aborted {
+		return
+	}
+
+	marked := make(map[string]bool)
+
+	for client := 0; client < relay.clients; client++ {
+		names, err := relay.sh.k.ListPodDirectory(stroppy.ClientPodName(client), relay.barrier)
+		if err != nil {
+			llog.Warnf("barrier relay: %v", err)
+
+			continue
+		}
+
+		for _, name := range names {
+			marked[name] = true
+			relay.received[client][name] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for client := 0; client < relay.clients; client++ {
+		var missing []string
+
+		for name := range marked {
+			if !relay.received[client][name] {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(missing) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(client int, missing []string) {
+			defer wg.Done()
+
+			relay.write(client, missing)
+		}(client, missing)
+	}
+
+	wg.Wait()
+}
+
+// abort - записать файл abort в каталоги согласования всех подов
+func (relay *barrierRelay) abort() {
+	if relay.aborted {
+		return
+	}
+
+	relay.aborted = true
+
+	llog.Warnf("aborting the rest of stroppy clients")
+
+	var wg sync.WaitGroup
+
+	for client := 0; client < relay.clients; client++ {
+		wg.Add(1)
+
+		go func(client int) {
+			defer wg.Done()
+
+			relay.write(client, []string{payload.AbortFileName})
+		}(client)
+	}
+
+	wg.Wait()
+}
+
+// write - записать файлы этапов в каталог согласования пода клиента
+func (relay *barrierRelay) write(client int, names []string) {
+	podName := stroppy.ClientPodName(client)
+
+	for _, name := range names {
+		filePath := path.Join(relay.barrier, name)
+		if err := relay.sh.k.WriteFileToPod(podName, filePath, nil); err != nil {
+			llog.Warnf("barrier relay: %v", err)
+
+			continue
+		}
+
+		llog.Tracef("barrier relay: %s passed to %s", name, podName)
+	}
+}
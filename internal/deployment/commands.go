@@ -0,0 +1,396 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package deployment
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gitlab.com/picodata/stroppy/internal/scenario"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/core/v1"
+)
+
+// shellResult - итог команды оболочки, итоги теста берутся из статистики
+// этого процесса или из отчета пода stroppy
+type shellResult struct {
+	Command  string
+	Start    time.Time
+	Duration time.Duration
+	Error    string
+	Summary  *statistics.Summary
+}
+
+// newCommands - дерево команд оболочки, у каждой команды свои флаги и справка
+func (sh *shell) newCommands() *cobra.Command {
+	root := &cobra.Command{ //nolint
+		Use:           "shell",
+		Short:         "Stroppy interactive shell",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		CompletionOptions: cobra.CompletionOptions{ //nolint
+			DisableDefaultCmd: true,
+		},
+	}
+
+	root.SetFlagErrorFunc(usageError)
+
+	root.AddCommand(
+		sh.stepCommand(scenario.StepPop, "Populate the deployed DB with accounts", popFlags),
+		sh.stepCommand(scenario.StepPay, "Run the transfers test in the deployed DB", payFlags),
+		sh.stepCommand(scenario.StepCheck, "Check the total balance of accounts", checkFlags),
+		sh.runCommand(),
+		sh.chaosCommand(),
+		sh.statusCommand(),
+		sh.podsCommand(),
+		sh.settingsCommand(),
+		sh.resultsCommand(),
+		sh.historyCommand(),
+		&cobra.Command{ //nolint
+			Use:     "quit",
+			Aliases: []string{"exit"},
+			Short:   "Exit stroppy, the cluster is destroyed with --auto-destroy",
+			Args:    cobra.NoArgs,
+			RunE: func(_ *cobra.Command, _ []string) error {
+				return errShellExit
+			},
+		},
+	)
+
+	root.InitDefaultHelpCmd()
+
+	return root
+}
+
+// флаги шагов накладываются на параметры первого шага этого вида из сценария,
+// имена флагов совпадают с ключами сценария с дефисом вместо подчеркивания
+func popFlags(flags *pflag.FlagSet) {
+	flags.Int("count", 0, "number of accounts to create")
+	flags.Int("workers", 0, "number of workers")
+	flags.Int64("seed", 0, "seed of generated accounts")
+	flags.Bool("enumerate", false, "enumerate accounts instead of drawing random BANs")
+	flags.Bool("account-payload", false, "populate accounts with additional attributes")
+	flags.Bool("multi-currency", false, "populate accounts with a currency")
+}
+
+func payFlags(flags *pflag.FlagSet) {
+	flags.Int("count", 0, "number of transfers, 0 with --duration for no limit")
+	flags.Int("workers", 0, "number of workers")
+	flags.Int64("seed", 0, "seed of generated transfers")
+	flags.Duration("duration", 0, "stop the test after this time, for example 10m")
+	flags.String("distribution", "", "distribution of accounts: uniform, zipfian, hotspot or latest")
+	flags.Bool("check", false, "check the balance before and after transfers")
+	flags.Bool("oracle", false, "check every transfer with the oracle")
+	flags.Bool("tx", false, "use custom transactions")
+	flags.Bool("journal", false, "journal transfers in the same transaction")
+	flags.Bool("history", false, "record the transfer history of accounts")
+	flags.Bool("multi-currency", false, "convert transfers between currencies")
+}
+
+func checkFlags(flags *pflag.FlagSet) {
+	flags.Bool("journal", false, "verify the transfer journal and per-bic aggregates")
+	flags.Bool("multi-currency", false, "verify balances per currency")
+}
+
+func (sh *shell) stepCommand(kind, short string, addFlags func(*pflag.FlagSet)) *cobra.Command {
+	cmd := &cobra.Command{ //nolint
+		Use:   kind + " [flags]",
+		Short: short + ", settings are taken from the scenario and overridden by flags",
+		Args:  cobra.NoArgs,
+	}
+
+	addFlags(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		overrides := make(map[string]string)
+
+		cmd.Flags().Visit(func(flag *pflag.Flag) {
+			overrides[strings.ReplaceAll(flag.Name, "-", "_")] = flag.Value.String()
+		})
+
+		return sh.record(cmd, func() error {
+			return sh.runScenarioStep(kind, overrides)
+		})
+	}
+
+	return cmd
+}
+
+func (sh *shell) runCommand() *cobra.Command {
+	return &cobra.Command{ //nolint
+		Use:   "run [scenario file]",
+		Short: "Run all steps of the scenario and save the run report",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				defer func(path string) {
+					sh.state.Settings.TestSettings.Scenario = path
+				}(sh.state.Settings.TestSettings.Scenario)
+
+				sh.state.Settings.TestSettings.Scenario = args[0]
+			}
+
+			llog.Infof("Starting scenario %s", sh.scenarioPath())
+
+			return sh.record(cmd, sh.RunScenario)
+		},
+	}
+}
+
+func (sh *shell) chaosCommand() *cobra.Command {
+	chaosCmd := &cobra.Command{ //nolint
+		Use:   "chaos",
+		Short: "Start or stop chaos-mesh scenarios",
+	}
+
+	chaosCmd.AddCommand(
+		&cobra.Command{ //nolint
+			Use:     "start <scenario>...",
+			Short:   "Start chaos scenarios, for example fdb-pod-kill-first",
+			Example: "chaos start pg-pod-kill-first pg-pod-kill-second",
+			Args:    cobra.MinimumNArgs(1),
+			RunE: func(_ *cobra.Command, args []string) error {
+				return shellExecutor{sh: sh}.StartChaos(strings.Join(args, ","))
+			},
+		},
+		&cobra.Command{ //nolint
+			Use:   "stop",
+			Short: "Stop running chaos scenarios",
+			Args:  cobra.NoArgs,
+			Run: func(_ *cobra.Command, _ []string) {
+				shellExecutor{sh: sh}.StopChaos()
+			},
+		},
+	)
+
+	return chaosCmd
+}
+
+func (sh *shell) statusCommand() *cobra.Command {
+	return &cobra.Command{ //nolint
+		Use:   "status",
+		Short: "Show the deployed cluster, where tests run, chaos and the last result",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			settings := sh.state.Settings
+
+			base, err := sh.scenarioBase()
+			if err != nil {
+				return err
+			}
+
+			mode := "locally"
+			if settings.TestSettings.UseCloudStroppy {
+				mode = "in the stroppy pod"
+			}
+
+			chaosStatus := "not running"
+			if sh.chaosRunning != "" {
+				chaosStatus = sh.chaosRunning
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+			fmt.Fprintf(writer, "cloud\t%s\n", settings.DeploymentSettings.Provider)
+			fmt.Fprintf(writer, "database\t%s\n", settings.DatabaseSettings.DBType)
+			fmt.Fprintf(writer, "url\t%s\n", config.MaskURL(base.DBURL))
+			fmt.Fprintf(writer, "nodes\t%d masters, %d workers\n",
+				sh.state.NodesInfo.MastersCnt, sh.state.NodesInfo.WorkersCnt)
+			fmt.Fprintf(writer, "first master\t%s (%s)\n",
+				sh.state.NodesInfo.IPs.FirstMasterIP.External, sh.state.NodesInfo.IPs.FirstMasterIP.Internal)
+			fmt.Fprintf(writer, "tests run\t%s\n", mode)
+			fmt.Fprintf(writer, "scenario\t%s\n", sh.scenarioPath())
+			fmt.Fprintf(writer, "chaos\t%s\n", chaosStatus)
+
+			if len(sh.results) > 0 {
+				last := &sh.results[len(sh.results)-1]
+				fmt.Fprintf(writer, "last command\t%s\n", last.status())
+			}
+
+			return merry.Wrap(writer.Flush())
+		},
+	}
+}
+
+func (sh *shell) podsCommand() *cobra.Command {
+	return &cobra.Command{ //nolint
+		Use:   "pods [namespace]",
+		Short: "List pods of the cluster, of all namespaces by default",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			namespace := ""
+			if len(args) == 1 {
+				namespace = args[0]
+			}
+
+			pods, err := sh.k.Engine.ListPods(namespace)
+			if err != nil {
+				return merry.Prepend(err, "failed to list pods")
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+			fmt.Fprintln(writer, "NAMESPACE\tNAME\tREADY\tSTATUS\tRESTARTS\tNODE\tAGE")
+
+			for i := range pods {
+				pod := &pods[i]
+				ready, restarts := podReadiness(pod)
+
+				fmt.Fprintf(writer, "%s\t%s\t%d/%d\t%s\t%d\t%s\t%s\n",
+					pod.Namespace, pod.Name, ready, len(pod.Status.ContainerStatuses),
+					pod.Status.Phase, restarts, pod.Spec.NodeName,
+					time.Since(pod.CreationTimestamp.Time).Truncate(time.Second))
+			}
+
+			return merry.Wrap(writer.Flush())
+		},
+	}
+}
+
+func podReadiness(pod *v1.Pod) (ready int, restarts int32) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Ready {
+			ready++
+		}
+
+		restarts += status.RestartCount
+	}
+
+	return ready, restarts
+}
+
+func (sh *shell) settingsCommand() *cobra.Command {
+	return &cobra.Command{ //nolint
+		Use:   "settings",
+		Short: "Show the settings the scenario steps are applied over",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			base, err := sh.scenarioBase()
+			if err != nil {
+				return err
+			}
+
+			data, err := yaml.Marshal(base.Masked())
+			if err != nil {
+				return merry.Prepend(err, "failed to format settings")
+			}
+
+			fmt.Print(string(data))
+
+			return nil
+		},
+	}
+}
+
+func (sh *shell) resultsCommand() *cobra.Command {
+	var all bool
+
+	resultsCmd := &cobra.Command{ //nolint
+		Use:   "results",
+		Short: "Show results of the last test commands",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if len(sh.results) == 0 {
+				fmt.Println("no test commands were run yet")
+
+				return nil
+			}
+
+			results := sh.results
+			if !all {
+				results = results[len(results)-1:]
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint
+			fmt.Fprintln(writer, "COMMAND\tSTART\tDURATION\tREQUESTS\tT/SEC\tP99, ms\tRESULT")
+
+			for i := range results {
+				result := &results[i]
+
+				requests, throughput, p99 := "-", "-", "-"
+				if result.Summary != nil {
+					requests = fmt.Sprintf("%d", result.Summary.Requests)
+					throughput = fmt.Sprintf("%.1f", result.Summary.Throughput)
+					p99 = fmt.Sprintf("%.2f", result.Summary.LatencyP99*1000) //nolint
+				}
+
+				outcome := "ok"
+				if result.Error != "" {
+					outcome = "failed: " + result.Error
+				}
+
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					result.Command, result.Start.Format(time.Stamp), result.Duration.Truncate(time.Second),
+					requests, throughput, p99, outcome)
+			}
+
+			return merry.Wrap(writer.Flush())
+		},
+	}
+
+	resultsCmd.Flags().BoolVar(&all, "all", false, "show all commands of the session")
+
+	return resultsCmd
+}
+
+func (sh *shell) historyCommand() *cobra.Command {
+	return &cobra.Command{ //nolint
+		Use:   "history",
+		Short: "Show commands of previous sessions and of this one, they are saved to " + historyFileName,
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			for i, line := range sh.history {
+				fmt.Printf("%4d  %s\n", i+1, line)
+			}
+		},
+	}
+}
+
+// record - выполнить тестовую команду и запомнить ее итог для results и status
+func (sh *shell) record(cmd *cobra.Command, run func() error) error {
+	result := shellResult{Command: cmd.CommandPath(), Start: time.Now()} //nolint
+	result.Command = strings.TrimPrefix(result.Command, cmd.Root().Name()+" ")
+
+	changed := make([]string, 0)
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		changed = append(changed, fmt.Sprintf("--%s=%s", flag.Name, flag.Value))
+	})
+	sort.Strings(changed)
+
+	if len(changed) > 0 {
+		result.Command += " " + strings.Join(changed, " ")
+	}
+
+	err := run()
+
+	result.Duration = time.Since(result.Start)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	if cmd.Name() != scenario.StepCheck && err == nil {
+		result.Summary = shellExecutor{sh: sh}.StepSummary()
+	}
+
+	sh.results = append(sh.results, result)
+
+	return err
+}
+
+func (result *shellResult) status() string {
+	if result.Error != "" {
+		return fmt.Sprintf("%s failed after %s: %s", result.Command, result.Duration.Truncate(time.Second), result.Error)
+	}
+
+	return fmt.Sprintf("%s passed in %s", result.Command, result.Duration.Truncate(time.Second))
+}
@@ -5,8 +5,8 @@
 package deployment
 
 const (
-	testConfDir    = "third_party/tests"
-	configFileName = "test_config.json"
+	testConfDir      = "third_party/tests"
+	scenarioFileName = "scenario.yaml"
 
 	interactiveUsageHelpTemplate = `
 Started ssh tunnel for kubernetes cluster and port-forward for monitoring.
@@ -17,6 +17,13 @@ Prometheus      http://%s:%d.
 Enter "quit" or "exit" to exit stroppy and destroy cluster.
 Enter "pop" to start populating deployed DB with accounts.
 Enter "pay" to start transfers test in deployed DB.
+Enter "check" to check the total balance of accounts.
+Enter "run" to run all steps of the test scenario.
+Steps and their settings are read from the scenario file %s,
+flags of pop, pay and check override them, for example "pay --workers 64 --duration 10m".
+Enter "status", "pods", "settings" or "results" to inspect the cluster and the last tests.
+Enter "help" to list all commands and "<command> --help" to show its flags.
+Commands can also be piped from a script: ./stroppy shell < script.
 To use kubectl for access kubernetes cluster in another console 
 execute command for set environment variables KUBECONFIG before using:
 "export KUBECONFIG=$(pwd)/config"`
@@ -5,9 +5,7 @@
 package deployment
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 
 	engineSsh "gitlab.com/picodata/stroppy/pkg/engine/ssh"
 
@@ -19,6 +17,7 @@ import (
 	"gitlab.com/picodata/stroppy/pkg/engine/terraform"
 	"gitlab.com/picodata/stroppy/pkg/kubernetes"
 	"gitlab.com/picodata/stroppy/pkg/state"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
 
 	"github.com/ansel1/merry"
 	llog "github.com/sirupsen/logrus"
@@ -27,7 +26,6 @@ import (
 func createShell(config *config.Settings) (d *shell) {
 	d = &shell{
 		state:            state.State{Settings: config}, //nolint
-		stdinScanner:     bufio.NewScanner(os.Stdin),
 		workingDirectory: config.WorkingDirectory,
 	}
 
@@ -45,7 +43,13 @@ type shell struct {
 	chaosMesh chaos.Controller
 	payload   payload.Payload
 
-	stdinScanner *bufio.Scanner
+	// команды сессии оболочки, их итоги и запущенные сценарии chaos-mesh
+	history      []string
+	results      []shellResult
+	chaosRunning string
+
+	// итоги последнего шага pop или pay из отчета пода stroppy
+	remoteSummary *statistics.Summary
 
 	workingDirectory string
 }
@@ -122,9 +126,13 @@ func (sh *shell) prepareEngine() error {
 	sh.state.InstanceAddresses = instanceAddresses
 	sh.state.Subnet = sh.tf.Provider.GetSubnet()
 
+	// у каждого клиента свой под, воркеры делятся между ними
 	sh.state.Settings.DatabaseSettings.Workers = int(
 		sh.state.NodesInfo.GetFirstMaster().Resources.CPU * 4, //nolint
 	)
+	if clients := sh.state.Settings.DatabaseSettings.Clients; clients > 1 {
+		sh.state.Settings.DatabaseSettings.Workers *= clients
+	}
 
 	// string var (like `remote` or `local`) which will be used to create ssh the client
 	commandClientType := engineSsh.RemoteClient
@@ -179,8 +187,13 @@ func (sh *shell) prepareDBForTests() error {
 
 		// \todo: Временное решение, убрать, как будут готовы функции загрузки файлов с подов
 		llog.Error(merry.Prepend(err, "failed to init foundation payload"))
+
+		return nil
 	}
 
+	// все шаги оболочки выполняются исполнителем сценария, он и управляет хаосом
+	sh.payload.DelegateChaos()
+
 	return nil
 }
 
@@ -263,6 +276,7 @@ func (sh *shell) deploy() error {
 		sh.state.Settings.DeploymentSettings.GrPort,
 		sh.state.NodesInfo.IPs.FirstMasterIP.External,
 		sh.state.Settings.DeploymentSettings.PromPort,
+		sh.scenarioPath(),
 	)
 
 	return nil
@@ -0,0 +1,281 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package deployment
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansel1/merry"
+	"github.com/google/shlex"
+	llog "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+const (
+	shellPrompt      = "stroppy> "
+	historyFileName  = ".stroppy_history"
+	historyFileMode  = 0o644
+	historyFileFlags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	// historySize - число команд прошлых сеансов, загружаемых из файла истории
+	historySize = 1000
+)
+
+// errShellExit - команда quit или exit завершает оболочку
+var errShellExit = errors.New("shell exit")
+
+// ReadEvalPrintLoop - выполнять команды оболочки: с терминала построчно с историей
+// и дополнением по Tab, а из перенаправленного ввода (stroppy shell < script) как сценарий,
+// который останавливается на первой ошибке. Конец ввода равносилен команде quit.
+func (sh *shell) ReadEvalPrintLoop() error {
+	sh.loadHistory()
+
+	fd := int(os.Stdin.Fd())
+
+	var err error
+	if term.IsTerminal(fd) {
+		err = sh.interactiveLoop(fd)
+	} else {
+		err = sh.scriptLoop(os.Stdin)
+	}
+
+	if shutdownErr := sh.gracefulShutdown(); shutdownErr != nil {
+		return merry.Prepend(shutdownErr, "Error then stopping stroppy")
+	}
+
+	return err
+}
+
+func (sh *shell) interactiveLoop(fd int) error {
+	llog.Infoln(`Enter "help" to list commands, "<command> --help" for its flags, Tab completes input`)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, shellPrompt)
+	terminal.AutoCompleteCallback = sh.complete
+
+	for {
+		// терминал в сыром режиме только на время ввода, чтобы журнал команд выводился как обычно
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return merry.Prepend(err, "failed to switch terminal to raw mode")
+		}
+
+		line, err := terminal.ReadLine()
+		_ = term.Restore(fd, oldState)
+
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+
+			return nil
+		}
+
+		if err != nil {
+			return merry.Prepend(err, "failed to read command")
+		}
+
+		if err = sh.executeLine(line); errors.Is(err, errShellExit) {
+			return nil
+		} else if err != nil {
+			llog.Errorf("%v", err)
+		}
+	}
+}
+
+func (sh *shell) scriptLoop(input io.Reader) error {
+	scanner := bufio.NewScanner(input)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		llog.Infof("%s%s", shellPrompt, line)
+
+		if err := sh.executeLine(line); errors.Is(err, errShellExit) {
+			return nil
+		} else if err != nil {
+			return merry.Prependf(err, "script line %d '%s' failed", number, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return merry.Prepend(err, "failed to read script")
+	}
+
+	return nil
+}
+
+// executeLine - разобрать строку с учетом кавычек и выполнить команду. Дерево команд
+// создается заново для каждой строки, чтобы значения флагов не переходили в следующую.
+func (sh *shell) executeLine(line string) error {
+	args, err := shlex.Split(line)
+	if err != nil {
+		return merry.Prepend(err, "failed to parse command")
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	sh.addHistory(line)
+
+	commands := sh.newCommands()
+	commands.SetArgs(args)
+
+	return commands.Execute()
+}
+
+// addHistory - запомнить команду в истории сессии и дописать в файл истории рабочего
+// каталога, из которого ее можно повторить как сценарий оболочки
+func (sh *shell) addHistory(line string) {
+	sh.history = append(sh.history, line)
+
+	file, err := os.OpenFile(filepath.Join(sh.workingDirectory, historyFileName), historyFileFlags, historyFileMode)
+	if err != nil {
+		llog.Debugf("failed to open shell history file: %v", err)
+
+		return
+	}
+	defer file.Close()
+
+	if _, err = fmt.Fprintln(file, line); err != nil {
+		llog.Debugf("failed to write shell history file: %v", err)
+	}
+}
+
+// loadHistory - загрузить последние команды прошлых сеансов из файла истории рабочего
+// каталога, команда history выводит их перед командами этого сеанса
+func (sh *shell) loadHistory() {
+	data, err := os.ReadFile(filepath.Join(sh.workingDirectory, historyFileName))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			llog.Debugf("failed to read shell history file: %v", err)
+		}
+
+		return
+	}
+
+	var previous []string
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			previous = append(previous, line)
+		}
+	}
+
+	if len(previous) > historySize {
+		previous = previous[len(previous)-historySize:]
+	}
+
+	sh.history = append(previous, sh.history...)
+}
+
+// complete - дополнить по Tab имя команды, подкоманды или флага под курсором
+// до общего префикса подходящих вариантов
+func (sh *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head := line[:pos]
+	words := strings.Fields(head)
+
+	word := ""
+	if len(words) > 0 && !strings.HasSuffix(head, " ") {
+		word, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	command := sh.newCommands()
+	for _, name := range words {
+		if strings.HasPrefix(name, "-") {
+			continue
+		}
+
+		if sub, _, err := command.Find([]string{name}); err == nil && sub != command {
+			command = sub
+		}
+	}
+
+	var candidates []string
+
+	if strings.HasPrefix(word, "-") {
+		command.Flags().VisitAll(func(flag *pflag.Flag) {
+			candidates = append(candidates, "--"+flag.Name)
+		})
+	} else {
+		for _, sub := range command.Commands() {
+			if sub.IsAvailableCommand() || sub.Name() == "help" {
+				candidates = append(candidates, sub.Name())
+			}
+		}
+	}
+
+	completion := commonPrefix(word, candidates)
+	if completion == word {
+		return "", 0, false
+	}
+
+	if matchesOne(completion, candidates) {
+		completion += " "
+	}
+
+	newLine := head[:len(head)-len(word)] + completion + line[pos:]
+
+	return newLine, pos - len(word) + len(completion), true
+}
+
+// commonPrefix - наибольший общий префикс вариантов, начинающихся с word
+func commonPrefix(word string, candidates []string) string {
+	prefix, found := "", false
+
+	for _, candidate := range candidates {
+		if !strings.HasPrefix(candidate, word) {
+			continue
+		}
+
+		if !found {
+			prefix, found = candidate, true
+
+			continue
+		}
+
+		for !strings.HasPrefix(candidate, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if !found {
+		return word
+	}
+
+	return prefix
+}
+
+func matchesOne(completion string, candidates []string) bool {
+	matches := 0
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, completion) {
+			matches++
+		}
+	}
+
+	return matches == 1
+}
+
+// usageError - ошибка флагов команды оболочки вместе с ее справкой
+func usageError(cmd *cobra.Command, err error) error {
+	return merry.Errorf("%v\n%s", err, cmd.UsageString())
+}
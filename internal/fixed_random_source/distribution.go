@@ -0,0 +1,180 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package fixed_random_source
+
+import (
+	"math"
+	mathrand "math/rand"
+	"sync"
+
+	"github.com/ansel1/merry"
+)
+
+// Распределения обращений к ключам теста переводов.
+const (
+	DistributionUniform    = "uniform"
+	DistributionZipfian    = "zipfian"
+	DistributionHotspot    = "hotspot"
+	DistributionLatest     = "latest"
+	DistributionSequential = "sequential"
+)
+
+// Distribution - распределение обращений к ключам и его параметры.
+// Применяется независимо к номеру БИК и к номеру BAN внутри БИК,
+// кроме sequential, который обходит пары БИК/BAN по порядку.
+type Distribution struct {
+	Name string
+	// ZipfTheta - параметр theta распределения zipfian и latest из (0, 1), как zipfian
+	// constant в YCSB: чем ближе к 1, тем больше обращений к первым ключам.
+	ZipfTheta float64
+	// HotspotKeys - доля горячих ключей, HotspotOps - доля обращений к ним.
+	HotspotKeys float64
+	HotspotOps  float64
+}
+
+// Validate - проверить название и параметры распределения.
+func (d Distribution) Validate() error {
+	switch d.Name {
+	case DistributionUniform, DistributionSequential:
+	case DistributionZipfian, DistributionLatest:
+		if d.ZipfTheta <= 0 || d.ZipfTheta >= 1 {
+			return merry.Errorf("zipf theta must be between 0 and 1, got %v", d.ZipfTheta)
+		}
+	case DistributionHotspot:
+		if d.HotspotKeys <= 0 || d.HotspotKeys >= 1 {
+			return merry.Errorf("hotspot keys fraction must be between 0 and 1, got %v", d.HotspotKeys)
+		}
+
+		if d.HotspotOps < 0 || d.HotspotOps > 1 {
+			return merry.Errorf("hotspot operations fraction must be between 0 and 1, got %v", d.HotspotOps)
+		}
+	default:
+		return merry.Errorf("unknown key distribution '%s', expected one of %s, %s, %s, %s, %s",
+			d.Name, DistributionUniform, DistributionZipfian, DistributionHotspot,
+			DistributionLatest, DistributionSequential)
+	}
+
+	return nil
+}
+
+// keyChooser - выбрать номер ключа из диапазона [0, size).
+type keyChooser func() int
+
+//nolint:gosec
+func newKeyChooser(rand *mathrand.Rand, d Distribution, size int) keyChooser {
+	switch d.Name {
+	case DistributionZipfian:
+		return newZipfian(rand, size, d.ZipfTheta).next
+	case DistributionLatest:
+		// самые частые ключи - в конце диапазона, куда попадают последние добавленные номера
+		zipf := newZipfian(rand, size, d.ZipfTheta)
+
+		return func() int {
+			return size - 1 - zipf.next()
+		}
+	case DistributionHotspot:
+		hot := int(float64(size) * d.HotspotKeys)
+		if hot < 1 {
+			hot = 1
+		}
+
+		if hot >= size {
+			break
+		}
+
+		return func() int {
+			if rand.Float64() < d.HotspotOps {
+				return rand.Intn(hot)
+			}
+
+			return hot + rand.Intn(size-hot)
+		}
+	}
+
+	return func() int {
+		return rand.Intn(size)
+	}
+}
+
+// zipfian - генератор Грея и др. ("Quickly Generating Billion-Record Synthetic Databases"),
+// как ZipfianGenerator в YCSB: ключ i из [0, items) выбирается с вероятностью,
+// пропорциональной 1/(i+1)^theta.
+type zipfian struct {
+	rand  *mathrand.Rand
+	items int
+	alpha float64
+	zetan float64
+	eta   float64
+	// halfPowTheta - 0.5^theta, вероятность ключа 1 относительно ключа 0
+	halfPowTheta float64
+}
+
+func newZipfian(rand *mathrand.Rand, items int, theta float64) *zipfian {
+	zetan := zeta(items, theta)
+	zeta2 := zeta(2, theta) //nolint:gomnd
+
+	return &zipfian{
+		rand:         rand,
+		items:        items,
+		alpha:        1 / (1 - theta),
+		zetan:        zetan,
+		eta:          (1 - math.Pow(2/float64(items), 1-theta)) / (1 - zeta2/zetan),
+		halfPowTheta: math.Pow(0.5, theta), //nolint:gomnd
+	}
+}
+
+// next - номер следующего ключа
+func (z *zipfian) next() int {
+	u := z.rand.Float64()
+	uz := u * z.zetan
+
+	if uz < 1 {
+		return 0
+	}
+
+	if uz < 1+z.halfPowTheta {
+		return 1
+	}
+
+	index := int(float64(z.items) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if index >= z.items {
+		index = z.items - 1
+	}
+
+	return index
+}
+
+// zetaKey - параметры суммы zeta, общей для генераторов всех воркеров
+type zetaKey struct {
+	items int
+	theta float64
+}
+
+var (
+	zetaLock  sync.Mutex
+	zetaCache = make(map[zetaKey]float64)
+)
+
+// zeta - сумма 1/i^theta для i от 1 до items. Для миллионов ключей она считается
+// заметное время, поэтому запоминается для следующих воркеров и шагов.
+func zeta(items int, theta float64) float64 {
+	key := zetaKey{items: items, theta: theta}
+
+	zetaLock.Lock()
+	defer zetaLock.Unlock()
+
+	if sum, ok := zetaCache[key]; ok {
+		return sum
+	}
+
+	var sum float64
+	for i := 1; i <= items; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+
+	zetaCache[key] = sum
+
+	return sum
+}
@@ -0,0 +1,200 @@
+package fixed_random_source
+
+import (
+	"math"
+	mathrand "math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributionValidate(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		distribution Distribution
+		expected     string
+	}{
+		{name: "uniform", distribution: Distribution{Name: DistributionUniform}},
+		{name: "sequential", distribution: Distribution{Name: DistributionSequential}},
+		{name: "zipfian", distribution: Distribution{Name: DistributionZipfian, ZipfTheta: 0.99}},
+		{name: "latest", distribution: Distribution{Name: DistributionLatest, ZipfTheta: 0.5}},
+		{
+			name:         "zipfian theta 0",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: 0},
+			expected:     "zipf theta must be between 0 and 1, got 0",
+		},
+		{
+			name:         "zipfian theta 1",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: 1},
+			expected:     "zipf theta must be between 0 and 1, got 1",
+		},
+		{
+			name:         "latest theta above 1",
+			distribution: Distribution{Name: DistributionLatest, ZipfTheta: 3},
+			expected:     "zipf theta must be between 0 and 1, got 3",
+		},
+		{name: "hotspot", distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0.2, HotspotOps: 0.8}},
+		{
+			name:         "hotspot without hot keys",
+			distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0, HotspotOps: 0.8},
+			expected:     "hotspot keys fraction must be between 0 and 1, got 0",
+		},
+		{
+			name:         "hotspot operations above 1",
+			distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0.2, HotspotOps: 1.5},
+			expected:     "hotspot operations fraction must be between 0 and 1, got 1.5",
+		},
+		{
+			name:         "unknown",
+			distribution: Distribution{Name: "normal"},
+			expected:     "unknown key distribution 'normal'",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.distribution.Validate()
+			if test.expected == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+// keyShares - доли обращений к каждому ключу диапазона size за draws выборов
+func keyShares(t *testing.T, distribution Distribution, size, draws int) []float64 {
+	t.Helper()
+
+	//nolint:gosec
+	next := newKeyChooser(mathrand.New(mathrand.NewSource(1)), distribution, size)
+
+	shares := make([]float64, size)
+	for i := 0; i < draws; i++ {
+		key := next()
+		require.True(t, key >= 0 && key < size, "key %d is out of [0, %d)", key, size)
+
+		shares[key] += 1 / float64(draws)
+	}
+
+	return shares
+}
+
+// share - суммарная доля обращений к ключам [from, to)
+func share(shares []float64, from, to int) float64 {
+	var sum float64
+	for _, value := range shares[from:to] {
+		sum += value
+	}
+
+	return sum
+}
+
+func TestKeyChooser(t *testing.T) {
+	const size, draws = 1000, 500000
+
+	theta := 0.99
+	zetan := zeta(size, theta)
+
+	for _, test := range []struct {
+		name         string
+		distribution Distribution
+		// ключи [from, to) и ожидаемая доля обращений к ним
+		from, to int
+		expected float64
+	}{
+		{name: "uniform first key", distribution: Distribution{Name: DistributionUniform}, from: 0, to: 1, expected: 0.001},
+		{name: "uniform first tenth", distribution: Distribution{Name: DistributionUniform}, from: 0, to: 100, expected: 0.1},
+		{name: "uniform last half", distribution: Distribution{Name: DistributionUniform}, from: 500, to: 1000, expected: 0.5},
+		{
+			name:         "zipfian first key",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: theta},
+			from:         0, to: 1, expected: 1 / zetan,
+		},
+		{
+			name:         "zipfian second key",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: theta},
+			from:         1, to: 2, expected: math.Pow(0.5, theta) / zetan,
+		},
+		{
+			name:         "zipfian first tenth",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: theta},
+			from:         0, to: 100, expected: zeta(100, theta) / zetan,
+		},
+		{
+			name:         "zipfian low theta is closer to uniform",
+			distribution: Distribution{Name: DistributionZipfian, ZipfTheta: 0.2},
+			from:         0, to: 100, expected: zeta(100, 0.2) / zeta(size, 0.2),
+		},
+		{
+			name:         "latest last key",
+			distribution: Distribution{Name: DistributionLatest, ZipfTheta: theta},
+			from:         size - 1, to: size, expected: 1 / zetan,
+		},
+		{
+			name:         "latest last tenth",
+			distribution: Distribution{Name: DistributionLatest, ZipfTheta: theta},
+			from:         size - 100, to: size, expected: zeta(100, theta) / zetan,
+		},
+		{
+			name:         "hotspot hot keys",
+			distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0.2, HotspotOps: 0.8},
+			from:         0, to: 200, expected: 0.8,
+		},
+		{
+			name:         "hotspot first hot key",
+			distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0.2, HotspotOps: 0.8},
+			from:         0, to: 1, expected: 0.8 / 200,
+		},
+		{
+			name:         "hotspot cold keys",
+			distribution: Distribution{Name: DistributionHotspot, HotspotKeys: 0.2, HotspotOps: 0.8},
+			from:         200, to: 1000, expected: 0.2,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			shares := keyShares(t, test.distribution, size, draws)
+
+			assert.InEpsilon(t, test.expected, share(shares, test.from, test.to), 0.05)
+		})
+	}
+}
+
+func TestZipfianSmallRanges(t *testing.T) {
+	for _, size := range []int{1, 2, 3} {
+		shares := keyShares(t, Distribution{Name: DistributionZipfian, ZipfTheta: 0.99}, size, 100000)
+
+		for key := range shares {
+			assert.InEpsilon(t, 1/zeta(size, 0.99)/float64(key+1), shares[key], 0.05,
+				"key %d of %d", key, size)
+		}
+	}
+}
+
+func TestSequential(t *testing.T) {
+	var source KVSource
+	source.Init(1, 0, Distribution{Name: DistributionSequential}, 10)
+
+	first := source.NextIndex(10)
+	for i := 1; i < 25; i++ {
+		assert.Equal(t, (first+i)%10, source.NextIndex(10))
+	}
+
+	var accounts FixedRandomSource
+	accounts.Init(100, 1, 1, 1, 0)
+	accounts.SetDistribution(Distribution{Name: DistributionSequential})
+
+	bics, bans := len(accounts.rs.bics), accounts.banRange()
+	visited := make(map[[2]int]bool)
+
+	for i := 0; i < bics*bans; i++ {
+		bic, ban := accounts.nextKey()
+		visited[[2]int{bic, ban}] = true
+	}
+
+	// за один проход каждая пара БИК/BAN выбирается ровно один раз
+	assert.Len(t, visited, bics*bans)
+}
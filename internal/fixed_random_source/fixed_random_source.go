@@ -10,7 +10,10 @@ import (
 	"sync"
 
 	"github.com/gocql/gocql"
+	"github.com/google/uuid"
 	"gopkg.in/inf.v0"
+
+	"gitlab.com/picodata/stroppy/pkg/statistics"
 )
 
 // Generate a string which looks like a real bank identifier code
@@ -51,40 +54,66 @@ type RandomSettings struct {
 	seed       int64
 	accounts   int
 	bansPerBic int
+	// число номеров BAN на БИК, достаточное для перечисления всех счетов
+	rowsPerBic int
+}
+
+// randomSettingsKey - параметры, от которых зависят БИК и диапазоны BAN
+type randomSettingsKey struct {
+	count              int
+	seed               int
+	banRangeMultiplier float64
 }
 
+// данные о БИК общие для воркеров одного запуска, а серия запусков sweep меняет
+// число счетов, seed и banRangeMultiplier, поэтому данные хранятся для каждого сочетания
 var (
-	once sync.Once
-	rs   *RandomSettings
+	settingsLock  sync.Mutex
+	settingsCache = make(map[randomSettingsKey]*RandomSettings)
 )
 
 //nolint:gosec
 func randomSettings(count int, seed int, banRangeMultiplier float64) *RandomSettings {
-	generateBics := func(rs *RandomSettings) {
-		rand := mathrand.New(mathrand.NewSource(rs.seed))
-		for i := 0; i < len(rs.bics); i++ {
-			rs.bics[i] = createRandomBic(rand)
-		}
+	key := randomSettingsKey{count: count, seed: seed, banRangeMultiplier: banRangeMultiplier}
+
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+
+	if rs, ok := settingsCache[key]; ok {
+		return rs
 	}
-	fetchSettings := func() {
-		rs = new(RandomSettings)
-		rs.accounts = count
-		rs.seed = int64(seed)
-		// If accounts are few, divide random space evenly between
-		// bics and bans, otherwise create no more than 500 bics
-		bics := int(math.Sqrt(float64(rs.accounts)))
-		if bics > 500 {
-			bics = 500
-		}
-		if bics > rs.accounts {
-			bics = rs.accounts
+
+	rs := new(RandomSettings)
+	rs.accounts = count
+	rs.seed = int64(seed)
+	// If accounts are few, divide random space evenly between
+	// bics and bans, otherwise create no more than 500 bics
+	bics := int(math.Sqrt(float64(rs.accounts)))
+	if bics > 500 {
+		bics = 500
+	}
+	if bics > rs.accounts {
+		bics = rs.accounts
+	}
+	// nolint:gosimple
+	rs.bics = make([]string, bics, bics)
+	rand := mathrand.New(mathrand.NewSource(rs.seed))
+	// БИК не повторяются, иначе AccountByIndex дал бы одинаковые счета для разных номеров
+	unique := make(map[string]bool, bics)
+	for i := 0; i < len(rs.bics); i++ {
+		bic := createRandomBic(rand)
+		for unique[bic] {
+			bic = createRandomBic(rand)
 		}
-		// nolint:gosimple
-		rs.bics = make([]string, bics, bics)
-		generateBics(rs)
-		rs.bansPerBic = int(float64(rs.accounts) * banRangeMultiplier / float64(bics))
+
+		unique[bic] = true
+		rs.bics[i] = bic
 	}
-	once.Do(fetchSettings)
+	rs.bansPerBic = int(float64(rs.accounts) * banRangeMultiplier / float64(bics))
+	rs.rowsPerBic = (rs.accounts + bics - 1) / bics
+
+	settingsCache[key] = rs
+
 	return rs
 }
 
@@ -103,28 +132,138 @@ type FixedRandomSource struct {
 	// Current account counter, wraps around accounts.Not yet in use
 	// offset int
 	rand *mathrand.Rand
-	zipf *mathrand.Zipf
+
+	// распределение обращений к существующим счетам, по умолчанию равномерное
+	distribution Distribution
+	bicKey       keyChooser
+	banKey       keyChooser
+	sequence     int
+	bicHits      *statistics.KeyHistogram
+	banHits      *statistics.KeyHistogram
+
+	// перечисление счетов по номеру вместо случайных BAN и доля обращений
+	// к заведомо отсутствующим счетам
+	enumerate bool
+	missRatio float64
+
+	// горячие счета и доля переводов, в которых участвует один из них
+	hotAccounts [][2]string
+	hotFraction float64
 }
 
-func (r *FixedRandomSource) Init(count int, seed int, random float64) {
+// Init - инициализировать генератор воркера. Данные о БИК общие для всех воркеров
+// и определяются seed загрузки счетов, а поток случайных значений воркера - парой
+// streamSeed и номером воркера, поэтому запуск с теми же параметрами на свежей БД
+// воспроизводит те же счета и переводы.
+func (r *FixedRandomSource) Init(count int, seed int, random float64, streamSeed int64, worker int) {
 	// Each worker gorotuine uses its own instance of FixedRandomSource,
 	// but they share the data about existing BICs.
 	r.rs = randomSettings(count, seed, random)
 	//nolint:gosec
-	r.rand = mathrand.New(mathrand.NewSource(mathrand.Int63()))
-	r.zipf = mathrand.NewZipf(r.rand, 3, 1, uint64(r.rs.bansPerBic))
+	r.rand = mathrand.New(mathrand.NewSource(WorkerSeed(streamSeed, worker)))
+	r.SetDistribution(Distribution{Name: DistributionUniform}) //nolint:exhaustivestruct
 }
 
-// Return a globally unique identifier
-// to ensure no client id conflicts
+// SetDistribution - задать распределение обращений BicAndBan. Распределение должно быть
+// проверено Validate. Выбранные ключи учитываются в общей для воркеров гистограмме отчета.
+func (r *FixedRandomSource) SetDistribution(distribution Distribution) {
+	r.distribution = distribution
+	r.bicKey = newKeyChooser(r.rand, distribution, len(r.rs.bics))
+	r.banKey = newKeyChooser(r.rand, distribution, r.banRange())
+
+	if distribution.Name == DistributionSequential {
+		// воркеры начинают обход с разных позиций, определяемых их потоком
+		r.sequence = r.rand.Intn(len(r.rs.bics) * r.banRange())
+	}
+
+	r.bicHits = statistics.StatsKeyHistogram("bic", len(r.rs.bics))
+	r.banHits = statistics.StatsKeyHistogram("ban", r.banRange())
+}
+
+// SetEnumeration - выбирать в BicAndBan только счета, загруженные перечислением
+// AccountByIndex, а с вероятностью missRatio - заведомо отсутствующий счет.
+// Распределение обращений применяется заново к диапазону перечисленных номеров.
+func (r *FixedRandomSource) SetEnumeration(missRatio float64) {
+	r.enumerate = true
+	r.missRatio = missRatio
+	r.SetDistribution(r.distribution)
+}
+
+// SetHotAccounts - направлять долю fraction переводов на один из первых count перечисленных
+// счетов. Горячие счета одинаковы у всех воркеров и существуют, если счета загружены перечислением.
+func (r *FixedRandomSource) SetHotAccounts(count int, fraction float64) {
+	r.hotAccounts = make([][2]string, count)
+	for i := range r.hotAccounts {
+		r.hotAccounts[i][0], r.hotAccounts[i][1] = r.AccountByIndex(i)
+	}
+	r.hotFraction = fraction
+}
+
+// HotAccount - с вероятностью доли горячих переводов вернуть сторону перевода
+// (0 - отправитель, 1 - получатель) и случайный горячий счет для нее.
+func (r *FixedRandomSource) HotAccount() (int, string, string, bool) {
+	if len(r.hotAccounts) == 0 || r.rand.Float64() >= r.hotFraction {
+		return 0, "", "", false
+	}
+
+	account := r.hotAccounts[r.rand.Intn(len(r.hotAccounts))]
+
+	return r.rand.Intn(2), account[0], account[1], true //nolint:gomnd
+}
+
+// banRange - число номеров BAN на БИК, из которых выбираются счета.
+func (r *FixedRandomSource) banRange() int {
+	if r.enumerate {
+		return r.rs.rowsPerBic
+	}
+
+	return r.rs.bansPerBic
+}
+
+// AccountByIndex - БИК и BAN счета с номером index из [0, count). Соседние номера
+// распределяются по разным БИК, отображение взаимно однозначно, поэтому загрузка
+// счетов перечислением не дает дубликатов.
+func (r *FixedRandomSource) AccountByIndex(index int) (string, string) {
+	bics := len(r.rs.bics)
+
+	return r.rs.bics[index%bics], createRandomBan(index / bics)
+}
+
+// WorkerSeed - seed генератора воркера. Номер воркера перемешивается с seed запуска
+// (splitmix64), чтобы потоки соседних воркеров и соседних seed не пересекались.
+func WorkerSeed(seed int64, worker int) int64 {
+	return int64(mix64(uint64(seed) + uint64(worker+1)*0x9E3779B97F4A7C15))
+}
+
+// mix64 - финальное перемешивание splitmix64, взаимно однозначное на uint64.
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+
+	return z ^ (z >> 31)
+}
+
+// newUUID - UUID версии 4 из потока воркера.
+func (r *FixedRandomSource) newUUID() gocql.UUID {
+	id, err := uuid.NewRandomFromReader(r.rand)
+	if err != nil {
+		// чтение из math/rand не возвращает ошибок
+		panic(err)
+	}
+
+	return gocql.UUID(id)
+}
+
+// Return an identifier derived from the worker stream,
+// unique among workers of the run
 func (r *FixedRandomSource) NewClientID() gocql.UUID {
-	return gocql.TimeUUID()
+	return r.newUUID()
 }
 
-// Return a globally unique identifier, each transfer
-// is unique
+// Return an identifier derived from the worker stream, each transfer
+// of the run is unique, a replay of the run yields the same ids
 func (r *FixedRandomSource) NewTransferID() gocql.UUID {
-	return gocql.TimeUUID()
+	return r.newUUID()
 }
 
 // Create a new BIC and BAN pair
@@ -142,7 +281,7 @@ const rangeBalance = 1000000
 func (r *FixedRandomSource) NewStartBalance() *inf.Dec {
 	// use 1 million because it gives bigger range for balances and
 	// reduce overdraft errors
-	return inf.NewDec(mathrand.Int63n(rangeBalance), 0)
+	return inf.NewDec(r.rand.Int63n(rangeBalance), 0)
 }
 
 const rangeTransfer = 10000
@@ -152,34 +291,57 @@ const rangeTransferScale = 3
 // Create a new random transfer
 //nolint:gosec
 func (r *FixedRandomSource) NewTransferAmount() *inf.Dec {
-	return inf.NewDec(mathrand.Int63n(rangeTransfer), inf.Scale(mathrand.Int63n(rangeTransferScale)))
+	return inf.NewDec(r.rand.Int63n(rangeTransfer), inf.Scale(r.rand.Int63n(rangeTransferScale)))
 }
 
-// Find an existing BIC and BAN pair for transaction.
+// Find an existing BIC and BAN pair for transaction
+// according to the configured distribution.
 // To avoid yielding a duplicate pair when called
 // twice in a row, pass pointers to previous BIC and BAN,
 // in this case the new pair is guaranteed to be distinct.
 func (r *FixedRandomSource) BicAndBan(src ...string) (string, string) {
 	for {
-		bic := r.rs.bics[r.rand.Intn(len(r.rs.bics))]
-		ban := createRandomBan(r.rand.Intn(r.rs.bansPerBic))
+		bicIndex, banIndex := r.nextKey()
+		banNumber := banIndex
+
+		if r.enumerate {
+			// последний ряд перечисления заполнен не для всех БИК. Ключ отбрасывается
+			// до выбора промаха, иначе доля промахов превышала бы missRatio.
+			if banIndex*len(r.rs.bics)+bicIndex >= r.rs.accounts {
+				continue
+			}
+
+			if r.missRatio > 0 && r.rand.Float64() < r.missRatio {
+				// номера за пределами перечисленного диапазона не загружались
+				banNumber += r.rs.rowsPerBic
+			}
+		}
+
+		bic := r.rs.bics[bicIndex]
+		ban := createRandomBan(banNumber)
 		if len(src) < 1 || bic != src[0] || len(src) < 2 || ban != src[1] {
+			r.bicHits.Add(bicIndex)
+			r.banHits.Add(banIndex)
+
 			return bic, ban
 		}
 	}
 }
 
-// Find an existing BIC and BAN pair for transaction.
-// Uses a normal distribution to return "hot" pairs.
-// To avoid yielding a duplicate pair when called
-// twice in a row, pass pointers to previous BIC and BAN,
-// in this case the new pair is guaranteed to be distinct.
-func (r *FixedRandomSource) HotBicAndBan(src ...string) (string, string) {
-	for {
-		bic := r.rs.bics[r.rand.Intn(len(r.rs.bics))]
-		ban := createRandomBan(int(r.zipf.Uint64()))
-		if len(src) < 1 || bic != src[0] || len(src) < 2 || ban != src[1] {
-			return bic, ban
-		}
+// Float64 - случайное число из [0, 1) потока воркера.
+func (r *FixedRandomSource) Float64() float64 {
+	return r.rand.Float64()
+}
+
+// nextKey - номера БИК и BAN очередного обращения.
+func (r *FixedRandomSource) nextKey() (int, int) {
+	if r.distribution.Name != DistributionSequential {
+		return r.bicKey(), r.banKey()
 	}
+
+	bicIndex := r.sequence / r.banRange() % len(r.rs.bics)
+	banIndex := r.sequence % r.banRange()
+	r.sequence = (r.sequence + 1) % (len(r.rs.bics) * r.banRange())
+
+	return bicIndex, banIndex
 }
@@ -0,0 +1,101 @@
+package fixed_random_source
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountByIndex(t *testing.T) {
+	for _, count := range []int{1, 2, 5, 99, 1000, 1023, 300000} {
+		t.Run(strconv.Itoa(count), func(t *testing.T) {
+			var source FixedRandomSource
+			source.Init(count, 1, 1.1, 1, 0)
+
+			bics := make(map[string]int, len(source.rs.bics))
+			for i, bic := range source.rs.bics {
+				bics[bic] = i
+			}
+
+			require.Len(t, bics, len(source.rs.bics), "BICs are not unique")
+
+			// обратное отображение восстанавливает номер счета, значит номера не сливаются
+			accounts := make(map[[2]string]bool, count)
+
+			for index := 0; index < count; index++ {
+				bic, ban := source.AccountByIndex(index)
+
+				bicIndex, ok := bics[bic]
+				require.True(t, ok, "unknown BIC %s of account %d", bic, index)
+
+				banNumber, err := strconv.Atoi(reverse(ban))
+				require.NoError(t, err)
+				require.Less(t, banNumber, source.rs.rowsPerBic, "BAN %s of account %d", ban, index)
+				require.Equal(t, index, banNumber*len(bics)+bicIndex)
+
+				accounts[[2]string{bic, ban}] = true
+			}
+
+			assert.Len(t, accounts, count)
+		})
+	}
+}
+
+// reverse - номер BAN, записанный createRandomBan младшими цифрами вперед
+func reverse(ban string) string {
+	runes := []rune(ban)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+func TestMissRatio(t *testing.T) {
+	const draws = 200000
+
+	for _, test := range []struct {
+		count     int
+		missRatio float64
+	}{
+		{count: 1000, missRatio: 0},
+		{count: 1000, missRatio: 0.1},
+		{count: 1000, missRatio: 1},
+		// 5 счетов на 2 БИК заполняют 5 из 6 мест перечисления
+		{count: 5, missRatio: 0.5},
+		{count: 1023, missRatio: 0.25},
+	} {
+		t.Run(strconv.Itoa(test.count)+"/"+strconv.FormatFloat(test.missRatio, 'f', -1, 64), func(t *testing.T) {
+			var source FixedRandomSource
+			source.Init(test.count, 1, 1.1, 1, 0)
+			source.SetEnumeration(test.missRatio)
+
+			accounts := make(map[[2]string]bool, test.count)
+			for index := 0; index < test.count; index++ {
+				bic, ban := source.AccountByIndex(index)
+				accounts[[2]string{bic, ban}] = true
+			}
+
+			misses := 0
+			hits := make(map[[2]string]bool, test.count)
+
+			for i := 0; i < draws; i++ {
+				bic, ban := source.BicAndBan()
+				if accounts[[2]string{bic, ban}] {
+					hits[[2]string{bic, ban}] = true
+				} else {
+					misses++
+				}
+			}
+
+			assert.InDelta(t, test.missRatio, float64(misses)/draws, 0.005)
+
+			// без промахов выбираются все загруженные счета
+			if test.missRatio < 1 {
+				assert.Len(t, hits, test.count)
+			}
+		})
+	}
+}
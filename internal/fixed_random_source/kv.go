@@ -0,0 +1,106 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package fixed_random_source
+
+import (
+	"fmt"
+	mathrand "math/rand"
+
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// KVKey - ключ записи нагрузки ключ-значение с номером index. Номер перемешивается
+// взаимно однозначно, как при hashed insertorder в YCSB, поэтому соседние номера
+// попадают в разные диапазоны ключей, а ключи записей не повторяются.
+func KVKey(index int) string {
+	return fmt.Sprintf("user%020d", mix64(uint64(index)))
+}
+
+// KVSource - генератор номеров записей, значений и длин сканирования одного воркера
+// нагрузки ключ-значение. Как и FixedRandomSource, не безопасен для нескольких горутин.
+type KVSource struct {
+	rand *mathrand.Rand
+
+	distribution Distribution
+	records      int
+	key          keyChooser
+	sequence     int
+	hits         *statistics.KeyHistogram
+}
+
+// Init - инициализировать генератор воркера worker для records загруженных записей
+// с распределением обращений distribution, которое должно быть проверено Validate.
+func (s *KVSource) Init(streamSeed int64, worker int, distribution Distribution, records int) {
+	//nolint:gosec
+	s.rand = mathrand.New(mathrand.NewSource(WorkerSeed(streamSeed, worker)))
+	s.distribution = distribution
+	s.records = records
+
+	chooser := distribution
+	if distribution.Name == DistributionLatest {
+		// latest отсчитывает смещение по закону Ципфа от последней добавленной записи
+		chooser.Name = DistributionZipfian
+	}
+	s.key = newKeyChooser(s.rand, chooser, records)
+
+	if distribution.Name == DistributionSequential {
+		s.sequence = s.rand.Intn(records)
+	}
+
+	s.hits = statistics.StatsKeyHistogram("key", records)
+}
+
+// NextIndex - номер существующей записи при inserted добавленных записях.
+// uniform, latest и sequential учитывают записи, добавленные после загрузки,
+// zipfian и hotspot выбирают среди загруженных, как scrambled zipfian в YCSB.
+func (s *KVSource) NextIndex(inserted int) int {
+	var index int
+
+	switch s.distribution.Name {
+	case DistributionUniform:
+		index = s.rand.Intn(inserted)
+	case DistributionLatest:
+		index = inserted - 1 - s.key()
+		if index < 0 {
+			index = 0
+		}
+	case DistributionSequential:
+		index = s.sequence % inserted
+		s.sequence = index + 1
+	default:
+		index = s.key()
+	}
+
+	if index < s.records {
+		s.hits.Add(index)
+	}
+
+	return index
+}
+
+// Value - случайное значение длиной от minSize до maxSize байт включительно,
+// при maxSize не больше minSize длина фиксирована.
+func (s *KVSource) Value(minSize, maxSize int) []byte {
+	size := minSize
+	if maxSize > minSize {
+		size += s.rand.Intn(maxSize - minSize + 1)
+	}
+
+	value := make([]byte, size)
+	// чтение из math/rand не возвращает ошибок
+	_, _ = s.rand.Read(value)
+
+	return value
+}
+
+// ScanLength - число записей сканирования, равномерно от 1 до maxLength.
+func (s *KVSource) ScanLength(maxLength int) int {
+	return 1 + s.rand.Intn(maxLength)
+}
+
+// Float64 - случайное число из [0, 1) для выбора операции.
+func (s *KVSource) Float64() float64 {
+	return s.rand.Float64()
+}
@@ -0,0 +1,52 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package fixed_random_source
+
+var firstNames = []string{
+	"Alexander", "Anna", "Boris", "Daria", "Dmitry", "Elena", "Fedor", "Galina",
+	"Igor", "Irina", "Konstantin", "Maria", "Mikhail", "Natalia", "Oleg", "Olga",
+	"Pavel", "Svetlana", "Sergey", "Tatiana", "Vladimir", "Yulia",
+}
+
+var lastNames = []string{
+	"Alekseev", "Belov", "Volkov", "Gusev", "Egorov", "Zaitsev", "Ivanov", "Kozlov",
+	"Lebedev", "Morozov", "Novikov", "Orlov", "Petrov", "Romanov", "Smirnov", "Sokolov",
+	"Titov", "Fedorov", "Kuznetsov", "Popov", "Vasiliev", "Yakovlev",
+}
+
+var currencies = []string{"RUB", "RUB", "RUB", "RUB", "USD", "USD", "EUR", "EUR", "CNY", "GBP", "CHF", "JPY"}
+
+// account statuses, most of accounts are active
+var accountStatuses = []string{
+	"active", "active", "active", "active", "active", "active", "active", "active", "blocked", "closed",
+}
+
+const paddingAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Create a random customer name
+func (r *FixedRandomSource) NewCustomerName() string {
+	return firstNames[r.rand.Intn(len(firstNames))] + " " + lastNames[r.rand.Intn(len(lastNames))]
+}
+
+// Create a random ISO 4217 currency code
+func (r *FixedRandomSource) NewCurrency() string {
+	return currencies[r.rand.Intn(len(currencies))]
+}
+
+// Create a random account status
+func (r *FixedRandomSource) NewAccountStatus() string {
+	return accountStatuses[r.rand.Intn(len(accountStatuses))]
+}
+
+// Create a random printable padding of the given size,
+// printable characters are stored as is by every driver
+func (r *FixedRandomSource) NewPadding(size int) string {
+	padding := make([]byte, size)
+	for i := range padding {
+		padding[i] = paddingAlphabet[r.rand.Intn(len(paddingAlphabet))]
+	}
+
+	return string(padding)
+}
@@ -0,0 +1,74 @@
+package fixed_random_source_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/picodata/stroppy/internal/fixed_random_source"
+	"gitlab.com/picodata/stroppy/internal/scenario"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+)
+
+// keySpace - различные БИК и BAN, которые генератор выдает при загрузке счетов
+func keySpace(settings *config.DatabaseSettings, draws int) (map[string]bool, map[string]bool) {
+	var source fixed_random_source.FixedRandomSource
+	source.Init(settings.Count, int(settings.Seed), settings.BanRangeMultiplier, settings.Seed, 0)
+
+	bics, bans := make(map[string]bool), make(map[string]bool)
+
+	for i := 0; i < draws; i++ {
+		bic, ban := source.NewBicAndBan()
+		bics[bic] = true
+		bans[ban] = true
+	}
+
+	return bics, bans
+}
+
+func TestSweepKeySpace(t *testing.T) {
+	base := config.DatabaseDefaults()
+	base.Seed = 1
+
+	testScenario, err := scenario.Parse([]byte(`
+version: 1
+matrix:
+  ban_range_multiplier: [1.1, 3]
+  count: [10000, 40000]
+steps:
+  - pop
+`), base)
+	require.NoError(t, err)
+	require.Len(t, testScenario.Combinations, 4)
+
+	type space struct {
+		bics, bans int
+	}
+
+	spaces := make([]space, 0, len(testScenario.Combinations))
+
+	for _, combination := range testScenario.Combinations {
+		bics, bans := keySpace(combination.Settings, 100000)
+		spaces = append(spaces, space{bics: len(bics), bans: len(bans)})
+	}
+
+	// число БИК - корень из числа счетов, номеров BAN на БИК - count * brm / БИК
+	assert.Equal(t, []space{
+		{bics: 100, bans: 110},
+		{bics: 200, bans: 220},
+		{bics: 100, bans: 300},
+		{bics: 200, bans: 600},
+	}, spaces)
+}
+
+func TestSweepSeed(t *testing.T) {
+	first, second := config.DatabaseDefaults(), config.DatabaseDefaults()
+	first.Seed, second.Seed = 1, 2
+
+	firstBics, _ := keySpace(first, 10000)
+	secondBics, _ := keySpace(second, 10000)
+
+	for bic := range firstBics {
+		assert.False(t, secondBics[bic], "BIC %s of seed 1 is generated for seed 2", bic)
+	}
+}
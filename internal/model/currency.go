@@ -0,0 +1,30 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package model
+
+// RateScale - курсы хранятся целыми числами в миллионных долях.
+const RateScale = 1000000
+
+// CurrencyRates - курсы валют счетов к рублю в миллионных долях,
+// из них строится таблица кросс-курсов мультивалютного режима.
+var CurrencyRates = map[string]int64{
+	"RUB": 1000000,
+	"USD": 90000000,
+	"EUR": 98000000,
+	"CNY": 12500000,
+	"GBP": 114000000,
+	"CHF": 101000000,
+	"JPY": 600000,
+}
+
+// CrossRate - курс пересчета суммы из валюты src в валюту dst в миллионных долях.
+func CrossRate(src, dst string) int64 {
+	return CurrencyRates[src] * RateScale / CurrencyRates[dst]
+}
+
+// ConvertAmount - пересчитать сумму по курсу в миллионных долях с округлением вниз.
+func ConvertAmount(amount int64, rate int64) int64 {
+	return amount * rate / RateScale
+}
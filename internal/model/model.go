@@ -29,6 +29,27 @@ type Account struct {
 	PendingAmount   *inf.Dec
 	PendingTransfer TransferId
 	Found           bool
+	// Payload is nil unless accounts are populated with additional attributes
+	Payload *AccountPayload
+}
+
+// AccountPayload - дополнительные атрибуты счета, приближающие размер записи к записи
+// реальной банковской системы. Не участвуют в переводах, но хранятся и читаются вместе со счетом.
+type AccountPayload struct {
+	Name     string
+	Currency string
+	Status   string
+	Padding  string
+}
+
+// NewAccountPayload - сгенерировать атрибуты счета с заполнителем paddingSize байт.
+func NewAccountPayload(randSource *fixed_random_source.FixedRandomSource, paddingSize int) *AccountPayload {
+	return &AccountPayload{
+		Name:     randSource.NewCustomerName(),
+		Currency: randSource.NewCurrency(),
+		Status:   randSource.NewAccountStatus(),
+		Padding:  randSource.NewPadding(paddingSize),
+	}
 }
 
 func (acc Account) AccountID() string {
@@ -42,6 +63,19 @@ type Transfer struct {
 	LockOrder []*Account
 	Amount    *inf.Dec
 	State     string
+	// CreditAmount is the amount converted to the destination account currency,
+	// nil unless the transfer is made in multi-currency mode
+	CreditAmount *inf.Dec
+}
+
+// Credit - сумма зачисления на счет получателя: в мультивалютном режиме это сумма
+// перевода, пересчитанная по курсу, иначе сама сумма перевода.
+func (t *Transfer) Credit() *inf.Dec {
+	if t.CreditAmount != nil {
+		return t.CreditAmount
+	}
+
+	return t.Amount
 }
 
 func (t *Transfer) InitAccounts() {
@@ -65,17 +99,19 @@ func (t *Transfer) InitAccounts() {
 	acs[1].PendingAmount = t.Amount
 }
 
-func (t *Transfer) InitRandomTransfer(randSource *fixed_random_source.FixedRandomSource, zipfian bool) {
+func (t *Transfer) InitRandomTransfer(randSource *fixed_random_source.FixedRandomSource) {
 	t.Amount = randSource.NewTransferAmount()
 	t.Acs = make([]Account, 2)
-	if zipfian {
-		t.Acs[0].Bic, t.Acs[0].Ban = randSource.HotBicAndBan()
-		t.Acs[1].Bic, t.Acs[1].Ban = randSource.HotBicAndBan(t.Acs[0].Bic, t.Acs[0].Ban)
-	} else {
-		t.Acs[0].Bic, t.Acs[0].Ban = randSource.BicAndBan()
-		t.Acs[1].Bic, t.Acs[1].Ban = randSource.BicAndBan(t.Acs[0].Bic, t.Acs[0].Ban)
+	t.Acs[0].Bic, t.Acs[0].Ban = randSource.BicAndBan()
+	t.Acs[1].Bic, t.Acs[1].Ban = randSource.BicAndBan(t.Acs[0].Bic, t.Acs[0].Ban)
+	if side, bic, ban, ok := randSource.HotAccount(); ok {
+		// если горячий счет уже на другой стороне, перевод и так его затрагивает
+		other := t.Acs[1-side]
+		if other.Bic != bic || other.Ban != ban {
+			t.Acs[side].Bic, t.Acs[side].Ban = bic, ban
+		}
 	}
-	t.Id = NewTransferId()
+	t.Id = TransferId(randSource.NewTransferID())
 	t.State = "new"
 	t.InitAccounts()
 }
@@ -77,6 +77,18 @@ func (c *ClientBasicTx) Init(cluster BasicTxTransfer, oracle *database.Oracle, p
 func (c *ClientBasicTx) MakeAtomicTransfer(t *model.Transfer, clientId uuid.UUID) (bool, error) {
 	sleepDuration := time.Millisecond*time.Duration(rand.Intn(10)) + time.Millisecond
 	applied := false
+	// перевод прерван, если завершился ошибкой или исчерпал попытки
+	aborted := true
+	retries := 0
+	start := time.Now()
+
+	defer func() {
+		elapsed := time.Since(start)
+		for _, account := range t.Acs {
+			statistics.StatsAccountTransfer(account.Bic+"/"+account.Ban, retries, aborted, elapsed)
+		}
+	}()
+
 	for i := 0; i < maxTxRetries; i++ {
 		if err := c.cluster.MakeAtomicTransfer(t, clientId); err != nil {
 			// description of fdb.error with code 1037 -  "Storage process does not have recent mutations"
@@ -104,6 +116,7 @@ func (c *ClientBasicTx) MakeAtomicTransfer(t *model.Transfer, clientId uuid.UUID
 				strings.Contains(err.Error(), "#2001 Transaction locks invalidated.") ||
 				errors.Is(err, context.DeadlineExceeded) {
 				atomic.AddUint64(&c.payStats.retries, 1)
+				retries++
 
 				llog.Tracef("[%v] Retrying transfer after sleeping %v",
 					t.Id, sleepDuration)
@@ -117,18 +130,21 @@ func (c *ClientBasicTx) MakeAtomicTransfer(t *model.Transfer, clientId uuid.UUID
 			}
 			if errors.Is(err, cluster.ErrInsufficientFunds) {
 				atomic.AddUint64(&c.payStats.InsufficientFunds, 1)
+				aborted = false
 				break
 			}
 			// that means one of accounts was not found
 			// and we should proceed to the next transfer
 			if errors.Is(err, cluster.ErrNoRows) {
 				atomic.AddUint64(&c.payStats.NoSuchAccount, 1)
+				aborted = false
 				break
 			}
 			atomic.AddUint64(&c.payStats.errors, 1)
 			return applied, merry.Prepend(err, "failed to make a transactional transfer")
 		}
 		applied = true
+		aborted = false
 		break
 	}
 
@@ -137,8 +153,9 @@ func (c *ClientBasicTx) MakeAtomicTransfer(t *model.Transfer, clientId uuid.UUID
 
 func payWorkerBuiltinTx(
 	settings *config.DatabaseSettings,
+	worker int,
 	nTransfers int,
-	zipfian bool,
+	deadline time.Time,
 	dbCluster CustomTxTransfer,
 	oracle *database.Oracle,
 	payStats *PayStats,
@@ -154,10 +171,17 @@ func payWorkerBuiltinTx(
 		llog.Fatalf("Got a fatal error fetching cluster settings: %v", err)
 	}
 
-	randSource.Init(clusterSettings.Count, clusterSettings.Seed, settings.BanRangeMultiplier)
-	for i := 0; i < nTransfers; {
+	randSource.Init(clusterSettings.Count, clusterSettings.Seed, settings.BanRangeMultiplier, settings.Seed, worker)
+	client.clientId = uuid.UUID(randSource.NewClientID())
+	if settings.Enumerate {
+		randSource.SetEnumeration(settings.MissRatio)
+	}
+	randSource.SetDistribution(settings.KeyDistribution())
+	randSource.SetHotAccounts(settings.HotAccounts, settings.HotFraction)
+
+	for i := 0; i < nTransfers && !pastDeadline(deadline); {
 		t := new(model.Transfer)
-		t.InitRandomTransfer(&randSource, zipfian)
+		t.InitRandomTransfer(&randSource)
 		cookie := statistics.StatsRequestStart()
 		if _, err := client.MakeAtomicTransfer(t, client.clientId); err != nil {
 			if IsTransientError(err) {
@@ -184,8 +208,7 @@ func payBuiltinTx(
 		payStats PayStats
 	)
 
-	transfersPerWorker := settings.Count / settings.Workers
-	remainder := settings.Count - transfersPerWorker*settings.Workers
+	transfersPerWorker, remainder, deadline := transferLimits(settings)
 
 	// is recovery needed for builtin? Maybe after x retries for Tx
 	// TODO: implement recovery
@@ -200,8 +223,9 @@ func payBuiltinTx(
 
 		go payWorkerBuiltinTx(
 			settings,
+			i,
 			nTransfers,
-			settings.Zipfian,
+			deadline,
 			dbCluster,
 			oracle,
 			&payStats,
@@ -34,12 +34,37 @@ func (p *BasePayload) Check(prev *inf.Dec) (sum *inf.Dec, err error) {
 		}
 	}
 
-	if prev != nil {
+	// в мультивалютном режиме общая сумма балансов меняется при пересчете по курсу,
+	// поэтому сохранение денег проверяется по каждой валюте отдельно
+	if prev != nil && p.config.MultiCurrency {
+		multiCurrency, ok := p.Cluster.(cluster.MultiCurrencyCluster)
+		if !ok {
+			llog.Fatalf("Currency check is not supported for %s cluster", p.config.DBType)
+		}
+
+		llog.Infof("Checking balances per currency...")
+		if err = multiCurrency.CheckCurrencies(); err != nil {
+			llog.Fatalf("Currency check failed: %v", err)
+		}
+	} else if prev != nil {
 		if prev.Cmp(sum) != 0 {
 			llog.Fatalf("Check balance mismatch:\nbefore: %v\nafter:  %v", prev, sum)
 		}
 	}
 
+	// после журналируемых переводов сверяем журнал и агрегаты по БИК с балансами счетов
+	if prev != nil && p.config.Journal {
+		journaled, ok := p.Cluster.(cluster.JournaledCluster)
+		if !ok {
+			llog.Fatalf("Journal check is not supported for %s cluster", p.config.DBType)
+		}
+
+		llog.Infof("Checking the transfer journal...")
+		if err = journaled.CheckJournal(); err != nil {
+			llog.Fatalf("Journal check failed: %v", err)
+		}
+	}
+
 	if persistBalance {
 		// Do not overwrite the total balance if it is already persisted.
 		llog.Infof("Persisting the total balance...")
@@ -0,0 +1,153 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package payload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+)
+
+// этапы совместного запуска клиентов
+const (
+	// клиенты подключились, первый клиент подготовил БД
+	StageReady = "ready"
+	// клиенты закончили загрузку счетов
+	StageDone = "done"
+	// первый клиент сохранил общий баланс
+	StageTotal = "total"
+
+	popStep = "pop"
+	payStep = "pay"
+
+	// AbortFileName - файл каталога согласования, по которому клиенты прекращают ожидание
+	AbortFileName = "abort"
+
+	barrierPollInterval = 100 * time.Millisecond
+	barrierTimeout      = 30 * time.Minute
+	barrierDirMode      = 0o755
+	barrierFileMode     = 0o644
+)
+
+// StageFileName - файл этапа шага step клиента в каталоге согласования
+func StageFileName(step, stage string, client int) string {
+	return fmt.Sprintf("%s-%s-%d", step, stage, client)
+}
+
+// clientShare - доля клиента index из clients в total: номер первого элемента и число
+// элементов, остаток достается первым клиентам
+func clientShare(total, clients, index int) (first, count int) {
+	count = total / clients
+	remainder := total - count*clients
+
+	if index < remainder {
+		return index * (count + 1), count + 1
+	}
+
+	return index*count + remainder, count
+}
+
+// clientSettings - параметры доли клиента в совместном запуске и номер первого счета
+// доли. Потоки воркеров клиентов не пересекаются за счет seed, смещенного на номер клиента.
+func clientSettings(settings *config.DatabaseSettings) (*config.DatabaseSettings, int) {
+	client := *settings
+	if settings.Clients <= 1 {
+		return &client, 0
+	}
+
+	var first int
+
+	first, client.Count = clientShare(settings.Count, settings.Clients, settings.ClientIndex)
+	_, client.Workers = clientShare(settings.Workers, settings.Clients, settings.ClientIndex)
+	_, client.StatementWorkers = clientShare(settings.StatementWorkers, settings.Clients, settings.ClientIndex)
+	client.Seed += int64(settings.ClientIndex)
+
+	llog.Infof("Client %d of %d: %d of %d items from %d, %d of %d workers",
+		settings.ClientIndex, settings.Clients, client.Count, settings.Count, first,
+		client.Workers, settings.Workers)
+
+	return &client, first
+}
+
+// syncClients - отметить этап шага step клиента и дождаться его у всех клиентов
+func syncClients(settings *config.DatabaseSettings, step, stage string) error {
+	if settings.Clients <= 1 {
+		return nil
+	}
+
+	if err := markStage(settings, step, stage); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, settings.Clients)
+	for i := 0; i < settings.Clients; i++ {
+		names = append(names, StageFileName(step, stage, i))
+	}
+
+	return waitStage(settings, stage, names...)
+}
+
+// markStage - создать файл этапа клиента в каталоге согласования. Файл, оставшийся
+// от прошлого запуска, пропустил бы ожидание, поэтому он считается ошибкой.
+func markStage(settings *config.DatabaseSettings, step, stage string) error {
+	if err := os.MkdirAll(settings.Barrier, barrierDirMode); err != nil {
+		return merry.Prepend(err, "failed to create barrier directory")
+	}
+
+	name := StageFileName(step, stage, settings.ClientIndex)
+
+	file, err := os.OpenFile(filepath.Join(settings.Barrier, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, barrierFileMode)
+	if errors.Is(err, os.ErrExist) {
+		return merry.Errorf("barrier directory '%s' holds '%s' of a previous run, give an empty directory",
+			settings.Barrier, name)
+	}
+
+	if err != nil {
+		return merry.Prepend(err, "failed to mark barrier stage")
+	}
+
+	return file.Close()
+}
+
+// waitStage - дождаться файлов этапа в каталоге согласования. Ожидание прекращается
+// файлом abort, который создается при ошибке одного из клиентов.
+func waitStage(settings *config.DatabaseSettings, stage string, names ...string) error {
+	llog.Infof("Waiting for %d clients to be %s...", settings.Clients, stage)
+
+	start := time.Now()
+
+	for _, name := range names {
+		for {
+			if _, err := os.Stat(filepath.Join(settings.Barrier, AbortFileName)); err == nil {
+				return merry.Errorf("run is aborted while waiting for clients to be %s", stage)
+			}
+
+			_, err := os.Stat(filepath.Join(settings.Barrier, name))
+			if err == nil {
+				break
+			}
+
+			if !errors.Is(err, os.ErrNotExist) {
+				return merry.Prepend(err, "failed to check barrier stage")
+			}
+
+			if time.Since(start) > barrierTimeout {
+				return merry.Errorf("clients are not %s after %v, '%s' is missing", stage, barrierTimeout, name)
+			}
+
+			time.Sleep(barrierPollInterval)
+		}
+	}
+
+	llog.Infof("All %d clients are %s in %v", settings.Clients, stage, time.Since(start).Truncate(time.Millisecond))
+
+	return nil
+}
@@ -5,6 +5,7 @@
 package payload
 
 import (
+	"context"
 	"math/rand"
 	"runtime"
 	"sync"
@@ -49,7 +50,9 @@ type CustomTxTransfer interface {
 
 	LockAccount(transferId model.TransferId, pendingAmount *inf.Dec, bic string, ban string) (*model.Account, error)
 	UnlockAccount(bic string, ban string, transferId model.TransferId) error
-	StartStatisticsCollect(statInterval time.Duration) error
+	StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error
+	// Close - закрыть соединения с БД
+	Close()
 }
 
 type ClientCustomTx struct {
@@ -58,6 +61,8 @@ type ClientCustomTx struct {
 	cluster  CustomTxTransfer
 	oracle   *database.Oracle
 	payStats *PayStats
+	// повторы блокировки счетов текущего перевода, для статистики по горячим счетам
+	lockRetries int
 }
 
 func (c *ClientCustomTx) Init(cluster CustomTxTransfer, oracle *database.Oracle, payStats *PayStats) {
@@ -75,7 +80,8 @@ func (c *ClientCustomTx) RegisterTransfer(t *model.Transfer) error {
 	// Register a new transfer
 	err := c.cluster.InsertTransfer(t)
 	if err != nil {
-		// Should never happen, transfer id is globally unique
+		// Should never happen, transfer id is unique within the run,
+		// a replay with the same seed must start from a fresh database
 		llog.Fatalf("[%v] [%v] Failed to create: a duplicate transfer exists",
 			c.shortId, t.Id)
 		return merry.Prepend(err, "failed to insert transfer")
@@ -209,6 +215,7 @@ func (c *ClientCustomTx) LockAccounts(t *model.Transfer, wait bool) error {
 					RecoverTransfer(receivedAccount.PendingTransfer)
 				}
 				atomic.AddUint64(&c.payStats.retries, 1)
+				c.lockRetries++
 
 				if !wait {
 					return merry.New("failed to lock account: Wait aborted")
@@ -322,8 +329,8 @@ func (c *ClientCustomTx) DeleteTransfer(transferId model.TransferId) error {
 }
 
 func payWorkerCustomTx(
-	settings config.DatabaseSettings,
-	n_transfers int, zipfian bool, dbCluster CustomTxTransfer,
+	settings config.DatabaseSettings, worker int,
+	n_transfers int, deadline time.Time, dbCluster CustomTxTransfer,
 	oracle *database.Oracle, payStats *PayStats,
 	wg *sync.WaitGroup) {
 
@@ -337,12 +344,19 @@ func payWorkerCustomTx(
 		llog.Fatalf("Got a fatal error fetching cluster settings: %v", err)
 	}
 
-	randSource.Init(clusterSettings.Count, clusterSettings.Seed, settings.BanRangeMultiplier)
+	randSource.Init(clusterSettings.Count, clusterSettings.Seed, settings.BanRangeMultiplier, settings.Seed, worker)
+	client.clientId = uuid.UUID(randSource.NewClientID())
+	llog.Tracef("[%v] Assigned worker %d client id %v", client.shortId, worker, client.clientId)
+	if settings.Enumerate {
+		randSource.SetEnumeration(settings.MissRatio)
+	}
+	randSource.SetDistribution(settings.KeyDistribution())
+	randSource.SetHotAccounts(settings.HotAccounts, settings.HotFraction)
 
-	for i := 0; i < n_transfers; {
+	for i := 0; i < n_transfers && !pastDeadline(deadline); {
 
 		t := new(model.Transfer)
-		t.InitRandomTransfer(&randSource, zipfian)
+		t.InitRandomTransfer(&randSource)
 
 		cookie := statistics.StatsRequestStart()
 		if err := client.MakeTransfer(t); err != nil {
@@ -370,8 +384,7 @@ func payCustomTx(settings *config.DatabaseSettings,
 	var wg sync.WaitGroup
 	var payStats PayStats
 
-	transfersPerWorker := settings.Count / settings.Workers
-	remainder := settings.Count - transfersPerWorker*settings.Workers
+	transfersPerWorker, remainder, deadline := transferLimits(settings)
 
 	RecoveryStart(cluster, oracle, &payStats)
 	//nolint:golint,gosimple
@@ -386,7 +399,7 @@ func payCustomTx(settings *config.DatabaseSettings,
 		if i < remainder {
 			nTransfers++
 		}
-		go payWorkerCustomTx(*settings, nTransfers, settings.Zipfian, clusterCustomTx, oracle, &payStats, &wg)
+		go payWorkerCustomTx(*settings, i, nTransfers, deadline, clusterCustomTx, oracle, &payStats, &wg)
 	}
 
 	wg.Wait()
@@ -399,7 +412,21 @@ func payCustomTx(settings *config.DatabaseSettings,
 	return &payStats, nil
 }
 
-func (c *ClientCustomTx) MakeTransfer(t *model.Transfer) error {
+// MakeTransfer - выполнить перевод и учесть его в статистике по счетам: повторы
+// блокировки, прерывание из-за ошибки и время перевода вместе с повторами
+//
+//nolint:nonamedreturns // ошибка нужна отложенному учету статистики
+func (c *ClientCustomTx) MakeTransfer(t *model.Transfer) (err error) {
+	c.lockRetries = 0
+	start := time.Now()
+
+	defer func() {
+		elapsed := time.Since(start)
+		for _, account := range t.Acs {
+			statistics.StatsAccountTransfer(account.Bic+"/"+account.Ban, c.lockRetries, err != nil, elapsed)
+		}
+	}()
+
 	if err := c.RegisterTransfer(t); err != nil {
 		return merry.Prepend(err, "failed to register transfer")
 	}
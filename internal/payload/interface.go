@@ -5,6 +5,7 @@
 package payload
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -22,10 +23,14 @@ import (
 type Payload interface {
 	Pay(*state.State) error
 	Pop(*state.State) error
+	KV(*state.State) error
 	Check(*inf.Dec) (*inf.Dec, error)
 	UpdateSettings(*config.DatabaseSettings)
-	StartStatisticsCollect(statInterval time.Duration) error
+	StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error
 	Connect() error
+	Close()
+	// DelegateChaos - передать управление хаосом исполнителю сценария
+	DelegateChaos()
 }
 
 type BasePayload struct {
@@ -38,13 +43,27 @@ type BasePayload struct {
 
 	chaos          chaos.Controller
 	chaosParameter string
+	// хаосом управляет исполнитель сценария: шаги chaos и события по времени
+	scenarioChaos bool
 
-	oracle  *database.Oracle
-	payFunc func(
-		settings *config.DatabaseSettings,
-		cluster CustomTxTransfer,
-		oracle *database.Oracle,
-	) (*PayStats, error)
+	oracle *database.Oracle
+}
+
+// payFunc - функция переводов одного запуска pay
+type payFunc func(
+	settings *config.DatabaseSettings,
+	cluster CustomTxTransfer,
+	oracle *database.Oracle,
+) (*PayStats, error)
+
+// selectPayFunc - переводы во встроенных или в собственных транзакциях stroppy. Выбор делается
+// при каждом запуске pay, потому что шаг сценария или команда оболочки могут изменить tx.
+func selectPayFunc(settings *config.DatabaseSettings) payFunc {
+	if settings.UseCustomTx {
+		return payCustomTx
+	}
+
+	return payBuiltinTx
 }
 
 func CreatePayload(
@@ -60,15 +79,15 @@ func CreatePayload(
 		chaos:          chaosController,
 		chaosParameter: settings.ChaosParameter,
 		oracle:         &database.Oracle{},
-		payFunc:        nil,
 	}
 
-	llog.Debugf("DatabaseSettings: DBType: %s, workers: %d, Zipfian: %v, Oracle: %v, Check: %v, "+
+	llog.Debugf("DatabaseSettings: DBType: %s, workers: %d, Zipfian: %v, Distribution: %s, Oracle: %v, Check: %v, "+
 		"DBURL: %s, UseCustomTx: %v, BanRangeMultiplier: %v, StatInterval: %v, "+
-		"ConnectPoolSize: %d, Sharded: %v",
+		"ConnectPoolSize: %d, Sharded: %v, Isolation: %s, Locking: %s, Journal: %v",
 		settings.DatabaseSettings.DBType,
 		settings.DatabaseSettings.Workers,
 		settings.DatabaseSettings.Zipfian,
+		settings.DatabaseSettings.Distribution,
 		settings.DatabaseSettings.Oracle,
 		settings.DatabaseSettings.Check,
 		settings.DatabaseSettings.DBURL,
@@ -77,30 +96,11 @@ func CreatePayload(
 		settings.DatabaseSettings.StatInterval,
 		settings.DatabaseSettings.ConnectPoolSize,
 		settings.DatabaseSettings.Sharded,
+		settings.DatabaseSettings.Isolation,
+		settings.DatabaseSettings.Locking,
+		settings.DatabaseSettings.Journal,
 	)
 
-	if basePayload.config.Oracle {
-		predictableCluster, ok := basePayload.Cluster.(database.PredictableCluster)
-		if !ok {
-			return nil, merry.Errorf(
-				"Oracle is not supported for %s cluster",
-				basePayload.config.DBType,
-			)
-		}
-
-		basePayload.oracle = new(database.Oracle)
-
-		basePayload.oracle.Init(predictableCluster)
-	}
-
-	llog.Debugf("CustomTx will be used: %v", basePayload.config.UseCustomTx)
-
-	if basePayload.config.UseCustomTx {
-		basePayload.payFunc = payCustomTx
-	} else {
-		basePayload.payFunc = payBuiltinTx
-	}
-
 	llog.Infof(
 		"Payload object constructed for database '%s', url '%s'",
 		basePayload.config.DBType,
@@ -118,14 +118,43 @@ func (p *BasePayload) UpdateSettings(newConfig *config.DatabaseSettings) {
 	p.config = &unpConfig
 }
 
-func (p *BasePayload) StartStatisticsCollect(statInterval time.Duration) (err error) {
-	if err = p.Cluster.StartStatisticsCollect(statInterval); err != nil {
+func (p *BasePayload) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) (err error) {
+	if err = p.Cluster.StartStatisticsCollect(ctx, statInterval); err != nil {
 		return merry.Errorf("failed to get statistic for %v cluster: %v", p.config.DBType, err)
 	}
 
 	return
 }
 
+// DelegateChaos - хаосом управляет исполнитель сценария, поэтому pop, pay и kv не запускают
+// хаос из --chaos-parameter и в конце не останавливают хаос, запущенный шагами сценария
+func (p *BasePayload) DelegateChaos() {
+	p.scenarioChaos = true
+}
+
+// startChaos - запустить хаос из параметров запуска, если хаосом не управляет сценарий
+func (p *BasePayload) startChaos(command string, shellState *state.State) error {
+	if p.scenarioChaos {
+		return nil
+	}
+
+	return p.chaos.ExecuteCommand(command, shellState)
+}
+
+// stopChaos - остановить хаос, запущенный startChaos
+func (p *BasePayload) stopChaos() {
+	if !p.scenarioChaos {
+		p.chaos.Stop()
+	}
+}
+
+// Close - закрыть соединения с БД, открытые Connect
+func (p *BasePayload) Close() {
+	if p.Cluster != nil {
+		p.Cluster.Close()
+	}
+}
+
 func (p *BasePayload) Connect() error {
 	// \todo: необходим большой рефакторинг
 	var (
@@ -151,5 +180,17 @@ func (p *BasePayload) Connect() error {
 
 	llog.Traceln("Payload type successefully casted to CustomTxTransfer")
 
+	// оракул читает счета кластера, поэтому инициализируется после подключения
+	if p.config.Oracle {
+		predictableCluster, ok := p.Cluster.(database.PredictableCluster)
+		if !ok {
+			return merry.Errorf("Oracle is not supported for %s cluster", p.config.DBType)
+		}
+
+		p.oracle = new(database.Oracle)
+
+		p.oracle.Init(predictableCluster)
+	}
+
 	return nil
 }
@@ -0,0 +1,97 @@
+package payload
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/picodata/stroppy/internal/scenario"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/state"
+)
+
+func TestStepTxSelectsPayFunc(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		startup  bool
+		step     string
+		expected payFunc
+	}{
+		{name: "startup builtin", startup: false, step: "{count: 10}", expected: payBuiltinTx},
+		{name: "startup custom", startup: true, step: "{count: 10}", expected: payCustomTx},
+		{name: "step enables tx", startup: false, step: "{count: 10, tx: true}", expected: payCustomTx},
+		{name: "step disables tx", startup: true, step: "{count: 10, tx: false}", expected: payBuiltinTx},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			settings := config.DefaultSettings()
+			settings.DatabaseSettings.UseCustomTx = test.startup
+
+			testScenario, err := scenario.Parse(
+				[]byte("version: 1\nsteps:\n  - pay: "+test.step+"\n"),
+				settings.DatabaseSettings,
+			)
+			require.NoError(t, err)
+
+			p, err := CreatePayload(nil, settings, nil)
+			require.NoError(t, err)
+
+			basePayload, _ := p.(*BasePayload)
+			basePayload.UpdateSettings(testScenario.First(scenario.StepPay).Settings)
+
+			assert.Equal(t,
+				reflect.ValueOf(test.expected).Pointer(),
+				reflect.ValueOf(selectPayFunc(basePayload.config)).Pointer(),
+			)
+		})
+	}
+}
+
+// chaosCounter - контроллер хаоса, считающий запуски и остановки
+type chaosCounter struct {
+	started []string
+	stopped int
+}
+
+func (c *chaosCounter) Deploy(*state.State) error { return nil }
+
+func (c *chaosCounter) ExecuteCommand(command string, _ *state.State) error {
+	c.started = append(c.started, command)
+
+	return nil
+}
+
+func (c *chaosCounter) Stop() { c.stopped++ }
+
+func TestDelegateChaos(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		delegate bool
+		started  []string
+		stopped  int
+	}{
+		{name: "payload owns chaos", delegate: false, started: []string{"pod-kill"}, stopped: 1},
+		{name: "scenario owns chaos", delegate: true, started: nil, stopped: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			controller := &chaosCounter{}
+
+			settings := config.DefaultSettings()
+			settings.ChaosParameter = "pod-kill"
+
+			p, err := CreatePayload(nil, settings, controller)
+			require.NoError(t, err)
+
+			if test.delegate {
+				p.DelegateChaos()
+			}
+
+			basePayload, _ := p.(*BasePayload)
+			require.NoError(t, basePayload.startChaos(basePayload.chaosParameter, &state.State{Settings: settings}))
+			basePayload.stopChaos()
+
+			assert.Equal(t, test.started, controller.started)
+			assert.Equal(t, test.stopped, controller.stopped)
+		})
+	}
+}
@@ -0,0 +1,399 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package payload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	llog "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"gitlab.com/picodata/stroppy/internal/fixed_random_source"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/state"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// Операции нагрузки ключ-значение, названия совпадают с отчетом YCSB.
+const (
+	kvOperationRead            = "READ"
+	kvOperationUpdate          = "UPDATE"
+	kvOperationInsert          = "INSERT"
+	kvOperationScan            = "SCAN"
+	kvOperationReadModifyWrite = "READ-MODIFY-WRITE"
+)
+
+// kvWorkloadCustom - смесь операций, заданная долями из параметров запуска.
+const kvWorkloadCustom = "custom"
+
+// kvWorkload - доли операций и распределение обращений к записям.
+type kvWorkload struct {
+	Read            float64
+	Update          float64
+	Insert          float64
+	Scan            float64
+	ReadModifyWrite float64
+	Distribution    string
+}
+
+// kvPresets - смеси стандартных нагрузок YCSB A-F.
+var kvPresets = map[string]kvWorkload{ //nolint:exhaustivestruct
+	"a": {Read: 0.5, Update: 0.5, Distribution: fixed_random_source.DistributionZipfian},
+	"b": {Read: 0.95, Update: 0.05, Distribution: fixed_random_source.DistributionZipfian},
+	"c": {Read: 1, Distribution: fixed_random_source.DistributionZipfian},
+	"d": {Read: 0.95, Insert: 0.05, Distribution: fixed_random_source.DistributionLatest},
+	"e": {Scan: 0.95, Insert: 0.05, Distribution: fixed_random_source.DistributionZipfian},
+	"f": {Read: 0.5, ReadModifyWrite: 0.5, Distribution: fixed_random_source.DistributionZipfian},
+}
+
+// total - сумма долей операций.
+func (w kvWorkload) total() float64 {
+	return w.Read + w.Update + w.Insert + w.Scan + w.ReadModifyWrite
+}
+
+// String - смесь операций для журнала запуска.
+func (w kvWorkload) String() string {
+	return fmt.Sprintf("read %v, update %v, insert %v, scan %v, read-modify-write %v, %s",
+		w.Read, w.Update, w.Insert, w.Scan, w.ReadModifyWrite, w.Distribution)
+}
+
+// operation - операция для случайного числа roll из [0, 1).
+func (w kvWorkload) operation(roll float64) string {
+	roll *= w.total()
+
+	for _, share := range []struct {
+		operation  string
+		proportion float64
+	}{
+		{kvOperationRead, w.Read},
+		{kvOperationUpdate, w.Update},
+		{kvOperationInsert, w.Insert},
+		{kvOperationScan, w.Scan},
+	} {
+		if roll < share.proportion {
+			return share.operation
+		}
+		roll -= share.proportion
+	}
+
+	return kvOperationReadModifyWrite
+}
+
+// kvWorkloadFromSettings - смесь операций из параметров запуска.
+func kvWorkloadFromSettings(settings *config.DatabaseSettings) (kvWorkload, error) {
+	name := strings.ToLower(settings.KVWorkload)
+
+	workload, ok := kvPresets[name]
+	if name == kvWorkloadCustom {
+		workload = kvWorkload{
+			Read:            settings.KVReadProportion,
+			Update:          settings.KVUpdateProportion,
+			Insert:          settings.KVInsertProportion,
+			Scan:            settings.KVScanProportion,
+			ReadModifyWrite: settings.KVReadModifyWriteProportion,
+			Distribution:    fixed_random_source.DistributionUniform,
+		}
+	} else if !ok {
+		return workload, merry.Errorf("unknown kv workload '%s', expected one of a, b, c, d, e, f or %s",
+			settings.KVWorkload, kvWorkloadCustom)
+	}
+
+	for _, proportion := range []float64{
+		workload.Read, workload.Update, workload.Insert, workload.Scan, workload.ReadModifyWrite,
+	} {
+		if proportion < 0 {
+			return workload, merry.Errorf("operation proportions must not be negative, got %+v", workload)
+		}
+	}
+
+	if workload.total() <= 0 {
+		return workload, merry.New("at least one operation proportion must be positive")
+	}
+
+	if settings.KVDistribution != "" {
+		workload.Distribution = settings.KVDistribution
+	}
+
+	return workload, nil
+}
+
+// KVStats - итоги нагрузки ключ-значение.
+type KVStats struct {
+	errors     uint64
+	notFound   uint64
+	duplicates uint64
+	retries    uint64
+}
+
+// KV - загрузить записи и выполнить смесь операций ключ-значение.
+func (p *BasePayload) KV(shellState *state.State) error {
+	kvCluster, ok := p.Cluster.(cluster.KVCluster)
+	if !ok {
+		return merry.Errorf("kv workload is not supported for %s cluster", p.config.DBType)
+	}
+
+	workload, err := kvWorkloadFromSettings(p.config)
+	if err != nil {
+		return merry.Prepend(err, "invalid kv workload")
+	}
+
+	distribution := fixed_random_source.Distribution{
+		Name:        workload.Distribution,
+		ZipfTheta:   p.config.ZipfTheta,
+		HotspotKeys: p.config.HotspotKeys,
+		HotspotOps:  p.config.HotspotOps,
+	}
+	if err = distribution.Validate(); err != nil {
+		return merry.Prepend(err, "invalid key distribution")
+	}
+
+	if p.config.KVRecords < 1 {
+		return merry.Errorf("records count must be positive, got %d", p.config.KVRecords)
+	}
+
+	if p.config.Clients > 1 {
+		return merry.New("kv workload runs in one client, set --clients 1")
+	}
+
+	if p.config.KVValueSize < 0 || p.config.KVMaxScanLength < 1 {
+		return merry.Errorf("value size must not be negative and max scan length must be positive, got %d and %d",
+			p.config.KVValueSize, p.config.KVMaxScanLength)
+	}
+
+	var stats KVStats
+
+	if !p.config.KVSkipLoad {
+		if err = p.kvLoad(kvCluster, &stats); err != nil {
+			return merry.Prepend(err, "kv load failed")
+		}
+
+		// статистика загрузки выведена, запуск операций считается заново
+		statistics.StatsInit()
+	}
+
+	llog.Infof("Running kv workload '%s' (%v): %d operations over %d records using %d workers on %d cores with seed %d",
+		p.config.KVWorkload, workload, p.config.Count, p.config.KVRecords,
+		p.config.Workers, runtime.NumCPU(), p.config.Seed)
+	llog.Infof("Key distribution: %+v", distribution)
+
+	if err = p.startChaos(p.chaosParameter, shellState); err != nil {
+		llog.Errorf("failed to execute chaos command: %v", err)
+	}
+
+	statistics.StatsSetTotal(p.config.Count)
+
+	// записи, добавленные операциями insert, получают номера после загруженных
+	inserted := int64(p.config.KVRecords)
+
+	var wg sync.WaitGroup
+
+	operationsPerWorker := p.config.Count / p.config.Workers
+	remainder := p.config.Count - operationsPerWorker*p.config.Workers
+
+	for i := 0; i < p.config.Workers; i++ {
+		nOperations := operationsPerWorker
+		if i < remainder {
+			nOperations++
+		}
+
+		wg.Add(1)
+
+		go p.kvWorker(kvCluster, workload, distribution, i, nOperations, &inserted, &stats, &wg)
+	}
+
+	wg.Wait()
+	p.stopChaos()
+	statistics.StatsReportSummary()
+
+	llog.Infof("Errors: %v, Retries: %v, Not found: %v, Duplicates: %v",
+		stats.errors, stats.retries, stats.notFound, stats.duplicates)
+
+	return nil
+}
+
+// kvLoad - создать таблицу и загрузить KVRecords записей, каждый воркер вставляет свой диапазон номеров.
+func (p *BasePayload) kvLoad(kvCluster cluster.KVCluster, stats *KVStats) error {
+	llog.Infof("Loading %d records of %d bytes using %d workers",
+		p.config.KVRecords, p.config.KVValueSize, p.config.Workers)
+
+	if err := kvCluster.BootstrapKV(); err != nil {
+		return merry.Prepend(err, "failed to bootstrap usertable")
+	}
+
+	statistics.StatsSetTotal(p.config.KVRecords)
+
+	var wg sync.WaitGroup
+
+	recordsPerWorker := p.config.KVRecords / p.config.Workers
+	remainder := p.config.KVRecords - recordsPerWorker*p.config.Workers
+
+	first := 0
+	for i := 0; i < p.config.Workers; i++ {
+		nRecords := recordsPerWorker
+		if i < remainder {
+			nRecords++
+		}
+
+		wg.Add(1)
+
+		go func(worker, first, nRecords int) {
+			defer wg.Done()
+
+			var source fixed_random_source.KVSource
+			source.Init(p.config.Seed, worker, fixed_random_source.Distribution{ //nolint:exhaustivestruct
+				Name: fixed_random_source.DistributionUniform,
+			}, p.config.KVRecords)
+
+			for index := first; index < first+nRecords; index++ {
+				key := fixed_random_source.KVKey(index)
+				value := source.Value(p.config.KVValueSize, p.config.KVValueSizeMax)
+				cookie := statistics.StatsRequestStart()
+
+				err := kvRetry(stats, func() error {
+					return kvCluster.KVInsert(key, value)
+				})
+				if errors.Is(err, cluster.ErrDuplicateKey) {
+					// запись уже вставлена попыткой, завершившейся ошибкой
+					atomic.AddUint64(&stats.duplicates, 1)
+				} else if err != nil {
+					llog.Fatalf("Fatal error: %+v", err)
+				}
+
+				statistics.StatsOperationEnd(kvOperationInsert, cookie)
+			}
+		}(i, first, nRecords)
+
+		first += nRecords
+	}
+
+	wg.Wait()
+	statistics.StatsReportSummary()
+
+	llog.Infof("Done %d records, %d duplicates", p.config.KVRecords, stats.duplicates)
+
+	return nil
+}
+
+// kvWorker - выполнить nOperations операций смеси workload.
+func (p *BasePayload) kvWorker(
+	kvCluster cluster.KVCluster,
+	workload kvWorkload,
+	distribution fixed_random_source.Distribution,
+	worker int,
+	nOperations int,
+	inserted *int64,
+	stats *KVStats,
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	var source fixed_random_source.KVSource
+	// поток операций отличается от потока загрузки с тем же номером воркера
+	source.Init(p.config.Seed, worker+p.config.Workers, distribution, p.config.KVRecords)
+
+	for i := 0; i < nOperations; i++ {
+		operation := workload.operation(source.Float64())
+		key := fixed_random_source.KVKey(source.NextIndex(int(atomic.LoadInt64(inserted))))
+		if operation == kvOperationInsert {
+			// ключ выделяется до повторов, чтобы повтор вставлял ту же запись
+			key = fixed_random_source.KVKey(int(atomic.AddInt64(inserted, 1) - 1))
+		}
+
+		cookie := statistics.StatsRequestStart()
+		attempts := 0
+
+		err := kvRetry(stats, func() error {
+			attempts++
+
+			switch operation {
+			case kvOperationRead:
+				_, err := kvCluster.KVRead(key)
+
+				return err
+			case kvOperationUpdate:
+				return kvCluster.KVUpdate(key, source.Value(p.config.KVValueSize, p.config.KVValueSizeMax))
+			case kvOperationInsert:
+				err := kvCluster.KVInsert(key, source.Value(p.config.KVValueSize, p.config.KVValueSizeMax))
+				if attempts > 1 && errors.Is(err, cluster.ErrDuplicateKey) {
+					// запись уже вставлена попыткой, завершившейся временной ошибкой
+					return nil
+				}
+
+				return err
+			case kvOperationScan:
+				_, err := kvCluster.KVScan(key, source.ScanLength(p.config.KVMaxScanLength))
+
+				return err
+			default:
+				return kvCluster.KVReadModifyWrite(key, source.Value(p.config.KVValueSize, p.config.KVValueSizeMax))
+			}
+		})
+
+		switch {
+		case err == nil:
+		case errors.Is(err, cluster.ErrNoRows):
+			atomic.AddUint64(&stats.notFound, 1)
+		case errors.Is(err, cluster.ErrDuplicateKey):
+			atomic.AddUint64(&stats.duplicates, 1)
+		default:
+			atomic.AddUint64(&stats.errors, 1)
+			llog.Errorf("Got a fatal error %v, ending worker", err)
+
+			return
+		}
+
+		statistics.StatsOperationEnd(operation, cookie)
+	}
+}
+
+// kvRetry - выполнить операцию, повторяя ее после временных ошибок, как перевод во встроенной транзакции.
+func kvRetry(stats *KVStats, operation func() error) error {
+	sleepDuration := time.Millisecond
+
+	for i := 0; ; i++ {
+		err := operation()
+		if err == nil || i+1 >= maxTxRetries || !isRetryableKVError(err) {
+			return err
+		}
+
+		atomic.AddUint64(&stats.retries, 1)
+		llog.Tracef("Retrying kv operation after sleeping %v: %v", sleepDuration, err)
+
+		time.Sleep(sleepDuration)
+		sleepDuration *= 2
+		if sleepDuration > maxSleepDuration {
+			sleepDuration = maxSleepDuration
+		}
+	}
+}
+
+// isRetryableKVError - ошибка вызвана конфликтом транзакций или недоступностью кластера.
+// description of fdb.error with code 1037 - "Storage process does not have recent mutations"
+// description of fdb.error with code 1009 - "Request for future version"
+// description of mongo.error with code 133 - FailedToSatisfyReadPreference
+func isRetryableKVError(err error) bool {
+	return errors.Is(err, cluster.ErrTxRollback) ||
+		errors.Is(err, cluster.ErrTimeoutExceeded) ||
+		errors.Is(err, cluster.ErrInternalServerError) ||
+		errors.Is(err, fdb.Error{Code: 1037}) || //nolint:gomnd
+		errors.Is(err, fdb.Error{Code: 1009}) || //nolint:gomnd
+		errors.Is(err, fdb.Error{Code: 1007}) || //nolint:gomnd
+		errors.Is(err, mongo.CommandError{Code: 133}) || //nolint
+		mongo.IsNetworkError(err) ||
+		mongo.IsTimeout(err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		strings.Contains(err.Error(), "connection") ||
+		strings.Contains(err.Error(), "socket")
+}
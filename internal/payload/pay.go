@@ -5,12 +5,16 @@
 package payload
 
 import (
+	"math"
 	"runtime"
+	"time"
 
 	"github.com/ansel1/merry"
 	llog "github.com/sirupsen/logrus"
 	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
 	"gitlab.com/picodata/stroppy/pkg/state"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
 )
 
 // IsTransientError is a wrapper to determine if request was
@@ -38,18 +42,85 @@ type PayStats struct {
 func (p *BasePayload) Pay(shellState *state.State) error {
 	var err error
 
-	llog.Infof("Making %d transfers using %d workers on %d cores \n",
-		p.config.Count, p.config.Workers, runtime.NumCPU())
+	distribution := p.config.KeyDistribution()
 
-	if err = p.chaos.ExecuteCommand(p.chaosParameter, shellState); err != nil {
+	if err = p.checkHotAccounts(); err != nil {
+		return merry.Prepend(err, "invalid hot accounts settings")
+	}
+
+	if p.config.Count <= 0 && p.config.Duration <= 0 {
+		return merry.Errorf("transfers count must be positive without duration, got %d", p.config.Count)
+	}
+
+	settings, _ := clientSettings(p.config)
+
+	llog.Infof("Making %d transfers using %d workers on %d cores with seed %d\n",
+		settings.Count, settings.Workers, runtime.NumCPU(), settings.Seed)
+	if p.config.Duration > 0 {
+		llog.Infof("Transfers stop after %v", p.config.Duration)
+	}
+	llog.Infof("Key distribution: %+v", distribution)
+	if p.config.Enumerate {
+		llog.Infof("Accounts are enumerated, miss ratio %v", p.config.MissRatio)
+	}
+	if p.config.HotAccounts > 0 {
+		llog.Infof("%v of transfers involve one of %d hot accounts", p.config.HotFraction, p.config.HotAccounts)
+	}
+	if p.config.ContentionTop > 0 {
+		statistics.StatsEnableContention(p.config.ContentionTop)
+	}
+
+	if err = p.startChaos(p.chaosParameter, shellState); err != nil {
 		llog.Errorf("failed to execute chaos command: %v", err)
 	}
 
+	if p.config.Journal {
+		if err = p.enableJournal(); err != nil {
+			return merry.Prepend(err, "failed to enable transfer journal")
+		}
+	}
+
+	if p.config.MultiCurrency {
+		if err = p.enableMultiCurrency(); err != nil {
+			return merry.Prepend(err, "failed to enable multi-currency transfers")
+		}
+	}
+
+	var (
+		statementStats StatementStats
+		stopStatements func()
+	)
+
+	var history cluster.HistoryCluster
+
+	if p.config.History || p.config.StatementWorkers > 0 {
+		if history, err = p.enableHistory(); err != nil {
+			return merry.Prepend(err, "failed to enable transfer history")
+		}
+	}
+
+	// клиенты совместного запуска начинают переводы одновременно
+	if err = syncClients(p.config, payStep, StageReady); err != nil {
+		return err
+	}
+
+	if settings.StatementWorkers > 0 {
+		if stopStatements, err = p.startStatementWorkers(settings, history, &statementStats); err != nil {
+			return merry.Prepend(err, "failed to start statement workers")
+		}
+	}
+
 	var payStats *PayStats
-	if payStats, err = p.payFunc(p.config, p.Cluster, p.oracle); err != nil {
+	llog.Debugf("CustomTx will be used: %v", settings.UseCustomTx)
+	payStats, err = selectPayFunc(settings)(settings, p.Cluster, p.oracle)
+	if stopStatements != nil {
+		// выписки читаются, пока выполняются переводы
+		stopStatements()
+	}
+	if err != nil {
 		return merry.Prepend(err, "pay function failed")
 	}
-	p.chaos.Stop()
+	p.stopChaos()
 
 	llog.Infof("Errors: %v, Retries: %v, Recoveries: %v, Not found: %v, Overdraft: %v\n",
 		payStats.errors,
@@ -58,5 +129,112 @@ func (p *BasePayload) Pay(shellState *state.State) error {
 		payStats.NoSuchAccount,
 		payStats.InsufficientFunds)
 
+	// параметры транзакций фиксируются в отчете, чтобы сравнивать запуски на одних и тех же данных
+	if p.config.DBType == cluster.Postgres || p.config.DBType == cluster.Cockroach {
+		llog.Infof("Isolation level: %s, locking: %s\n", p.config.Isolation, p.config.Locking)
+	}
+
+	if p.config.Journal {
+		llog.Infof("Transfers were journaled")
+	}
+
+	if p.config.MultiCurrency {
+		llog.Infof("Transfers were converted by fx rates")
+	}
+
+	if p.config.History || p.config.StatementWorkers > 0 {
+		llog.Infof("Transfers were recorded in account history")
+	}
+
+	if p.config.StatementWorkers > 0 {
+		llog.Infof("Statements: %v (%v entries), bank scans: %v (%v pages, %v accounts), statement errors: %v",
+			statementStats.statements,
+			statementStats.entries,
+			statementStats.scans,
+			statementStats.pages,
+			statementStats.accounts,
+			statementStats.errors)
+	}
+
+	return nil
+}
+
+// transferLimits - число переводов на воркера, остаток для первых воркеров и момент окончания
+// переводов. С длительностью без числа переводов воркеры работают до окончания времени.
+func transferLimits(settings *config.DatabaseSettings) (perWorker, remainder int, deadline time.Time) {
+	if settings.Duration > 0 {
+		deadline = time.Now().Add(settings.Duration)
+	}
+
+	if settings.Count <= 0 {
+		return math.MaxInt, 0, deadline
+	}
+
+	perWorker = settings.Count / settings.Workers
+	remainder = settings.Count - perWorker*settings.Workers
+
+	return perWorker, remainder, deadline
+}
+
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// defaultContentionTop - сколько самых конфликтных счетов выводить, если заданы горячие счета.
+const defaultContentionTop = 10
+
+// checkHotAccounts - сверить число горячих счетов с числом загруженных счетов, остальные
+// параметры горячих счетов проверяются до подключения к БД.
+func (p *BasePayload) checkHotAccounts() error {
+	if p.config.HotAccounts == 0 {
+		return nil
+	}
+
+	clusterSettings, err := p.Cluster.FetchSettings()
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch cluster settings")
+	}
+
+	if p.config.HotAccounts > clusterSettings.Count {
+		return merry.Errorf("hot accounts must not exceed %d populated accounts, got %d",
+			clusterSettings.Count, p.config.HotAccounts)
+	}
+
+	if p.config.ContentionTop == 0 {
+		p.config.ContentionTop = defaultContentionTop
+	}
+
 	return nil
 }
+
+// enableJournal - подготовить журнал и агрегаты по БИК для журналируемых переводов.
+func (p *BasePayload) enableJournal() error {
+	if p.config.UseCustomTx {
+		return merry.New("journaled transfers require builtin transactions, unset --tx")
+	}
+
+	journaled, ok := p.Cluster.(cluster.JournaledCluster)
+	if !ok {
+		return merry.Errorf("journaled transfers are not supported for %s cluster", p.config.DBType)
+	}
+
+	llog.Infof("Preparing transfer journal and per-bic aggregates...")
+
+	return journaled.EnableJournal()
+}
+
+// enableMultiCurrency - заполнить таблицу курсов и сохранить суммы балансов по валютам.
+func (p *BasePayload) enableMultiCurrency() error {
+	if p.config.UseCustomTx {
+		return merry.New("multi-currency transfers require builtin transactions, unset --tx")
+	}
+
+	multiCurrency, ok := p.Cluster.(cluster.MultiCurrencyCluster)
+	if !ok {
+		return merry.Errorf("multi-currency transfers are not supported for %s cluster", p.config.DBType)
+	}
+
+	llog.Infof("Preparing fx rates and per-currency totals...")
+
+	return multiCurrency.EnableMultiCurrency()
+}
@@ -45,11 +45,19 @@ type PopStats struct {
 func (p *BasePayload) Pop(shellState *state.State) error { //nolint //TODO: refactor
 	stats := PopStats{}
 
-	llog.Tracef("%#v %#v", p.config.Count, p.config.Seed) // TODO: remove
+	var err error
 
-	err := p.Cluster.BootstrapDB(p.config.Count, int(p.config.Seed))
-	if err != nil {
-		return merry.Prepend(err, "cluster bootstrap failed")
+	// при совместной загрузке БД готовит первый клиент, остальные ждут его готовности
+	if p.config.ClientIndex == 0 {
+		if err = p.Cluster.BootstrapDB(p.config.Count, int(p.config.Seed)); err != nil {
+			return merry.Prepend(err, "cluster bootstrap failed")
+		}
+	}
+
+	settings, firstAccount := clientSettings(p.config)
+
+	if err = syncClients(p.config, popStep, StageReady); err != nil {
+		return err
 	}
 
 	var clusterSettings cluster.Settings
@@ -57,16 +65,21 @@ func (p *BasePayload) Pop(shellState *state.State) error { //nolint //TODO: refa
 		return merry.Prepend(err, "cluster settings fetch failed")
 	}
 
-	worker := func(id, nAccounts int, wg *sync.WaitGroup) {
+	worker := func(id, first, nAccounts int, wg *sync.WaitGroup) {
 		defer wg.Done()
 
 		var rand fixed_random_source.FixedRandomSource
-		rand.Init(clusterSettings.Count, clusterSettings.Seed, p.config.BanRangeMultiplier)
+		rand.Init(clusterSettings.Count, clusterSettings.Seed, p.config.BanRangeMultiplier, settings.Seed, id)
 
 		llog.Tracef("Worker %d inserting %d accounts", id, nAccounts)
 		for i := 0; i < nAccounts; {
 			cookie := statistics.StatsRequestStart()
-			bic, ban := rand.NewBicAndBan()
+			var bic, ban string
+			if p.config.Enumerate {
+				bic, ban = rand.AccountByIndex(first + i)
+			} else {
+				bic, ban = rand.NewBicAndBan()
+			}
 			balance := rand.NewStartBalance()
 			acc := model.Account{ //nolint
 				Bic:     bic,
@@ -74,12 +87,22 @@ func (p *BasePayload) Pop(shellState *state.State) error { //nolint //TODO: refa
 				Balance: balance,
 				Found:   false,
 			}
+			if p.config.AccountPayload {
+				acc.Payload = model.NewAccountPayload(&rand, p.config.PaddingSize)
+			} else if p.config.MultiCurrency {
+				acc.Payload = &model.AccountPayload{Currency: rand.NewCurrency()} //nolint:exhaustivestruct
+			}
 			// Retry loop
 			for {
 				insertErr := p.Cluster.InsertAccount(acc)
 				if insertErr != nil {
 					if errors.Is(insertErr, cluster.ErrDuplicateKey) {
 						atomic.AddUint64(&stats.duplicates, 1)
+						if p.config.Enumerate {
+							// номера счетов не пересекаются, значит счет уже вставлен
+							// предыдущей попыткой, завершившейся ошибкой
+							break
+						}
 						// Duplicate account means we need to re-generate the values and retry
 						bic, ban := rand.NewBicAndBan()
 						acc = model.Account{ //nolint
@@ -87,6 +110,7 @@ func (p *BasePayload) Pop(shellState *state.State) error { //nolint //TODO: refa
 							Ban:     ban,
 							Balance: balance,
 							Found:   false,
+							Payload: acc.Payload,
 						}
 
 						continue
@@ -138,35 +162,65 @@ func (p *BasePayload) Pop(shellState *state.State) error { //nolint //TODO: refa
 		llog.Tracef("Worker %d done %d accounts", id, nAccounts)
 	}
 
-	llog.Infof("Creating %d accounts using %d workers on %d cores \n",
-		p.config.Count, p.config.Workers,
-		runtime.NumCPU())
+	llog.Infof("Creating %d accounts using %d workers on %d cores with seed %d\n",
+		settings.Count, settings.Workers,
+		runtime.NumCPU(), settings.Seed)
+	if p.config.Enumerate {
+		llog.Infof("Accounts are enumerated")
+	}
 
 	var wg sync.WaitGroup
 
-	accountsPerWorker := p.config.Count / p.config.Workers
-	remainder := p.config.Count - accountsPerWorker*p.config.Workers
+	accountsPerWorker := settings.Count / settings.Workers
+	remainder := settings.Count - accountsPerWorker*settings.Workers
 
 	chaosCommand := fmt.Sprintf("%s-%s", p.config.DBType, p.chaosParameter)
-	if err = p.chaos.ExecuteCommand(chaosCommand, shellState); err != nil {
+	if err = p.startChaos(chaosCommand, shellState); err != nil {
 		return errors.Wrap(err, "failed to execute chaos command")
 	}
 
-	for i := 0; i < p.config.Workers; i++ {
+	first := firstAccount
+	for i := 0; i < settings.Workers; i++ {
 		nAccounts := accountsPerWorker
 		if i < remainder {
 			nAccounts++
 		}
 		wg.Add(1)
-		go worker(i+1, nAccounts, &wg)
+		go worker(i+1, first, nAccounts, &wg)
+		first += nAccounts
 	}
 
 	wg.Wait()
 	llog.Infof("Done %v accounts, %v errors, %v duplicates",
-		p.config.Count, stats.errors, stats.duplicates)
+		settings.Count, stats.errors, stats.duplicates)
 
-	p.chaos.Stop()
+	p.stopChaos()
 	statistics.StatsReportSummary()
 
-	return nil
+	return p.syncPopTotal()
+}
+
+// syncPopTotal - дождаться окончания совместной загрузки: общий баланс считает и сохраняет
+// первый клиент, остальные ждут его, чтобы не сохранить сумму недозагруженных счетов
+func (p *BasePayload) syncPopTotal() error {
+	if p.config.Clients <= 1 {
+		return nil
+	}
+
+	if err := syncClients(p.config, popStep, StageDone); err != nil {
+		return err
+	}
+
+	if p.config.ClientIndex > 0 {
+		return waitStage(p.config, StageTotal, StageFileName(popStep, StageTotal, 0))
+	}
+
+	balance, err := p.Check(nil)
+	if err != nil {
+		return merry.Prepend(err, "failed to persist total balance")
+	}
+
+	llog.Infof("Total balance of all clients: %v", balance)
+
+	return markStage(p.config, popStep, StageTotal)
 }
@@ -0,0 +1,194 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package payload
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+
+	"gitlab.com/picodata/stroppy/internal/fixed_random_source"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// Операции чтения диапазонов, выполняемые параллельно с переводами.
+const (
+	statementOperation    = "STATEMENT"
+	bankScanPageOperation = "BANK-SCAN-PAGE"
+)
+
+// StatementStats - итоги чтения выписок и обхода счетов банков.
+type StatementStats struct {
+	statements uint64
+	entries    uint64
+	scans      uint64
+	pages      uint64
+	accounts   uint64
+	errors     uint64
+}
+
+// enableHistory - записывать переводы в историю счетов.
+func (p *BasePayload) enableHistory() (cluster.HistoryCluster, error) {
+	if p.config.UseCustomTx {
+		return nil, merry.New("transfer history requires builtin transactions, unset --tx")
+	}
+
+	history, ok := p.Cluster.(cluster.HistoryCluster)
+	if !ok {
+		return nil, merry.Errorf("transfer history is not supported for %s cluster", p.config.DBType)
+	}
+
+	llog.Infof("Recording transfer history...")
+
+	return history, history.EnableHistory()
+}
+
+// startStatementWorkers - запустить воркеров чтения выписок, которые работают до вызова
+// возвращаемой функции остановки. Задержки операций учитываются отдельно от переводов.
+// Число воркеров и seed берутся из параметров доли клиента settings.
+func (p *BasePayload) startStatementWorkers(
+	settings *config.DatabaseSettings,
+	history cluster.HistoryCluster,
+	stats *StatementStats,
+) (func(), error) {
+	clusterSettings, err := p.Cluster.FetchSettings()
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to fetch cluster settings")
+	}
+
+	llog.Infof("Running %d statement workers: last %d transfers per statement, "+
+		"%v of operations scan all accounts of a bic by %d per page",
+		settings.StatementWorkers, p.config.StatementLength, p.config.BankScanShare, p.config.ScanPageSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < settings.StatementWorkers; i++ {
+		var randSource fixed_random_source.FixedRandomSource
+		// поток воркера выписок отличается от потоков воркеров переводов
+		randSource.Init(clusterSettings.Count, clusterSettings.Seed,
+			p.config.BanRangeMultiplier, settings.Seed, i+settings.Workers)
+		if p.config.Enumerate {
+			randSource.SetEnumeration(0)
+		}
+		randSource.SetDistribution(p.config.KeyDistribution())
+
+		wg.Add(1)
+
+		go p.statementWorker(ctx, history, &randSource, stats, &wg)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
+
+// statementWorker - читать выписки случайных счетов и обходить счета случайных БИК,
+// пока не будет отменен контекст. Ошибки учитываются, но не останавливают воркер:
+// чтения продолжаются во время неисправностей, как и переводы.
+func (p *BasePayload) statementWorker(
+	ctx context.Context,
+	history cluster.HistoryCluster,
+	randSource *fixed_random_source.FixedRandomSource,
+	stats *StatementStats,
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+
+	// после ошибки воркер ждет, как повтор перевода, чтобы не нагружать недоступный кластер
+	sleepDuration := time.Millisecond
+
+	for ctx.Err() == nil {
+		bic, ban := randSource.BicAndBan()
+
+		var err error
+		if randSource.Float64() < p.config.BankScanShare {
+			err = p.scanBank(ctx, history, bic, stats)
+		} else {
+			err = p.fetchStatement(history, bic, ban, stats)
+		}
+
+		if err == nil {
+			sleepDuration = time.Millisecond
+
+			continue
+		}
+
+		atomic.AddUint64(&stats.errors, 1)
+		llog.Tracef("Statement worker sleeps %v after error: %v", sleepDuration, err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(sleepDuration):
+		}
+
+		sleepDuration *= 2
+		if sleepDuration > maxSleepDuration {
+			sleepDuration = maxSleepDuration
+		}
+	}
+}
+
+// fetchStatement - прочитать последние проводки счета.
+func (p *BasePayload) fetchStatement(
+	history cluster.HistoryCluster,
+	bic, ban string,
+	stats *StatementStats,
+) error {
+	cookie := statistics.StatsRequestStart()
+
+	entries, err := history.FetchStatement(bic, ban, p.config.StatementLength)
+	if err != nil {
+		return merry.Prependf(err, "failed to fetch statement of %s/%s", bic, ban)
+	}
+
+	statistics.StatsBackgroundOperationEnd(statementOperation, cookie)
+	atomic.AddUint64(&stats.statements, 1)
+	atomic.AddUint64(&stats.entries, uint64(len(entries)))
+
+	return nil
+}
+
+// scanBank - прочитать все счета БИК по страницам, каждая страница продолжает предыдущую
+// с последнего прочитанного номера счета.
+func (p *BasePayload) scanBank(
+	ctx context.Context,
+	history cluster.HistoryCluster,
+	bic string,
+	stats *StatementStats,
+) error {
+	afterBan := ""
+
+	for ctx.Err() == nil {
+		cookie := statistics.StatsRequestStart()
+
+		accounts, err := history.ScanBankAccounts(bic, afterBan, p.config.ScanPageSize)
+		if err != nil {
+			return merry.Prependf(err, "failed to scan accounts of %s after %q", bic, afterBan)
+		}
+
+		statistics.StatsBackgroundOperationEnd(bankScanPageOperation, cookie)
+		atomic.AddUint64(&stats.pages, 1)
+		atomic.AddUint64(&stats.accounts, uint64(len(accounts)))
+
+		if len(accounts) < p.config.ScanPageSize {
+			atomic.AddUint64(&stats.scans, 1)
+
+			return nil
+		}
+
+		afterBan = accounts[len(accounts)-1].Ban
+	}
+
+	return nil
+}
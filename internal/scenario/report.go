@@ -0,0 +1,309 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+const (
+	reportDateFormat = "02-01-2006_15_04_05"
+	reportFileMode   = 0o644
+)
+
+// Report - отчет о запуске сценария: фазы, события и ряд статистики на одной шкале,
+// смещения указаны в секундах от начала запуска
+type Report struct {
+	Scenario string         `json:"scenario"`
+	File     string         `json:"file,omitempty"`
+	DBType   string         `json:"dbtype"`
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Error    string         `json:"error,omitempty"`
+	Phases   []ReportPhase  `json:"phases"`
+	Events   []ReportEvent  `json:"events"`
+	Series   []ReportSample `json:"series"`
+}
+
+type ReportPhase struct {
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	StartOffset float64   `json:"start_offset"`
+	EndOffset   float64   `json:"end_offset"`
+	Error       string    `json:"error,omitempty"`
+	Isolation   string    `json:"isolation,omitempty"`
+	Locking     string    `json:"locking,omitempty"`
+
+	Summary *statistics.Summary `json:"summary,omitempty"`
+}
+
+type ReportEvent struct {
+	Time   time.Time `json:"time"`
+	Offset float64   `json:"offset"`
+	Phase  string    `json:"phase,omitempty"`
+	Event  string    `json:"event"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ReportSample - точка ряда статистики с фазой, в которую она попала
+type ReportSample struct {
+	statistics.Sample
+	Offset float64 `json:"offset"`
+	Phase  string  `json:"phase,omitempty"`
+}
+
+// Execute - выполнить сценарий с записью ряда статистики, вывести временную шкалу
+// и сохранить отчет в каталог dir или в ReportFile. Ряд статистики собирается только для шагов,
+// выполненных в этом процессе, шаги в поде stroppy дают фазы, события и итоги из отчета пода.
+func (s *Scenario) Execute(executor Executor, dir, dbType string) error {
+	statistics.StatsRecordSeries()
+
+	timeline, err := s.Run(executor)
+
+	report := newReport(s, dbType, timeline, statistics.StatsSeries(), err)
+	report.print()
+
+	path := s.ReportFile
+	if path == "" {
+		path = filepath.Join(dir, fmt.Sprintf("%s_run_%s_%s.json",
+			dbType, s.Name, timeline.Start.Format(reportDateFormat)))
+	}
+	if writeErr := report.Write(path); writeErr != nil {
+		llog.Errorf("failed to write run report: %v", writeErr)
+	} else {
+		llog.Infof("Run report saved to %s", path)
+	}
+
+	return err
+}
+
+func newReport(
+	s *Scenario,
+	dbType string,
+	timeline *Timeline,
+	samples []statistics.Sample,
+	err error,
+) *Report {
+	offset := func(t time.Time) float64 {
+		return t.Sub(timeline.Start).Seconds()
+	}
+
+	report := &Report{
+		Scenario: s.Name,
+		File:     s.Path,
+		DBType:   dbType,
+		Start:    timeline.Start,
+		End:      timeline.End,
+		Phases:   make([]ReportPhase, 0, len(timeline.Phases)),
+		Events:   make([]ReportEvent, 0, len(timeline.Events)),
+		Series:   make([]ReportSample, 0, len(samples)),
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	for _, phase := range timeline.Phases {
+		report.Phases = append(report.Phases, ReportPhase{
+			Name:        phase.Name,
+			Kind:        phase.Kind,
+			Start:       phase.Start,
+			End:         phase.End,
+			StartOffset: offset(phase.Start),
+			EndOffset:   offset(phase.End),
+			Error:       phase.Error,
+			Isolation:   phase.Isolation,
+			Locking:     phase.Locking,
+			Summary:     phase.Summary,
+		})
+	}
+
+	for _, event := range timeline.Events {
+		report.Events = append(report.Events, ReportEvent{
+			Time:   event.Time,
+			Offset: offset(event.Time),
+			Phase:  event.Phase,
+			Event:  event.Name,
+			Error:  event.Error,
+		})
+	}
+
+	for _, sample := range samples {
+		reportSample := ReportSample{Sample: sample, Offset: offset(sample.Time)} //nolint
+
+		for _, phase := range timeline.Phases {
+			if !sample.Time.Before(phase.Start) && !sample.Time.After(phase.End) {
+				reportSample.Phase = phase.Name
+
+				break
+			}
+		}
+
+		report.Series = append(report.Series, reportSample)
+	}
+
+	return report
+}
+
+// print - вывести фазы и события в журнал в порядке времени
+func (r *Report) print() {
+	llog.Infof("Scenario '%s' timeline:", r.Scenario)
+	llog.Infof("%9s %9s %-24s %s", "START", "END", "PHASE", "EVENT")
+
+	events := r.Events
+
+	for _, phase := range r.Phases {
+		for len(events) > 0 && events[0].Offset < phase.StartOffset {
+			r.printEvent(&events[0])
+			events = events[1:]
+		}
+
+		status := phase.Kind
+		if phase.Isolation != "" {
+			status += fmt.Sprintf(" (isolation %s, locking %s)", phase.Isolation, phase.Locking)
+		}
+
+		if phase.Error != "" {
+			status += " failed: " + phase.Error
+		}
+
+		llog.Infof("%8.1fs %8.1fs %-24s %s", phase.StartOffset, phase.EndOffset, phase.Name, status)
+	}
+
+	for i := range events {
+		r.printEvent(&events[i])
+	}
+}
+
+func (r *Report) printEvent(event *ReportEvent) {
+	name := event.Event
+	if event.Error != "" {
+		name += " failed: " + event.Error
+	}
+
+	llog.Infof("%8.1fs %9s %-24s %s", event.Offset, "", event.Phase, name)
+}
+
+// Write - сохранить отчет в файл json
+func (r *Report) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return merry.Prepend(err, "failed to marshal run report")
+	}
+
+	if err = os.WriteFile(path, data, reportFileMode); err != nil {
+		return merry.Prepend(err, "failed to write run report")
+	}
+
+	return nil
+}
+
+// MergeReports - объединить отчеты клиентов, одновременно выполнивших один сценарий:
+// итоги одноименных фаз объединяются по t-digest, события всех клиентов попадают на общую
+// шкалу. Ряды статистики клиентов не складываются, они остаются в отчетах клиентов.
+func MergeReports(reports []*Report) (*Report, error) {
+	if len(reports) == 0 {
+		return nil, merry.New("no reports to merge")
+	}
+
+	first := reports[0]
+	merged := &Report{
+		Scenario: first.Scenario,
+		File:     first.File,
+		DBType:   first.DBType,
+		Start:    first.Start,
+		End:      first.End,
+		Phases:   make([]ReportPhase, len(first.Phases)),
+		Events:   make([]ReportEvent, 0, len(first.Events)),
+		Series:   []ReportSample{},
+	}
+
+	copy(merged.Phases, first.Phases)
+
+	for client, report := range reports {
+		if len(report.Phases) != len(merged.Phases) {
+			return nil, merry.Errorf("client %d ran %d phases instead of %d",
+				client, len(report.Phases), len(merged.Phases))
+		}
+
+		if report.Start.Before(merged.Start) {
+			merged.Start = report.Start
+		}
+
+		if report.End.After(merged.End) {
+			merged.End = report.End
+		}
+
+		if report.Error != "" && merged.Error == "" {
+			merged.Error = fmt.Sprintf("client %d: %s", client, report.Error)
+		}
+
+		for i := range report.Phases {
+			phase, mergedPhase := &report.Phases[i], &merged.Phases[i]
+
+			if phase.Start.Before(mergedPhase.Start) {
+				mergedPhase.Start = phase.Start
+			}
+
+			if phase.End.After(mergedPhase.End) {
+				mergedPhase.End = phase.End
+			}
+
+			if phase.Error != "" && (client == 0 || mergedPhase.Error == "") {
+				mergedPhase.Error = fmt.Sprintf("client %d: %s", client, phase.Error)
+			}
+		}
+
+		for _, event := range report.Events {
+			event.Event = fmt.Sprintf("client %d: %s", client, event.Event)
+			merged.Events = append(merged.Events, event)
+		}
+	}
+
+	for i := range merged.Phases {
+		phase := &merged.Phases[i]
+		phase.StartOffset = phase.Start.Sub(merged.Start).Seconds()
+		phase.EndOffset = phase.End.Sub(merged.Start).Seconds()
+
+		if phase.Summary == nil {
+			continue
+		}
+
+		summaries := make([]statistics.Summary, 0, len(reports))
+		for _, report := range reports {
+			if report.Phases[i].Summary != nil {
+				summaries = append(summaries, *report.Phases[i].Summary)
+			}
+		}
+
+		summary, err := statistics.MergeSummaries(summaries)
+		if err != nil {
+			return nil, merry.Prependf(err, "failed to merge summaries of phase '%s'", phase.Name)
+		}
+
+		phase.Summary = &summary
+	}
+
+	sort.SliceStable(merged.Events, func(i, j int) bool {
+		return merged.Events[i].Time.Before(merged.Events[j].Time)
+	})
+
+	for i := range merged.Events {
+		merged.Events[i].Offset = merged.Events[i].Time.Sub(merged.Start).Seconds()
+	}
+
+	return merged, nil
+}
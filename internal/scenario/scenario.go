@@ -0,0 +1,647 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+	"gopkg.in/yaml.v3"
+)
+
+// Version - поддерживаемая версия формата сценария
+const Version = 1
+
+// виды шагов сценария
+const (
+	StepPop        = "pop"
+	StepPay        = "pay"
+	StepCheck      = "check"
+	StepChaosStart = "chaos start"
+	StepChaosStop  = "chaos stop"
+	StepSleep      = "sleep"
+)
+
+// connectionKeys - параметры подключения и совместного запуска клиентов, которые
+// задаются при запуске stroppy и не могут меняться от шага к шагу
+var connectionKeys = map[string]bool{
+	"dbtype":       true,
+	"url":          true,
+	"user":         true,
+	"password":     true,
+	"endpoints":    true,
+	"routing":      true,
+	"pool_size":    true,
+	"clients":      true,
+	"client_index": true,
+	"barrier":      true,
+}
+
+// ключи шагов pop и pay, которые не относятся к параметрам теста
+const (
+	nameKey  = "name"
+	chaosKey = "chaos"
+)
+
+// Step - шаг сценария с итоговыми параметрами
+type Step struct {
+	Kind string
+
+	// название фазы в журнале и отчете, по умолчанию вид и номер шага
+	Name string
+
+	// параметры pop, pay и check: базовые параметры, поверх них общие параметры
+	// сценария, поверх них параметры шага
+	Settings *config.DatabaseSettings
+
+	// сценарий chaos-mesh для chaos start
+	Chaos string
+
+	// длительность sleep
+	Duration time.Duration
+
+	// хаос, запускаемый и останавливаемый по времени внутри шага pop или pay
+	Events []TimedChaos
+}
+
+// TimedChaos - запуск или остановка хаоса через At после начала шага
+type TimedChaos struct {
+	At    time.Duration
+	Start string
+	Stop  bool
+}
+
+func (e *TimedChaos) String() string {
+	if e.Stop {
+		return StepChaosStop
+	}
+
+	return fmt.Sprintf("%s %s", StepChaosStart, e.Start)
+}
+
+func (s *Step) String() string {
+	switch s.Kind {
+	case StepPop:
+		return fmt.Sprintf("pop of %d accounts", s.Settings.Count)
+	case StepPay:
+		switch {
+		case s.Settings.Duration > 0 && s.Settings.Count <= 0:
+			return fmt.Sprintf("pay for %v", s.Settings.Duration)
+		case s.Settings.Duration > 0:
+			return fmt.Sprintf("pay of %d transfers for at most %v", s.Settings.Count, s.Settings.Duration)
+		default:
+			return fmt.Sprintf("pay of %d transfers", s.Settings.Count)
+		}
+	case StepChaosStart:
+		return fmt.Sprintf("chaos start %s", s.Chaos)
+	case StepSleep:
+		return fmt.Sprintf("sleep %v", s.Duration)
+	default:
+		return s.Kind
+	}
+}
+
+// Scenario - упорядоченный список шагов теста
+type Scenario struct {
+	Name  string
+	Path  string
+	Steps []Step
+
+	// сочетания значений матрицы параметров для серии запусков
+	Combinations []Combination
+
+	// ReportFile - файл отчета о запуске, по умолчанию имя строится из типа БД, имени и даты
+	ReportFile string
+}
+
+// Param - значение параметра матрицы в сочетании
+type Param struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Combination - одно сочетание значений матрицы: общие параметры с наложенными значениями
+// матрицы и шаги, в параметрах которых значения матрицы наложены поверх параметров шага
+type Combination struct {
+	Params   []Param
+	Settings *config.DatabaseSettings
+	Scenario *Scenario
+}
+
+// Executor - исполнитель шагов сценария: локально, в поде stroppy или из интерактивной оболочки
+type Executor interface {
+	Pop(settings *config.DatabaseSettings) error
+	Pay(settings *config.DatabaseSettings) error
+	Check(settings *config.DatabaseSettings) error
+	StartChaos(scenario string) error
+	StopChaos()
+}
+
+// StepSummarizer - исполнитель, который знает итоги последнего шага pop или pay,
+// итоги попадают в фазы отчета о запуске
+type StepSummarizer interface {
+	StepSummary() *statistics.Summary
+}
+
+// document - файл сценария в том виде, в котором он записан
+type document struct {
+	Version    int         `yaml:"version"`
+	Name       string      `yaml:"name"`
+	Settings   yaml.Node   `yaml:"settings"`
+	Matrix     yaml.Node   `yaml:"matrix"`
+	Repopulate bool        `yaml:"repopulate"`
+	Steps      []yaml.Node `yaml:"steps"`
+}
+
+type chaosSpec struct {
+	At    string `yaml:"at"`
+	Start string `yaml:"start"`
+	Stop  bool   `yaml:"stop"`
+}
+
+// Default - сценарий по умолчанию, если файл сценария отсутствует: загрузка счетов и переводы
+func Default(base *config.DatabaseSettings) *Scenario {
+	popSettings, paySettings := *base, *base
+
+	return &Scenario{
+		Name: "default",
+		Steps: []Step{
+			{Kind: StepPop, Name: "pop-1", Settings: &popSettings},
+			{Kind: StepPay, Name: "pay-2", Settings: &paySettings},
+		},
+	}
+}
+
+// Load - прочитать сценарий из файла YAML или JSON, параметры шагов накладываются на base
+func Load(path string, base *config.DatabaseSettings) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read scenario file")
+	}
+
+	scenario, err := Parse(data, base)
+	if err != nil {
+		return nil, merry.Prependf(err, "invalid scenario '%s'", path)
+	}
+
+	scenario.Path = path
+
+	return scenario, nil
+}
+
+// Parse - разобрать и проверить сценарий. JSON является подмножеством YAML,
+// поэтому оба формата читаются одним разборщиком, неизвестные ключи считаются ошибкой.
+func Parse(data []byte, base *config.DatabaseSettings) (*Scenario, error) {
+	var doc document
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, merry.Prepend(err, "failed to parse scenario")
+	}
+
+	if doc.Version != Version {
+		return nil, merry.Errorf("unsupported scenario version %d, expected %d", doc.Version, Version)
+	}
+
+	if len(doc.Steps) == 0 {
+		return nil, merry.New("scenario has no steps")
+	}
+
+	common := *base
+	if err := decodeSettings(&doc.Settings, &common, connectionKeys); err != nil {
+		return nil, merry.Prepend(err, "invalid scenario settings")
+	}
+
+	if doc.Name == "" {
+		doc.Name = "scenario"
+	}
+
+	steps, err := parseSteps(&doc, &common, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &Scenario{Name: doc.Name, Steps: steps}
+
+	if scenario.Combinations, err = parseMatrix(&doc, &common); err != nil {
+		return nil, merry.Prepend(err, "invalid matrix")
+	}
+
+	return scenario, nil
+}
+
+func parseSteps(doc *document, common *config.DatabaseSettings, overlay *yaml.Node, skipPop bool) ([]Step, error) {
+	steps := make([]Step, 0, len(doc.Steps))
+
+	for i := range doc.Steps {
+		step, err := parseStep(&doc.Steps[i], common, overlay)
+		if err != nil {
+			return nil, merry.Prependf(err, "step %d (line %d)", i+1, doc.Steps[i].Line)
+		}
+
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s-%d", strings.ReplaceAll(step.Kind, " ", "-"), i+1)
+		}
+
+		if skipPop && step.Kind == StepPop {
+			continue
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// parseMatrix - разобрать матрицу параметров "ключ: [значения]" и построить все сочетания,
+// первый ключ меняется реже всех. Размер пула соединений можно перебирать, остальные
+// параметры подключения нельзя. Без repopulate шаги pop выполняются только в первом сочетании.
+func parseMatrix(doc *document, common *config.DatabaseSettings) ([]Combination, error) {
+	if isEmpty(&doc.Matrix) {
+		return nil, nil
+	}
+
+	if doc.Matrix.Kind != yaml.MappingNode || len(doc.Matrix.Content) == 0 {
+		return nil, merry.Errorf("line %d: matrix must be a mapping of settings to lists of values", doc.Matrix.Line)
+	}
+
+	combinations := [][]*yaml.Node{nil}
+
+	for i := 0; i < len(doc.Matrix.Content); i += 2 {
+		key, values := doc.Matrix.Content[i], doc.Matrix.Content[i+1]
+
+		if values.Kind != yaml.SequenceNode || len(values.Content) == 0 {
+			return nil, merry.Errorf("line %d: values of '%s' must be a non-empty list", values.Line, key.Value)
+		}
+
+		next := make([][]*yaml.Node, 0, len(combinations)*len(values.Content))
+
+		for _, combination := range combinations {
+			for _, value := range values.Content {
+				pairs := append(append([]*yaml.Node(nil), combination...), key, value)
+				next = append(next, pairs)
+			}
+		}
+
+		combinations = next
+	}
+
+	result := make([]Combination, 0, len(combinations))
+
+	for i, pairs := range combinations {
+		overlay := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: pairs} //nolint
+
+		settings := *common
+		if err := decodeSettings(overlay, &settings, matrixForbiddenKeys()); err != nil {
+			return nil, err
+		}
+
+		steps, err := parseSteps(doc, common, overlay, i > 0 && !doc.Repopulate)
+		if err != nil {
+			return nil, merry.Prependf(err, "combination %d", i+1)
+		}
+
+		params := make([]Param, 0, len(pairs)/2)
+		for j := 0; j < len(pairs); j += 2 {
+			params = append(params, Param{Key: pairs[j].Value, Value: pairs[j+1].Value})
+		}
+
+		result = append(result, Combination{
+			Params:   params,
+			Settings: &settings,
+			Scenario: &Scenario{Name: doc.Name, Steps: steps}, //nolint
+		})
+	}
+
+	return result, nil
+}
+
+// matrixForbiddenKeys - параметры подключения, кроме размера пула соединений
+func matrixForbiddenKeys() map[string]bool {
+	forbidden := make(map[string]bool, len(connectionKeys))
+
+	for key := range connectionKeys {
+		if key != "pool_size" {
+			forbidden[key] = true
+		}
+	}
+
+	return forbidden
+}
+
+// parseStep - разобрать шаг: строку с видом шага без параметров ("- check")
+// или словарь из одного ключа с параметрами шага ("- pay: {count: 1000}")
+func parseStep(node *yaml.Node, common *config.DatabaseSettings, overlay *yaml.Node) (Step, error) {
+	var (
+		kind  string
+		value *yaml.Node
+	)
+
+	switch {
+	case node.Kind == yaml.ScalarNode:
+		kind = node.Value
+	case node.Kind == yaml.MappingNode && len(node.Content) == 2:
+		kind, value = node.Content[0].Value, node.Content[1]
+	default:
+		return Step{}, merry.New("step must be a step name or a mapping with exactly one step")
+	}
+
+	switch kind {
+	case StepPop, StepPay, StepCheck:
+		return parseTestStep(kind, value, common, overlay)
+
+	case chaosKey:
+		return parseChaos(value)
+
+	case StepSleep:
+		if isEmpty(value) || value.Kind != yaml.ScalarNode {
+			return Step{}, merry.New("sleep requires a duration, for example 'sleep: 30s'")
+		}
+
+		duration, err := time.ParseDuration(value.Value)
+		if err != nil {
+			return Step{}, merry.Prepend(err, "invalid sleep duration")
+		}
+
+		if duration <= 0 {
+			return Step{}, merry.Errorf("sleep duration must be positive, got %v", duration)
+		}
+
+		return Step{Kind: StepSleep, Duration: duration}, nil
+
+	default:
+		return Step{}, merry.Errorf(
+			"unknown step '%s', expected one of pop, pay, check, chaos or sleep", kind,
+		)
+	}
+}
+
+// parseTestStep - разобрать шаг pop, pay или check: параметры теста, название фазы
+// и для pop и pay список хаоса по времени от начала шага. Значения матрицы overlay
+// накладываются поверх параметров шага.
+func parseTestStep(kind string, value *yaml.Node, common *config.DatabaseSettings, overlay *yaml.Node) (Step, error) {
+	step := Step{Kind: kind}
+	settings := *common
+
+	if !isEmpty(value) && value.Kind == yaml.MappingNode {
+		params := *value
+		params.Content = nil
+
+		for i := 0; i < len(value.Content); i += 2 {
+			key, keyValue := value.Content[i], value.Content[i+1]
+
+			switch {
+			case key.Value == nameKey:
+				step.Name = keyValue.Value
+			case key.Value == chaosKey && kind != StepCheck:
+				events, err := parseTimedChaos(keyValue)
+				if err != nil {
+					return Step{}, err
+				}
+
+				step.Events = events
+			default:
+				params.Content = append(params.Content, key, keyValue)
+			}
+		}
+
+		value = &params
+	}
+
+	if err := decodeSettings(value, &settings, connectionKeys); err != nil {
+		return Step{}, err
+	}
+
+	if err := decodeSettings(overlay, &settings, matrixForbiddenKeys()); err != nil {
+		return Step{}, err
+	}
+
+	if kind != StepCheck && settings.Count <= 0 && (kind != StepPay || settings.Duration <= 0) {
+		return Step{}, merry.Errorf("%s count must be positive, got %d", kind, settings.Count)
+	}
+
+	for _, event := range step.Events {
+		if settings.Duration > 0 && event.At >= settings.Duration {
+			return Step{}, merry.Errorf(
+				"%s at %v is not within the step duration %v", event.String(), event.At, settings.Duration,
+			)
+		}
+	}
+
+	step.Settings = &settings
+
+	return step, nil
+}
+
+// parseTimedChaos - разобрать список хаоса внутри шага: "- {at: 3m, start: <сценарий>}"
+// или "- {at: 6m, stop: true}", события выполняются в порядке времени
+func parseTimedChaos(node *yaml.Node) ([]TimedChaos, error) {
+	if node.Kind != yaml.SequenceNode {
+		return nil, merry.Errorf("line %d: chaos of a step must be a list of {at, start} or {at, stop}", node.Line)
+	}
+
+	events := make([]TimedChaos, 0, len(node.Content))
+
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			return nil, merry.Errorf("line %d: chaos event must be a mapping with at and start or stop", item.Line)
+		}
+
+		for i := 0; i < len(item.Content); i += 2 {
+			if key := item.Content[i]; key.Value != "at" && key.Value != "start" && key.Value != "stop" {
+				return nil, merry.Errorf("line %d: unknown chaos key '%s', expected at, start or stop",
+					key.Line, key.Value)
+			}
+		}
+
+		var spec chaosSpec
+		if err := item.Decode(&spec); err != nil {
+			return nil, merry.Prepend(err, "invalid chaos event")
+		}
+
+		at, err := time.ParseDuration(spec.At)
+		if err != nil || at < 0 {
+			return nil, merry.Errorf("line %d: chaos event requires a non-negative 'at', for example 'at: 3m'", item.Line)
+		}
+
+		if (spec.Start == "") == !spec.Stop {
+			return nil, merry.Errorf("line %d: chaos event requires either start or stop", item.Line)
+		}
+
+		events = append(events, TimedChaos{At: at, Start: spec.Start, Stop: spec.Stop})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+
+	return events, nil
+}
+
+// parseChaos - разобрать шаг chaos: "chaos: {start: <сценарии через запятую>}" или "chaos: stop"
+func parseChaos(value *yaml.Node) (Step, error) {
+	var spec chaosSpec
+
+	switch {
+	case isEmpty(value):
+		return Step{}, merry.New("chaos requires 'start: <scenario>' or 'stop'")
+	case value.Kind == yaml.ScalarNode && value.Value == "stop":
+		spec.Stop = true
+	case value.Kind == yaml.MappingNode:
+		for i := 0; i < len(value.Content); i += 2 {
+			if key := value.Content[i]; key.Value != "start" && key.Value != "stop" {
+				return Step{}, merry.Errorf("line %d: unknown chaos key '%s', expected start or stop", key.Line, key.Value)
+			}
+		}
+
+		if err := value.Decode(&spec); err != nil {
+			return Step{}, merry.Prepend(err, "invalid chaos step")
+		}
+	default:
+		return Step{}, merry.Errorf("line %d: chaos requires 'start: <scenario>' or 'stop'", value.Line)
+	}
+
+	switch {
+	case spec.Start != "" && spec.Stop:
+		return Step{}, merry.New("chaos step can not both start and stop")
+	case spec.Start != "":
+		return Step{Kind: StepChaosStart, Chaos: spec.Start}, nil
+	case spec.Stop:
+		return Step{Kind: StepChaosStop}, nil
+	default:
+		return Step{}, merry.New("chaos requires 'start: <scenario>' or 'stop'")
+	}
+}
+
+// decodeSettings - наложить параметры из узла на settings. Ключи проверяются по тегам
+// config.DatabaseSettings заранее, чтобы в ошибке был номер строки исходного файла.
+func decodeSettings(node *yaml.Node, settings *config.DatabaseSettings, forbidden map[string]bool) error {
+	if isEmpty(node) {
+		return nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return merry.Errorf("line %d: settings must be a mapping", node.Line)
+	}
+
+	known := settingsKeys()
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+
+		if forbidden[key.Value] {
+			return merry.Errorf(
+				"line %d: '%s' is a connection setting and can not be set in a scenario, use the command flag",
+				key.Line, key.Value,
+			)
+		}
+
+		if !known[key.Value] {
+			return merry.Errorf("line %d: unknown setting '%s'", key.Line, key.Value)
+		}
+	}
+
+	if err := node.Decode(settings); err != nil {
+		return merry.Prepend(err, "invalid settings")
+	}
+
+	return nil
+}
+
+func isEmpty(node *yaml.Node) bool {
+	return node == nil || node.Kind == 0 || node.ShortTag() == "!!null"
+}
+
+// settingsKeys - ключи параметров по тегам yaml полей config.DatabaseSettings
+func settingsKeys() map[string]bool {
+	settingsType := reflect.TypeOf(config.DatabaseSettings{}) //nolint
+	keys := make(map[string]bool, settingsType.NumField())
+
+	for i := 0; i < settingsType.NumField(); i++ {
+		name := strings.Split(settingsType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+
+	return keys
+}
+
+// First - первый шаг указанного вида, nil если такого шага нет
+func (s *Scenario) First(kind string) *Step {
+	for i := range s.Steps {
+		if s.Steps[i].Kind == kind {
+			return &s.Steps[i]
+		}
+	}
+
+	return nil
+}
+
+// Validate - проверить параметры всех шагов и сочетаний матрицы до подключения к БД,
+// чтобы ошибка в середине сценария не обнаружилась после часов работы предыдущих шагов
+func (s *Scenario) Validate(settings *config.Settings) error {
+	if err := validateSteps(s.Steps, settings); err != nil {
+		return err
+	}
+
+	for i := range s.Combinations {
+		combination := &s.Combinations[i]
+
+		err := settings.ValidateStep(combination.Settings)
+		if err == nil {
+			err = validateSteps(combination.Scenario.Steps, settings)
+		}
+
+		if err != nil {
+			return merry.Prependf(err, "combination %d (%s)", i+1, formatParams(combination.Params))
+		}
+	}
+
+	return nil
+}
+
+func validateSteps(steps []Step, settings *config.Settings) error {
+	for i := range steps {
+		if steps[i].Settings == nil {
+			continue
+		}
+
+		if err := settings.ValidateStep(steps[i].Settings); err != nil {
+			return merry.Prependf(err, "step '%s'", steps[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// Override - наложить на параметры значения "ключ: значение", например из флагов команды
+// оболочки. Ключи и значения проверяются так же, как параметры шага в файле сценария.
+func Override(settings *config.DatabaseSettings, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	node := &yaml.Node{Kind: yaml.MappingNode} //nolint
+	for _, key := range keys {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},         //nolint
+			&yaml.Node{Kind: yaml.ScalarNode, Value: values[key]}, //nolint
+		)
+	}
+
+	return decodeSettings(node, settings, connectionKeys)
+}
@@ -0,0 +1,222 @@
+package scenario
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/picodata/stroppy/pkg/database/config"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "unknown document key",
+			data:     "version: 1\nsteps: [check]\nrepeat: 2\n",
+			expected: "field repeat not found",
+		},
+		{
+			name:     "unknown setting",
+			data:     "version: 1\nsettings:\n  wokers: 2\nsteps: [check]\n",
+			expected: "line 3: unknown setting 'wokers'",
+		},
+		{
+			name:     "unknown step setting",
+			data:     "version: 1\nsteps:\n  - pay:\n      count: 10\n      speed: 2\n",
+			expected: "line 5: unknown setting 'speed'",
+		},
+		{
+			name:     "unknown step",
+			data:     "version: 1\nsteps: [load]\n",
+			expected: "unknown step 'load'",
+		},
+		{
+			name:     "connection key in settings",
+			data:     "version: 1\nsettings:\n  url: postgres://localhost\nsteps: [check]\n",
+			expected: "'url' is a connection setting",
+		},
+		{
+			name:     "connection key in step",
+			data:     "version: 1\nsteps:\n  - pay:\n      count: 10\n      pool_size: 8\n",
+			expected: "'pool_size' is a connection setting",
+		},
+		{
+			name:     "connection key in matrix",
+			data:     "version: 1\nmatrix:\n  dbtype: [postgres, cockroach]\nsteps: [check]\n",
+			expected: "'dbtype' is a connection setting",
+		},
+		{
+			name:     "matrix values are not a list",
+			data:     "version: 1\nmatrix:\n  workers: 4\nsteps: [check]\n",
+			expected: "values of 'workers' must be a non-empty list",
+		},
+		{
+			name: "timed chaos after the step duration",
+			data: "version: 1\nsteps:\n  - pay:\n      duration: 5m\n" +
+				"      chaos:\n        - {at: 6m, start: pod-kill}\n",
+			expected: "is not within the step duration 5m0s",
+		},
+		{
+			name:     "timed chaos without start or stop",
+			data:     "version: 1\nsteps:\n  - pay:\n      count: 10\n      chaos:\n        - {at: 1m}\n",
+			expected: "chaos event requires either start or stop",
+		},
+		{
+			name:     "unsupported version",
+			data:     "version: 2\nsteps: [check]\n",
+			expected: "unsupported scenario version 2",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.data), config.DatabaseDefaults())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+		})
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	base := config.DatabaseDefaults()
+	base.Workers = 16
+
+	scenario, err := Parse([]byte(`
+version: 1
+name: layers
+settings:
+  count: 100
+steps:
+  - pop
+  - pay:
+      name: main
+      count: 200
+      duration: 10m
+      chaos:
+        - {at: 6m, stop: true}
+        - {at: 3m, start: pod-kill}
+  - sleep: 30s
+  - check
+`), base)
+	require.NoError(t, err)
+
+	require.Len(t, scenario.Steps, 4)
+	assert.Equal(t, "layers", scenario.Name)
+	assert.Empty(t, scenario.Combinations)
+
+	pop, pay, sleep, check := scenario.Steps[0], scenario.Steps[1], scenario.Steps[2], scenario.Steps[3]
+
+	assert.Equal(t, "pop-1", pop.Name)
+	assert.Equal(t, 100, pop.Settings.Count)
+	assert.Equal(t, 16, pop.Settings.Workers)
+
+	assert.Equal(t, "main", pay.Name)
+	assert.Equal(t, 200, pay.Settings.Count)
+	assert.Equal(t, 10*time.Minute, pay.Settings.Duration)
+	assert.Equal(t, []TimedChaos{
+		{At: 3 * time.Minute, Start: "pod-kill"},
+		{At: 6 * time.Minute, Stop: true},
+	}, pay.Events)
+
+	assert.Equal(t, StepSleep, sleep.Kind)
+	assert.Equal(t, 30*time.Second, sleep.Duration)
+	assert.Nil(t, sleep.Settings)
+
+	assert.Equal(t, "check-4", check.Name)
+
+	// параметры шагов не меняют параметры запуска
+	assert.Equal(t, 10000, base.Count)
+}
+
+func TestParseMatrix(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		repopulate bool
+		pops       []int
+	}{
+		{name: "pop in the first combination only", repopulate: false, pops: []int{1, 0, 0, 0}},
+		{name: "repopulate", repopulate: true, pops: []int{1, 1, 1, 1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := "version: 1\nmatrix:\n  workers: [4, 8]\n  pool_size: [10, 20]\n" +
+				"steps:\n  - pop: {count: 100}\n  - pay: {count: 1000, workers: 2}\n"
+			if test.repopulate {
+				data += "repopulate: true\n"
+			}
+
+			scenario, err := Parse([]byte(data), config.DatabaseDefaults())
+			require.NoError(t, err)
+			require.Len(t, scenario.Combinations, 4)
+
+			// первый ключ матрицы меняется реже всех, значения матрицы накладываются поверх шага
+			expected := []struct{ workers, poolSize int }{{4, 10}, {4, 20}, {8, 10}, {8, 20}}
+
+			for i, combination := range scenario.Combinations {
+				assert.Equal(t, []Param{
+					{Key: "workers", Value: strconv.Itoa(expected[i].workers)},
+					{Key: "pool_size", Value: strconv.Itoa(expected[i].poolSize)},
+				}, combination.Params)
+				assert.Equal(t, expected[i].workers, combination.Settings.Workers)
+				assert.Equal(t, expected[i].poolSize, combination.Settings.ConnectPoolSize)
+
+				pay := combination.Scenario.First(StepPay)
+				require.NotNil(t, pay)
+				assert.Equal(t, expected[i].workers, pay.Settings.Workers)
+				assert.Equal(t, expected[i].poolSize, pay.Settings.ConnectPoolSize)
+
+				pops := 0
+				for _, step := range combination.Scenario.Steps {
+					if step.Kind == StepPop {
+						pops++
+					}
+				}
+
+				assert.Equal(t, test.pops[i], pops, "pop steps of combination %d", i+1)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "valid",
+			data:     "version: 1\nmatrix:\n  workers: [2, 4]\nsteps:\n  - pay: {count: 10}\n",
+			expected: "",
+		},
+		{
+			name:     "step without workers",
+			data:     "version: 1\nsteps:\n  - pay: {count: 10, workers: 0}\n",
+			expected: "step 'pay-1'",
+		},
+		{
+			name:     "combination without workers",
+			data:     "version: 1\nmatrix:\n  workers: [2, 0]\nsteps:\n  - pay: {count: 10}\n",
+			expected: "combination 2 (workers=0)",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			scenario, err := Parse([]byte(test.data), config.DatabaseDefaults())
+			require.NoError(t, err)
+
+			err = scenario.Validate(config.DefaultSettings())
+			if test.expected == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expected)
+			assert.Contains(t, err.Error(), "workers count must be positive")
+		})
+	}
+}
@@ -0,0 +1,220 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package scenario
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// SweepExecutor - исполнитель шагов, запоминающий итоги последнего шага pay
+type SweepExecutor interface {
+	Executor
+	PaySummary() statistics.Summary
+}
+
+// SweepReport - итоги серии запусков сценария по всем сочетаниям матрицы
+type SweepReport struct {
+	Scenario string     `json:"scenario"`
+	File     string     `json:"file,omitempty"`
+	DBType   string     `json:"dbtype"`
+	Start    time.Time  `json:"start"`
+	End      time.Time  `json:"end"`
+	Rows     []SweepRow `json:"rows"`
+}
+
+// SweepRow - итоги последнего шага pay одного сочетания
+type SweepRow struct {
+	Params []Param `json:"params"`
+	statistics.Summary
+	Error string `json:"error,omitempty"`
+}
+
+// Sweep - выполнить сценарий для каждого сочетания матрицы, prepare вызывается перед
+// каждым сочетанием и возвращает исполнитель для него. Ошибка сочетания не прерывает
+// серию, она попадает в итоги. Итоги сохраняются в каталог dir в CSV и JSON.
+func (s *Scenario) Sweep(
+	prepare func(combination *Combination) (SweepExecutor, error),
+	dir, dbType string,
+) error {
+	if len(s.Combinations) == 0 {
+		return merry.Errorf("scenario '%s' has no matrix to sweep", s.Name)
+	}
+
+	report := &SweepReport{
+		Scenario: s.Name,
+		File:     s.Path,
+		DBType:   dbType,
+		Start:    time.Now(),
+		Rows:     make([]SweepRow, 0, len(s.Combinations)),
+	}
+
+	failed := 0
+
+	for i := range s.Combinations {
+		combination := &s.Combinations[i]
+
+		llog.Infof("Sweep '%s' combination %d/%d: %s",
+			s.Name, i+1, len(s.Combinations), formatParams(combination.Params))
+
+		row := SweepRow{Params: combination.Params} //nolint
+
+		executor, err := prepare(combination)
+		if err == nil {
+			_, err = combination.Scenario.Run(executor)
+			row.Summary = executor.PaySummary()
+		}
+
+		if err != nil {
+			llog.Errorf("combination %d failed: %v", i+1, err)
+
+			row.Error = err.Error()
+			failed++
+		}
+
+		report.Rows = append(report.Rows, row)
+	}
+
+	report.End = time.Now()
+	report.print()
+
+	base := filepath.Join(dir, fmt.Sprintf("%s_sweep_%s_%s",
+		dbType, s.Name, report.Start.Format(reportDateFormat)))
+
+	if err := report.writeCSV(base + ".csv"); err != nil {
+		llog.Errorf("failed to write sweep results: %v", err)
+	} else {
+		llog.Infof("Sweep results saved to %s.csv", base)
+	}
+
+	if err := report.writeJSON(base + ".json"); err != nil {
+		llog.Errorf("failed to write sweep results: %v", err)
+	} else {
+		llog.Infof("Sweep results saved to %s.json", base)
+	}
+
+	if failed > 0 {
+		return merry.Errorf("%d of %d combinations failed", failed, len(s.Combinations))
+	}
+
+	return nil
+}
+
+func formatParams(params []Param) string {
+	pairs := make([]string, 0, len(params))
+	for _, param := range params {
+		pairs = append(pairs, param.Key+"="+param.Value)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+var sweepColumns = []string{ //nolint
+	"requests", "duration", "throughput",
+	"latency_min", "latency_avg", "latency_p50", "latency_p95",
+	"latency_p99", "latency_p999", "latency_max", "error",
+}
+
+func (r *SweepReport) header() []string {
+	header := make([]string, 0, len(sweepColumns)+len(r.Rows[0].Params))
+	for _, param := range r.Rows[0].Params {
+		header = append(header, param.Key)
+	}
+
+	return append(header, sweepColumns...)
+}
+
+func (row *SweepRow) record() []string {
+	float := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 6, 64) //nolint
+	}
+
+	record := make([]string, 0, len(sweepColumns)+len(row.Params))
+	for _, param := range row.Params {
+		record = append(record, param.Value)
+	}
+
+	return append(record,
+		strconv.FormatInt(row.Requests, 10), //nolint
+		float(row.Duration),
+		float(row.Throughput),
+		float(row.LatencyMin),
+		float(row.LatencyAvg),
+		float(row.LatencyP50),
+		float(row.LatencyP95),
+		float(row.LatencyP99),
+		float(row.LatencyP999),
+		float(row.LatencyMax),
+		row.Error,
+	)
+}
+
+// print - вывести итоги в журнал таблицей, задержки в миллисекундах
+func (r *SweepReport) print() {
+	llog.Infof("Sweep '%s' results:", r.Scenario)
+	llog.Infof("%-40s %10s %10s %9s %9s %9s %9s",
+		"PARAMETERS", "REQUESTS", "T/SEC", "P50, ms", "P95, ms", "P99, ms", "MAX, ms")
+
+	for i := range r.Rows {
+		row := &r.Rows[i]
+
+		status := ""
+		if row.Error != "" {
+			status = "failed: " + row.Error
+		}
+
+		llog.Infof("%-40s %10d %10.1f %9.2f %9.2f %9.2f %9.2f %s",
+			formatParams(row.Params), row.Requests, row.Throughput,
+			row.LatencyP50*1000, row.LatencyP95*1000, row.LatencyP99*1000, row.LatencyMax*1000, //nolint
+			status)
+	}
+}
+
+func (r *SweepReport) writeCSV(path string) error {
+	var buffer bytes.Buffer
+
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write(r.header()); err != nil {
+		return merry.Prepend(err, "failed to format sweep results")
+	}
+
+	for i := range r.Rows {
+		if err := writer.Write(r.Rows[i].record()); err != nil {
+			return merry.Prepend(err, "failed to format sweep results")
+		}
+	}
+
+	writer.Flush()
+
+	if err := os.WriteFile(path, buffer.Bytes(), reportFileMode); err != nil {
+		return merry.Prepend(err, "failed to write sweep results")
+	}
+
+	return nil
+}
+
+func (r *SweepReport) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return merry.Prepend(err, "failed to marshal sweep results")
+	}
+
+	if err = os.WriteFile(path, data, reportFileMode); err != nil {
+		return merry.Prepend(err, "failed to write sweep results")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package scenario
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// Phase - выполненный шаг сценария
+type Phase struct {
+	Name  string
+	Kind  string
+	Start time.Time
+	End   time.Time
+	Error string
+
+	// уровень изоляции и режим блокировок переводов шага pay в SQL БД
+	Isolation string
+	Locking   string
+
+	Summary *statistics.Summary
+}
+
+// Event - событие внутри сценария: запуск или остановка хаоса
+type Event struct {
+	Time  time.Time
+	Phase string
+	Name  string
+	Error string
+}
+
+// Timeline - временная шкала выполнения сценария
+type Timeline struct {
+	Start  time.Time
+	End    time.Time
+	Phases []Phase
+	Events []Event
+}
+
+// runner - выполнение сценария: события хаоса по времени приходят из таймеров,
+// поэтому состояние хаоса и шкала защищены мьютексом
+type runner struct {
+	sync.Mutex
+
+	executor Executor
+	timeline Timeline
+
+	chaosRunning bool
+	phaseChaos   bool
+	phaseDone    bool
+}
+
+// Run - выполнить шаги сценария по порядку и вернуть временную шкалу. Первая ошибка
+// прерывает сценарий, запущенный сценарием хаос при этом останавливается.
+func (s *Scenario) Run(executor Executor) (*Timeline, error) {
+	r := &runner{executor: executor}
+	r.timeline.Start = time.Now()
+
+	var err error
+
+	for i := range s.Steps {
+		step := &s.Steps[i]
+
+		llog.Infof("Scenario '%s' phase %d/%d '%s': %s", s.Name, i+1, len(s.Steps), step.Name, step)
+
+		if err = r.runPhase(step); err != nil {
+			err = merry.Prependf(err, "phase %d '%s' (%s) failed", i+1, step.Name, step)
+
+			break
+		}
+	}
+
+	r.Lock()
+	if err != nil && r.chaosRunning {
+		r.stopChaos("", "chaos stop (scenario failed)")
+	}
+	r.timeline.End = time.Now()
+	r.Unlock()
+
+	return &r.timeline, err
+}
+
+func (r *runner) runPhase(step *Step) error {
+	phase := Phase{Name: step.Name, Kind: step.Kind, Start: time.Now()} //nolint
+
+	if step.Kind == StepPay {
+		capabilities, err := cluster.GetCapabilities(step.Settings.DBType, step.Settings.DBURL)
+		if err == nil && capabilities.SQLTx {
+			phase.Isolation, phase.Locking = step.Settings.Isolation, step.Settings.Locking
+		}
+	}
+
+	r.Lock()
+	r.phaseChaos, r.phaseDone = false, false
+	r.Unlock()
+
+	timers := r.schedule(step)
+
+	var err error
+
+	switch step.Kind {
+	case StepPop:
+		err = r.executor.Pop(step.Settings)
+	case StepPay:
+		err = r.executor.Pay(step.Settings)
+	case StepCheck:
+		err = r.executor.Check(step.Settings)
+	case StepChaosStart:
+		r.Lock()
+		err = r.startChaos(step.Name, step.Chaos)
+		r.Unlock()
+	case StepChaosStop:
+		r.Lock()
+		r.stopChaos(step.Name, StepChaosStop)
+		r.Unlock()
+	case StepSleep:
+		time.Sleep(step.Duration)
+	}
+
+	for _, timer := range timers {
+		timer.Stop()
+	}
+
+	r.Lock()
+	r.phaseDone = true
+	// хаос, запущенный по времени внутри фазы, не переходит в следующую фазу
+	if r.phaseChaos && r.chaosRunning {
+		r.stopChaos(step.Name, "chaos stop (end of phase)")
+	}
+	r.Unlock()
+
+	phase.End = time.Now()
+	if err != nil {
+		phase.Error = err.Error()
+	}
+
+	if summarizer, ok := r.executor.(StepSummarizer); ok && err == nil &&
+		(step.Kind == StepPop || step.Kind == StepPay) {
+		phase.Summary = summarizer.StepSummary()
+	}
+
+	r.Lock()
+	r.timeline.Phases = append(r.timeline.Phases, phase)
+	r.Unlock()
+
+	return err
+}
+
+// schedule - запустить таймеры хаоса шага, событие после окончания фазы не выполняется
+func (r *runner) schedule(step *Step) []*time.Timer {
+	timers := make([]*time.Timer, 0, len(step.Events))
+
+	for i := range step.Events {
+		event := step.Events[i]
+
+		timers = append(timers, time.AfterFunc(event.At, func() {
+			r.Lock()
+			defer r.Unlock()
+
+			if r.phaseDone {
+				return
+			}
+
+			if event.Stop {
+				r.stopChaos(step.Name, event.String())
+
+				return
+			}
+
+			if err := r.startChaos(step.Name, event.Start); err != nil {
+				llog.Errorf("phase '%s': %v", step.Name, err)
+			}
+
+			r.phaseChaos = true
+		}))
+	}
+
+	return timers
+}
+
+// startChaos и stopChaos вызываются под мьютексом runner
+func (r *runner) startChaos(phase, chaosScenario string) error {
+	err := r.executor.StartChaos(chaosScenario)
+
+	event := Event{Time: time.Now(), Phase: phase, Name: StepChaosStart + " " + chaosScenario} //nolint
+	if err != nil {
+		event.Error = err.Error()
+	} else {
+		r.chaosRunning = true
+	}
+
+	r.timeline.Events = append(r.timeline.Events, event)
+
+	return err
+}
+
+func (r *runner) stopChaos(phase, name string) {
+	r.executor.StopChaos()
+	r.chaosRunning = false
+
+	r.timeline.Events = append(r.timeline.Events, Event{Time: time.Now(), Phase: phase, Name: name}) //nolint
+}
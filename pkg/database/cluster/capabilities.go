@@ -0,0 +1,77 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"strings"
+
+	"github.com/ansel1/merry"
+	"gitlab.com/picodata/stroppy/pkg/database"
+)
+
+// Capabilities - возможности драйвера кластера, по ним параметры проверяются до подключения
+type Capabilities struct {
+	Name string
+
+	Oracle        bool
+	Journal       bool
+	MultiCurrency bool
+	History       bool
+	KV            bool
+
+	// уровень изоляции, стратегия блокировки и несколько адресов с маршрутизацией
+	SQLTx   bool
+	Routing bool
+	Sharded bool
+
+	// драйвер не подставляет адрес по умолчанию, без --url подключиться нельзя
+	RequiresURL bool
+}
+
+// GetCapabilities - возможности драйвера для типа БД, для cartridge драйвер выбирается по url.
+// Поддержка необязательных возможностей определяется по интерфейсам, которые реализует драйвер.
+func GetCapabilities(dbType, dbURL string) (Capabilities, error) {
+	var (
+		driver       interface{}
+		capabilities Capabilities
+	)
+
+	switch dbType {
+	case Postgres:
+		driver = (*PostgresCluster)(nil)
+		capabilities = Capabilities{Name: "postgres", SQLTx: true, Routing: true} //nolint
+	case Cockroach:
+		driver = (*CockroachDatabase)(nil)
+		capabilities = Capabilities{Name: "cockroach", SQLTx: true, Routing: true, RequiresURL: true} //nolint
+	case Foundation:
+		driver = (*FDBCluster)(nil)
+		capabilities = Capabilities{Name: "fdb"} //nolint
+	case MongoDB:
+		driver = (*MongoDBCluster)(nil)
+		capabilities = Capabilities{Name: "mongodb", Sharded: true} //nolint
+	case Cartridge:
+		if strings.HasPrefix(dbURL, CartridgeIprotoScheme+"://") {
+			driver = (*CartridgeIprotoCluster)(nil)
+			capabilities = Capabilities{Name: "cartridge (iproto)"} //nolint
+		} else {
+			driver = (*CartridgeCluster)(nil)
+			capabilities = Capabilities{Name: "cartridge (http)", Sharded: true} //nolint
+		}
+	case YandexDB:
+		driver = (*YandexDBCluster)(nil)
+		capabilities = Capabilities{Name: "ydb"} //nolint
+	default:
+		return Capabilities{}, merry.Errorf("unknown database type '%s', expected one of %s",
+			dbType, strings.Join([]string{Postgres, Cockroach, Foundation, MongoDB, Cartridge, YandexDB}, ", "))
+	}
+
+	_, capabilities.Oracle = driver.(database.PredictableCluster)
+	_, capabilities.Journal = driver.(JournaledCluster)
+	_, capabilities.MultiCurrency = driver.(MultiCurrencyCluster)
+	_, capabilities.History = driver.(HistoryCluster)
+	_, capabilities.KV = driver.(KVCluster)
+
+	return capabilities, nil
+}
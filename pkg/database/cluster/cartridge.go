@@ -6,12 +6,15 @@ package cluster
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tarantool/go-tarantool"
@@ -23,6 +26,8 @@ import (
 	"gopkg.in/inf.v0"
 )
 
+const cartridgeStatJsonFileTemplate = "cartridge_metrics_%v.jsonl"
+
 // CartridgeCluster - объявление соединения к FDB и ссылки на модель данных.
 //nolint:golint,structcheck
 type CartridgeCluster struct {
@@ -39,6 +44,11 @@ type account struct {
 	PendingAmount   int64     `json:"pending_amount"`
 	PendingTransfer uuid.UUID `json:"pending_transfer"`
 	Found           bool      `json:"found"`
+	// атрибуты счета передаются, только если счета загружаются с ними
+	Name     string `json:"name,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Padding  string `json:"padding,omitempty"`
 }
 
 type transferMessage struct {
@@ -204,6 +214,12 @@ func (cluster *CartridgeCluster) InsertAccount(acc model.Account) (err error) {
 		Balance: acc.Balance.UnscaledBig().Int64(),
 		Found:   false,
 	}
+	if acc.Payload != nil {
+		account.Name = acc.Payload.Name
+		account.Currency = acc.Payload.Currency
+		account.Status = acc.Payload.Status
+		account.Padding = acc.Payload.Padding
+	}
 
 	account_json, err := json.Marshal(account)
 	if err != nil {
@@ -458,7 +474,104 @@ func (cluster *CartridgeCluster) FetchBalance(bic string, ban string) (*inf.Dec,
 	return nil, nil, nil
 }
 
-func (cluster *CartridgeCluster) StartStatisticsCollect(statInterval time.Duration) error {
-	llog.Infoln("collect statistic from cartridge db is not implemented")
-	return nil
+// StartStatisticsCollect - периодически сохранять метрики роли cartridge.roles.metrics,
+// опубликованные в формате prometheus по адресу /metrics, пока не будет отменен контекст.
+func (cluster *CartridgeCluster) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     Cartridge,
+		fileTemplate: cartridgeStatJsonFileTemplate,
+		collect:      cluster.fetchMetrics,
+		extract: func(data interface{}) map[string]float64 {
+			metrics, _ := data.(map[string]interface{})
+			values := make(map[string]float64)
+
+			for series, value := range metrics {
+				name := series
+				if labels := strings.Index(series, "{"); labels >= 0 {
+					name = series[:labels]
+				}
+
+				if number, ok := value.(float64); ok {
+					addCartridgeMetric(values, name, number)
+				}
+			}
+
+			return values
+		},
+	})
+}
+
+// addCartridgeMetric - учесть значение ряда, если он входит в выбранные для временного ряда запуска,
+// ряды с разными метками (операция, экземпляр) суммируются.
+func addCartridgeMetric(values map[string]float64, name string, value float64) {
+	switch name {
+	case "tnt_net_requests_total", "tnt_stats_op_total", "tnt_info_memory_data":
+		values["cartridge."+name] += value
+	}
+}
+
+func (cluster *CartridgeCluster) fetchMetrics() (interface{}, error) {
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/metrics", cluster.url), nil)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create request to fetch metrics in cartridge app")
+	}
+
+	resp, err := cluster.client.Do(request)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to make request to fetch metrics in cartridge app")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, merry.Errorf("failed to fetch metrics in cartridge app, status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read metrics from cartridge app response")
+	}
+
+	return parsePrometheusMetrics(string(body)), nil
+}
+
+// parsePrometheusMetrics - разобрать текстовый формат prometheus в словарь
+// "имя ряда с метками - значение". Нечисловые значения (NaN, Inf) пропускаются.
+func parsePrometheusMetrics(text string) map[string]float64 {
+	metrics := make(map[string]float64)
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// метки могут содержать пробелы, поэтому значение ищем после закрывающей скобки
+		nameEnd := strings.LastIndex(line, "}") + 1
+		if nameEnd == 0 {
+			nameEnd = strings.Index(line, " ")
+		}
+		if nameEnd <= 0 {
+			continue
+		}
+
+		fields := strings.Fields(line[nameEnd:])
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		metrics[line[:nameEnd]] = value
+	}
+
+	return metrics
+}
+
+// Close - закрыть простаивающие http-соединения к роутерам.
+func (cluster *CartridgeCluster) Close() {
+	cluster.client.CloseIdleConnections()
 }
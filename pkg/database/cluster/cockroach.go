@@ -7,7 +7,6 @@ package cluster
 import (
 	"context"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ansel1/merry"
@@ -24,12 +23,17 @@ import (
 )
 
 type CockroachDatabase struct {
-	pool *pgxpool.Pool
-	ctxt context.Context
+	router         *sqlRouter
+	ctxt           context.Context
+	txSettings     SQLTxSettings
+	schemaTemplate string
+	journal        bool
+	multiCurrency  bool
+	history        bool
 }
 
 func (cockroach *CockroachDatabase) InsertTransfer(transfer *model.Transfer) error {
-	res, err := cockroach.pool.Exec(
+	res, err := cockroach.router.exec(
 		context.Background(),
 		insertTransfer,
 		transfer.Id,
@@ -50,7 +54,7 @@ func (cockroach *CockroachDatabase) InsertTransfer(transfer *model.Transfer) err
 }
 
 func (cockroach *CockroachDatabase) DeleteTransfer(transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := cockroach.pool.Exec(context.Background(), deleteTransfer, transferId, clientId)
+	res, err := cockroach.router.exec(context.Background(), deleteTransfer, transferId, clientId)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return ErrNoRows
@@ -65,7 +69,7 @@ func (cockroach *CockroachDatabase) DeleteTransfer(transferId model.TransferId,
 }
 
 func (cockroach *CockroachDatabase) SetTransferClient(clientId uuid.UUID, transferId model.TransferId) error {
-	res, err := cockroach.pool.Exec(context.Background(), setTransferClient, clientId, transferId)
+	res, err := cockroach.router.exec(context.Background(), setTransferClient, clientId, transferId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -77,7 +81,7 @@ func (cockroach *CockroachDatabase) SetTransferClient(clientId uuid.UUID, transf
 }
 
 func (cockroach *CockroachDatabase) FetchTransferClient(transferId model.TransferId) (*uuid.UUID, error) {
-	row := cockroach.pool.QueryRow(context.Background(), fetchTransferClient, transferId)
+	row := cockroach.router.queryRow(context.Background(), fetchTransferClient, transferId)
 
 	var clientId uuid.UUID
 	err := row.Scan(&clientId)
@@ -92,7 +96,7 @@ func (cockroach *CockroachDatabase) FetchTransferClient(transferId model.Transfe
 }
 
 func (cockroach *CockroachDatabase) ClearTransferClient(transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := cockroach.pool.Exec(context.Background(), clearTransferClient, transferId, clientId)
+	res, err := cockroach.router.exec(context.Background(), clearTransferClient, transferId, clientId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -106,7 +110,7 @@ func (cockroach *CockroachDatabase) ClearTransferClient(transferId model.Transfe
 }
 
 func (cockroach *CockroachDatabase) SetTransferState(state string, transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := cockroach.pool.Exec(context.Background(), setTransferState, state, transferId, clientId)
+	res, err := cockroach.router.exec(context.Background(), setTransferState, state, transferId, clientId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -120,7 +124,7 @@ func (cockroach *CockroachDatabase) SetTransferState(state string, transferId mo
 func (cockroach *CockroachDatabase) FetchTransfer(transferId model.TransferId) (*model.Transfer, error) {
 	t := new(model.Transfer)
 	t.InitEmptyTransfer(transferId)
-	row := cockroach.pool.QueryRow(context.Background(), fetchTransfer, transferId)
+	row := cockroach.router.queryRow(context.Background(), fetchTransfer, transferId)
 	// Ignore possible error, we will retry
 	var amount int64
 	if err := row.Scan(&t.Acs[0].Bic, &t.Acs[0].Ban, &t.Acs[1].Bic,
@@ -135,7 +139,7 @@ func (cockroach *CockroachDatabase) FetchTransfer(transferId model.TransferId) (
 }
 
 func (cockroach *CockroachDatabase) FetchDeadTransfers() ([]model.TransferId, error) {
-	rows, err := cockroach.pool.Query(context.Background(), fetchDeadTransfers)
+	rows, err := cockroach.router.query(context.Background(), fetchDeadTransfers)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return []model.TransferId{}, nil
@@ -169,49 +173,60 @@ func (cockroach *CockroachDatabase) UnlockAccount(bic string, ban string, transf
 	panic("implement me")
 }
 
-func NewCockroachCluster(dbURL string, connectionPoolSize int) (cluster *CockroachDatabase, err error) {
+func NewCockroachCluster(
+	dbURL string,
+	connectionPoolSize int,
+	txSettings SQLTxSettings,
+	routing RoutingSettings,
+) (cluster *CockroachDatabase, err error) {
 	llog.Infof("Establishing connection to cockroach on %v", dbURL)
 
-	var poolConfig *pgxpool.Config
-	if poolConfig, err = pgxpool.ParseConfig(dbURL); err != nil {
-		err = merry.Prepend(err, "parse url parameter")
+	if err = txSettings.Validate(); err != nil {
+		err = merry.Prepend(err, "invalid transaction settings")
 		return
 	}
 
-	if !strings.Contains(dbURL, "pool_max_conns") {
-		poolConfig.MaxConns = int32(connectionPoolSize)
-	}
-
-	llog.Debugf("Connection pool size: %v", poolConfig.MaxConns)
-
 	ctxt := context.Background()
 
-	var pgPool *pgxpool.Pool
-	if pgPool, err = pgxpool.ConnectConfig(ctxt, poolConfig); err != nil {
-		err = merry.Prepend(err, "connection")
+	var router *sqlRouter
+	if router, err = newSQLRouter(ctxt, dbURL, connectionPoolSize, routing); err != nil {
 		return
 	}
 
 	cluster = &CockroachDatabase{
-		pool: pgPool,
-		ctxt: ctxt,
+		router:     router,
+		ctxt:       ctxt,
+		txSettings: txSettings,
 	}
 	return
 }
 
+// SetSchemaTemplate - задать пользовательский шаблон скрипта инициализации.
+func (cockroach *CockroachDatabase) SetSchemaTemplate(schemaTemplate string) {
+	cockroach.schemaTemplate = schemaTemplate
+}
+
+// pool - пул соединений к адресу, на который направляются изменения. Запросы драйвера
+// идут через методы маршрутизатора, которые учитывают их результат, пул нужен тестам.
+func (cockroach *CockroachDatabase) pool() *pgxpool.Pool {
+	return cockroach.router.write().pool
+}
+
 func (cockroach *CockroachDatabase) BootstrapDB(count int, seed int) (err error) {
 	llog.Infof("Bootstrapping cluster...")
-	if _, err = cockroach.pool.Exec(cockroach.ctxt, bootstrapScript); err != nil {
-		return merry.Prepend(err, "failed to execute bootstrap script")
+	if err = cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return bootstrapSQLSchema(cockroach.ctxt, pool, cockroach.schemaTemplate, count, seed)
+	}); err != nil {
+		return merry.Prepend(err, "failed to bootstrap schema")
 	}
 
 	llog.Infof("Loading settings...")
-	_, err = cockroach.pool.Exec(cockroach.ctxt, insertSetting, "count", strconv.Itoa(count))
+	_, err = cockroach.router.exec(cockroach.ctxt, insertSetting, "count", strconv.Itoa(count))
 	if err != nil {
 		return merry.Prepend(err, "failed to load count setting")
 	}
 
-	_, err = cockroach.pool.Exec(cockroach.ctxt, insertSetting, "seed", strconv.Itoa(seed))
+	_, err = cockroach.router.exec(cockroach.ctxt, insertSetting, "seed", strconv.Itoa(seed))
 	if err != nil {
 		return merry.Prepend(err, "failed to load seed setting")
 	}
@@ -230,7 +245,7 @@ func (cockroach *CockroachDatabase) FetchSettings() (clusterSettings Settings, e
 	defer cancel()
 
 	var rows pgx.Rows
-	rows, err = cockroach.pool.Query(ctx, fetchSettings)
+	rows, err = cockroach.router.query(ctx, fetchSettings)
 	if err != nil {
 		return Settings{
 			Count: 0,
@@ -269,7 +284,8 @@ func (cockroach *CockroachDatabase) FetchSettings() (clusterSettings Settings, e
 }
 
 func (cockroach *CockroachDatabase) InsertAccount(acc model.Account) (err error) {
-	_, err = cockroach.pool.Exec(cockroach.ctxt, upsertAccount, acc.Bic, acc.Ban, acc.Balance.UnscaledBig().Int64())
+	query, args := accountInsertQuery(acc)
+	_, err = cockroach.router.exec(cockroach.ctxt, query, args...)
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			if pgErr.Code == pgerrcode.UniqueViolation {
@@ -283,7 +299,7 @@ func (cockroach *CockroachDatabase) InsertAccount(acc model.Account) (err error)
 }
 
 func (cockroach *CockroachDatabase) FetchTotal() (*inf.Dec, error) {
-	row := cockroach.pool.QueryRow(context.Background(), fetchTotal)
+	row := cockroach.router.queryRow(context.Background(), fetchTotal)
 
 	var amount inf.Dec
 	if err := row.Scan(&amount); err != nil {
@@ -297,7 +313,7 @@ func (cockroach *CockroachDatabase) FetchTotal() (*inf.Dec, error) {
 }
 
 func (cockroach *CockroachDatabase) PersistTotal(total inf.Dec) error {
-	res, err := cockroach.pool.Exec(context.Background(), persistTotal, total.UnscaledBig().Int64())
+	res, err := cockroach.router.exec(context.Background(), persistTotal, total.UnscaledBig().Int64())
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -309,7 +325,7 @@ func (cockroach *CockroachDatabase) PersistTotal(total inf.Dec) error {
 }
 
 func (cockroach *CockroachDatabase) CheckBalance() (*inf.Dec, error) {
-	row := cockroach.pool.QueryRow(context.Background(), checkBalance)
+	row := cockroach.router.queryRow(context.Background(), checkBalance)
 	var totalBalance int64
 	err := row.Scan(&totalBalance)
 	if err != nil {
@@ -325,14 +341,23 @@ func (cockroach *CockroachDatabase) CheckBalance() (*inf.Dec, error) {
 const cockroachTxTimeout = 45 * time.Second
 
 func (cockroach *CockroachDatabase) MakeAtomicTransfer(transfer *model.Transfer, clientId uuid.UUID) error {
+	return cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return cockroach.makeAtomicTransfer(pool, transfer)
+	})
+}
+
+func (cockroach *CockroachDatabase) makeAtomicTransfer(pool *pgxpool.Pool, transfer *model.Transfer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), cockroachTxTimeout)
 	defer cancel()
 
-	// RepeatableRead is sufficient to provide consistent balance update even though
+	// RepeatableRead (default) is sufficient to provide consistent balance update even though
 	// serialization anomalies are allowed that should not affect us (no dependable transaction, except obviously blocked rows)
-	tx, err := cockroach.pool.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel: pgx.RepeatableRead,
-	})
+	txOptions, err := cockroach.txSettings.TxOptions()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	tx, err := pool.BeginTx(ctx, txOptions)
 	if err != nil {
 		return merry.Prepend(err, "failed to acquire tx")
 	}
@@ -368,6 +393,25 @@ func (cockroach *CockroachDatabase) MakeAtomicTransfer(transfer *model.Transfer,
 		return merry.Prepend(err, "failed to insert transfer")
 	}
 
+	if cockroach.multiCurrency {
+		if err = ConvertTransferAmount(ctx, tx, transfer); err != nil {
+			return merry.Prepend(err, "failed to convert transfer amount")
+		}
+	}
+
+	switch cockroach.txSettings.Locking {
+	case LockingOptimistic:
+		if err = CompareAndSetMoney(ctx, tx, *transfer); err != nil {
+			return merry.Prepend(err, "failed to make optimistic transfer")
+		}
+
+		return cockroach.commitTransfer(ctx, tx, transfer)
+	case LockingForUpdate:
+		if err = LockAccountsForUpdate(ctx, tx, *transfer); err != nil {
+			return merry.Prepend(err, "failed to lock accounts")
+		}
+	}
+
 	//	If we always withdraw money first deadlock may occur.
 	//	Imagine we have concurrent txA (transfer X -> Y) and txB (transfer Y -> X).
 	//	We will see the following timeline:
@@ -408,7 +452,23 @@ func (cockroach *CockroachDatabase) MakeAtomicTransfer(transfer *model.Transfer,
 		}
 	}
 
-	if err = tx.Commit(ctx); err != nil {
+	return cockroach.commitTransfer(ctx, tx, transfer)
+}
+
+func (cockroach *CockroachDatabase) commitTransfer(ctx context.Context, tx pgx.Tx, transfer *model.Transfer) error {
+	if cockroach.journal {
+		if err := AppendJournal(ctx, tx, transfer); err != nil {
+			return merry.Prepend(err, "failed to journal transfer")
+		}
+	}
+
+	if cockroach.history {
+		if err := AppendHistory(ctx, tx, transfer, time.Now().UTC()); err != nil {
+			return merry.Prepend(err, "failed to record transfer history")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			if pgerrcode.IsTransactionRollback(pgErr.Code) {
 				return ErrTxRollback
@@ -421,27 +481,18 @@ func (cockroach *CockroachDatabase) MakeAtomicTransfer(transfer *model.Transfer,
 }
 
 func (cockroach *CockroachDatabase) FetchAccounts() ([]model.Account, error) {
-	rows, err := cockroach.pool.Query(context.Background(), `SELECT bic, ban, balance FROM account;`)
-	if err != nil {
-		return nil, merry.Prepend(err, "failed to fetch accounts")
-	}
-	var accs []model.Account
-	for rows.Next() {
-		var Balance int64
-		dec := new(inf.Dec)
-		var acc model.Account
-		if err := rows.Scan(&acc.Bic, &acc.Ban, &Balance); err != nil {
-			return nil, merry.Prepend(err, "failed to scan account for FetchAccounts")
-		}
-		dec.SetUnscaled(Balance)
-		acc.Balance = dec
-		accs = append(accs, acc)
-	}
-	return accs, nil
+	var accounts []model.Account
+
+	err := cockroach.router.onRead(func(pool *pgxpool.Pool) (err error) {
+		accounts, err = fetchSQLAccounts(context.Background(), pool)
+		return err
+	})
+
+	return accounts, err
 }
 
 func (cockroach *CockroachDatabase) FetchBalance(bic string, ban string) (*inf.Dec, *inf.Dec, error) {
-	row := cockroach.pool.QueryRow(context.Background(), fetchBalance, bic, ban)
+	row := cockroach.router.readRow(context.Background(), fetchBalance, bic, ban)
 	var balance, pendingAmount inf.Dec
 	err := row.Scan(&balance, &pendingAmount)
 	if err != nil {
@@ -450,8 +501,140 @@ func (cockroach *CockroachDatabase) FetchBalance(bic string, ban string) (*inf.D
 	return &balance, &pendingAmount, nil
 }
 
-func (cockroach *CockroachDatabase) StartStatisticsCollect(_ time.Duration) (_ error) {
-	llog.Warnln("stat metrics is not suppoerted now for cockroach")
+// EnableJournal - подготовить журнал и агрегаты по БИК и журналировать последующие переводы.
+func (cockroach *CockroachDatabase) EnableJournal() error {
+	if err := cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLJournal(context.Background(), pool)
+	}); err != nil {
+		return err
+	}
 
-	return
+	cockroach.journal = true
+
+	return nil
+}
+
+// CheckJournal - сверить агрегаты по БИК с балансами счетов и журналом переводов.
+func (cockroach *CockroachDatabase) CheckJournal() error {
+	return cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return checkSQLJournal(context.Background(), pool)
+	})
+}
+
+// EnableMultiCurrency - заполнить таблицу курсов и пересчитывать последующие переводы по курсу.
+func (cockroach *CockroachDatabase) EnableMultiCurrency() error {
+	if err := cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLCurrencies(context.Background(), pool)
+	}); err != nil {
+		return err
+	}
+
+	cockroach.multiCurrency = true
+
+	return nil
+}
+
+// CheckCurrencies - сверить суммы балансов по валютам с оборотами мультивалютных переводов.
+func (cockroach *CockroachDatabase) CheckCurrencies() error {
+	return cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return checkSQLCurrencies(context.Background(), pool)
+	})
+}
+
+// BootstrapKV - создать и очистить таблицу записей нагрузки ключ-значение.
+func (cockroach *CockroachDatabase) BootstrapKV() error {
+	return cockroach.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLKV(cockroach.ctxt, pool)
+	})
+}
+
+// KVInsert - добавить запись нагрузки ключ-значение.
+func (cockroach *CockroachDatabase) KVInsert(key string, value []byte) error {
+	return sqlKVInsert(cockroach.router, key, value)
+}
+
+// KVRead - прочитать значение записи.
+func (cockroach *CockroachDatabase) KVRead(key string) ([]byte, error) {
+	return sqlKVRead(cockroach.router, key)
+}
+
+// KVUpdate - заменить значение существующей записи.
+func (cockroach *CockroachDatabase) KVUpdate(key string, value []byte) error {
+	return sqlKVUpdate(cockroach.router, key, value)
+}
+
+// KVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func (cockroach *CockroachDatabase) KVScan(startKey string, count int) (int, error) {
+	return sqlKVScan(cockroach.router, startKey, count)
+}
+
+// KVReadModifyWrite - прочитать запись с блокировкой и заменить ее значение.
+func (cockroach *CockroachDatabase) KVReadModifyWrite(key string, value []byte) error {
+	return sqlKVReadModifyWrite(cockroach.router, key, value)
+}
+
+// EnableHistory - записывать последующие переводы в историю счетов.
+func (cockroach *CockroachDatabase) EnableHistory() error {
+	cockroach.history = true
+
+	return nil
+}
+
+// FetchStatement - последние limit записей истории счета.
+func (cockroach *CockroachDatabase) FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error) {
+	return sqlFetchStatement(cockroach.router, bic, ban, limit)
+}
+
+// ScanBankAccounts - страница счетов банка после afterBan.
+func (cockroach *CockroachDatabase) ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error) {
+	return sqlScanBankAccounts(cockroach.router, bic, afterBan, limit)
+}
+
+// StartStatisticsCollect - периодически сохранять состояние узлов и счетчики транзакций
+// из crdb_internal по каждому адресу кластера, пока не будет отменен контекст.
+func (cockroach *CockroachDatabase) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     Cockroach,
+		fileTemplate: crdbStatJsonFileTemplate,
+		collect: func() (interface{}, error) {
+			return cockroach.router.collectStatistics(map[string]string{
+				"gossip_nodes": crdbGossipNodes,
+				"node_metrics": crdbNodeMetrics,
+			})
+		},
+		extract: extractCrdbMetrics,
+	})
+}
+
+// extractCrdbMetrics - счетчики транзакций суммируются по узлам, а число живых узлов
+// берется из gossip любого из них.
+func extractCrdbMetrics(data interface{}) map[string]float64 {
+	values := make(map[string]float64)
+
+	for _, row := range statRows(data, "node_metrics") {
+		name, _ := row["name"].(string)
+		value, ok := row["value"].(float64)
+
+		switch name {
+		case "sql.txn.commit.count", "sql.txn.abort.count", "txn.restarts":
+			if ok {
+				values["crdb."+name] += value
+			}
+		}
+	}
+
+	liveNodes := make(map[float64]bool)
+	for _, row := range statRows(data, "gossip_nodes") {
+		if nodeID, ok := row["node_id"].(float64); ok && row["is_live"] == true {
+			liveNodes[nodeID] = true
+		}
+	}
+	values["crdb.live_nodes"] = float64(len(liveNodes))
+
+	return values
+}
+
+// Close - закрыть пулы соединений ко всем узлам.
+func (cockroach *CockroachDatabase) Close() {
+	cockroach.router.Close()
 }
@@ -17,7 +17,7 @@ var cockroachCluster *CockroachDatabase
 
 func (cockroach *CockroachDatabase) TruncateTable(tableName string) error {
 	sqlString := fmt.Sprintf("TRUNCATE %s", tableName)
-	_, err := cockroach.pool.Exec(context.TODO(), sqlString)
+	_, err := cockroach.pool().Exec(context.TODO(), sqlString)
 	if err != nil {
 		return err
 	}
@@ -28,7 +28,7 @@ func (cockroach *CockroachDatabase) GetAccount(Bic string, Ban string) (Account
 	var Balance int64
 	dec := new(inf.Dec)
 
-	if err := cockroach.pool.QueryRow(
+	if err := cockroach.pool().QueryRow(
 		context.TODO(),
 		`SELECT bic, ban, balance FROM account WHERE bic = $1 and ban = $2;`,
 		Bic,
@@ -47,7 +47,7 @@ func (cockroach *CockroachDatabase) GetAccount(Bic string, Ban string) (Account
 func (cockroach *CockroachDatabase) CheckTableExist(tableName string) (exist bool, err error) {
 	var name string
 	sqlQuery := fmt.Sprintf("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_NAME='%s';", tableName)
-	if err = cockroach.pool.QueryRow(context.TODO(), sqlQuery).Scan(&name); err != nil {
+	if err = cockroach.pool().QueryRow(context.TODO(), sqlQuery).Scan(&name); err != nil {
 		return false, err
 	}
 	if tableName != name {
@@ -62,7 +62,7 @@ func NewTestCockroachCluster(t *testing.T) {
 	if err != nil {
 		t.Fatal("Get environment error:", err)
 	}
-	cockroachCluster, err = NewCockroachCluster(cockroachUrlString, poolSize)
+	cockroachCluster, err = NewCockroachCluster(cockroachUrlString, poolSize, DefaultSQLTxSettings(), DefaultRoutingSettings())
 	if err != nil {
 		t.Fatal("Cockroach cluster start fail:", err)
 	}
@@ -91,12 +91,12 @@ func CockroachBootstrapDB(t *testing.T) {
 	}
 
 	var count int
-	if err := cockroachCluster.pool.QueryRow(context.TODO(), "SELECT COUNT(*) FROM account;").Scan(&count); err != nil {
+	if err := cockroachCluster.pool().QueryRow(context.TODO(), "SELECT COUNT(*) FROM account;").Scan(&count); err != nil {
 		t.Errorf("TestCockroachBootstrapDB() received internal error %s, expected nil", err)
 	}
 	assert.Equal(t, count, 0, "Must be equal")
 
-	if err := cockroachCluster.pool.QueryRow(context.TODO(), "SELECT COUNT(*) FROM transfer;").Scan(&count); err != nil {
+	if err := cockroachCluster.pool().QueryRow(context.TODO(), "SELECT COUNT(*) FROM transfer;").Scan(&count); err != nil {
 		t.Errorf("TestCockroachBootstrapDB() received internal error %s, expected nil", err)
 	}
 	assert.Equal(t, count, 0, "Must be equal")
@@ -175,7 +175,7 @@ func CockroachMakeAtomicTransfer(t *testing.T) {
 		t.Errorf("TestMakeAtomicTransfer() received internal error %v, but expected nil", err)
 	}
 
-	if err := cockroachCluster.pool.QueryRow(
+	if err := cockroachCluster.pool().QueryRow(
 		context.TODO(),
 		`SELECT src_bic, src_ban, dst_bic, dst_ban, amount FROM transfer WHERE transfer_id = $1;`, expectedTransfer.Id).Scan(
 		&receivedTransfer.Acs[0].Bic,
@@ -0,0 +1,65 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+)
+
+// MultiCurrencyCluster - кластер, поддерживающий мультивалютные переводы: в транзакции перевода
+// читаются валюты счетов и курс из таблицы курсов, на счет получателя зачисляется пересчитанная сумма.
+type MultiCurrencyCluster interface {
+	// EnableMultiCurrency - заполнить таблицу курсов, сохранить суммы балансов по валютам
+	// и пересчитывать по курсу последующие вызовы MakeAtomicTransfer.
+	EnableMultiCurrency() error
+	// CheckCurrencies - для каждой валюты сверить сумму балансов счетов с суммой на момент
+	// включения режима и оборотами мультивалютных переводов.
+	CheckCurrencies() error
+}
+
+// currencyTotal - суммы по валюте: балансы на момент включения режима, списания и зачисления переводов.
+type currencyTotal struct {
+	Initial int64
+	Debit   int64
+	Credit  int64
+}
+
+// compareCurrencies - сумма балансов счетов в каждой валюте должна быть равна сумме
+// на момент включения режима за вычетом списаний и с учетом зачислений в этой валюте.
+func compareCurrencies(totals map[string]currencyTotal, accountSums map[string]int64) error {
+	currencies := make(map[string]bool, len(totals))
+	for currency := range totals {
+		currencies[currency] = true
+	}
+	for currency := range accountSums {
+		currencies[currency] = true
+	}
+
+	mismatches := 0
+	for currency := range currencies {
+		total := totals[currency]
+		expected := total.Initial - total.Debit + total.Credit
+		if accountSums[currency] == expected {
+			llog.Infof("Currency %s: initial %d, debit %d, credit %d, balance %d",
+				currency, total.Initial, total.Debit, total.Credit, accountSums[currency])
+
+			continue
+		}
+
+		mismatches++
+		llog.Errorf("Currency %s mismatch: initial %d, debit %d, credit %d, expected %d, accounts %d",
+			currency, total.Initial, total.Debit, total.Credit, expected, accountSums[currency])
+	}
+
+	if mismatches > 0 {
+		return merry.Errorf("balances do not match transfers for %d of %d currencies",
+			mismatches, len(currencies))
+	}
+
+	llog.Infof("Balances match transfers for %d currencies", len(currencies))
+
+	return nil
+}
@@ -18,6 +18,9 @@ const (
 
 )
 
+// GetEnvDataStore - адрес БД для интеграционных тестов пакета из TEST_<БД>_URL. Переменные
+// нужны только go test и не относятся к параметрам stroppy, поэтому загрузчик конфигурации
+// их не читает.
 func GetEnvDataStore(opts string) (dbParams string, err error) {
 	var present bool
 	switch opts {
@@ -5,12 +5,11 @@
 package cluster
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"os"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,13 +26,15 @@ import (
 
 const (
 	versionAPI              = 620
-	fdbStatJsonFileTemplate = "status_json_%v.json"
+	fdbStatJsonFileTemplate = "status_json_%v.jsonl"
 )
 
 // FDBCluster - объявление соединения к FDB и ссылки на модель данных.
 type FDBCluster struct {
-	pool  fdb.Database
-	model modelFDB
+	pool    fdb.Database
+	model   modelFDB
+	journal bool
+	history bool
 }
 
 func (cluster *FDBCluster) InsertTransfer(_ *model.Transfer) error {
@@ -86,6 +87,13 @@ type modelFDB struct {
 	transfers directory.DirectorySubspace
 	settings  directory.DirectorySubspace
 	checksum  directory.DirectorySubspace
+	// журнал переводов и агрегаты по БИК для журналируемого режима
+	journal    directory.DirectorySubspace
+	bankTotals directory.DirectorySubspace
+	// записи нагрузки ключ-значение
+	usertable directory.DirectorySubspace
+	// история переводов по счетам, ключ (bic, ban, время, id перевода)
+	history directory.DirectorySubspace
 }
 
 // transferValue - объявление атрибутов перевода.
@@ -93,9 +101,11 @@ type transferValue struct {
 	Amount *inf.Dec `json:"Amount"`
 }
 
-// accountValue - объявление атрибутов счета.
+// accountValue - объявление атрибутов счета. Дополнительные атрибуты читаются и записываются
+// переводом вместе с балансом, поэтому сохраняются при его изменении.
 type accountValue struct {
-	Balance *inf.Dec `json:"Balance"`
+	Balance *inf.Dec              `json:"Balance"`
+	Payload *model.AccountPayload `json:"Payload,omitempty"`
 }
 
 // NewFoundationCluster - Создать подключение к FDB и создать новые DirectorySubspace, если ещё не созданы.
@@ -135,13 +145,37 @@ func NewFoundationCluster(dbURL string) (*FDBCluster, error) {
 		return nil, merry.Prepend(err, "failed to create checksum directory")
 	}
 
+	journal, err := directory.CreateOrOpen(FDBPool, []string{"journal"}, nil)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create journal directory")
+	}
+
+	bankTotals, err := directory.CreateOrOpen(FDBPool, []string{"bank_totals"}, nil)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create bank totals directory")
+	}
+
+	usertable, err := directory.CreateOrOpen(FDBPool, []string{"usertable"}, nil)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create usertable directory")
+	}
+
+	history, err := directory.CreateOrOpen(FDBPool, []string{"history"}, nil)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create history directory")
+	}
+
 	return &FDBCluster{
 		pool: FDBPool,
 		model: modelFDB{
-			accounts:  accounts,
-			transfers: transfers,
-			settings:  settings,
-			checksum:  checkSum,
+			accounts:   accounts,
+			transfers:  transfers,
+			settings:   settings,
+			checksum:   checkSum,
+			journal:    journal,
+			bankTotals: bankTotals,
+			usertable:  usertable,
+			history:    history,
 		},
 	}, nil
 }
@@ -155,6 +189,7 @@ func (cluster *FDBCluster) BootstrapDB(count int, seed int) error {
 		tx.ClearRange(cluster.model.checksum)
 		tx.ClearRange(cluster.model.settings)
 		tx.ClearRange(cluster.model.transfers)
+		tx.ClearRange(cluster.model.history)
 		countKey := cluster.model.settings.Pack(tuple.Tuple{"count"})
 		seedKey := cluster.model.settings.Pack(tuple.Tuple{"seed"})
 		checkSumTotalKey := cluster.model.checksum.Pack(tuple.Tuple{"total"})
@@ -227,6 +262,7 @@ func (cluster *FDBCluster) InsertAccount(acc model.Account) error {
 		}
 		var valueAccount accountValue
 		valueAccount.Balance = acc.Balance
+		valueAccount.Payload = acc.Payload
 		valueAccountSet, err := serializeValue(valueAccount)
 		if err != nil {
 			return nil, merry.Prepend(err, "failed to serialize account value for insert")
@@ -422,12 +458,188 @@ func (cluster *FDBCluster) MakeAtomicTransfer(transfer *model.Transfer, clientId
 			return nil, merry.Prepend(err, "failed to serialize destination account")
 		}
 		tx.Set(destAccountKey, setDestAccountValue)
+		if cluster.journal {
+			cluster.appendJournal(tx, transfer)
+		}
+		if cluster.history {
+			cluster.appendHistory(tx, transfer, time.Now())
+		}
 		return nil, nil
 	})
 
 	return merry.Wrap(err)
 }
 
+// Поля агрегата по БИК в подпространстве bankTotals.
+const (
+	fdbBankTotalInitial = "initial"
+	fdbBankTotalBalance = "balance"
+)
+
+// fdbJournalBatch - число проводок журнала, читаемых в одной транзакции при проверке.
+const fdbJournalBatch = 10000
+
+// encodeFDBInt64 - значение в формате little-endian, который ожидает атомарная операция Add.
+func encodeFDBInt64(value int64) []byte {
+	encoded := make([]byte, 8)
+	binary.LittleEndian.PutUint64(encoded, uint64(value))
+
+	return encoded
+}
+
+func decodeFDBInt64(encoded []byte) int64 {
+	if len(encoded) < 8 {
+		return 0
+	}
+
+	return int64(binary.LittleEndian.Uint64(encoded))
+}
+
+// appendJournal - добавить проводки перевода и изменить агрегаты по БИК.
+// Агрегаты меняются атомарным Add, поэтому параллельные переводы не конфликтуют на них.
+func (cluster *FDBCluster) appendJournal(tx fdb.Transaction, transfer *model.Transfer) {
+	for _, entry := range journalEntries(transfer) {
+		key := cluster.model.journal.Pack(tuple.Tuple{
+			tuple.UUID(transfer.Id),
+			entry.Leg,
+			entry.Account.Bic,
+			entry.Account.Ban,
+		})
+		tx.Set(key, encodeFDBInt64(entry.Amount))
+	}
+
+	for _, delta := range bankTotalDeltas(transfer) {
+		tx.Add(cluster.model.bankTotals.Pack(tuple.Tuple{delta.Bic, fdbBankTotalBalance}), encodeFDBInt64(delta.Delta))
+	}
+}
+
+// accountSumsByBic - суммы балансов счетов по БИК.
+func (cluster *FDBCluster) accountSumsByBic() (map[string]int64, error) {
+	accounts, err := cluster.FetchAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]int64)
+	for _, account := range accounts {
+		sums[account.Bic] += account.Balance.UnscaledBig().Int64()
+	}
+
+	return sums, nil
+}
+
+// EnableJournal - подготовить журнал и агрегаты по БИК и журналировать последующие переводы.
+func (cluster *FDBCluster) EnableJournal() error {
+	accountSums, err := cluster.accountSumsByBic()
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate bank totals")
+	}
+
+	_, err = cluster.pool.Transact(func(tx fdb.Transaction) (interface{}, error) {
+		tx.ClearRange(cluster.model.journal)
+		tx.ClearRange(cluster.model.bankTotals)
+
+		for bic, sum := range accountSums {
+			tx.Set(cluster.model.bankTotals.Pack(tuple.Tuple{bic, fdbBankTotalInitial}), encodeFDBInt64(sum))
+			tx.Set(cluster.model.bankTotals.Pack(tuple.Tuple{bic, fdbBankTotalBalance}), encodeFDBInt64(sum))
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return merry.Prepend(err, "failed to prepare journal")
+	}
+
+	cluster.journal = true
+
+	return nil
+}
+
+// CheckJournal - сверить агрегаты по БИК с балансами счетов и журналом переводов.
+func (cluster *FDBCluster) CheckJournal() error {
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		return tx.GetRange(cluster.model.bankTotals, fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).GetSliceWithError()
+	})
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch bank totals")
+	}
+
+	totalsKeyValues, ok := data.([]fdb.KeyValue)
+	if !ok {
+		return merry.Errorf("this type data of fdb.KeyValue is not supported")
+	}
+
+	totals := make(map[string]bankTotal)
+	for _, keyValue := range totalsKeyValues {
+		key, err := cluster.model.bankTotals.Unpack(keyValue.Key)
+		if err != nil {
+			return merry.Prepend(err, "failed to unpack bank total key")
+		}
+
+		bic, _ := key[0].(string)
+		total := totals[bic]
+		switch key[1] {
+		case fdbBankTotalInitial:
+			total.Initial = decodeFDBInt64(keyValue.Value)
+		case fdbBankTotalBalance:
+			total.Balance = decodeFDBInt64(keyValue.Value)
+		}
+		totals[bic] = total
+	}
+
+	accountSums, err := cluster.accountSumsByBic()
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate account totals")
+	}
+
+	journalSums, err := cluster.journalSumsByBic()
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate journal totals")
+	}
+
+	return compareJournal(totals, accountSums, journalSums)
+}
+
+// journalSumsByBic - суммы проводок журнала по БИК. Журнал читается пачками,
+// чтобы не упереться в ограничение времени жизни транзакции fdb.
+func (cluster *FDBCluster) journalSumsByBic() (map[string]int64, error) {
+	sums := make(map[string]int64)
+	begin, end := cluster.model.journal.FDBRangeKeys()
+
+	for {
+		data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+			return tx.GetRange(
+				fdb.KeyRange{Begin: begin, End: end},
+				fdb.RangeOptions{Limit: fdbJournalBatch, Mode: fdb.StreamingModeWantAll},
+			).GetSliceWithError()
+		})
+		if err != nil {
+			return nil, merry.Wrap(err)
+		}
+
+		keyValues, ok := data.([]fdb.KeyValue)
+		if !ok {
+			return nil, merry.Errorf("this type data of fdb.KeyValue is not supported")
+		}
+
+		for _, keyValue := range keyValues {
+			key, err := cluster.model.journal.Unpack(keyValue.Key)
+			if err != nil {
+				return nil, merry.Prepend(err, "failed to unpack journal key")
+			}
+
+			bic, _ := key[2].(string)
+			sums[bic] += decodeFDBInt64(keyValue.Value)
+		}
+
+		if len(keyValues) < fdbJournalBatch {
+			return sums, nil
+		}
+
+		begin = fdb.Key(append(keyValues[len(keyValues)-1].Key, 0x00))
+	}
+}
+
 // FetchAccounts - получить список аккаунтов
 func (cluster *FDBCluster) FetchAccounts() ([]model.Account, error) {
 	var accounts []model.Account
@@ -474,6 +686,7 @@ func (cluster *FDBCluster) FetchAccounts() ([]model.Account, error) {
 		}
 
 		fetchAccount.Balance = fetchAccountValue.Balance
+		fetchAccount.Payload = fetchAccountValue.Payload
 		Bic, ok := keyAccountTuple[0].(string)
 		if !ok {
 			return nil, merry.Errorf("account bic is not string, value: %v \n", fetchAccount.Bic)
@@ -622,78 +835,266 @@ func (cluster *FDBCluster) setTransfer(tx fdb.Transaction, transfer *model.Trans
 	return err
 }
 
-func (cluster *FDBCluster) StartStatisticsCollect(statInterval time.Duration) error {
-	errChan := make(chan error)
+// BootstrapKV - очистить подпространство записей нагрузки ключ-значение.
+func (cluster *FDBCluster) BootstrapKV() error {
+	_, err := cluster.pool.Transact(func(tx fdb.Transaction) (interface{}, error) {
+		tx.ClearRange(cluster.model.usertable)
 
-	llog.Debugln("starting of statistic goroutine...")
-	go cluster.getStatistics(statInterval, errChan)
+		return nil, nil
+	})
 
-	errorCheck := <-errChan
+	return merry.Prepend(err, "failed to clear usertable")
+}
 
-	if errorCheck != nil {
-		return merry.Prepend(errorCheck, "failed to get statistic")
+// KVInsert - добавить запись, существующий ключ возвращается как ErrDuplicateKey.
+func (cluster *FDBCluster) KVInsert(key string, value []byte) error {
+	_, err := cluster.pool.Transact(func(tx fdb.Transaction) (interface{}, error) {
+		recordKey := cluster.model.usertable.Pack(tuple.Tuple{key})
+		previous, err := tx.Get(recordKey).Get()
+		if err != nil {
+			return nil, err
+		}
+
+		if previous != nil {
+			return nil, ErrDuplicateKey
+		}
+
+		tx.Set(recordKey, value)
+
+		return nil, nil
+	})
+	if errors.Is(err, ErrDuplicateKey) {
+		return ErrDuplicateKey
 	}
 
-	return nil
+	return merry.Wrap(err)
 }
 
-func (cluster *FDBCluster) getStatistics(statInterval time.Duration, errChan chan error) {
-	var once sync.Once
-	var resultMap map[string]interface{}
-	var jsonResult []byte
+// KVRead - прочитать значение записи.
+func (cluster *FDBCluster) KVRead(key string) ([]byte, error) {
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		return tx.Get(cluster.model.usertable.Pack(tuple.Tuple{key})).Get()
+	})
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read record")
+	}
 
-	const dateFormat = "02-01-2006_15:04:05"
+	value, _ := data.([]byte)
+	if value == nil {
+		return nil, ErrNoRows
+	}
+
+	return value, nil
+}
+
+// KVUpdate - записать значение без предварительного чтения, как update в YCSB для fdb.
+func (cluster *FDBCluster) KVUpdate(key string, value []byte) error {
+	_, err := cluster.pool.Transact(func(tx fdb.Transaction) (interface{}, error) {
+		tx.Set(cluster.model.usertable.Pack(tuple.Tuple{key}), value)
+
+		return nil, nil
+	})
+
+	return merry.Wrap(err)
+}
+
+// KVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func (cluster *FDBCluster) KVScan(startKey string, count int) (int, error) {
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		_, end := cluster.model.usertable.FDBRangeKeys()
+		keyRange := fdb.KeyRange{
+			Begin: cluster.model.usertable.Pack(tuple.Tuple{startKey}),
+			End:   end,
+		}
 
-	statFileName := fmt.Sprintf(fdbStatJsonFileTemplate, time.Now().Format(dateFormat))
-	llog.Debugln("Opening statistic file...")
-	statFile, err := os.OpenFile(statFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		return tx.GetRange(keyRange, fdb.RangeOptions{Limit: count, Mode: fdb.StreamingModeWantAll}).GetSliceWithError()
+	})
 	if err != nil {
-		errChan <- merry.Prepend(err, "failed to open statistic file")
+		return 0, merry.Prepend(err, "failed to scan records")
 	}
 
-	defer statFile.Close()
+	keyValues, _ := data.([]fdb.KeyValue)
 
-	llog.Debugln("Opening statistic file: success")
+	return len(keyValues), nil
+}
 
-	for {
-		data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
-			status, err := tx.Get(fdb.Key("\xFF\xFF/status/json")).Get()
-			if err != nil {
-				return nil, err
-			}
-			return status, nil
+// KVReadModifyWrite - прочитать запись и заменить ее значение в одной транзакции.
+func (cluster *FDBCluster) KVReadModifyWrite(key string, value []byte) error {
+	_, err := cluster.pool.Transact(func(tx fdb.Transaction) (interface{}, error) {
+		recordKey := cluster.model.usertable.Pack(tuple.Tuple{key})
+		previous, err := tx.Get(recordKey).Get()
+		if err != nil {
+			return nil, err
+		}
+
+		if previous == nil {
+			return nil, ErrNoRows
+		}
+
+		tx.Set(recordKey, value)
+
+		return nil, nil
+	})
+	if errors.Is(err, ErrNoRows) {
+		return ErrNoRows
+	}
+
+	return merry.Wrap(err)
+}
+
+// appendHistory - добавить записи перевода в историю счетов отправителя и получателя.
+func (cluster *FDBCluster) appendHistory(tx fdb.Transaction, transfer *model.Transfer, timestamp time.Time) {
+	for _, entry := range journalEntries(transfer) {
+		key := cluster.model.history.Pack(tuple.Tuple{
+			entry.Account.Bic,
+			entry.Account.Ban,
+			timestamp.UnixNano(),
+			tuple.UUID(transfer.Id),
 		})
+		tx.Set(key, encodeFDBInt64(entry.Amount))
+	}
+}
+
+// EnableHistory - записывать последующие переводы в историю счетов.
+func (cluster *FDBCluster) EnableHistory() error {
+	cluster.history = true
+
+	return nil
+}
+
+// FetchStatement - последние limit записей истории счета: обратное чтение диапазона
+// с префиксом (bic, ban), ключи которого упорядочены по времени перевода.
+func (cluster *FDBCluster) FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error) {
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		return tx.GetRange(
+			cluster.model.history.Sub(bic, ban),
+			fdb.RangeOptions{Limit: limit, Mode: fdb.StreamingModeWantAll, Reverse: true},
+		).GetSliceWithError()
+	})
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to fetch statement")
+	}
+
+	keyValues, ok := data.([]fdb.KeyValue)
+	if !ok {
+		return nil, merry.Errorf("this type data of fdb.KeyValue is not supported")
+	}
+
+	entries := make([]HistoryEntry, 0, len(keyValues))
+	for _, keyValue := range keyValues {
+		key, err := cluster.model.history.Unpack(keyValue.Key)
 		if err != nil {
-			errChan <- merry.Prepend(err, "failed to get status json from db")
+			return nil, merry.Prepend(err, "failed to unpack history key")
 		}
 
-		result, ok := data.([]byte)
+		timestamp, ok := key[2].(int64)
 		if !ok {
-			errChan <- merry.Errorf("status data type is not supported, value: %v", result)
+			return nil, merry.Errorf("history timestamp is not int64, value: %v", key[2])
 		}
 
-		if err = json.Unmarshal(result, &resultMap); err != nil {
-			errChan <- merry.Prepend(err, "failed to unmarchal status json")
+		transferID, ok := key[3].(tuple.UUID)
+		if !ok {
+			return nil, merry.Errorf("history transfer id is not uuid, value: %v", key[3])
 		}
 
-		separateString := fmt.Sprintf("\n %v \n", time.Now().Format(dateFormat))
-		if _, err = statFile.Write([]byte(separateString)); err != nil {
-			errChan <- merry.Prepend(err, "failed to write separate string to statistic file")
+		entries = append(entries, HistoryEntry{
+			TransferId: model.TransferId(transferID),
+			Amount:     decodeFDBInt64(keyValue.Value),
+			Timestamp:  time.Unix(0, timestamp),
+		})
+	}
+
+	return entries, nil
+}
+
+// ScanBankAccounts - страница счетов банка: чтение диапазона с префиксом bic после ключа afterBan.
+func (cluster *FDBCluster) ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error) {
+	_, end := cluster.model.accounts.Sub(bic).FDBRangeKeys()
+
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		return tx.GetRange(
+			fdb.SelectorRange{
+				Begin: fdb.FirstGreaterThan(cluster.model.accounts.Pack(tuple.Tuple{bic, afterBan})),
+				End:   fdb.FirstGreaterOrEqual(end),
+			},
+			fdb.RangeOptions{Limit: limit, Mode: fdb.StreamingModeWantAll, Reverse: false},
+		).GetSliceWithError()
+	})
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to scan bank accounts")
+	}
+
+	keyValues, ok := data.([]fdb.KeyValue)
+	if !ok {
+		return nil, merry.Errorf("this type data of fdb.KeyValue is not supported")
+	}
+
+	accounts := make([]model.Account, 0, len(keyValues))
+	for _, keyValue := range keyValues {
+		key, err := cluster.model.accounts.Unpack(keyValue.Key)
+		if err != nil {
+			return nil, merry.Prepend(err, "failed to unpack account key")
 		}
 
-		if jsonResult, err = json.MarshalIndent(resultMap, "", "    "); err != nil {
-			errChan <- merry.Prepend(err, "failed to marshal data")
+		ban, ok := key[1].(string)
+		if !ok {
+			return nil, merry.Errorf("account ban is not string, value: %v", key[1])
 		}
 
-		if _, err = statFile.Write(jsonResult); err != nil {
-			errChan <- merry.Prepend(err, "failed to write data to statistic file")
+		value, err := deserializeAccountValue(keyValue.Value)
+		if err != nil {
+			return nil, err
 		}
 
-		// если ошибки нет, то отправляем nil, чтобы продолжить работу
-		once.Do(func() {
-			errChan <- nil
+		accounts = append(accounts, model.Account{ //nolint:exhaustivestruct
+			Bic:     bic,
+			Ban:     ban,
+			Balance: value.Balance,
+			Payload: value.Payload,
+			Found:   true,
 		})
+	}
+
+	return accounts, nil
+}
+
+// StartStatisticsCollect - периодически сохранять status json кластера, пока не будет отменен контекст.
+func (cluster *FDBCluster) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     Foundation,
+		fileTemplate: fdbStatJsonFileTemplate,
+		collect:      cluster.getStatistics,
+		extract: extractStatPaths(map[string]string{
+			"fdb.committed_hz":  "cluster.workload.transactions.committed.hz",
+			"fdb.conflicted_hz": "cluster.workload.transactions.conflicted.hz",
+			"fdb.reads_hz":      "cluster.workload.operations.reads.hz",
+			"fdb.writes_hz":     "cluster.workload.operations.writes.hz",
+			"fdb.commit_s":      "cluster.latency_probe.commit_seconds",
+		}),
+	})
+}
 
-		time.Sleep(statInterval * time.Second)
+func (cluster *FDBCluster) getStatistics() (interface{}, error) {
+	data, err := cluster.pool.ReadTransact(func(tx fdb.ReadTransaction) (interface{}, error) {
+		return tx.Get(fdb.Key("\xFF\xFF/status/json")).Get()
+	})
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to get status json from db")
 	}
+
+	result, ok := data.([]byte)
+	if !ok {
+		return nil, merry.Errorf("status data type is not supported, value: %v", data)
+	}
+
+	var resultMap map[string]interface{}
+	if err = json.Unmarshal(result, &resultMap); err != nil {
+		return nil, merry.Prepend(err, "failed to unmarchal status json")
+	}
+
+	return resultMap, nil
 }
+
+// Close - привязки fdb этой версии не закрывают соединение с кластером,
+// оно живет до завершения процесса.
+func (cluster *FDBCluster) Close() {}
@@ -0,0 +1,31 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"time"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// HistoryCluster - кластер, поддерживающий историю переводов по счетам: таблица истории
+// создается и очищается при загрузке счетов, а переводы записываются в нее после EnableHistory.
+// Выписка и постраничное чтение счетов банка - операции чтения диапазона по индексу.
+type HistoryCluster interface {
+	// EnableHistory - записывать последующие переводы MakeAtomicTransfer в историю счетов.
+	EnableHistory() error
+	// FetchStatement - последние limit записей истории счета, от новых к старым.
+	FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error)
+	// ScanBankAccounts - до limit счетов банка bic с номером больше afterBan по порядку номеров,
+	// пустой afterBan означает первую страницу.
+	ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error)
+}
+
+// HistoryEntry - запись истории счета, сумма списания отрицательна.
+type HistoryEntry struct {
+	TransferId model.TransferId
+	Amount     int64
+	Timestamp  time.Time
+}
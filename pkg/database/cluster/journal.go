@@ -0,0 +1,124 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"sort"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// Проводки журнала: списание со счета отправителя и зачисление на счет получателя.
+const (
+	JournalLegDebit  = "debit"
+	JournalLegCredit = "credit"
+)
+
+// maxReportedMismatches - сколько расхождений журнала выводить в журнал приложения.
+const maxReportedMismatches = 10
+
+// JournaledCluster - кластер, поддерживающий журналируемый перевод: в той же транзакции,
+// что и изменение балансов, добавляются проводки в журнал и обновляются агрегаты по БИК.
+type JournaledCluster interface {
+	// EnableJournal - очистить журнал, заполнить агрегаты по БИК из текущих балансов
+	// и включить журналирование для последующих вызовов MakeAtomicTransfer.
+	EnableJournal() error
+	// CheckJournal - сверить агрегаты по БИК с балансами счетов и суммами проводок журнала.
+	CheckJournal() error
+}
+
+// journalEntry - проводка журнала по одному счету перевода.
+type journalEntry struct {
+	Leg     string
+	Account model.Account
+	Amount  int64
+}
+
+// journalEntries - проводки перевода, сумма списания отрицательна.
+// В мультивалютном режиме зачисляется сумма, пересчитанная по курсу.
+func journalEntries(transfer *model.Transfer) [2]journalEntry {
+	return [2]journalEntry{
+		{Leg: JournalLegDebit, Account: transfer.Acs[0], Amount: -transfer.Amount.UnscaledBig().Int64()},
+		{Leg: JournalLegCredit, Account: transfer.Acs[1], Amount: transfer.Credit().UnscaledBig().Int64()},
+	}
+}
+
+// bankTotalDelta - изменение агрегата одного БИК.
+type bankTotalDelta struct {
+	Bic   string
+	Delta int64
+}
+
+// bankTotalDeltas - изменения агрегатов по БИК, упорядоченные по БИК, чтобы параллельные
+// переводы блокировали агрегаты в одном порядке. Перевод внутри одного банка меняет агрегат
+// только на разницу сумм списания и зачисления в мультивалютном режиме.
+func bankTotalDeltas(transfer *model.Transfer) []bankTotalDelta {
+	entries := journalEntries(transfer)
+	if entries[0].Account.Bic == entries[1].Account.Bic {
+		delta := entries[0].Amount + entries[1].Amount
+		if delta == 0 {
+			return nil
+		}
+
+		return []bankTotalDelta{{Bic: entries[0].Account.Bic, Delta: delta}}
+	}
+
+	deltas := []bankTotalDelta{
+		{Bic: entries[0].Account.Bic, Delta: entries[0].Amount},
+		{Bic: entries[1].Account.Bic, Delta: entries[1].Amount},
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i].Bic < deltas[j].Bic
+	})
+
+	return deltas
+}
+
+// bankTotal - агрегат по БИК: сумма балансов на момент включения журнала и текущая.
+type bankTotal struct {
+	Initial int64
+	Balance int64
+}
+
+// compareJournal - для каждого БИК текущий агрегат должен совпадать с суммой балансов счетов,
+// а его изменение с момента включения журнала - с суммой проводок.
+func compareJournal(totals map[string]bankTotal, accountSums, journalSums map[string]int64) error {
+	bics := make(map[string]bool, len(totals))
+	for bic := range totals {
+		bics[bic] = true
+	}
+	for bic := range accountSums {
+		bics[bic] = true
+	}
+	for bic := range journalSums {
+		bics[bic] = true
+	}
+
+	mismatches := 0
+	for bic := range bics {
+		total, found := totals[bic]
+		if found && accountSums[bic] == total.Balance && total.Initial+journalSums[bic] == total.Balance {
+			continue
+		}
+
+		mismatches++
+		if mismatches <= maxReportedMismatches {
+			llog.Errorf("Journal mismatch for bic %s: aggregate found %v, initial %d, aggregate %d, "+
+				"accounts %d, journal %d",
+				bic, found, total.Initial, total.Balance, accountSums[bic], journalSums[bic])
+		}
+	}
+
+	if mismatches > 0 {
+		return merry.Errorf("journal does not match balances for %d of %d banks", mismatches, len(bics))
+	}
+
+	llog.Infof("Journal matches balances for %d banks", len(bics))
+
+	return nil
+}
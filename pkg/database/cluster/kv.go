@@ -0,0 +1,25 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+// KVCluster - кластер, поддерживающий обобщенную нагрузку ключ-значение в духе YCSB:
+// таблица usertable из строкового ключа ycsb_key и бинарного значения field0.
+type KVCluster interface {
+	// BootstrapKV - создать таблицу записей или очистить ее, если она уже существует.
+	BootstrapKV() error
+	// KVInsert - добавить запись, для существующего ключа возвращается ErrDuplicateKey,
+	// если СУБД проверяет уникальность при вставке.
+	KVInsert(key string, value []byte) error
+	// KVRead - прочитать значение записи, для отсутствующей возвращается ErrNoRows.
+	KVRead(key string) ([]byte, error)
+	// KVUpdate - заменить значение записи.
+	KVUpdate(key string, value []byte) error
+	// KVScan - прочитать по порядку ключей до count записей, начиная с startKey,
+	// и вернуть число прочитанных записей.
+	KVScan(startKey string, count int) (int, error)
+	// KVReadModifyWrite - прочитать запись и заменить ее значение в одной транзакции,
+	// для отсутствующей записи возвращается ErrNoRows.
+	KVReadModifyWrite(key string, value []byte) error
+}
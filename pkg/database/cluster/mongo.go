@@ -6,10 +6,9 @@ package cluster
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"sync"
 	"time"
 
 	"github.com/ansel1/merry/v2"
@@ -24,21 +23,76 @@ import (
 	"gopkg.in/inf.v0"
 )
 
-const mongoStatJsonFileTemplate = "serverStatus_%v.json"
+const mongoStatJsonFileTemplate = "serverStatus_%v.jsonl"
+
+// mongoAccountBic - БИК счета: документ счета хранит только ключ bicBan,
+// в котором БИК фиксированной длины в 8 символов идет первым.
+var mongoAccountBic = bson.M{"$substrBytes": bson.A{"$bicBan", 0, mongoBicLength}}
+
+// mongoBicLength - длина БИК в ключе bicBan.
+const mongoBicLength = 8
+
+// время на закрытие соединений клиента mongo
+const mongoCloseTimeout = 10 * time.Second
 
 // MongoDBCluster - объявление соединения к FDB и ссылки на модель данных.
 type MongoDBCluster struct {
-	db         *mongo.Database
-	mongoModel mongoModel
-	client     *mongo.Client
-	sharded    bool
+	db             *mongo.Database
+	mongoModel     mongoModel
+	client         *mongo.Client
+	sharded        bool
+	schemaTemplate string
+	journal        bool
+	history        bool
+}
+
+// mongoSchema - пользовательский шаблон схемы mongo в формате Extended JSON:
+// список команд, выполняемых в указанной БД (по умолчанию stroppy), например
+// {"commands": [{"db": "admin", "command": {"shardCollection": "stroppy.accounts", "key": {"bicBan": "hashed"}}}]}.
+type mongoSchema struct {
+	Commands []struct {
+		DB      string `bson:"db"`
+		Command bson.D `bson:"command"`
+	} `bson:"commands"`
 }
 
+// mongoRequiredCollections - коллекции, которые должны существовать после инициализации.
+var mongoRequiredCollections = []string{"accounts", "settings"}
+
 type mongoModel struct {
-	accounts  *mongo.Collection
-	transfers *mongo.Collection
-	settings  *mongo.Collection
-	checksum  *mongo.Collection
+	accounts   *mongo.Collection
+	transfers  *mongo.Collection
+	settings   *mongo.Collection
+	checksum   *mongo.Collection
+	journal    *mongo.Collection
+	bankTotals *mongo.Collection
+	usertable  *mongo.Collection
+	history    *mongo.Collection
+}
+
+// mongoHistoryEntry - документ истории счета, индекс historyIndex (bic, ban, ts).
+type mongoHistoryEntry struct {
+	TransferID string    `bson:"transferId"`
+	Bic        string    `bson:"bic"`
+	Ban        string    `bson:"ban"`
+	Timestamp  time.Time `bson:"ts"`
+	Amount     int64     `bson:"amount"`
+}
+
+// mongoRecord - документ записи нагрузки ключ-значение, ключ хранится в _id.
+type mongoRecord struct {
+	Key   string `bson:"_id"`
+	Value []byte `bson:"field0"`
+}
+
+// mongoAccount - документ счета, атрибуты присутствуют, только если счета загружены с ними.
+type mongoAccount struct {
+	BicBan   string `bson:"bicBan"`
+	Balance  int64  `bson:"balance"`
+	Name     string `bson:"name,omitempty"`
+	Currency string `bson:"currency,omitempty"`
+	Status   string `bson:"status,omitempty"`
+	Padding  string `bson:"padding,omitempty"`
 }
 
 type AggregateResult struct {
@@ -133,14 +187,22 @@ func NewMongoDBCluster(dbURL string, poolSize uint64, sharded bool, setDirect bo
 	transfers := db.Collection("transfers", majorityCollectionOpts)
 	settings := db.Collection("settings")
 	checksum := db.Collection("checksum")
+	journal := db.Collection("journal", majorityCollectionOpts)
+	bankTotals := db.Collection("bank_total", majorityCollectionOpts)
+	usertable := db.Collection("usertable", majorityCollectionOpts)
+	history := db.Collection("history", majorityCollectionOpts)
 
 	return &MongoDBCluster{
 			db: db,
 			mongoModel: mongoModel{
-				accounts:  accounts,
-				transfers: transfers,
-				settings:  settings,
-				checksum:  checksum,
+				accounts:   accounts,
+				transfers:  transfers,
+				settings:   settings,
+				checksum:   checksum,
+				journal:    journal,
+				bankTotals: bankTotals,
+				usertable:  usertable,
+				history:    history,
 			},
 			client:  client,
 			sharded: sharded,
@@ -210,6 +272,10 @@ func (cluster *MongoDBCluster) BootstrapDB(count int, seed int) error {
 	}
 	llog.Debugf("Cleaned checksum collection \n")
 
+	if err = cluster.createHistory(); err != nil {
+		return merry.Prepend(err, "failed to create history")
+	}
+
 	if insertResult, err = cluster.mongoModel.settings.InsertOne(context.TODO(), bson.D{primitive.E{Key: "count", Value: count}}, &options.InsertOneOptions{}); err != nil {
 		return merry.Prepend(err, "failed to insert count value in mongodb settings")
 	}
@@ -222,6 +288,14 @@ func (cluster *MongoDBCluster) BootstrapDB(count int, seed int) error {
 
 	llog.Debugf("added seed in setting with id %v", insertResult)
 
+	if cluster.schemaTemplate != "" {
+		if err = cluster.applySchemaTemplate(count, seed); err != nil {
+			return merry.Prepend(err, "failed to apply schema template")
+		}
+
+		return cluster.checkCollections()
+	}
+
 	accountIndex := mongo.IndexModel{
 		Keys:    bson.D{primitive.E{Key: "bicBan", Value: 1}},
 		Options: options.Index().SetUnique(true).SetName("accountIndex"),
@@ -239,9 +313,52 @@ func (cluster *MongoDBCluster) BootstrapDB(count int, seed int) error {
 		}
 	}
 
+	return cluster.checkCollections()
+}
+
+// SetSchemaTemplate - задать пользовательский шаблон индексов и шардирования.
+// Шаблон заменяет встроенное создание индекса accountIndex и шардирование.
+func (cluster *MongoDBCluster) SetSchemaTemplate(schemaTemplate string) {
+	cluster.schemaTemplate = schemaTemplate
+}
+
+func (cluster *MongoDBCluster) applySchemaTemplate(count int, seed int) error {
+	rendered, err := renderSchema(cluster.schemaTemplate, count, seed)
+	if err != nil {
+		return err
+	}
+
+	var schema mongoSchema
+	if err = bson.UnmarshalExtJSON([]byte(rendered), false, &schema); err != nil {
+		return merry.Prepend(err, "failed to parse mongo schema template")
+	}
+
+	for _, command := range schema.Commands {
+		database := cluster.db
+		if command.DB != "" {
+			database = cluster.client.Database(command.DB)
+		}
+
+		llog.Debugf("Running schema command %v in %s", command.Command, database.Name())
+
+		if singleResult := database.RunCommand(context.TODO(), command.Command); singleResult.Err() != nil {
+			return merry.Prepend(singleResult.Err(), fmt.Sprintf("failed to run schema command %v", command.Command))
+		}
+	}
+
 	return nil
 }
 
+// checkCollections - проверить, что созданы коллекции, необходимые для pop и pay.
+func (cluster *MongoDBCluster) checkCollections() error {
+	names, err := cluster.db.ListCollectionNames(context.TODO(), bson.D{})
+	if err != nil {
+		return merry.Prepend(err, "failed to list collections")
+	}
+
+	return checkRequiredTables(mongoRequiredCollections, names)
+}
+
 // GetClusterType - получить тип DBCluster.
 func (cluster *MongoDBCluster) GetClusterType() DBClusterType {
 	return MongoDBClusterType
@@ -279,6 +396,14 @@ func (cluster *MongoDBCluster) InsertAccount(acc model.Account) (err error) {
 	var account mongo.InsertOneModel
 
 	account.Document = bson.D{primitive.E{Key: "bicBan", Value: fmt.Sprintf("%v%v", acc.Bic, acc.Ban)}, {Key: "balance", Value: acc.Balance.UnscaledBig().Int64()}}
+	if acc.Payload != nil {
+		account.Document = append(account.Document.(bson.D),
+			primitive.E{Key: "name", Value: acc.Payload.Name},
+			primitive.E{Key: "currency", Value: acc.Payload.Currency},
+			primitive.E{Key: "status", Value: acc.Payload.Status},
+			primitive.E{Key: "padding", Value: acc.Payload.Padding},
+		)
+	}
 
 	if insertAccountResult, err = cluster.mongoModel.accounts.InsertOne(context.TODO(), account.Document); err != nil {
 		if mongo.IsDuplicateKeyError(err) {
@@ -509,6 +634,18 @@ func (cluster *MongoDBCluster) MakeAtomicTransfer(transfer *model.Transfer, clie
 		}
 		llog.Tracef("Inserted transfer with %v and document Id %v", transfer.Id, insertResult)
 
+		if cluster.journal {
+			if err = cluster.appendJournal(sessCtx, transfer); err != nil {
+				return nil, merry.Prepend(err, "failed to journal transfer")
+			}
+		}
+
+		if cluster.history {
+			if err = cluster.appendHistory(sessCtx, transfer, time.Now().UTC()); err != nil {
+				return nil, merry.Prepend(err, "failed to record transfer history")
+			}
+		}
+
 		return nil, nil
 	}
 
@@ -527,9 +664,193 @@ func (cluster *MongoDBCluster) MakeAtomicTransfer(transfer *model.Transfer, clie
 	return nil
 }
 
-// FetchAccounts - получить список аккаунтов
+// appendJournal - добавить проводки перевода и обновить агрегаты по БИК в транзакции перевода.
+func (cluster *MongoDBCluster) appendJournal(sessCtx mongo.SessionContext, transfer *model.Transfer) error {
+	entries := journalEntries(transfer)
+	docs := make([]interface{}, 0, len(entries))
+
+	for _, entry := range entries {
+		docs = append(docs, bson.D{
+			{Key: "transferId", Value: transfer.Id.String()},
+			{Key: "leg", Value: entry.Leg},
+			{Key: "bic", Value: entry.Account.Bic},
+			{Key: "ban", Value: entry.Account.Ban},
+			{Key: "amount", Value: entry.Amount},
+		})
+	}
+
+	if _, err := cluster.mongoModel.journal.InsertMany(sessCtx, docs); err != nil {
+		return merry.Prepend(err, "failed to insert journal entries")
+	}
+
+	for _, delta := range bankTotalDeltas(transfer) {
+		if _, err := cluster.mongoModel.bankTotals.UpdateOne(
+			sessCtx,
+			bson.D{primitive.E{Key: "_id", Value: delta.Bic}},
+			bson.D{primitive.E{Key: "$inc", Value: bson.D{{Key: "balance", Value: delta.Delta}}}},
+		); err != nil {
+			return merry.Prepend(err, "failed to update bank total")
+		}
+	}
+
+	return nil
+}
+
+// EnableJournal - подготовить журнал и агрегаты по БИК и журналировать последующие переводы.
+func (cluster *MongoDBCluster) EnableJournal() error {
+	ctx := context.TODO()
+
+	if err := cluster.mongoModel.journal.Drop(ctx); err != nil {
+		return merry.Prepend(err, "failed to clean journal")
+	}
+
+	if err := cluster.mongoModel.bankTotals.Drop(ctx); err != nil {
+		return merry.Prepend(err, "failed to clean bank totals")
+	}
+
+	// коллекции создаются заранее: в транзакции перевода создание коллекций недоступно
+	if err := cluster.db.CreateCollection(ctx, cluster.mongoModel.journal.Name()); err != nil {
+		return merry.Prepend(err, "failed to create journal")
+	}
+
+	accountSums, err := cluster.sumByBic(cluster.mongoModel.accounts, mongoAccountBic, "$balance")
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate bank totals")
+	}
+
+	docs := make([]interface{}, 0, len(accountSums))
+	for bic, sum := range accountSums {
+		docs = append(docs, bson.D{
+			{Key: "_id", Value: bic},
+			{Key: "initial", Value: sum},
+			{Key: "balance", Value: sum},
+		})
+	}
+
+	if len(docs) == 0 {
+		err = cluster.db.CreateCollection(ctx, cluster.mongoModel.bankTotals.Name())
+	} else {
+		_, err = cluster.mongoModel.bankTotals.InsertMany(ctx, docs)
+	}
+	if err != nil {
+		return merry.Prepend(err, "failed to insert bank totals")
+	}
+
+	cluster.journal = true
+
+	return nil
+}
+
+// CheckJournal - сверить агрегаты по БИК с балансами счетов и журналом переводов.
+func (cluster *MongoDBCluster) CheckJournal() error {
+	cursor, err := cluster.mongoModel.bankTotals.Find(context.TODO(), bson.D{})
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch bank totals")
+	}
+
+	var documents []struct {
+		Bic     string `bson:"_id"`
+		Initial int64  `bson:"initial"`
+		Balance int64  `bson:"balance"`
+	}
+	if err = cursor.All(context.TODO(), &documents); err != nil {
+		return merry.Prepend(err, "failed to decode bank totals")
+	}
+
+	totals := make(map[string]bankTotal, len(documents))
+	for _, document := range documents {
+		totals[document.Bic] = bankTotal{Initial: document.Initial, Balance: document.Balance}
+	}
+
+	accountSums, err := cluster.sumByBic(cluster.mongoModel.accounts, mongoAccountBic, "$balance")
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate account totals")
+	}
+
+	journalSums, err := cluster.sumByBic(cluster.mongoModel.journal, "$bic", "$amount")
+	if err != nil {
+		return merry.Prepend(err, "failed to calculate journal totals")
+	}
+
+	return compareJournal(totals, accountSums, journalSums)
+}
+
+// sumByBic - просуммировать поле документов коллекции с группировкой по БИК.
+func (cluster *MongoDBCluster) sumByBic(
+	collection *mongo.Collection,
+	bic interface{},
+	field string,
+) (map[string]int64, error) {
+	pipe := []bson.M{
+		{"$group": bson.M{
+			"_id": bic,
+			"sum": bson.M{"$sum": field},
+		}},
+	}
+
+	cursor, err := collection.Aggregate(context.TODO(), pipe, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	var results []AggregateResult
+	if err = cursor.All(context.TODO(), &results); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	sums := make(map[string]int64, len(results))
+	for _, result := range results {
+		sums[result.ID] = result.Balance
+	}
+
+	return sums, nil
+}
+
+// FetchAccounts - получить список аккаунтов вместе с атрибутами.
 func (cluster *MongoDBCluster) FetchAccounts() ([]model.Account, error) {
-	return nil, nil
+	opts := options.Find().SetProjection(bson.M{"_id": 0})
+
+	cursor, err := cluster.mongoModel.accounts.Find(context.TODO(), bson.D{}, opts)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to fetch accounts")
+	}
+	defer cursor.Close(context.TODO())
+
+	var accounts []model.Account
+
+	for cursor.Next(context.TODO()) {
+		var document mongoAccount
+		if err = cursor.Decode(&document); err != nil {
+			return nil, merry.Prepend(err, "failed to decode account")
+		}
+
+		if len(document.BicBan) < mongoBicLength {
+			return nil, merry.Errorf("unexpected account key '%s'", document.BicBan)
+		}
+
+		account := model.Account{ //nolint:exhaustivestruct
+			Bic:     document.BicBan[:mongoBicLength],
+			Ban:     document.BicBan[mongoBicLength:],
+			Balance: inf.NewDec(document.Balance, 0),
+		}
+
+		if document.Name != "" || document.Currency != "" || document.Status != "" || document.Padding != "" {
+			account.Payload = &model.AccountPayload{
+				Name:     document.Name,
+				Currency: document.Currency,
+				Status:   document.Status,
+				Padding:  document.Padding,
+			}
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	if err = cursor.Err(); err != nil {
+		return nil, merry.Prepend(err, "failed to fetch accounts")
+	}
+
+	return accounts, nil
 }
 
 // FetchBalance - получить баланс счета по атрибутам ключа счета.
@@ -564,69 +885,304 @@ func (cluster *MongoDBCluster) FetchBalance(bic string, ban string) (*inf.Dec, *
 	return &balances, &pendingAmount, nil
 }
 
-func (cluster *MongoDBCluster) StartStatisticsCollect(statInterval time.Duration) error {
+// BootstrapKV - пересоздать коллекцию записей нагрузки ключ-значение.
+func (cluster *MongoDBCluster) BootstrapKV() error {
+	ctx := context.TODO()
+
+	if err := cluster.mongoModel.usertable.Drop(ctx); err != nil {
+		return merry.Prepend(err, "failed to drop usertable")
+	}
 
-	errChan := make(chan error)
+	// коллекция создается заранее: в транзакции read-modify-write создание коллекций недоступно
+	if err := cluster.db.CreateCollection(ctx, cluster.mongoModel.usertable.Name()); err != nil {
+		return merry.Prepend(err, "failed to create usertable")
+	}
 
-	llog.Debugln("starting of statistic goroutine...")
-	go cluster.getStatistics(statInterval, errChan)
+	return nil
+}
 
-	errorCheck := <-errChan
+// KVInsert - добавить запись, существующий ключ возвращается как ErrDuplicateKey.
+func (cluster *MongoDBCluster) KVInsert(key string, value []byte) error {
+	if _, err := cluster.mongoModel.usertable.InsertOne(
+		context.TODO(),
+		mongoRecord{Key: key, Value: value},
+	); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return merry.Wrap(ErrDuplicateKey)
+		}
 
-	if errorCheck != nil {
-		return merry.Prepend(errorCheck, "failed to get statistic")
+		return merry.Prepend(err, "failed to insert record")
 	}
 
 	return nil
 }
 
-func (cluster *MongoDBCluster) getStatistics(statInterval time.Duration, errChan chan error) {
+// KVRead - прочитать значение записи.
+func (cluster *MongoDBCluster) KVRead(key string) ([]byte, error) {
+	return cluster.readRecord(context.TODO(), key)
+}
 
-	var once sync.Once
-	var commandResult bson.M
-	var serverStatus []byte
+func (cluster *MongoDBCluster) readRecord(ctx context.Context, key string) ([]byte, error) {
+	var record mongoRecord
+	if err := cluster.mongoModel.usertable.FindOne(
+		ctx,
+		bson.D{primitive.E{Key: "_id", Value: key}},
+	).Decode(&record); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNoRows
+		}
+
+		return nil, merry.Prepend(err, "failed to read record")
+	}
+
+	return record.Value, nil
+}
 
-	const dateFormat = "02-01-2006_15:04:05"
+// KVUpdate - заменить значение существующей записи.
+func (cluster *MongoDBCluster) KVUpdate(key string, value []byte) error {
+	return cluster.updateRecord(context.TODO(), key, value)
+}
 
-	statFileName := fmt.Sprintf(mongoStatJsonFileTemplate, time.Now().Format(dateFormat))
-	llog.Debugln("Opening statistic file...")
-	statFile, err := os.OpenFile(statFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+func (cluster *MongoDBCluster) updateRecord(ctx context.Context, key string, value []byte) error {
+	result, err := cluster.mongoModel.usertable.UpdateOne(
+		ctx,
+		bson.D{primitive.E{Key: "_id", Value: key}},
+		bson.D{primitive.E{Key: "$set", Value: bson.D{{Key: "field0", Value: value}}}},
+	)
 	if err != nil {
-		errChan <- merry.Prepend(err, "failed to open statistic file")
+		return merry.Prepend(err, "failed to update record")
 	}
 
-	defer statFile.Close()
+	if result.MatchedCount == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
 
-	llog.Debugln("Opening statistic file: success")
+// KVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func (cluster *MongoDBCluster) KVScan(startKey string, count int) (int, error) {
+	ctx := context.TODO()
 
-	command := bson.D{primitive.E{Key: "serverStatus", Value: 1}}
+	cursor, err := cluster.mongoModel.usertable.Find(
+		ctx,
+		bson.D{primitive.E{Key: "_id", Value: bson.D{{Key: "$gte", Value: startKey}}}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(count)),
+	)
+	if err != nil {
+		return 0, merry.Prepend(err, "failed to scan records")
+	}
+	defer cursor.Close(ctx)
 
-	err = cluster.client.Database("admin").RunCommand(
-		context.TODO(),
-		command,
-	).Decode(&commandResult)
+	scanned := 0
+	for cursor.Next(ctx) {
+		var record mongoRecord
+		if err = cursor.Decode(&record); err != nil {
+			return scanned, merry.Prepend(err, "failed to decode record")
+		}
+		scanned++
+	}
+
+	return scanned, merry.Wrap(cursor.Err())
+}
+
+// KVReadModifyWrite - прочитать запись и заменить ее значение в одной транзакции.
+func (cluster *MongoDBCluster) KVReadModifyWrite(key string, value []byte) error {
+	ctx := context.TODO()
+
+	session, err := cluster.client.StartSession()
+	if err != nil {
+		return merry.Prepend(err, "failed to start session for transaction")
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if _, err := cluster.readRecord(sessCtx, key); err != nil {
+			return nil, err
+		}
+
+		return nil, cluster.updateRecord(sessCtx, key, value)
+	})
+	if errors.Is(err, ErrNoRows) {
+		return ErrNoRows
+	}
+
+	return merry.Wrap(err)
+}
+
+// createHistory - пересоздать историю переводов с индексом для выписки по счету.
+// Коллекция создается заранее: в транзакции перевода создание коллекций недоступно.
+func (cluster *MongoDBCluster) createHistory() error {
+	ctx := context.TODO()
+
+	if err := cluster.mongoModel.history.Drop(ctx); err != nil {
+		return merry.Prepend(err, "failed to clean history")
+	}
+
+	if err := cluster.db.CreateCollection(ctx, cluster.mongoModel.history.Name()); err != nil {
+		return merry.Prepend(err, "failed to create history collection")
+	}
+
+	historyIndex := mongo.IndexModel{
+		Keys: bson.D{
+			primitive.E{Key: "bic", Value: 1},
+			primitive.E{Key: "ban", Value: 1},
+			primitive.E{Key: "ts", Value: -1},
+		},
+		Options: options.Index().SetName("historyIndex"),
+	}
+	if _, err := cluster.mongoModel.history.Indexes().CreateOne(ctx, historyIndex); err != nil {
+		return merry.Prepend(err, "failed to create history index")
+	}
+
+	return nil
+}
+
+// appendHistory - добавить записи перевода в историю счетов отправителя и получателя.
+func (cluster *MongoDBCluster) appendHistory(
+	sessCtx mongo.SessionContext,
+	transfer *model.Transfer,
+	timestamp time.Time,
+) error {
+	entries := journalEntries(transfer)
+	docs := make([]interface{}, 0, len(entries))
+
+	for _, entry := range entries {
+		docs = append(docs, mongoHistoryEntry{
+			TransferID: transfer.Id.String(),
+			Bic:        entry.Account.Bic,
+			Ban:        entry.Account.Ban,
+			Timestamp:  timestamp,
+			Amount:     entry.Amount,
+		})
+	}
+
+	if _, err := cluster.mongoModel.history.InsertMany(sessCtx, docs); err != nil {
+		return merry.Prepend(err, "failed to insert history entries")
+	}
+
+	return nil
+}
+
+// EnableHistory - записывать последующие переводы в историю счетов.
+func (cluster *MongoDBCluster) EnableHistory() error {
+	cluster.history = true
+
+	return nil
+}
+
+// FetchStatement - последние limit записей истории счета по индексу historyIndex.
+func (cluster *MongoDBCluster) FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error) {
+	ctx := context.TODO()
 
+	cursor, err := cluster.mongoModel.history.Find(
+		ctx,
+		bson.D{{Key: "bic", Value: bic}, {Key: "ban", Value: ban}},
+		options.Find().SetSort(bson.D{{Key: "ts", Value: -1}}).SetLimit(int64(limit)),
+	)
 	if err != nil {
-		errChan <- merry.Prepend(err, "failed to get db.serverStatus() from mongodb")
+		return nil, merry.Prepend(err, "failed to fetch statement")
 	}
 
-	if serverStatus, err = bson.Marshal(commandResult); err != nil {
-		errChan <- merry.Prepend(err, "failed to unmarchal status json")
+	var docs []mongoHistoryEntry
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, merry.Prepend(err, "failed to decode statement")
 	}
 
-	separateString := fmt.Sprintf("\n %v \n", time.Now().Format(dateFormat))
-	if _, err = statFile.Write([]byte(separateString)); err != nil {
-		errChan <- merry.Prepend(err, "failed to write separate string to statistic file")
+	entries := make([]HistoryEntry, 0, len(docs))
+	for _, doc := range docs {
+		transferID, err := uuid.Parse(doc.TransferID)
+		if err != nil {
+			return nil, merry.Prepend(err, "failed to parse transfer id")
+		}
+
+		entries = append(entries, HistoryEntry{
+			TransferId: transferID,
+			Amount:     doc.Amount,
+			Timestamp:  doc.Timestamp,
+		})
+	}
+
+	return entries, nil
+}
+
+// ScanBankAccounts - страница счетов банка по индексу accountIndex: ключи bicBan
+// банка начинаются с его БИК, за которым следует номер счета.
+func (cluster *MongoDBCluster) ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error) {
+	ctx := context.TODO()
+
+	cursor, err := cluster.mongoModel.accounts.Find(
+		ctx,
+		bson.D{{Key: "bicBan", Value: bson.D{
+			{Key: "$gt", Value: bic + afterBan},
+			{Key: "$lt", Value: bic + "\uffff"},
+		}}},
+		options.Find().SetSort(bson.D{{Key: "bicBan", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to scan bank accounts")
+	}
+
+	var docs []mongoAccount
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, merry.Prepend(err, "failed to decode bank accounts")
 	}
 
-	if _, err = statFile.Write(serverStatus); err != nil {
-		errChan <- merry.Prepend(err, "failed to write data to statistic file")
+	accounts := make([]model.Account, 0, len(docs))
+	for _, doc := range docs {
+		accounts = append(accounts, model.Account{ //nolint:exhaustivestruct
+			Bic:     bic,
+			Ban:     doc.BicBan[len(bic):],
+			Balance: inf.NewDec(doc.Balance, 0),
+			Found:   true,
+		})
 	}
 
-	// если ошибки нет, то отправляем nil, чтобы продолжить работу
-	once.Do(func() {
-		errChan <- nil
+	return accounts, nil
+}
+
+// StartStatisticsCollect - периодически сохранять вывод db.serverStatus(), пока не будет отменен контекст.
+func (cluster *MongoDBCluster) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     MongoDB,
+		fileTemplate: mongoStatJsonFileTemplate,
+		collect:      cluster.getStatistics,
+		extract: extractStatPaths(map[string]string{
+			"mongo.insert":      "opcounters.insert",
+			"mongo.query":       "opcounters.query",
+			"mongo.update":      "opcounters.update",
+			"mongo.connections": "connections.current",
+		}),
 	})
+}
+
+func (cluster *MongoDBCluster) getStatistics() (interface{}, error) {
+	var commandResult bson.M
+
+	command := bson.D{primitive.E{Key: "serverStatus", Value: 1}}
+
+	if err := cluster.client.Database("admin").RunCommand(
+		context.TODO(),
+		command,
+	).Decode(&commandResult); err != nil {
+		return nil, merry.Prepend(err, "failed to get db.serverStatus() from mongodb")
+	}
 
-	time.Sleep(statInterval * time.Second)
+	// типы bson приводятся к json через relaxed extended json, чтобы числа остались числами
+	serverStatus, err := bson.MarshalExtJSON(commandResult, false, false)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to marshal server status")
+	}
+
+	return json.RawMessage(serverStatus), nil
+}
+
+// Close - закрыть соединения клиента mongo.
+func (cluster *MongoDBCluster) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoCloseTimeout)
+	defer cancel()
+
+	if err := cluster.client.Disconnect(ctx); err != nil {
+		llog.Warnf("failed to disconnect from mongo: %v", err)
+	}
 }
@@ -106,7 +106,7 @@ func MongoBootstrapDB(t *testing.T) {
 func MongoInsertAccount(t *testing.T) {
 	for i := 0; i < 2; i++ {
 
-		rand.Init(expectedCount, int(time.Now().UnixNano()), defaultBanRangeMultiplier)
+		rand.Init(expectedCount, int(time.Now().UnixNano()), defaultBanRangeMultiplier, time.Now().UnixNano(), i)
 		bic, ban := rand.NewBicAndBan()
 		balance := rand.NewStartBalance()
 		expectedAccount := model.Account{
@@ -1,6 +1,12 @@
 package cluster
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
 
 const (
 	bootstrapScript = `
@@ -14,6 +20,10 @@ CREATE TABLE IF NOT EXISTS account (
 	bic TEXT, -- bank identifier code
 	ban TEXT, -- bank account number within the bank
 	balance DECIMAL, -- account balance
+	name TEXT, -- customer name, filled if accounts are populated with payload
+	currency TEXT, -- ISO 4217 currency code
+	status TEXT, -- 'active', 'blocked' or 'closed'
+	padding TEXT, -- filler of configurable size to get a realistic row width
 	PRIMARY KEY(bic, ban)
 );
 TRUNCATE account;
@@ -39,8 +49,14 @@ TRUNCATE checksum;
 `
 )
 
+// sqlRequiredTables - таблицы, которые должен создать скрипт инициализации postgres и cockroach.
+var sqlRequiredTables = []string{"setting", "account", "transfer", "checksum"}
+
 // --- fetching ------------------
 const (
+	fetchTableNames = `SELECT table_name FROM information_schema.tables
+  WHERE table_schema = current_schema();`
+
 	fetchTotal = `SELECT amount FROM checksum WHERE name = 'total;'`
 
 	fetchSettings = `SELECT value FROM setting WHERE KEY in ('count', 'seed');`
@@ -57,12 +73,17 @@ const (
   FROM account WHERE bic = $1 AND ban = $2;`
 
 	fetchDeadTransfers = `SELECT transfer_id FROM transfer;`
+
+	fetchAccounts = `SELECT * FROM account;`
 )
 
 // --- insertions ----------------
 const (
 	upsertAccount = `INSERT INTO account (bic, ban, balance) VALUES ($1, $2, $3);`
 
+	upsertAccountPayload = `INSERT INTO account (bic, ban, balance, name, currency, status, padding)
+  VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
 	insertSetting = `INSERT INTO setting (key, value) VALUES ($1, $2);`
 
 	persistTotal = `INSERT INTO checksum (name, amount) VALUES('total', $1)
@@ -88,7 +109,64 @@ const (
 	AND client_id = $2 AND client_timestamp > now() - interval '30 second';`
 )
 
+// bootstrapSQLSchema выполняет встроенный или пользовательский скрипт инициализации
+// и проверяет, что все необходимые таблицы созданы.
+func bootstrapSQLSchema(ctx context.Context, pool *pgxpool.Pool, schemaTemplate string, count, seed int) error {
+	script := bootstrapScript
+	if schemaTemplate != "" {
+		var err error
+		if script, err = renderSchema(schemaTemplate, count, seed); err != nil {
+			return err
+		}
+	}
+
+	if _, err := pool.Exec(ctx, script); err != nil {
+		return merry.Prepend(err, "failed to execute bootstrap script")
+	}
+
+	if err := prepareSQLHistory(ctx, pool); err != nil {
+		return err
+	}
+
+	rows, err := pool.Query(ctx, fetchTableNames)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch table names")
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return merry.Prepend(err, "failed to scan table name")
+		}
+		tables = append(tables, name)
+	}
+
+	return checkRequiredTables(sqlRequiredTables, tables)
+}
+
 const (
 	timeOutSettings = 5
 	txTimeout       = 5 * time.Second
 )
+
+// Файлы и запросы периодической статистики postgres и cockroach.
+const (
+	pgStatJsonFileTemplate   = "pg_stat_%v.jsonl"
+	crdbStatJsonFileTemplate = "crdb_stat_%v.jsonl"
+
+	pgStatDatabase = `SELECT datname::TEXT AS datname, numbackends, xact_commit, xact_rollback,
+	blks_read, blks_hit, tup_returned, tup_fetched, tup_inserted, tup_updated, tup_deleted,
+	conflicts, deadlocks
+	FROM pg_stat_database WHERE datname = current_database();`
+
+	pgStatActivity = `SELECT coalesce(state, '') AS state, coalesce(wait_event_type, '') AS wait_event_type,
+	count(*) AS count
+	FROM pg_stat_activity WHERE datname = current_database() GROUP BY 1, 2;`
+
+	crdbGossipNodes = `SELECT node_id, address, is_live, ranges, leases FROM crdb_internal.gossip_nodes;`
+
+	crdbNodeMetrics = `SELECT name, value FROM crdb_internal.node_metrics
+	WHERE name LIKE 'sql.txn.%' OR name LIKE 'txn.%' OR name IN ('sql.conns', 'liveness.livenodes');`
+)
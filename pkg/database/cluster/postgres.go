@@ -8,7 +8,6 @@ import (
 	"context"
 	"errors"
 	"strconv"
-	"strings"
 	"time"
 
 	"gitlab.com/picodata/stroppy/internal/model"
@@ -25,50 +24,67 @@ import (
 )
 
 type PostgresCluster struct {
-	pool *pgxpool.Pool
+	router         *sqlRouter
+	txSettings     SQLTxSettings
+	schemaTemplate string
+	journal        bool
+	multiCurrency  bool
+	history        bool
 }
 
-func NewPostgresCluster(dbURL string, connectionPoolCount int) (*PostgresCluster, error) {
+func NewPostgresCluster(
+	dbURL string,
+	connectionPoolCount int,
+	txSettings SQLTxSettings,
+	routing RoutingSettings,
+) (*PostgresCluster, error) {
 	llog.Infof("Establishing connection to pg on %v", dbURL)
 
-	poolConfig, err := pgxpool.ParseConfig(dbURL)
-	if err != nil {
-		return nil, merry.Wrap(err)
-	}
-
-	if !strings.Contains(dbURL, "pool_max_conns") {
-		poolConfig.MaxConns = int32(connectionPoolCount)
+	if err := txSettings.Validate(); err != nil {
+		return nil, merry.Prepend(err, "invalid transaction settings")
 	}
 
-	llog.Debugf("Connection pool size: %v", poolConfig.MaxConns)
-
-	pgPool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	router, err := newSQLRouter(context.Background(), dbURL, connectionPoolCount, routing)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
 	return &PostgresCluster{
-		pgPool,
+		router:     router,
+		txSettings: txSettings,
 	}, nil
 }
 
+// pool - пул соединений к адресу, на который направляются изменения. Запросы драйвера
+// идут через методы маршрутизатора, которые учитывают их результат, пул нужен тестам.
+func (self *PostgresCluster) pool() *pgxpool.Pool {
+	return self.router.write().pool
+}
+
+// SetSchemaTemplate - задать пользовательский шаблон скрипта инициализации.
+func (self *PostgresCluster) SetSchemaTemplate(schemaTemplate string) {
+	self.schemaTemplate = schemaTemplate
+}
+
 func (*PostgresCluster) GetClusterType() DBClusterType {
 	return PostgresClusterType
 }
 
 func (self *PostgresCluster) BootstrapDB(count int, seed int) error {
 	llog.Infof("Creating the tables...")
-	_, err := self.pool.Exec(context.Background(), bootstrapScript)
+	err := self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return bootstrapSQLSchema(context.Background(), pool, self.schemaTemplate, count, seed)
+	})
 	if err != nil {
-		return merry.Prepend(err, "failed to execute bootstrap script")
+		return merry.Prepend(err, "failed to bootstrap schema")
 	}
 	llog.Infof("Populating settings...")
-	_, err = self.pool.Exec(context.Background(), insertSetting, "count", strconv.Itoa(count))
+	_, err = self.router.exec(context.Background(), insertSetting, "count", strconv.Itoa(count))
 	if err != nil {
 		return merry.Prepend(err, "failed to populate settings")
 	}
 
-	_, err = self.pool.Exec(context.Background(), insertSetting, "seed", strconv.Itoa(seed))
+	_, err = self.router.exec(context.Background(), insertSetting, "seed", strconv.Itoa(seed))
 	if err != nil {
 		return merry.Prepend(err, "failed to save seed")
 	}
@@ -79,7 +95,7 @@ func (self *PostgresCluster) BootstrapDB(count int, seed int) error {
 func (self *PostgresCluster) FetchSettings() (Settings, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeOutSettings*time.Second)
 	defer cancel()
-	rows, err := self.pool.Query(ctx, fetchSettings)
+	rows, err := self.router.query(ctx, fetchSettings)
 	if err != nil {
 		return Settings{
 			Count: 0,
@@ -115,7 +131,8 @@ func (self *PostgresCluster) FetchSettings() (Settings, error) {
 }
 
 func (self *PostgresCluster) InsertAccount(acc model.Account) error {
-	_, err := self.pool.Exec(context.Background(), upsertAccount, acc.Bic, acc.Ban, acc.Balance.UnscaledBig().Int64())
+	query, args := accountInsertQuery(acc)
+	_, err := self.router.exec(context.Background(), query, args...)
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			if pgErr.Code == pgerrcode.UniqueViolation {
@@ -130,27 +147,18 @@ func (self *PostgresCluster) InsertAccount(acc model.Account) error {
 }
 
 func (self *PostgresCluster) FetchAccounts() ([]model.Account, error) {
-	rows, err := self.pool.Query(context.Background(), `SELECT bic, ban, balance FROM account;`)
-	if err != nil {
-		return nil, merry.Prepend(err, "failed to fetch accounts")
-	}
-	var accs []model.Account
-	for rows.Next() {
-		var Balance int64
-		dec := new(inf.Dec)
-		var acc model.Account
-		if err := rows.Scan(&acc.Bic, &acc.Ban, &Balance); err != nil {
-			return nil, merry.Prepend(err, "failed to scan account for FetchAccounts")
-		}
-		dec.SetUnscaled(Balance)
-		acc.Balance = dec
-		accs = append(accs, acc)
-	}
-	return accs, nil
+	var accounts []model.Account
+
+	err := self.router.onRead(func(pool *pgxpool.Pool) (err error) {
+		accounts, err = fetchSQLAccounts(context.Background(), pool)
+		return err
+	})
+
+	return accounts, err
 }
 
 func (self *PostgresCluster) FetchTotal() (*inf.Dec, error) {
-	row := self.pool.QueryRow(context.Background(), fetchTotal)
+	row := self.router.queryRow(context.Background(), fetchTotal)
 
 	var amount inf.Dec
 	err := row.Scan(&amount)
@@ -165,7 +173,7 @@ func (self *PostgresCluster) FetchTotal() (*inf.Dec, error) {
 }
 
 func (self *PostgresCluster) PersistTotal(total inf.Dec) error {
-	res, err := self.pool.Exec(context.Background(), persistTotal, total.UnscaledBig().Int64())
+	res, err := self.router.exec(context.Background(), persistTotal, total.UnscaledBig().Int64())
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -177,7 +185,7 @@ func (self *PostgresCluster) PersistTotal(total inf.Dec) error {
 }
 
 func (self *PostgresCluster) CheckBalance() (*inf.Dec, error) {
-	row := self.pool.QueryRow(context.Background(), checkBalance)
+	row := self.router.queryRow(context.Background(), checkBalance)
 	var totalBalance int64
 	err := row.Scan(&totalBalance)
 	if err != nil {
@@ -191,7 +199,7 @@ func (self *PostgresCluster) CheckBalance() (*inf.Dec, error) {
 }
 
 func (self *PostgresCluster) InsertTransfer(transfer *model.Transfer) error {
-	res, err := self.pool.Exec(
+	res, err := self.router.exec(
 		context.Background(),
 		insertTransfer,
 		transfer.Id,
@@ -212,7 +220,7 @@ func (self *PostgresCluster) InsertTransfer(transfer *model.Transfer) error {
 }
 
 func (self *PostgresCluster) SetTransferState(state string, transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := self.pool.Exec(context.Background(), setTransferState, state, transferId, clientId)
+	res, err := self.router.exec(context.Background(), setTransferState, state, transferId, clientId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -224,7 +232,7 @@ func (self *PostgresCluster) SetTransferState(state string, transferId model.Tra
 }
 
 func (self *PostgresCluster) SetTransferClient(clientId uuid.UUID, transferId model.TransferId) error {
-	res, err := self.pool.Exec(context.Background(), setTransferClient, clientId, transferId)
+	res, err := self.router.exec(context.Background(), setTransferClient, clientId, transferId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -236,7 +244,7 @@ func (self *PostgresCluster) SetTransferClient(clientId uuid.UUID, transferId mo
 }
 
 func (self *PostgresCluster) ClearTransferClient(transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := self.pool.Exec(context.Background(), clearTransferClient, transferId, clientId)
+	res, err := self.router.exec(context.Background(), clearTransferClient, transferId, clientId)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -250,7 +258,7 @@ func (self *PostgresCluster) ClearTransferClient(transferId model.TransferId, cl
 }
 
 func (self *PostgresCluster) DeleteTransfer(transferId model.TransferId, clientId uuid.UUID) error {
-	res, err := self.pool.Exec(context.Background(), deleteTransfer, transferId, clientId)
+	res, err := self.router.exec(context.Background(), deleteTransfer, transferId, clientId)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return ErrNoRows
@@ -267,7 +275,7 @@ func (self *PostgresCluster) DeleteTransfer(transferId model.TransferId, clientI
 func (self *PostgresCluster) FetchTransfer(transferId model.TransferId) (*model.Transfer, error) {
 	t := new(model.Transfer)
 	t.InitEmptyTransfer(transferId)
-	row := self.pool.QueryRow(context.Background(), fetchTransfer, transferId)
+	row := self.router.queryRow(context.Background(), fetchTransfer, transferId)
 	// Ignore possible error, we will retry
 	var amount int64
 	if err := row.Scan(&t.Acs[0].Bic, &t.Acs[0].Ban, &t.Acs[1].Bic,
@@ -282,7 +290,7 @@ func (self *PostgresCluster) FetchTransfer(transferId model.TransferId) (*model.
 }
 
 func (self *PostgresCluster) FetchTransferClient(transferId model.TransferId) (*uuid.UUID, error) {
-	row := self.pool.QueryRow(context.Background(), fetchTransferClient, transferId)
+	row := self.router.queryRow(context.Background(), fetchTransferClient, transferId)
 
 	var clientId uuid.UUID
 	err := row.Scan(&clientId)
@@ -309,7 +317,7 @@ func (self *PostgresCluster) UpdateBalance(balance *inf.Dec, bic string, ban str
 }
 
 func (self *PostgresCluster) FetchBalance(bic string, ban string) (*inf.Dec, *inf.Dec, error) {
-	row := self.pool.QueryRow(context.Background(), fetchBalance, bic, ban)
+	row := self.router.readRow(context.Background(), fetchBalance, bic, ban)
 	var balance, pendingAmount inf.Dec
 	err := row.Scan(&balance, &pendingAmount)
 	if err != nil {
@@ -319,7 +327,7 @@ func (self *PostgresCluster) FetchBalance(bic string, ban string) (*inf.Dec, *in
 }
 
 func (self *PostgresCluster) FetchDeadTransfers() ([]model.TransferId, error) {
-	rows, err := self.pool.Query(context.Background(), fetchDeadTransfers)
+	rows, err := self.router.query(context.Background(), fetchDeadTransfers)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return []model.TransferId{}, nil
@@ -385,7 +393,7 @@ func TopUpMoney(ctx context.Context, tx pgx.Tx, acc model.Account, transfer mode
 		select balance from account where bic = $2 and ban = $3 -- that works only because of READ_COMMITTED isolation level
 	  ) as old_balance, now();
 	`,
-		transfer.Amount.UnscaledBig().Int64(),
+		transfer.Credit().UnscaledBig().Int64(),
 		acc.Bic,
 		acc.Ban,
 	)
@@ -411,14 +419,23 @@ func TopUpMoney(ctx context.Context, tx pgx.Tx, acc model.Account, transfer mode
 // MakeAtomicTransfer inserts new transfer (should be used as history in the future) and
 // update corresponding balances in a single SQL transaction
 func (self *PostgresCluster) MakeAtomicTransfer(transfer *model.Transfer, clientId uuid.UUID) error {
+	return self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return self.makeAtomicTransfer(pool, transfer)
+	})
+}
+
+func (self *PostgresCluster) makeAtomicTransfer(pool *pgxpool.Pool, transfer *model.Transfer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
 	defer cancel()
 
-	// RepeateableRead is sufficient to provide consistent balance update even though
+	// RepeateableRead (default) is sufficient to provide consistent balance update even though
 	// serialization anomalies are allowed that should not affect us (no dependable transaction, except obviously blocked rows)
-	tx, err := self.pool.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel: pgx.RepeatableRead,
-	})
+	txOptions, err := self.txSettings.TxOptions()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	tx, err := pool.BeginTx(ctx, txOptions)
 	if err != nil {
 		return merry.Prepend(err, "failed to acquire tx")
 	}
@@ -454,6 +471,25 @@ func (self *PostgresCluster) MakeAtomicTransfer(transfer *model.Transfer, client
 		return merry.Prepend(err, "failed to insert transfer")
 	}
 
+	if self.multiCurrency {
+		if err = ConvertTransferAmount(ctx, tx, transfer); err != nil {
+			return merry.Prepend(err, "failed to convert transfer amount")
+		}
+	}
+
+	switch self.txSettings.Locking {
+	case LockingOptimistic:
+		if err = CompareAndSetMoney(ctx, tx, *transfer); err != nil {
+			return merry.Prepend(err, "failed to make optimistic transfer")
+		}
+
+		return self.commitTransfer(ctx, tx, transfer)
+	case LockingForUpdate:
+		if err = LockAccountsForUpdate(ctx, tx, *transfer); err != nil {
+			return merry.Prepend(err, "failed to lock accounts")
+		}
+	}
+
 	//	If we always withdraw money first deadlock may occur.
 	//	Imagine we have concurrent txA (transfer X -> Y) and txB (transfer Y -> X).
 	//	We will see the following timeline:
@@ -497,8 +533,23 @@ func (self *PostgresCluster) MakeAtomicTransfer(transfer *model.Transfer, client
 		}
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
+	return self.commitTransfer(ctx, tx, transfer)
+}
+
+func (self *PostgresCluster) commitTransfer(ctx context.Context, tx pgx.Tx, transfer *model.Transfer) error {
+	if self.journal {
+		if err := AppendJournal(ctx, tx, transfer); err != nil {
+			return merry.Prepend(err, "failed to journal transfer")
+		}
+	}
+
+	if self.history {
+		if err := AppendHistory(ctx, tx, transfer, time.Now().UTC()); err != nil {
+			return merry.Prepend(err, "failed to record transfer history")
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok {
 			if pgerrcode.IsTransactionRollback(pgErr.Code) {
 				return ErrTxRollback
@@ -506,13 +557,119 @@ func (self *PostgresCluster) MakeAtomicTransfer(transfer *model.Transfer, client
 		}
 		return merry.Prepend(err, "failed to commit tx")
 	}
-	//nolint:golint,errcheck
-	merry.Prepend(err, "failed to insert new history item")
 
 	return nil
 }
 
-func (self *PostgresCluster) StartStatisticsCollect(_ time.Duration) error {
-	llog.Debugln("statistic for postgres not supported, watch grafana metrics, please")
+// EnableJournal - подготовить журнал и агрегаты по БИК и журналировать последующие переводы.
+func (self *PostgresCluster) EnableJournal() error {
+	if err := self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLJournal(context.Background(), pool)
+	}); err != nil {
+		return err
+	}
+
+	self.journal = true
+
+	return nil
+}
+
+// CheckJournal - сверить агрегаты по БИК с балансами счетов и журналом переводов.
+func (self *PostgresCluster) CheckJournal() error {
+	return self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return checkSQLJournal(context.Background(), pool)
+	})
+}
+
+// EnableMultiCurrency - заполнить таблицу курсов и пересчитывать последующие переводы по курсу.
+func (self *PostgresCluster) EnableMultiCurrency() error {
+	if err := self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLCurrencies(context.Background(), pool)
+	}); err != nil {
+		return err
+	}
+
+	self.multiCurrency = true
+
+	return nil
+}
+
+// CheckCurrencies - сверить суммы балансов по валютам с оборотами мультивалютных переводов.
+func (self *PostgresCluster) CheckCurrencies() error {
+	return self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return checkSQLCurrencies(context.Background(), pool)
+	})
+}
+
+// BootstrapKV - создать и очистить таблицу записей нагрузки ключ-значение.
+func (self *PostgresCluster) BootstrapKV() error {
+	return self.router.onWrite(func(pool *pgxpool.Pool) error {
+		return prepareSQLKV(context.Background(), pool)
+	})
+}
+
+// KVInsert - добавить запись нагрузки ключ-значение.
+func (self *PostgresCluster) KVInsert(key string, value []byte) error {
+	return sqlKVInsert(self.router, key, value)
+}
+
+// KVRead - прочитать значение записи.
+func (self *PostgresCluster) KVRead(key string) ([]byte, error) {
+	return sqlKVRead(self.router, key)
+}
+
+// KVUpdate - заменить значение существующей записи.
+func (self *PostgresCluster) KVUpdate(key string, value []byte) error {
+	return sqlKVUpdate(self.router, key, value)
+}
+
+// KVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func (self *PostgresCluster) KVScan(startKey string, count int) (int, error) {
+	return sqlKVScan(self.router, startKey, count)
+}
+
+// KVReadModifyWrite - прочитать запись с блокировкой и заменить ее значение.
+func (self *PostgresCluster) KVReadModifyWrite(key string, value []byte) error {
+	return sqlKVReadModifyWrite(self.router, key, value)
+}
+
+// EnableHistory - записывать последующие переводы в историю счетов.
+func (self *PostgresCluster) EnableHistory() error {
+	self.history = true
+
 	return nil
 }
+
+// FetchStatement - последние limit записей истории счета.
+func (self *PostgresCluster) FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error) {
+	return sqlFetchStatement(self.router, bic, ban, limit)
+}
+
+// ScanBankAccounts - страница счетов банка после afterBan.
+func (self *PostgresCluster) ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error) {
+	return sqlScanBankAccounts(self.router, bic, afterBan, limit)
+}
+
+// StartStatisticsCollect - периодически сохранять pg_stat_database и сводку pg_stat_activity
+// по каждому адресу кластера, пока не будет отменен контекст.
+func (self *PostgresCluster) StartStatisticsCollect(ctx context.Context, statInterval time.Duration) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     Postgres,
+		fileTemplate: pgStatJsonFileTemplate,
+		collect: func() (interface{}, error) {
+			return self.router.collectStatistics(map[string]string{
+				"pg_stat_database": pgStatDatabase,
+				"pg_stat_activity": pgStatActivity,
+			})
+		},
+		extract: func(data interface{}) map[string]float64 {
+			return sumStatColumns(data, "pg_stat_database", "pg.",
+				"numbackends", "xact_commit", "xact_rollback", "deadlocks")
+		},
+	})
+}
+
+// Close - закрыть пулы соединений ко всем адресам БД.
+func (self *PostgresCluster) Close() {
+	self.router.Close()
+}
@@ -19,7 +19,7 @@ var postgresCluster *PostgresCluster
 func (self *PostgresCluster) CheckTableExist(tableName string) (exist bool, err error) {
 	var name string
 	sqlQuery := fmt.Sprintf("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_NAME='%s';", tableName)
-	if err = self.pool.QueryRow(context.TODO(), sqlQuery).Scan(&name); err != nil {
+	if err = self.pool().QueryRow(context.TODO(), sqlQuery).Scan(&name); err != nil {
 		return false, err
 	}
 	if tableName != name {
@@ -30,7 +30,7 @@ func (self *PostgresCluster) CheckTableExist(tableName string) (exist bool, err
 
 func (self *PostgresCluster) TruncateTable(tableName string) error {
 	sqlString := fmt.Sprintf("TRUNCATE %s", tableName)
-	_, err := self.pool.Exec(context.TODO(), sqlString)
+	_, err := self.pool().Exec(context.TODO(), sqlString)
 	if err != nil {
 		return err
 	}
@@ -41,7 +41,7 @@ func (self *PostgresCluster) GetAccount(Bic string, Ban string) (Account model.A
 	var Balance int64
 	dec := new(inf.Dec)
 
-	if err := self.pool.QueryRow(
+	if err := self.pool().QueryRow(
 		context.TODO(),
 		`SELECT bic, ban, balance FROM account WHERE bic = $1 and ban = $2;`,
 		Bic,
@@ -63,7 +63,7 @@ func NewTestPostgresCluster(t *testing.T) {
 	if err != nil {
 		t.Fatal("Get environment error:", err)
 	}
-	postgresCluster, err = NewPostgresCluster(postgresUrlString, poolSize)
+	postgresCluster, err = NewPostgresCluster(postgresUrlString, poolSize, DefaultSQLTxSettings(), DefaultRoutingSettings())
 	if err != nil {
 		t.Fatal("Postgres cluster start fail:", err)
 	}
@@ -92,12 +92,12 @@ func PostgresBootstrapDB(t *testing.T) {
 	}
 
 	var count int
-	if err := postgresCluster.pool.QueryRow(context.TODO(), "SELECT COUNT(*) FROM account;").Scan(&count); err != nil {
+	if err := postgresCluster.pool().QueryRow(context.TODO(), "SELECT COUNT(*) FROM account;").Scan(&count); err != nil {
 		t.Errorf("TestCockroachBootstrapDB() received internal error %s, expected nil", err)
 	}
 	assert.Equal(t, count, 0, "Must be equal")
 
-	if err := postgresCluster.pool.QueryRow(context.TODO(), "SELECT COUNT(*) FROM transfer;").Scan(&count); err != nil {
+	if err := postgresCluster.pool().QueryRow(context.TODO(), "SELECT COUNT(*) FROM transfer;").Scan(&count); err != nil {
 		t.Errorf("TestCockroachBootstrapDB() received internal error %s, expected nil", err)
 	}
 	assert.Equal(t, count, 0, "Must be equal")
@@ -176,7 +176,7 @@ func PostgresMakeAtomicTransfer(t *testing.T) {
 		t.Errorf("TestMakeAtomicTransfer() received internal error %v, but expected nil", err)
 	}
 
-	if err := postgresCluster.pool.QueryRow(
+	if err := postgresCluster.pool().QueryRow(
 		context.TODO(),
 		`SELECT src_bic, src_ban, dst_bic, dst_ban, amount FROM transfer WHERE transfer_id = $1;`, expectedTransfer.Id).Scan(
 		&receivedTransfer.Acs[0].Bic,
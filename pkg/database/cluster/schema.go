@@ -0,0 +1,63 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/ansel1/merry"
+)
+
+// SchemaTemplateFileName - имя файла пользовательского шаблона схемы в каталоге настроек БД.
+const SchemaTemplateFileName = "schema.tmpl"
+
+// SchemaCustomizable - кластер, схему которого можно задать пользовательским шаблоном
+// вместо встроенного скрипта инициализации.
+type SchemaCustomizable interface {
+	SetSchemaTemplate(schemaTemplate string)
+}
+
+// SchemaParams - параметры, доступные в шаблоне схемы как {{.Count}} и {{.Seed}}.
+type SchemaParams struct {
+	Count int
+	Seed  int
+}
+
+// renderSchema - подставить параметры запуска в шаблон схемы.
+func renderSchema(schemaTemplate string, count int, seed int) (string, error) {
+	parsed, err := template.New("schema").Option("missingkey=error").Parse(schemaTemplate)
+	if err != nil {
+		return "", merry.Prepend(err, "failed to parse schema template")
+	}
+
+	var rendered bytes.Buffer
+	if err = parsed.Execute(&rendered, SchemaParams{Count: count, Seed: seed}); err != nil {
+		return "", merry.Prepend(err, "failed to render schema template")
+	}
+
+	return rendered.String(), nil
+}
+
+// checkRequiredTables - убедиться, что все необходимые драйверу таблицы созданы.
+func checkRequiredTables(required []string, existing []string) error {
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+
+	var missing []string
+	for _, name := range required {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return merry.Errorf("required tables %v do not exist after bootstrap", missing)
+	}
+
+	return nil
+}
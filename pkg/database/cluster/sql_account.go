@@ -0,0 +1,106 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/inf.v0"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// accountInsertQuery - запрос и параметры вставки счета. Атрибуты счета пишутся, только если
+// они сгенерированы, поэтому пользовательские схемы без этих колонок продолжают работать.
+func accountInsertQuery(acc model.Account) (string, []interface{}) {
+	balance := acc.Balance.UnscaledBig().Int64()
+
+	if acc.Payload == nil {
+		return upsertAccount, []interface{}{acc.Bic, acc.Ban, balance}
+	}
+
+	return upsertAccountPayload, []interface{}{
+		acc.Bic,
+		acc.Ban,
+		balance,
+		acc.Payload.Name,
+		acc.Payload.Currency,
+		acc.Payload.Status,
+		acc.Payload.Padding,
+	}
+}
+
+// fetchSQLAccounts - прочитать все счета вместе с атрибутами. Колонки сопоставляются по имени,
+// атрибуты заполняются, если таблица их содержит и они заданы.
+func fetchSQLAccounts(ctx context.Context, pool *pgxpool.Pool) ([]model.Account, error) {
+	rows, err := pool.Query(ctx, fetchAccounts)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to fetch accounts")
+	}
+	defer rows.Close()
+
+	var accs []model.Account
+
+	for rows.Next() {
+		var (
+			acc                            model.Account
+			balance                        int64
+			name, currency, status, padded *string
+		)
+
+		dest := make([]interface{}, len(rows.FieldDescriptions()))
+		for i, field := range rows.FieldDescriptions() {
+			switch string(field.Name) {
+			case "bic":
+				dest[i] = &acc.Bic
+			case "ban":
+				dest[i] = &acc.Ban
+			case "balance":
+				dest[i] = &balance
+			case "name":
+				dest[i] = &name
+			case "currency":
+				dest[i] = &currency
+			case "status":
+				dest[i] = &status
+			case "padding":
+				dest[i] = &padded
+			}
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, merry.Prepend(err, "failed to scan account for FetchAccounts")
+		}
+
+		acc.Balance = inf.NewDec(balance, 0)
+
+		if name != nil || currency != nil || status != nil || padded != nil {
+			acc.Payload = &model.AccountPayload{
+				Name:     stringOrEmpty(name),
+				Currency: stringOrEmpty(currency),
+				Status:   stringOrEmpty(status),
+				Padding:  stringOrEmpty(padded),
+			}
+		}
+
+		accs = append(accs, acc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, merry.Prepend(err, "failed to fetch accounts")
+	}
+
+	return accs, nil
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
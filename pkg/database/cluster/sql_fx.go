@@ -0,0 +1,172 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/inf.v0"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// fxScript - таблицы мультивалютного режима создаются при его включении, как и журнал.
+// Обороты переводов пишутся отдельной строкой на перевод, а не в общие агрегаты по валютам,
+// чтобы несколько валют не становились точкой конкуренции всех транзакций.
+var fxScript = []string{
+	`CREATE TABLE IF NOT EXISTS fx_rate (
+	src_currency TEXT, -- currency of the debited account
+	dst_currency TEXT, -- currency of the credited account
+	rate BIGINT, -- cross rate in millionths
+	PRIMARY KEY(src_currency, dst_currency)
+);`,
+	`TRUNCATE fx_rate;`,
+	`CREATE TABLE IF NOT EXISTS fx_transfer (
+	transfer_id UUID PRIMARY KEY, -- transfer UUID
+	src_currency TEXT, -- currency of the debit amount
+	dst_currency TEXT, -- currency of the credit amount
+	debit DECIMAL, -- amount debited in the source currency
+	credit DECIMAL -- amount credited in the destination currency
+);`,
+	`TRUNCATE fx_transfer;`,
+	`CREATE TABLE IF NOT EXISTS currency_total (
+	currency TEXT PRIMARY KEY, -- ISO 4217 currency code
+	initial_balance DECIMAL -- sum of balances when the multi-currency mode was enabled
+);`,
+	`TRUNCATE currency_total;`,
+	`INSERT INTO currency_total (currency, initial_balance)
+	SELECT currency, SUM(balance) FROM account GROUP BY currency;`,
+}
+
+const (
+	countAccountsWithoutCurrency = `SELECT COUNT(*) FROM account WHERE currency IS NULL OR currency = '';`
+
+	insertFXRate = `INSERT INTO fx_rate (src_currency, dst_currency, rate) VALUES ($1, $2, $3);`
+
+	// валюты обоих счетов и курс читаются одним запросом в транзакции перевода
+	fetchTransferRate = `SELECT src.currency, dst.currency, fx.rate
+	FROM account src, account dst, fx_rate fx
+	WHERE src.bic = $1 AND src.ban = $2 AND dst.bic = $3 AND dst.ban = $4
+	AND fx.src_currency = src.currency AND fx.dst_currency = dst.currency;`
+
+	insertFXTransfer = `INSERT INTO fx_transfer (transfer_id, src_currency, dst_currency, debit, credit)
+	VALUES ($1, $2, $3, $4, $5);`
+
+	fetchCurrencyInitial = `SELECT currency, initial_balance FROM currency_total;`
+
+	fetchCurrencyDebit = `SELECT src_currency, SUM(debit) FROM fx_transfer GROUP BY src_currency;`
+
+	fetchCurrencyCredit = `SELECT dst_currency, SUM(credit) FROM fx_transfer GROUP BY dst_currency;`
+
+	fetchAccountCurrencyTotals = `SELECT currency, SUM(balance) FROM account GROUP BY currency;`
+)
+
+// prepareSQLCurrencies - проверить, что у всех счетов задана валюта, заполнить таблицу
+// кросс-курсов и сохранить суммы балансов по валютам.
+func prepareSQLCurrencies(ctx context.Context, pool *pgxpool.Pool) error {
+	var withoutCurrency int64
+	if err := pool.QueryRow(ctx, countAccountsWithoutCurrency).Scan(&withoutCurrency); err != nil {
+		return merry.Prepend(err, "failed to check account currencies")
+	}
+
+	if withoutCurrency > 0 {
+		return merry.Errorf("%d accounts have no currency, populate accounts with --multi-currency",
+			withoutCurrency)
+	}
+
+	for _, query := range fxScript[:2] {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return merry.Prepend(err, "failed to prepare fx tables")
+		}
+	}
+
+	for src := range model.CurrencyRates {
+		for dst := range model.CurrencyRates {
+			if _, err := pool.Exec(ctx, insertFXRate, src, dst, model.CrossRate(src, dst)); err != nil {
+				return merry.Prepend(err, "failed to insert fx rate")
+			}
+		}
+	}
+
+	for _, query := range fxScript[2:] {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return merry.Prepend(err, "failed to prepare fx tables")
+		}
+	}
+
+	return nil
+}
+
+// ConvertTransferAmount читает в транзакции перевода валюты счетов и курс, сохраняет
+// пересчитанную сумму зачисления в transfer.CreditAmount и записывает обороты перевода.
+// Отсутствие счета возвращается как ErrNoRows, как и при обычном переводе.
+func ConvertTransferAmount(ctx context.Context, tx pgx.Tx, transfer *model.Transfer) error {
+	source, dest := transfer.Acs[0], transfer.Acs[1]
+
+	var (
+		srcCurrency, dstCurrency string
+		rate                     int64
+	)
+
+	if err := tx.QueryRow(ctx, fetchTransferRate, source.Bic, source.Ban, dest.Bic, dest.Ban).
+		Scan(&srcCurrency, &dstCurrency, &rate); err != nil {
+		return merry.Prepend(wrapTxError(err), "failed to fetch fx rate")
+	}
+
+	debit := transfer.Amount.UnscaledBig().Int64()
+	credit := model.ConvertAmount(debit, rate)
+	transfer.CreditAmount = inf.NewDec(credit, 0)
+
+	if _, err := tx.Exec(ctx, insertFXTransfer, transfer.Id, srcCurrency, dstCurrency, debit, credit); err != nil {
+		return merry.Prepend(wrapTxError(err), "failed to insert fx transfer")
+	}
+
+	return nil
+}
+
+// checkSQLCurrencies - сверить суммы балансов по валютам с оборотами мультивалютных переводов.
+func checkSQLCurrencies(ctx context.Context, pool *pgxpool.Pool) error {
+	initial, err := fetchGroupedSums(ctx, pool, fetchCurrencyInitial)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch initial currency totals")
+	}
+
+	debit, err := fetchGroupedSums(ctx, pool, fetchCurrencyDebit)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch currency debit")
+	}
+
+	credit, err := fetchGroupedSums(ctx, pool, fetchCurrencyCredit)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch currency credit")
+	}
+
+	accountSums, err := fetchGroupedSums(ctx, pool, fetchAccountCurrencyTotals)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch account currency totals")
+	}
+
+	totals := make(map[string]currencyTotal, len(initial))
+	for currency, sum := range initial {
+		total := totals[currency]
+		total.Initial = sum
+		totals[currency] = total
+	}
+	for currency, sum := range debit {
+		total := totals[currency]
+		total.Debit = sum
+		totals[currency] = total
+	}
+	for currency, sum := range credit {
+		total := totals[currency]
+		total.Credit = sum
+		totals[currency] = total
+	}
+
+	return compareCurrencies(totals, accountSums)
+}
@@ -0,0 +1,161 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/inf.v0"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// historyScript - история создается после скрипта инициализации, чтобы пользовательские
+// шаблоны схемы не были обязаны ее описывать.
+// Запросы выполняются по одному: cockroach не допускает TRUNCATE вместе с другими запросами транзакции.
+var historyScript = []string{
+	`CREATE TABLE IF NOT EXISTS history (
+	transfer_id UUID, -- transfer UUID
+	bic TEXT, -- bank identifier code
+	ban TEXT, -- bank account number within the bank
+	ts TIMESTAMP, -- transfer timestamp
+	amount DECIMAL, -- signed amount, negative for debit
+	PRIMARY KEY(transfer_id, bic, ban)
+);`,
+	`CREATE INDEX IF NOT EXISTS history_account_ts ON history (bic, ban, ts);`,
+	`TRUNCATE history;`,
+}
+
+const (
+	insertHistoryEntry = `INSERT INTO history (transfer_id, bic, ban, ts, amount) VALUES ($1, $2, $3, $4, $5);`
+
+	fetchStatement = `SELECT transfer_id, amount, ts FROM history
+	WHERE bic = $1 AND ban = $2 ORDER BY ts DESC LIMIT $3;`
+
+	scanBankAccounts = `SELECT ban, balance FROM account
+	WHERE bic = $1 AND ban > $2 ORDER BY ban LIMIT $3;`
+)
+
+// prepareSQLHistory - создать и очистить историю переводов.
+func prepareSQLHistory(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, query := range historyScript {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return merry.Prepend(err, "failed to prepare history table")
+		}
+	}
+
+	return nil
+}
+
+// AppendHistory добавляет записи перевода в историю счетов отправителя и получателя
+// в транзакции перевода.
+func AppendHistory(ctx context.Context, tx pgx.Tx, transfer *model.Transfer, timestamp time.Time) error {
+	for _, entry := range journalEntries(transfer) {
+		if _, err := tx.Exec(
+			ctx,
+			insertHistoryEntry,
+			transfer.Id,
+			entry.Account.Bic,
+			entry.Account.Ban,
+			timestamp,
+			entry.Amount,
+		); err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to insert history entry")
+		}
+	}
+
+	return nil
+}
+
+// sqlFetchStatement - последние записи истории счета, запрос может быть направлен на реплику.
+func sqlFetchStatement(router *sqlRouter, bic string, ban string, limit int) ([]HistoryEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	var entries []HistoryEntry
+
+	err := router.onRead(func(pool *pgxpool.Pool) (err error) {
+		entries, err = fetchSQLStatement(ctx, pool, bic, ban, limit)
+		return err
+	})
+
+	if err != nil {
+		return nil, merry.Prepend(wrapTxError(err), "failed to fetch statement")
+	}
+
+	return entries, nil
+}
+
+func fetchSQLStatement(ctx context.Context, pool *pgxpool.Pool, bic string, ban string, limit int) ([]HistoryEntry, error) {
+	rows, err := pool.Query(ctx, fetchStatement, bic, ban, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		if err = rows.Scan(&entry.TransferId, &entry.Amount, &entry.Timestamp); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// sqlScanBankAccounts - страница счетов банка по первичному ключу (bic, ban).
+func sqlScanBankAccounts(router *sqlRouter, bic string, afterBan string, limit int) ([]model.Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	var accounts []model.Account
+
+	err := router.onRead(func(pool *pgxpool.Pool) (err error) {
+		accounts, err = scanSQLBankAccounts(ctx, pool, bic, afterBan, limit)
+		return err
+	})
+
+	if err != nil {
+		return nil, merry.Prepend(wrapTxError(err), "failed to scan bank accounts")
+	}
+
+	return accounts, nil
+}
+
+func scanSQLBankAccounts(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	bic string,
+	afterBan string,
+	limit int,
+) ([]model.Account, error) {
+	rows, err := pool.Query(ctx, scanBankAccounts, bic, afterBan, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []model.Account
+	for rows.Next() {
+		var balance int64
+
+		acc := model.Account{Bic: bic} //nolint:exhaustivestruct
+		if err = rows.Scan(&acc.Ban, &balance); err != nil {
+			return nil, err
+		}
+		acc.Balance = inf.NewDec(balance, 0)
+		acc.Found = true
+
+		accounts = append(accounts, acc)
+	}
+
+	return accounts, rows.Err()
+}
@@ -0,0 +1,145 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// journalScript - таблицы журнала создаются при включении журналирования, а не при загрузке счетов,
+// чтобы пользовательские шаблоны схемы не были обязаны их описывать.
+// Запросы выполняются по одному: cockroach не допускает TRUNCATE вместе с другими запросами транзакции.
+var journalScript = []string{
+	`CREATE TABLE IF NOT EXISTS journal (
+	transfer_id UUID, -- transfer UUID
+	leg TEXT, -- 'debit' or 'credit'
+	bic TEXT, -- bank identifier code
+	ban TEXT, -- bank account number within the bank
+	amount DECIMAL, -- signed amount, negative for debit
+	PRIMARY KEY(transfer_id, leg)
+);`,
+	`TRUNCATE journal;`,
+	`CREATE TABLE IF NOT EXISTS bank_total (
+	bic TEXT PRIMARY KEY, -- bank identifier code
+	initial_balance DECIMAL, -- sum of balances when the journal was enabled
+	balance DECIMAL -- current sum of balances
+);`,
+	`TRUNCATE bank_total;`,
+	`INSERT INTO bank_total (bic, initial_balance, balance)
+	SELECT bic, SUM(balance), SUM(balance) FROM account GROUP BY bic;`,
+}
+
+const (
+	insertJournalEntry = `INSERT INTO journal (transfer_id, leg, bic, ban, amount) VALUES ($1, $2, $3, $4, $5);`
+
+	updateBankTotal = `UPDATE bank_total SET balance = balance + $1 WHERE bic = $2;`
+
+	fetchBankTotals = `SELECT bic, initial_balance, balance FROM bank_total;`
+
+	fetchAccountTotals = `SELECT bic, SUM(balance) FROM account GROUP BY bic;`
+
+	fetchJournalTotals = `SELECT bic, SUM(amount) FROM journal GROUP BY bic;`
+)
+
+// prepareSQLJournal - создать и очистить журнал, заполнить агрегаты по БИК из текущих балансов.
+func prepareSQLJournal(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, query := range journalScript {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return merry.Prepend(err, "failed to prepare journal tables")
+		}
+	}
+
+	return nil
+}
+
+// AppendJournal добавляет проводки перевода в журнал и обновляет агрегаты по БИК
+// в транзакции перевода, после изменения балансов счетов.
+func AppendJournal(ctx context.Context, tx pgx.Tx, transfer *model.Transfer) error {
+	for _, entry := range journalEntries(transfer) {
+		if _, err := tx.Exec(
+			ctx,
+			insertJournalEntry,
+			transfer.Id,
+			entry.Leg,
+			entry.Account.Bic,
+			entry.Account.Ban,
+			entry.Amount,
+		); err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to insert journal entry")
+		}
+	}
+
+	for _, delta := range bankTotalDeltas(transfer) {
+		if _, err := tx.Exec(ctx, updateBankTotal, delta.Delta, delta.Bic); err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to update bank total")
+		}
+	}
+
+	return nil
+}
+
+// checkSQLJournal - сверить агрегаты по БИК с балансами счетов и журналом.
+func checkSQLJournal(ctx context.Context, pool *pgxpool.Pool) error {
+	totals := make(map[string]bankTotal)
+
+	rows, err := pool.Query(ctx, fetchBankTotals)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch bank totals")
+	}
+
+	for rows.Next() {
+		var bic string
+		var total bankTotal
+		if err = rows.Scan(&bic, &total.Initial, &total.Balance); err != nil {
+			rows.Close()
+			return merry.Prepend(err, "failed to scan bank total")
+		}
+		totals[bic] = total
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return merry.Prepend(err, "failed to fetch bank totals")
+	}
+
+	accountSums, err := fetchGroupedSums(ctx, pool, fetchAccountTotals)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch account totals")
+	}
+
+	journalSums, err := fetchGroupedSums(ctx, pool, fetchJournalTotals)
+	if err != nil {
+		return merry.Prepend(err, "failed to fetch journal totals")
+	}
+
+	return compareJournal(totals, accountSums, journalSums)
+}
+
+// fetchGroupedSums - выполнить запрос вида SELECT key, SUM(value) ... GROUP BY key.
+func fetchGroupedSums(ctx context.Context, pool *pgxpool.Pool, query string) (map[string]int64, error) {
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sums := make(map[string]int64)
+	for rows.Next() {
+		var bic string
+		var sum int64
+		if err = rows.Scan(&bic, &sum); err != nil {
+			return nil, err
+		}
+		sums[bic] = sum
+	}
+
+	return sums, rows.Err()
+}
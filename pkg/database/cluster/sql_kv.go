@@ -0,0 +1,181 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// kvScript - таблица нагрузки ключ-значение создается отдельно от схемы счетов.
+// Запросы выполняются по одному: cockroach не допускает TRUNCATE вместе с другими запросами транзакции.
+var kvScript = []string{
+	`CREATE TABLE IF NOT EXISTS usertable (
+	ycsb_key TEXT PRIMARY KEY, -- record key
+	field0 BYTEA -- record value
+);`,
+	`TRUNCATE usertable;`,
+}
+
+const (
+	insertKV = `INSERT INTO usertable (ycsb_key, field0) VALUES ($1, $2);`
+
+	readKV = `SELECT field0 FROM usertable WHERE ycsb_key = $1;`
+
+	readKVForUpdate = `SELECT field0 FROM usertable WHERE ycsb_key = $1 FOR UPDATE;`
+
+	updateKV = `UPDATE usertable SET field0 = $2 WHERE ycsb_key = $1;`
+
+	scanKV = `SELECT ycsb_key, field0 FROM usertable WHERE ycsb_key >= $1 ORDER BY ycsb_key LIMIT $2;`
+)
+
+// prepareSQLKV - создать и очистить таблицу записей.
+func prepareSQLKV(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, query := range kvScript {
+		if _, err := pool.Exec(ctx, query); err != nil {
+			return merry.Prepend(err, "failed to prepare usertable")
+		}
+	}
+
+	return nil
+}
+
+// sqlKVInsert - добавить запись, нарушение уникальности ключа возвращается как ErrDuplicateKey.
+func sqlKVInsert(router *sqlRouter, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	_, err := router.exec(ctx, insertKV, key, value)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return merry.Wrap(ErrDuplicateKey)
+		}
+
+		return merry.Prepend(wrapTxError(err), "failed to insert record")
+	}
+
+	return nil
+}
+
+// sqlKVRead - прочитать значение записи, запрос может быть направлен на реплику.
+func sqlKVRead(router *sqlRouter, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	var value []byte
+
+	err := router.readRow(ctx, readKV, key).Scan(&value)
+
+	if err != nil {
+		return nil, merry.Prepend(wrapTxError(err), "failed to read record")
+	}
+
+	return value, nil
+}
+
+// sqlKVUpdate - заменить значение записи, для отсутствующей записи возвращается ErrNoRows.
+func sqlKVUpdate(router *sqlRouter, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	tag, err := router.exec(ctx, updateKV, key, value)
+
+	if err != nil {
+		return merry.Prepend(wrapTxError(err), "failed to update record")
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRows
+	}
+
+	return nil
+}
+
+// sqlKVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func sqlKVScan(router *sqlRouter, startKey string, count int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	var scanned int
+
+	err := router.onRead(func(pool *pgxpool.Pool) (err error) {
+		scanned, err = scanSQLKV(ctx, pool, startKey, count)
+		return err
+	})
+
+	if err != nil {
+		return scanned, merry.Prepend(wrapTxError(err), "failed to scan records")
+	}
+
+	return scanned, nil
+}
+
+func scanSQLKV(ctx context.Context, pool *pgxpool.Pool, startKey string, count int) (int, error) {
+	rows, err := pool.Query(ctx, scanKV, startKey, count)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	scanned := 0
+	for rows.Next() {
+		var (
+			key   string
+			value []byte
+		)
+		if err = rows.Scan(&key, &value); err != nil {
+			return scanned, err
+		}
+		scanned++
+	}
+
+	return scanned, rows.Err()
+}
+
+// sqlKVReadModifyWrite - прочитать запись с блокировкой и заменить ее значение в одной транзакции.
+func sqlKVReadModifyWrite(router *sqlRouter, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), txTimeout)
+	defer cancel()
+
+	err := router.onWrite(func(pool *pgxpool.Pool) error {
+		return readModifyWriteSQLKV(ctx, pool, key, value)
+	})
+
+	if err != nil {
+		return merry.Prepend(wrapTxError(err), "failed to read-modify-write record")
+	}
+
+	return nil
+}
+
+func readModifyWriteSQLKV(ctx context.Context, pool *pgxpool.Pool, key string, value []byte) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Rollback после успешного Commit ничего не делает
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var previous []byte
+	if err = tx.QueryRow(ctx, readKVForUpdate, key).Scan(&previous); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, updateKV, key, value); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,388 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	llog "github.com/sirupsen/logrus"
+
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+// Политики маршрутизации запросов между несколькими адресами кластера.
+const (
+	// RoutingRoundRobin - запросы распределяются по всем доступным адресам по очереди.
+	RoutingRoundRobin = "round-robin"
+	// RoutingPrimaryFailover - все запросы идут на текущий основной адрес,
+	// при ошибке соединения основным становится следующий адрес списка.
+	RoutingPrimaryFailover = "primary-failover"
+	// RoutingReadReplica - изменения идут на основной адрес с переключением как в primary-failover,
+	// а чтения балансов распределяются по остальным адресам (репликам).
+	RoutingReadReplica = "read-replica"
+)
+
+// endpointRetryInterval - время, в течение которого недоступный адрес не используется.
+const endpointRetryInterval = time.Second
+
+// RoutingSettings - дополнительные адреса кластера и политика маршрутизации между ними.
+type RoutingSettings struct {
+	// Endpoints - адреса в дополнение к основному url.
+	Endpoints []string
+	Policy    string
+}
+
+// DefaultRoutingSettings - один адрес без маршрутизации.
+func DefaultRoutingSettings() RoutingSettings {
+	return RoutingSettings{
+		Endpoints: nil,
+		Policy:    RoutingPrimaryFailover,
+	}
+}
+
+// Validate - проверить, что политика маршрутизации поддерживается.
+func (settings RoutingSettings) Validate() error {
+	switch settings.Policy {
+	case RoutingRoundRobin, RoutingPrimaryFailover, RoutingReadReplica:
+		return nil
+	}
+
+	return merry.Errorf("unknown routing policy '%s', expected one of '%s', '%s', '%s'",
+		settings.Policy, RoutingRoundRobin, RoutingPrimaryFailover, RoutingReadReplica)
+}
+
+type sqlEndpoint struct {
+	host string
+	pool *pgxpool.Pool
+	// время перехода в недоступное состояние в наносекундах, 0 - адрес доступен
+	downSince int64
+}
+
+func (endpoint *sqlEndpoint) available() bool {
+	downSince := atomic.LoadInt64(&endpoint.downSince)
+	return downSince == 0 || time.Since(time.Unix(0, downSince)) > endpointRetryInterval
+}
+
+// sqlRouter - набор пулов соединений к адресам кластера postgres или cockroach.
+// Ошибки соединения фиксируются как события переподключения, а время от первой такой
+// ошибки до первого успешного запроса - как время восстановления.
+type sqlRouter struct {
+	policy    string
+	endpoints []*sqlEndpoint
+	primary   uint64
+	next      uint64
+
+	// inOutage - 1 с первой ошибки соединения до первого успешного запроса,
+	// пока флаг не установлен, успешные запросы не берут outageLock
+	inOutage    int32
+	outageLock  sync.Mutex
+	outageStart time.Time
+}
+
+func newSQLRouter(
+	ctx context.Context,
+	dbURL string,
+	connectionPoolSize int,
+	routing RoutingSettings,
+) (*sqlRouter, error) {
+	if err := routing.Validate(); err != nil {
+		return nil, merry.Prepend(err, "invalid routing settings")
+	}
+
+	router := &sqlRouter{
+		policy: routing.Policy,
+	}
+
+	for i, endpointURL := range append([]string{dbURL}, routing.Endpoints...) {
+		poolConfig, err := pgxpool.ParseConfig(endpointURL)
+		if err != nil {
+			router.Close()
+			return nil, merry.Prepend(err, "parse url parameter")
+		}
+
+		if !strings.Contains(endpointURL, "pool_max_conns") {
+			poolConfig.MaxConns = int32(connectionPoolSize)
+		}
+
+		// к дополнительным адресам подключаемся по мере необходимости,
+		// чтобы недоступная реплика не мешала запуску
+		poolConfig.LazyConnect = i > 0
+
+		llog.Debugf("Connection pool size for %s: %v", poolConfig.ConnConfig.Host, poolConfig.MaxConns)
+
+		pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
+		if err != nil {
+			router.Close()
+			return nil, merry.Prepend(err, "connection")
+		}
+
+		router.endpoints = append(router.endpoints, &sqlEndpoint{
+			host: endpointHost(endpointURL),
+			pool: pool,
+		})
+	}
+
+	if len(router.endpoints) > 1 {
+		llog.Infof("Routing requests between %d endpoints with '%s' policy",
+			len(router.endpoints), router.policy)
+	}
+
+	return router, nil
+}
+
+// endpointHost - адрес без учетных данных для журнала и статистики.
+func endpointHost(endpointURL string) string {
+	if parsed, err := url.Parse(endpointURL); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+
+	return endpointURL
+}
+
+// Close - закрыть пулы соединений ко всем адресам.
+func (router *sqlRouter) Close() {
+	for _, endpoint := range router.endpoints {
+		endpoint.pool.Close()
+	}
+}
+
+// write - адрес для изменяющих запросов.
+func (router *sqlRouter) write() *sqlEndpoint {
+	if router.policy == RoutingRoundRobin {
+		return router.roundRobin()
+	}
+
+	return router.endpoints[atomic.LoadUint64(&router.primary)%uint64(len(router.endpoints))]
+}
+
+// read - адрес для запросов на чтение, не требующих согласованности со всей БД.
+func (router *sqlRouter) read() *sqlEndpoint {
+	if router.policy != RoutingReadReplica || len(router.endpoints) < 2 {
+		return router.write()
+	}
+
+	primary := router.write()
+	for i := 0; i < len(router.endpoints); i++ {
+		endpoint := router.roundRobin()
+		if endpoint != primary && endpoint.available() {
+			return endpoint
+		}
+	}
+
+	return primary
+}
+
+// roundRobin - следующий доступный адрес, либо очередной, если недоступны все.
+func (router *sqlRouter) roundRobin() *sqlEndpoint {
+	count := uint64(len(router.endpoints))
+	start := atomic.AddUint64(&router.next, 1)
+
+	for i := uint64(0); i < count; i++ {
+		endpoint := router.endpoints[(start+i)%count]
+		if endpoint.available() {
+			return endpoint
+		}
+	}
+
+	return router.endpoints[start%count]
+}
+
+// onWrite - выполнить запрос query на адресе для изменений и учесть его результат.
+func (router *sqlRouter) onWrite(query func(pool *pgxpool.Pool) error) error {
+	return router.on(router.write(), query)
+}
+
+// onRead - выполнить запрос query на адресе для чтения и учесть его результат.
+func (router *sqlRouter) onRead(query func(pool *pgxpool.Pool) error) error {
+	return router.on(router.read(), query)
+}
+
+func (router *sqlRouter) on(endpoint *sqlEndpoint, query func(pool *pgxpool.Pool) error) error {
+	err := query(endpoint.pool)
+	router.observe(endpoint, err)
+
+	return err
+}
+
+// exec - выполнить изменяющий запрос на адресе для изменений.
+func (router *sqlRouter) exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	var tag pgconn.CommandTag
+
+	err := router.onWrite(func(pool *pgxpool.Pool) (err error) {
+		tag, err = pool.Exec(ctx, sql, args...)
+		return err
+	})
+
+	return tag, err
+}
+
+// queryRow - запрос одной строки на адресе для изменений, результат учитывается при Scan.
+func (router *sqlRouter) queryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	endpoint := router.write()
+
+	return &observedRow{router: router, endpoint: endpoint, row: endpoint.pool.QueryRow(ctx, sql, args...)}
+}
+
+// readRow - запрос одной строки на адресе для чтения, результат учитывается при Scan.
+func (router *sqlRouter) readRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	endpoint := router.read()
+
+	return &observedRow{router: router, endpoint: endpoint, row: endpoint.pool.QueryRow(ctx, sql, args...)}
+}
+
+// query - запрос строк на адресе для изменений, результат учитывается по окончании строк.
+func (router *sqlRouter) query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	endpoint := router.write()
+
+	rows, err := endpoint.pool.Query(ctx, sql, args...)
+	if err != nil {
+		router.observe(endpoint, err)
+		return nil, err
+	}
+
+	return &observedRows{Rows: rows, router: router, endpoint: endpoint}, nil
+}
+
+type observedRow struct {
+	router   *sqlRouter
+	endpoint *sqlEndpoint
+	row      pgx.Row
+}
+
+func (row *observedRow) Scan(dest ...interface{}) error {
+	err := row.row.Scan(dest...)
+	row.router.observe(row.endpoint, err)
+
+	return err
+}
+
+type observedRows struct {
+	pgx.Rows
+	router   *sqlRouter
+	endpoint *sqlEndpoint
+	observed bool
+}
+
+func (rows *observedRows) Next() bool {
+	if rows.Rows.Next() {
+		return true
+	}
+
+	if !rows.observed {
+		rows.observed = true
+		rows.router.observe(rows.endpoint, rows.Rows.Err())
+	}
+
+	return false
+}
+
+// observe - учесть результат запроса к адресу.
+func (router *sqlRouter) observe(endpoint *sqlEndpoint, err error) {
+	if err != nil && isConnectionError(err) {
+		router.markDown(endpoint, err)
+		return
+	}
+
+	// сервер ответил, пусть даже ошибкой уровня транзакции - адрес доступен
+	if atomic.LoadInt64(&endpoint.downSince) != 0 {
+		atomic.StoreInt64(&endpoint.downSince, 0)
+	}
+
+	if atomic.LoadInt32(&router.inOutage) == 0 {
+		return
+	}
+
+	router.outageLock.Lock()
+	defer router.outageLock.Unlock()
+
+	if !router.outageStart.IsZero() {
+		recoveryTime := time.Since(router.outageStart)
+		router.outageStart = time.Time{}
+		atomic.StoreInt32(&router.inOutage, 0)
+
+		llog.Infof("Connection to the cluster recovered via %s after %v", endpoint.host, recoveryTime)
+		statistics.StatsRecovered(recoveryTime)
+	}
+}
+
+func (router *sqlRouter) markDown(endpoint *sqlEndpoint, err error) {
+	router.outageLock.Lock()
+	if router.outageStart.IsZero() {
+		router.outageStart = time.Now()
+		atomic.StoreInt32(&router.inOutage, 1)
+	}
+	router.outageLock.Unlock()
+
+	now := time.Now().UnixNano()
+	downSince := atomic.LoadInt64(&endpoint.downSince)
+	if downSince != 0 && time.Since(time.Unix(0, downSince)) <= endpointRetryInterval {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt64(&endpoint.downSince, downSince, now) {
+		return
+	}
+
+	llog.Warnf("Endpoint %s is unavailable: %v", endpoint.host, err)
+	statistics.StatsReconnect(endpoint.host)
+
+	if router.policy == RoutingRoundRobin || len(router.endpoints) < 2 {
+		return
+	}
+
+	// переключаем основной адрес, только если недоступен именно он
+	count := uint64(len(router.endpoints))
+	primary := atomic.LoadUint64(&router.primary)
+	if router.endpoints[primary%count] != endpoint {
+		return
+	}
+
+	if atomic.CompareAndSwapUint64(&router.primary, primary, primary+1) {
+		llog.Warnf("Switching primary endpoint from %s to %s",
+			endpoint.host, router.endpoints[(primary+1)%count].host)
+	}
+}
+
+// isConnectionError - ошибка вызвана потерей соединения с сервером, а не логикой транзакции.
+func isConnectionError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// из вмешательства оператора соединение теряется только при остановке сервера,
+		// отмена запроса по таймауту к потере соединения не относится
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgErr.Code == pgerrcode.AdminShutdown ||
+			pgErr.Code == pgerrcode.CrashShutdown ||
+			pgErr.Code == pgerrcode.CannotConnectNow
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	message := err.Error()
+
+	return strings.Contains(message, "failed to connect") ||
+		strings.Contains(message, "conn closed") ||
+		strings.Contains(message, "connection refused") ||
+		strings.Contains(message, "connection reset")
+}
@@ -0,0 +1,307 @@
+package cluster
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// ошибки запросов для проверки маршрутизации без БД
+var (
+	errConnection    = &pgconn.PgError{Code: pgerrcode.AdminShutdown}
+	errSerialization = &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+)
+
+// newTestRouter - маршрутизатор по адресам hosts без пулов соединений
+func newTestRouter(policy string, hosts ...string) *sqlRouter {
+	router := &sqlRouter{policy: policy}
+
+	for _, host := range hosts {
+		router.endpoints = append(router.endpoints, &sqlEndpoint{host: host})
+	}
+
+	return router
+}
+
+// hostsOf - адреса, которые выбирает pick за count запросов
+func hostsOf(count int, pick func() *sqlEndpoint) []string {
+	hosts := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		hosts = append(hosts, pick().host)
+	}
+
+	return hosts
+}
+
+func TestIsConnectionError(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "admin shutdown", err: errConnection, expected: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: pgerrcode.ConnectionFailure}, expected: true},
+		{name: "cannot connect now", err: &pgconn.PgError{Code: pgerrcode.CannotConnectNow}, expected: true},
+		{name: "serialization failure", err: errSerialization, expected: false},
+		{name: "query canceled", err: &pgconn.PgError{Code: pgerrcode.QueryCanceled}, expected: false},
+		{name: "eof", err: io.EOF, expected: true},
+		{name: "wrapped unexpected eof", err: merry.Prepend(io.ErrUnexpectedEOF, "scan"), expected: true},
+		{name: "network", err: &net.OpError{Op: "read", Err: errors.New("broken pipe")}, expected: true},
+		{name: "refused", err: errors.New("dial tcp: connection refused"), expected: true},
+		{name: "no rows", err: pgx.ErrNoRows, expected: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, isConnectionError(test.err))
+		})
+	}
+}
+
+func TestRouting(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy string
+		hosts  []string
+		down   []int
+		writes []string
+		reads  []string
+	}{
+		{
+			name:   "primary failover",
+			policy: RoutingPrimaryFailover,
+			hosts:  []string{"a", "b", "c"},
+			writes: []string{"a", "a", "a"},
+			reads:  []string{"a", "a", "a"},
+		},
+		{
+			name:   "primary failover after the primary is down",
+			policy: RoutingPrimaryFailover,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{0},
+			writes: []string{"b", "b", "b"},
+			reads:  []string{"b", "b", "b"},
+		},
+		{
+			name:   "primary failover after two primaries are down",
+			policy: RoutingPrimaryFailover,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{0, 1},
+			writes: []string{"c", "c", "c"},
+			reads:  []string{"c", "c", "c"},
+		},
+		{
+			name:   "primary failover keeps the primary when a replica is down",
+			policy: RoutingPrimaryFailover,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{1},
+			writes: []string{"a", "a", "a"},
+			reads:  []string{"a", "a", "a"},
+		},
+		{
+			name:   "round robin",
+			policy: RoutingRoundRobin,
+			hosts:  []string{"a", "b", "c"},
+			writes: []string{"b", "c", "a"},
+			reads:  []string{"b", "c", "a"},
+		},
+		{
+			name:   "round robin skips a down endpoint",
+			policy: RoutingRoundRobin,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{1},
+			writes: []string{"c", "c", "a"},
+			reads:  []string{"c", "c", "a"},
+		},
+		{
+			name:   "round robin when all endpoints are down",
+			policy: RoutingRoundRobin,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{0, 1, 2},
+			writes: []string{"b", "c", "a"},
+			reads:  []string{"b", "c", "a"},
+		},
+		{
+			name:   "read replica",
+			policy: RoutingReadReplica,
+			hosts:  []string{"a", "b", "c"},
+			writes: []string{"a", "a", "a"},
+			reads:  []string{"b", "c", "b"},
+		},
+		{
+			name:   "read replica skips a down replica",
+			policy: RoutingReadReplica,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{1},
+			writes: []string{"a", "a", "a"},
+			reads:  []string{"c", "c", "c"},
+		},
+		{
+			name:   "read replica after the primary is down",
+			policy: RoutingReadReplica,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{0},
+			writes: []string{"b", "b", "b"},
+			reads:  []string{"c", "c", "c"},
+		},
+		{
+			name:   "read replica reads the primary when replicas are down",
+			policy: RoutingReadReplica,
+			hosts:  []string{"a", "b", "c"},
+			down:   []int{1, 2},
+			writes: []string{"a", "a", "a"},
+			reads:  []string{"a", "a", "a"},
+		},
+		{
+			name:   "read replica with one endpoint",
+			policy: RoutingReadReplica,
+			hosts:  []string{"a"},
+			writes: []string{"a", "a"},
+			reads:  []string{"a", "a"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			router := newTestRouter(test.policy, test.hosts...)
+
+			for _, i := range test.down {
+				router.observe(router.endpoints[i], errConnection)
+			}
+
+			assert.Equal(t, test.writes, hostsOf(len(test.writes), router.write))
+			assert.Equal(t, test.reads, hostsOf(len(test.reads), router.read))
+		})
+	}
+}
+
+func TestOutage(t *testing.T) {
+	type query struct {
+		read bool
+		err  error
+	}
+
+	for _, test := range []struct {
+		name     string
+		policy   string
+		queries  []query
+		down     []bool
+		primary  string
+		inOutage bool
+	}{
+		{
+			name:    "successful queries",
+			policy:  RoutingPrimaryFailover,
+			queries: []query{{}, {read: true}},
+			down:    []bool{false, false},
+			primary: "a",
+		},
+		{
+			name:    "transaction errors do not mark endpoints down",
+			policy:  RoutingPrimaryFailover,
+			queries: []query{{err: errSerialization}, {err: pgx.ErrNoRows}},
+			down:    []bool{false, false},
+			primary: "a",
+		},
+		{
+			name:     "connection error starts an outage and switches the primary",
+			policy:   RoutingPrimaryFailover,
+			queries:  []query{{err: errConnection}},
+			down:     []bool{true, false},
+			primary:  "b",
+			inOutage: true,
+		},
+		{
+			name:     "errors of both endpoints move the primary around",
+			policy:   RoutingPrimaryFailover,
+			queries:  []query{{err: io.EOF}, {read: true, err: io.EOF}},
+			down:     []bool{true, true},
+			primary:  "a",
+			inOutage: true,
+		},
+		{
+			name:    "any answer of the new primary ends the outage",
+			policy:  RoutingPrimaryFailover,
+			queries: []query{{err: errConnection}, {err: errSerialization}},
+			down:    []bool{true, false},
+			primary: "b",
+		},
+		{
+			name:     "round robin does not switch the primary",
+			policy:   RoutingRoundRobin,
+			queries:  []query{{err: errConnection}},
+			down:     []bool{false, true},
+			primary:  "a",
+			inOutage: true,
+		},
+		{
+			name:    "round robin recovers on the next endpoint",
+			policy:  RoutingRoundRobin,
+			queries: []query{{err: errConnection}, {}},
+			down:    []bool{false, true},
+			primary: "a",
+		},
+		{
+			name:     "read replica error does not switch the primary",
+			policy:   RoutingReadReplica,
+			queries:  []query{{read: true, err: errConnection}},
+			down:     []bool{false, true},
+			primary:  "a",
+			inOutage: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			router := newTestRouter(test.policy, "a", "b")
+
+			for _, q := range test.queries {
+				run := router.onWrite
+				if q.read {
+					run = router.onRead
+				}
+
+				err := run(func(*pgxpool.Pool) error { return q.err })
+				assert.Equal(t, q.err, err)
+			}
+
+			down := make([]bool, 0, len(router.endpoints))
+			for _, endpoint := range router.endpoints {
+				down = append(down, !endpoint.available())
+			}
+
+			assert.Equal(t, test.down, down)
+			assert.Equal(t, test.primary, router.write().host)
+			assert.Equal(t, test.inOutage, atomic.LoadInt32(&router.inOutage) == 1)
+			assert.Equal(t, test.inOutage, !router.outageStart.IsZero())
+		})
+	}
+}
+
+func TestEndpointRetry(t *testing.T) {
+	router := newTestRouter(RoutingPrimaryFailover, "a", "b", "c")
+	endpoint := router.endpoints[0]
+
+	// ошибки запросов, начатых до переключения, не переключают основной адрес еще раз
+	router.observe(endpoint, errConnection)
+	router.observe(endpoint, errConnection)
+	assert.False(t, endpoint.available())
+	assert.Equal(t, "b", router.write().host)
+
+	// по истечении endpointRetryInterval адрес снова пробуют, не дожидаясь успешного запроса
+	atomic.StoreInt64(&endpoint.downSince, time.Now().Add(-2*endpointRetryInterval).UnixNano())
+	assert.True(t, endpoint.available())
+
+	// повторная ошибка после интервала снова делает адрес недоступным
+	router.observe(endpoint, errConnection)
+	assert.False(t, endpoint.available())
+	assert.Equal(t, "b", router.write().host)
+
+	router.observe(endpoint, nil)
+	assert.True(t, endpoint.available())
+	assert.Zero(t, atomic.LoadInt64(&endpoint.downSince))
+}
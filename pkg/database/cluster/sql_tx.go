@@ -0,0 +1,149 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+// Уровни изоляции транзакции перевода.
+const (
+	IsolationReadCommitted  = "read committed"
+	IsolationRepeatableRead = "repeatable read"
+	IsolationSerializable   = "serializable"
+)
+
+// Стратегии блокировки счетов при переводе.
+const (
+	// LockingUpdate - счета блокируются неявно самими UPDATE в согласованном порядке.
+	LockingUpdate = "update"
+	// LockingForUpdate - счета предварительно блокируются через SELECT ... FOR UPDATE.
+	LockingForUpdate = "for-update"
+	// LockingOptimistic - балансы читаются без блокировок и обновляются через compare-and-set.
+	LockingOptimistic = "optimistic"
+)
+
+const (
+	selectAccountForUpdate = `SELECT balance FROM account WHERE bic = $1 AND ban = $2 FOR UPDATE;`
+
+	compareAndSetBalance = `UPDATE account SET balance = $1
+	WHERE bic = $2 AND ban = $3 AND balance = $4;`
+)
+
+// SQLTxSettings - параметры транзакции перевода для postgres и cockroach.
+type SQLTxSettings struct {
+	Isolation string
+	Locking   string
+}
+
+// DefaultSQLTxSettings - параметры, с которыми переводы выполнялись до появления настройки.
+func DefaultSQLTxSettings() SQLTxSettings {
+	return SQLTxSettings{
+		Isolation: IsolationRepeatableRead,
+		Locking:   LockingUpdate,
+	}
+}
+
+// Validate - проверить, что уровень изоляции и стратегия блокировки поддерживаются.
+func (settings SQLTxSettings) Validate() error {
+	if _, err := settings.TxOptions(); err != nil {
+		return err
+	}
+
+	switch settings.Locking {
+	case LockingUpdate, LockingForUpdate, LockingOptimistic:
+		return nil
+	}
+
+	return merry.Errorf("unknown locking strategy '%s', expected one of '%s', '%s', '%s'",
+		settings.Locking, LockingUpdate, LockingForUpdate, LockingOptimistic)
+}
+
+// TxOptions - получить параметры транзакции pgx.
+func (settings SQLTxSettings) TxOptions() (pgx.TxOptions, error) {
+	switch settings.Isolation {
+	case IsolationReadCommitted:
+		return pgx.TxOptions{IsoLevel: pgx.ReadCommitted}, nil
+	case IsolationRepeatableRead:
+		return pgx.TxOptions{IsoLevel: pgx.RepeatableRead}, nil
+	case IsolationSerializable:
+		return pgx.TxOptions{IsoLevel: pgx.Serializable}, nil
+	}
+
+	return pgx.TxOptions{}, merry.Errorf("unknown isolation level '%s', expected one of '%s', '%s', '%s'",
+		settings.Isolation, IsolationReadCommitted, IsolationRepeatableRead, IsolationSerializable)
+}
+
+func wrapTxError(err error) error {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		if pgerrcode.IsTransactionRollback(pgErr.Code) {
+			return ErrTxRollback
+		}
+	}
+	// failed to find account
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoRows
+	}
+
+	return err
+}
+
+// LockAccountsForUpdate блокирует оба счета перевода через SELECT ... FOR UPDATE
+// в том же порядке, в котором их затем обновляет MakeAtomicTransfer.
+func LockAccountsForUpdate(ctx context.Context, tx pgx.Tx, transfer model.Transfer) error {
+	first, second := transfer.Acs[0], transfer.Acs[1]
+	if first.AccountID() <= second.AccountID() {
+		first, second = second, first
+	}
+
+	for _, acc := range []model.Account{first, second} {
+		var balance int64
+		if err := tx.QueryRow(ctx, selectAccountForUpdate, acc.Bic, acc.Ban).Scan(&balance); err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to lock account")
+		}
+	}
+
+	return nil
+}
+
+// CompareAndSetMoney выполняет перевод без блокировок: балансы читаются обычным SELECT,
+// а обновление применяется, только если баланс не изменился с момента чтения.
+// Конфликт с параллельной транзакцией возвращается как ErrTxRollback, чтобы перевод был повторен.
+func CompareAndSetMoney(ctx context.Context, tx pgx.Tx, transfer model.Transfer) error {
+	amount := transfer.Amount.UnscaledBig().Int64()
+	credit := transfer.Credit().UnscaledBig().Int64()
+
+	var balances [2]int64
+	for i, acc := range transfer.Acs {
+		if err := tx.QueryRow(ctx, fetchBalance, acc.Bic, acc.Ban).Scan(&balances[i]); err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to read balance")
+		}
+	}
+
+	if balances[0] < amount {
+		return ErrInsufficientFunds
+	}
+
+	newBalances := [2]int64{balances[0] - amount, balances[1] + credit}
+	for i, acc := range transfer.Acs {
+		res, err := tx.Exec(ctx, compareAndSetBalance, newBalances[i], acc.Bic, acc.Ban, balances[i])
+		if err != nil {
+			return merry.Prepend(wrapTxError(err), "failed to update balance")
+		}
+		if res.RowsAffected() != 1 {
+			return ErrTxRollback
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/inf.v0"
+
+	"gitlab.com/picodata/stroppy/internal/model"
+)
+
+func TestSQLTxSettingsValidate(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		isolation string
+		locking   string
+		expected  pgx.TxIsoLevel
+		err       string
+	}{
+		{name: "defaults", isolation: IsolationRepeatableRead, locking: LockingUpdate, expected: pgx.RepeatableRead},
+		{name: "read committed", isolation: IsolationReadCommitted, locking: LockingForUpdate, expected: pgx.ReadCommitted},
+		{name: "serializable", isolation: IsolationSerializable, locking: LockingOptimistic, expected: pgx.Serializable},
+		{
+			name:      "unknown isolation",
+			isolation: "snapshot",
+			locking:   LockingUpdate,
+			err:       "unknown isolation level 'snapshot', expected one of 'read committed', 'repeatable read', 'serializable'",
+		},
+		{
+			name:      "isolation is case sensitive",
+			isolation: "Serializable",
+			locking:   LockingUpdate,
+			err:       "unknown isolation level 'Serializable'",
+		},
+		{
+			name:      "unknown locking",
+			isolation: IsolationSerializable,
+			locking:   "pessimistic",
+			err:       "unknown locking strategy 'pessimistic', expected one of 'update', 'for-update', 'optimistic'",
+		},
+		{
+			name:      "empty locking",
+			isolation: IsolationSerializable,
+			locking:   "",
+			err:       "unknown locking strategy ''",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			settings := SQLTxSettings{Isolation: test.isolation, Locking: test.locking}
+
+			err := settings.Validate()
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			options, err := settings.TxOptions()
+			require.NoError(t, err)
+			assert.Equal(t, pgx.TxOptions{IsoLevel: test.expected}, options)
+		})
+	}
+}
+
+func TestDefaultSQLTxSettings(t *testing.T) {
+	settings := DefaultSQLTxSettings()
+	require.NoError(t, settings.Validate())
+
+	options, err := settings.TxOptions()
+	require.NoError(t, err)
+	assert.Equal(t, pgx.RepeatableRead, options.IsoLevel)
+	assert.Equal(t, LockingUpdate, settings.Locking)
+}
+
+func TestWrapTxError(t *testing.T) {
+	other := errors.New("syntax error")
+
+	for _, test := range []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: pgerrcode.SerializationFailure}, expected: ErrTxRollback},
+		{name: "deadlock", err: &pgconn.PgError{Code: pgerrcode.DeadlockDetected}, expected: ErrTxRollback},
+		{name: "no rows", err: pgx.ErrNoRows, expected: ErrNoRows},
+		{name: "other", err: other, expected: other},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, wrapTxError(test.err))
+		})
+	}
+}
+
+// fakeTx - транзакция над балансами в памяти для проверки CompareAndSetMoney без БД
+type fakeTx struct {
+	pgx.Tx
+
+	balances map[string]int64
+	// concurrent - изменения балансов параллельной транзакцией сразу после их чтения
+	concurrent map[string]int64
+	execErr    error
+	execs      int
+}
+
+func (tx *fakeTx) QueryRow(_ context.Context, _ string, args ...interface{}) pgx.Row {
+	key := args[0].(string) + args[1].(string)
+
+	balance, ok := tx.balances[key]
+	if !ok {
+		return fakeRow{err: pgx.ErrNoRows}
+	}
+
+	tx.balances[key] += tx.concurrent[key]
+
+	return fakeRow{balance: balance}
+}
+
+func (tx *fakeTx) Exec(_ context.Context, _ string, args ...interface{}) (pgconn.CommandTag, error) {
+	tx.execs++
+
+	if tx.execErr != nil {
+		return nil, tx.execErr
+	}
+
+	key := args[1].(string) + args[2].(string)
+	if tx.balances[key] != args[3].(int64) {
+		return pgconn.CommandTag("UPDATE 0"), nil
+	}
+
+	tx.balances[key] = args[0].(int64)
+
+	return pgconn.CommandTag("UPDATE 1"), nil
+}
+
+type fakeRow struct {
+	balance int64
+	err     error
+}
+
+func (row fakeRow) Scan(dest ...interface{}) error {
+	if row.err != nil {
+		return row.err
+	}
+
+	*dest[0].(*int64) = row.balance
+
+	return nil
+}
+
+func TestCompareAndSetMoney(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		balances   map[string]int64
+		concurrent map[string]int64
+		execErr    error
+		err        error
+		expected   map[string]int64
+		execs      int
+	}{
+		{
+			name:     "transfer",
+			balances: map[string]int64{"1a": 100, "2b": 50},
+			expected: map[string]int64{"1a": 70, "2b": 80},
+			execs:    2,
+		},
+		{
+			name:       "source changed after read",
+			balances:   map[string]int64{"1a": 100, "2b": 50},
+			concurrent: map[string]int64{"1a": -10},
+			err:        ErrTxRollback,
+			expected:   map[string]int64{"1a": 90, "2b": 50},
+			execs:      1,
+		},
+		{
+			name:       "destination changed after read",
+			balances:   map[string]int64{"1a": 100, "2b": 50},
+			concurrent: map[string]int64{"2b": 10},
+			err:        ErrTxRollback,
+			// списание откатывается вместе с транзакцией
+			expected: map[string]int64{"1a": 70, "2b": 60},
+			execs:    2,
+		},
+		{
+			name:     "insufficient funds",
+			balances: map[string]int64{"1a": 20, "2b": 50},
+			err:      ErrInsufficientFunds,
+			expected: map[string]int64{"1a": 20, "2b": 50},
+		},
+		{
+			name:     "missing account",
+			balances: map[string]int64{"1a": 100},
+			err:      ErrNoRows,
+			expected: map[string]int64{"1a": 100},
+		},
+		{
+			name:     "serialization failure",
+			balances: map[string]int64{"1a": 100, "2b": 50},
+			execErr:  &pgconn.PgError{Code: pgerrcode.SerializationFailure},
+			err:      ErrTxRollback,
+			expected: map[string]int64{"1a": 100, "2b": 50},
+			execs:    1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tx := &fakeTx{balances: test.balances, concurrent: test.concurrent, execErr: test.execErr}
+
+			err := CompareAndSetMoney(context.Background(), tx, model.Transfer{
+				Acs:    []model.Account{{Bic: "1", Ban: "a"}, {Bic: "2", Ban: "b"}},
+				Amount: inf.NewDec(30, 0),
+			})
+
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+
+			assert.Equal(t, test.expected, tx.balances)
+			assert.Equal(t, test.execs, tx.execs)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+	"github.com/jackc/pgx/v4/pgxpool"
+	llog "github.com/sirupsen/logrus"
+
+	"gitlab.com/picodata/stroppy/pkg/results"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+const statDateFormat = "02-01-2006_15:04:05"
+
+// statCollector - получить очередной срез статистики БД, пригодный для сериализации в json.
+type statCollector func() (interface{}, error)
+
+// statExtractor - извлечь из среза статистики, приведенного к виду encoding/json
+// (словари, списки, float64), метрики для временного ряда запуска.
+type statExtractor func(data interface{}) map[string]float64
+
+// statSource - источник периодической статистики конкретной СУБД.
+type statSource struct {
+	database     string
+	fileTemplate string
+	collect      statCollector
+	extract      statExtractor
+}
+
+// statRecord - строка файла статистики в формате json lines.
+type statRecord struct {
+	Time     time.Time   `json:"time"`
+	Database string      `json:"database"`
+	Data     interface{} `json:"data"`
+}
+
+// startStatisticsCollect - открыть файл статистики по шаблону имени и дописывать в него
+// по одной json-записи на срез с заданным интервалом, пока не будет отменен контекст.
+// Первый срез снимается синхронно, чтобы сразу вернуть ошибку недоступности статистики,
+// ошибки последующих срезов только пишутся в журнал и не останавливают сбор.
+func startStatisticsCollect(ctx context.Context, statInterval time.Duration, source statSource) error {
+	// файл статистики сохраняется в каталог результатов запуска, если он начат
+	statFileName := filepath.Join(
+		results.Dir(""),
+		fmt.Sprintf(source.fileTemplate, time.Now().Format(statDateFormat)),
+	)
+	llog.Debugln("Opening statistic file...")
+
+	statFile, err := os.OpenFile(statFileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return merry.Prepend(err, "failed to open statistic file")
+	}
+
+	llog.Debugln("Opening statistic file: success")
+
+	encoder := json.NewEncoder(statFile)
+	if err = source.writeRecord(encoder); err != nil {
+		statFile.Close()
+		return merry.Prepend(err, "failed to get statistic")
+	}
+
+	llog.Debugln("starting of statistic goroutine...")
+
+	go func() {
+		defer statFile.Close()
+
+		ticker := time.NewTicker(statInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				llog.Debugf("statistic collection for %s stopped", source.database)
+				return
+			case <-ticker.C:
+				if err := source.writeRecord(encoder); err != nil {
+					llog.Warnf("failed to get statistic for %s: %v", source.database, err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (source *statSource) writeRecord(encoder *json.Encoder) error {
+	data, err := source.collect()
+	if err != nil {
+		return err
+	}
+
+	record := statRecord{
+		Time:     time.Now(),
+		Database: source.database,
+		Data:     data,
+	}
+
+	if err = encoder.Encode(record); err != nil {
+		return merry.Prepend(err, "failed to write data to statistic file")
+	}
+
+	if source.extract == nil {
+		return nil
+	}
+
+	// извлечение работает с тем же представлением, что попало в файл
+	var decoded interface{}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return merry.Prepend(err, "failed to marshal data")
+	}
+
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		return merry.Prepend(err, "failed to decode data")
+	}
+
+	statistics.StatsDBMetrics(source.database, source.extract(decoded))
+
+	return nil
+}
+
+// statValue - получить числовое значение по пути из ключей словарей, например
+// statValue(data, "cluster", "workload", "transactions", "committed", "hz").
+func statValue(data interface{}, path ...string) (float64, bool) {
+	for _, key := range path {
+		node, ok := data.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+
+		if data, ok = node[key]; !ok {
+			return 0, false
+		}
+	}
+
+	value, ok := data.(float64)
+
+	return value, ok
+}
+
+// extractStatPaths - извлекатель метрик по словарю "имя метрики - путь через точку".
+func extractStatPaths(paths map[string]string) statExtractor {
+	return func(data interface{}) map[string]float64 {
+		values := make(map[string]float64, len(paths))
+
+		for name, path := range paths {
+			if value, ok := statValue(data, strings.Split(path, ".")...); ok {
+				values[name] = value
+			}
+		}
+
+		return values
+	}
+}
+
+// queryStatRows - выполнить запрос к системным представлениям и вернуть строки
+// в виде списка словарей "колонка - значение".
+func queryStatRows(ctx context.Context, pool *pgxpool.Pool, query string) ([]map[string]interface{}, error) {
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to query statistic")
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	result := make([]map[string]interface{}, 0)
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, merry.Prepend(err, "failed to read statistic row")
+		}
+
+		row := make(map[string]interface{}, len(values))
+		for i, value := range values {
+			row[string(fields[i].Name)] = value
+		}
+
+		result = append(result, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, merry.Prepend(err, "failed to read statistic rows")
+	}
+
+	return result, nil
+}
+
+// collectStatistics - выполнить запросы статистики на каждом адресе кластера.
+// Недоступный дополнительный адрес не прерывает сбор, ошибка сохраняется в срезе.
+func (router *sqlRouter) collectStatistics(queries map[string]string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), socketTimeout)
+	defer cancel()
+
+	result := make(map[string]interface{}, len(router.endpoints))
+
+	for i, endpoint := range router.endpoints {
+		endpointStat := make(map[string]interface{}, len(queries))
+
+		for name, query := range queries {
+			rows, err := queryStatRows(ctx, endpoint.pool, query)
+			if err != nil {
+				if i == 0 {
+					return nil, merry.Prepend(err, name)
+				}
+
+				endpointStat = map[string]interface{}{"error": err.Error()}
+
+				break
+			}
+
+			endpointStat[name] = rows
+		}
+
+		result[endpoint.host] = endpointStat
+	}
+
+	return result, nil
+}
+
+// statRows - строки запроса statName по всем адресам кластера из среза collectStatistics.
+func statRows(data interface{}, statName string) []map[string]interface{} {
+	var result []map[string]interface{}
+
+	endpoints, _ := data.(map[string]interface{})
+	for _, endpointStat := range endpoints {
+		node, _ := endpointStat.(map[string]interface{})
+		rows, _ := node[statName].([]interface{})
+
+		for _, rawRow := range rows {
+			if row, ok := rawRow.(map[string]interface{}); ok {
+				result = append(result, row)
+			}
+		}
+	}
+
+	return result
+}
+
+// sumStatColumns - просуммировать числовые колонки строк запроса statName по всем адресам кластера.
+func sumStatColumns(data interface{}, statName string, prefix string, columns ...string) map[string]float64 {
+	values := make(map[string]float64, len(columns))
+
+	for _, row := range statRows(data, statName) {
+		for _, column := range columns {
+			if value, ok := row[column].(float64); ok {
+				values[prefix+column] += value
+			}
+		}
+	}
+
+	return values
+}
@@ -18,7 +18,7 @@ var rand fixed_random_source.FixedRandomSource
 
 func GenerateAccounts() (generatedAccounts []model.Account) {
 	for i := 0; i < 2; i++ {
-		rand.Init(expectedCount, int(time.Now().UnixNano()), defaultBanRangeMultiplier)
+		rand.Init(expectedCount, int(time.Now().UnixNano()), defaultBanRangeMultiplier, time.Now().UnixNano(), i)
 		bic, ban := rand.NewBicAndBan()
 		balance := rand.NewStartBalance()
 		generatedAccount := model.Account{
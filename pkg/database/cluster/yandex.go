@@ -35,19 +35,39 @@ const (
 	partitionsMinCount  = 100
 	partitionsMaxMbytes = 256
 	poolSizeOverhead    = 10
+	// statistic file name template.
+	ydbStatJsonFileTemplate = "ydb_stat_%v.jsonl"
 )
 
 var errIllegalNilOutput = errors.New(
 	"Illegal nil output value of balance column for srcdst account statement",
 )
 
+// ydbAccountPayloadColumns - колонки атрибутов счета в порядке полей model.AccountPayload.
+var ydbAccountPayloadColumns = [4]string{"name", "currency", "status", "padding"}
+
+// ydbRequiredTables - таблицы каталога stroppy, которые должны существовать после инициализации.
+var ydbRequiredTables = []string{"settings", "account", "transfer", "checksum"}
+
 type YandexDBCluster struct {
 	ydbConnection       ydb.Connection
 	yqlInsertAccount    string
+	yqlInsertPayload    string
 	yqlUpsertTransfer   string
 	yqlSelectSrcDstAcc  string
 	yqlUpsertSrcDstAcc  string
 	yqlSelectBalanceAcc string
+	yqlJournalTransfer  string
+	yqlKVInsert         string
+	yqlKVUpsert         string
+	yqlKVRead           string
+	yqlKVScan           string
+	yqlHistoryTransfer  string
+	yqlFetchStatement   string
+	yqlScanBankAccounts string
+	schemaTemplate      string
+	journal             bool
+	history             bool
 }
 
 func envExists(key string) bool {
@@ -58,6 +78,9 @@ func envExists(key string) bool {
 	return false
 }
 
+// envConfigured - учетные данные YDB заданы переменными окружения ydb-go-sdk-auth-environ.
+// Переменные читает сама библиотека, а значения секретны, поэтому в загрузчик конфигурации
+// и в config print они не попадают.
 func envConfigured() bool {
 	return (envExists("YDB_SERVICE_ACCOUNT_KEY_FILE_CREDENTIALS") ||
 		envExists("YDB_METADATA_CREDENTIALS") ||
@@ -105,7 +128,16 @@ func NewYandexDBCluster(
 		yqlSelectSrcDstAcc:  expandYql(yqlSelectSrcDstAccount),
 		yqlUpsertSrcDstAcc:  expandYql(yqlUpsertSrcDstAccount),
 		yqlInsertAccount:    expandYql(yqlInsertAccount),
+		yqlInsertPayload:    expandYql(yqlInsertAccountPayload),
 		yqlSelectBalanceAcc: expandYql(yqlSelectBalanceAccount),
+		yqlJournalTransfer:  expandYql(yqlJournalTransfer),
+		yqlKVInsert:         expandYql(yqlKVInsert),
+		yqlKVUpsert:         expandYql(yqlKVUpsert),
+		yqlKVRead:           expandYql(yqlKVRead),
+		yqlKVScan:           expandYql(yqlKVScan),
+		yqlHistoryTransfer:  expandYql(yqlHistoryTransfer),
+		yqlFetchStatement:   expandYql(yqlFetchStatement),
+		yqlScanBankAccounts: expandYql(yqlScanBankAccounts),
 	}, nil
 }
 
@@ -307,6 +339,58 @@ func (ydbCluster *YandexDBCluster) MakeAtomicTransfer(
 				return errors.Wrap(err, "failed to execute transaction")
 			}
 
+			if ydbCluster.journal {
+				// Journal entries and per-bic aggregates in the same transaction.
+				_, err = tx.Execute(
+					ctx, ydbCluster.yqlJournalTransfer,
+					table.NewQueryParameters(
+						table.ValueParam("transfer_id",
+							types.BytesValueFromString(transfer.Id.String())),
+						table.ValueParam("src_bic",
+							types.BytesValueFromString(transfer.Acs[0].Bic)),
+						table.ValueParam("src_ban",
+							types.BytesValueFromString(transfer.Acs[0].Ban)),
+						table.ValueParam("dst_bic",
+							types.BytesValueFromString(transfer.Acs[1].Bic)),
+						table.ValueParam("dst_ban",
+							types.BytesValueFromString(transfer.Acs[1].Ban)),
+						table.ValueParam("amount",
+							types.Int64Value(amount)),
+					),
+					options.WithKeepInCache(true),
+				)
+				if err != nil {
+					return errors.Wrap(err, "failed to journal transfer")
+				}
+			}
+
+			if ydbCluster.history {
+				// History entries of both accounts in the same transaction.
+				_, err = tx.Execute(
+					ctx, ydbCluster.yqlHistoryTransfer,
+					table.NewQueryParameters(
+						table.ValueParam("transfer_id",
+							types.BytesValueFromString(transfer.Id.String())),
+						table.ValueParam("src_bic",
+							types.BytesValueFromString(transfer.Acs[0].Bic)),
+						table.ValueParam("src_ban",
+							types.BytesValueFromString(transfer.Acs[0].Ban)),
+						table.ValueParam("dst_bic",
+							types.BytesValueFromString(transfer.Acs[1].Bic)),
+						table.ValueParam("dst_ban",
+							types.BytesValueFromString(transfer.Acs[1].Ban)),
+						table.ValueParam("ts",
+							types.TimestampValueFromTime(time.Now())),
+						table.ValueParam("amount",
+							types.Int64Value(amount)),
+					),
+					options.WithKeepInCache(true),
+				)
+				if err != nil {
+					return errors.Wrap(err, "failed to record transfer history")
+				}
+			}
+
 			return nil
 		},
 		// Mark the transaction idempotent to allow retries.
@@ -318,6 +402,137 @@ func (ydbCluster *YandexDBCluster) MakeAtomicTransfer(
 	return nil
 }
 
+// EnableJournal - пересоздать журнал и агрегаты по БИК и журналировать последующие переводы.
+func (ydbCluster *YandexDBCluster) EnableJournal() error {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	prefix := path.Join(ydbCluster.ydbConnection.Name(), stroppyDir)
+	ydbClient := ydbCluster.ydbConnection.Table()
+
+	if err := createJournalTables(ydbContext, ydbClient, prefix); err != nil {
+		return err
+	}
+
+	if err := ydbClient.Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			if _, _, err := session.Execute(
+				ctx, table.DefaultTxControl(),
+				expandYql(yqlFillBankTotals),
+				nil,
+			); err != nil {
+				return errors.Wrap(err, "failed to execute query")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to fill bank totals")
+	}
+
+	ydbCluster.journal = true
+
+	return nil
+}
+
+// CheckJournal - сверить агрегаты по БИК с балансами счетов и журналом переводов.
+func (ydbCluster *YandexDBCluster) CheckJournal() error {
+	totals := make(map[string]bankTotal)
+
+	if err := ydbCluster.scanByBic(
+		expandYql(yqlSelectBankTotals),
+		func(queryResult result.Result, bic *string) error {
+			var total bankTotal
+			if err := queryResult.ScanNamed(
+				named.OptionalWithDefault("bic", bic),
+				named.OptionalWithDefault("initial_balance", &total.Initial),
+				named.OptionalWithDefault("balance", &total.Balance),
+			); err != nil {
+				return err
+			}
+			totals[*bic] = total
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to fetch bank totals")
+	}
+
+	accountSums, err := ydbCluster.sumByBic(expandYql(yqlSumAccountsByBic))
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate account totals")
+	}
+
+	journalSums, err := ydbCluster.sumByBic(expandYql(yqlSumJournalByBic))
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate journal totals")
+	}
+
+	return compareJournal(totals, accountSums, journalSums)
+}
+
+func (ydbCluster *YandexDBCluster) sumByBic(query string) (map[string]int64, error) {
+	sums := make(map[string]int64)
+
+	err := ydbCluster.scanByBic(query, func(queryResult result.Result, bic *string) error {
+		var total int64
+		if err := queryResult.ScanNamed(
+			named.OptionalWithDefault("bic", bic),
+			named.OptionalWithDefault("total", &total),
+		); err != nil {
+			return err
+		}
+		sums[*bic] = total
+
+		return nil
+	})
+
+	return sums, err
+}
+
+// scanByBic - выполнить запрос на чтение и передать каждую строку результата в scan.
+func (ydbCluster *YandexDBCluster) scanByBic(
+	query string,
+	scan func(queryResult result.Result, bic *string) error,
+) error {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	var queryResult result.Result
+
+	if err := ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) (err error) {
+			if _, queryResult, err = session.Execute(
+				ctx, table.OnlineReadOnlyTxControl(),
+				query,
+				nil,
+			); err != nil {
+				return errors.Wrap(err, "failed to execute query")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+	defer func() {
+		_ = queryResult.Close()
+	}()
+
+	for queryResult.NextResultSet(ydbContext) {
+		for queryResult.NextRow() {
+			var bic string
+			if err := scan(queryResult, &bic); err != nil {
+				return errors.Wrap(err, "failed to scan columns values")
+			}
+		}
+	}
+
+	return queryResult.Err()
+}
+
 func (ydbCluster *YandexDBCluster) FetchAccounts() ([]model.Account, error) {
 	var err error
 
@@ -325,7 +540,7 @@ func (ydbCluster *YandexDBCluster) FetchAccounts() ([]model.Account, error) {
 	defer ctxCloseFn()
 
 	tablePath := path.Join(stroppyDir, "account")
-	selectStmnt := fmt.Sprintf("SELECT bic, ban, balance FROM `%s`", tablePath)
+	selectStmnt := fmt.Sprintf("SELECT * FROM `%s`", tablePath)
 
 	var accs []model.Account
 
@@ -343,20 +558,48 @@ func (ydbCluster *YandexDBCluster) FetchAccounts() ([]model.Account, error) {
 				_ = rows.Close()
 			}()
 			for rows.NextResultSet(ydbContext) {
+				// атрибуты счета читаются, только если они есть в схеме таблицы
+				columns := make(map[string]bool)
+				rows.CurrentResultSet().Columns(func(column options.Column) {
+					columns[column.Name] = true
+				})
+
 				for rows.NextRow() {
-					var Balance int64
-					var acc model.Account
-					if err = rows.Scan(&acc.Bic, &acc.Ban, &Balance); err != nil {
+					var (
+						acc     model.Account
+						balance int64
+						payload [4]*string
+					)
+
+					values := []named.Value{
+						named.OptionalWithDefault("bic", &acc.Bic),
+						named.OptionalWithDefault("ban", &acc.Ban),
+						named.OptionalWithDefault("balance", &balance),
+					}
+					for i, column := range ydbAccountPayloadColumns {
+						if columns[column] {
+							values = append(values, named.Optional(column, &payload[i]))
+						}
+					}
+
+					if err = rows.ScanNamed(values...); err != nil {
 						return errors.Wrap(err, "failed to scan columns values")
 					}
-					dec := new(inf.Dec)
-					dec.SetUnscaled(Balance)
-					acc.Balance = dec
+
+					acc.Balance = inf.NewDec(balance, 0)
+					if payload[0] != nil || payload[1] != nil || payload[2] != nil || payload[3] != nil {
+						acc.Payload = &model.AccountPayload{
+							Name:     stringOrEmpty(payload[0]),
+							Currency: stringOrEmpty(payload[1]),
+							Status:   stringOrEmpty(payload[2]),
+							Padding:  stringOrEmpty(payload[3]),
+						}
+					}
 					accs = append(accs, acc)
 				}
 			}
 
-			return nil
+			return rows.Err()
 		},
 	); err != nil {
 		return nil, errors.Wrap(err, "failed to fetch accounts")
@@ -567,7 +810,11 @@ func (ydbCluster *YandexDBCluster) BootstrapDB(count, seed int) error {
 		return err
 	}
 
-	if err = createSettingsTable(
+	if ydbCluster.schemaTemplate != "" {
+		if err = ydbCluster.applySchemaTemplate(ydbContext, prefix, count, seed); err != nil {
+			return err
+		}
+	} else if err = createDefaultTables(
 		ydbContext,
 		ydbCluster.ydbConnection.Table(),
 		prefix,
@@ -575,42 +822,102 @@ func (ydbCluster *YandexDBCluster) BootstrapDB(count, seed int) error {
 		return err
 	}
 
-	if err = createAccountTable(
-		ydbContext,
-		ydbCluster.ydbConnection.Table(),
-		prefix,
-	); err != nil {
+	if err = checkYdbTables(ydbContext, ydbCluster.ydbConnection, prefix); err != nil {
 		return err
 	}
 
-	if err = createTransferTable(
-		ydbContext,
-		ydbCluster.ydbConnection.Table(),
-		prefix,
-	); err != nil {
+	// история не описывается пользовательскими шаблонами и создается всегда
+	if err = createHistoryTable(ydbContext, ydbCluster.ydbConnection.Table(), prefix); err != nil {
 		return err
 	}
 
-	if err = createChecksumTable(
+	if err = upsertSettings(
 		ydbContext,
 		ydbCluster.ydbConnection.Table(),
-		prefix,
+		fmt.Sprintf("%d", count),
+		fmt.Sprintf("%d", seed),
 	); err != nil {
 		return err
 	}
 
-	if err = upsertSettings(
+	return nil
+}
+
+// SetSchemaTemplate - задать пользовательский шаблон YQL для создания таблиц.
+// Таблицы создаются в каталоге stroppy, например "CREATE TABLE `stroppy/account` (...)".
+func (ydbCluster *YandexDBCluster) SetSchemaTemplate(schemaTemplate string) {
+	ydbCluster.schemaTemplate = schemaTemplate
+}
+
+func createDefaultTables(ydbContext context.Context, ydbClient table.Client, prefix string) error {
+	if err := createSettingsTable(ydbContext, ydbClient, prefix); err != nil {
+		return err
+	}
+
+	if err := createAccountTable(ydbContext, ydbClient, prefix); err != nil {
+		return err
+	}
+
+	if err := createTransferTable(ydbContext, ydbClient, prefix); err != nil {
+		return err
+	}
+
+	return createChecksumTable(ydbContext, ydbClient, prefix)
+}
+
+func (ydbCluster *YandexDBCluster) applySchemaTemplate(
+	ydbContext context.Context,
+	prefix string,
+	count, seed int,
+) error {
+	schemeQuery, err := renderSchema(ydbCluster.schemaTemplate, count, seed)
+	if err != nil {
+		return err
+	}
+
+	ydbClient := ydbCluster.ydbConnection.Table()
+
+	if err = ydbClient.Do(
 		ydbContext,
-		ydbCluster.ydbConnection.Table(),
-		fmt.Sprintf("%d", count),
-		fmt.Sprintf("%d", seed),
+		func(ctx context.Context, session table.Session) error {
+			for _, name := range ydbRequiredTables {
+				if err = session.DropTable(ctx, path.Join(prefix, name)); err != nil &&
+					!strings.Contains(err.Error(), schemeErr) {
+					return errors.Wrap(err, fmt.Sprintf("failed to drop table %s", name))
+				}
+			}
+
+			if err = session.ExecuteSchemeQuery(ctx, schemeQuery); err != nil {
+				return errors.Wrap(err, "failed to execute scheme query")
+			}
+
+			return nil
+		},
 	); err != nil {
-		return err
+		return errors.Wrap(err, "failed to apply schema template")
 	}
 
+	llog.Infoln("Tables created from schema template")
+
 	return nil
 }
 
+func checkYdbTables(ydbContext context.Context, ydbConnection ydb.Connection, prefix string) error {
+	directory, err := ydbConnection.Scheme().ListDirectory(ydbContext, prefix)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to list directory %s", prefix))
+	}
+
+	tables := make([]string, 0, len(directory.Children))
+	for _, entry := range directory.Children {
+		if entry.IsTable() {
+			tables = append(tables, entry.Name)
+		}
+	}
+
+	return checkRequiredTables(ydbRequiredTables, tables)
+}
+
 func createSettingsTable( //nolint:dupl // because it golang
 	ydbContext context.Context,
 	ydbClient table.Client, prefix string,
@@ -654,6 +961,10 @@ func createAccountTable(
 				options.WithColumn("bic", types.Optional(types.TypeString)),
 				options.WithColumn("ban", types.Optional(types.TypeString)),
 				options.WithColumn("balance", types.Optional(types.TypeInt64)),
+				options.WithColumn("name", types.Optional(types.TypeUTF8)),
+				options.WithColumn("currency", types.Optional(types.TypeUTF8)),
+				options.WithColumn("status", types.Optional(types.TypeUTF8)),
+				options.WithColumn("padding", types.Optional(types.TypeUTF8)),
 				options.WithPrimaryKeyColumn("bic", "ban"),
 				options.WithPartitioningSettings(
 					options.WithPartitioningByLoad(options.FeatureEnabled),
@@ -743,6 +1054,129 @@ func createChecksumTable( //nolint:dupl // because it golang
 	return nil
 }
 
+// createJournalTables - пересоздать таблицы журнала переводов и агрегатов по БИК.
+func createJournalTables(ydbContext context.Context, ydbClient table.Client, prefix string) error {
+	var err error
+
+	journalPath := path.Join(prefix, "journal")
+	if err = recreateTable(
+		ydbContext, ydbClient, journalPath,
+		func(ctx context.Context, session table.Session) error {
+			if err = session.CreateTable(
+				ctx, journalPath,
+				options.WithColumn("transfer_id", types.Optional(types.TypeString)),
+				options.WithColumn("leg", types.Optional(types.TypeString)),
+				options.WithColumn("bic", types.Optional(types.TypeString)),
+				options.WithColumn("ban", types.Optional(types.TypeString)),
+				options.WithColumn("amount", types.Optional(types.TypeInt64)),
+				options.WithPrimaryKeyColumn("transfer_id", "leg"),
+				options.WithPartitioningSettings(
+					options.WithPartitioningByLoad(options.FeatureEnabled),
+					options.WithPartitioningBySize(options.FeatureEnabled),
+					options.WithMinPartitionsCount(partitionsMinCount),
+					options.WithPartitionSizeMb(partitionsMaxMbytes),
+				),
+			); err != nil {
+				return errors.Wrap(err, "failed to create table")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to recreate journal table")
+	}
+
+	bankTotalPath := path.Join(prefix, "bank_total")
+	if err = recreateTable(
+		ydbContext, ydbClient, bankTotalPath,
+		func(ctx context.Context, session table.Session) error {
+			if err = session.CreateTable(
+				ctx, bankTotalPath,
+				options.WithColumn("bic", types.Optional(types.TypeString)),
+				options.WithColumn("initial_balance", types.Optional(types.TypeInt64)),
+				options.WithColumn("balance", types.Optional(types.TypeInt64)),
+				options.WithPrimaryKeyColumn("bic"),
+			); err != nil {
+				return errors.Wrap(err, "failed to create table")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to recreate bank_total table")
+	}
+
+	return nil
+}
+
+// createHistoryTable - пересоздать историю переводов. Первичный ключ (bic, ban, ts, transfer_id)
+// упорядочивает записи счета по времени, выписка читается диапазоном по его префиксу.
+func createHistoryTable(ydbContext context.Context, ydbClient table.Client, prefix string) error {
+	var err error
+
+	historyPath := path.Join(prefix, "history")
+	if err = recreateTable(
+		ydbContext, ydbClient, historyPath,
+		func(ctx context.Context, session table.Session) error {
+			if err = session.CreateTable(
+				ctx, historyPath,
+				options.WithColumn("bic", types.Optional(types.TypeString)),
+				options.WithColumn("ban", types.Optional(types.TypeString)),
+				options.WithColumn("ts", types.Optional(types.TypeTimestamp)),
+				options.WithColumn("transfer_id", types.Optional(types.TypeString)),
+				options.WithColumn("amount", types.Optional(types.TypeInt64)),
+				options.WithPrimaryKeyColumn("bic", "ban", "ts", "transfer_id"),
+				options.WithPartitioningSettings(
+					options.WithPartitioningByLoad(options.FeatureEnabled),
+					options.WithPartitioningBySize(options.FeatureEnabled),
+					options.WithMinPartitionsCount(partitionsMinCount),
+					options.WithPartitionSizeMb(partitionsMaxMbytes),
+				),
+			); err != nil {
+				return errors.Wrap(err, "failed to create table")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to recreate history table")
+	}
+
+	return nil
+}
+
+// createKVTable - пересоздать таблицу записей нагрузки ключ-значение.
+func createKVTable(ydbContext context.Context, ydbClient table.Client, prefix string) error {
+	var err error
+
+	usertablePath := path.Join(prefix, "usertable")
+	if err = recreateTable(
+		ydbContext, ydbClient, usertablePath,
+		func(ctx context.Context, session table.Session) error {
+			if err = session.CreateTable(
+				ctx, usertablePath,
+				options.WithColumn("ycsb_key", types.Optional(types.TypeString)),
+				options.WithColumn("field0", types.Optional(types.TypeString)),
+				options.WithPrimaryKeyColumn("ycsb_key"),
+				options.WithPartitioningSettings(
+					options.WithPartitioningByLoad(options.FeatureEnabled),
+					options.WithPartitioningBySize(options.FeatureEnabled),
+					options.WithMinPartitionsCount(partitionsMinCount),
+					options.WithPartitionSizeMb(partitionsMaxMbytes),
+				),
+			); err != nil {
+				return errors.Wrap(err, "failed to create table")
+			}
+
+			return nil
+		},
+	); err != nil {
+		return errors.Wrap(err, "failed to recreate usertable table")
+	}
+
+	return nil
+}
+
 func recreateTable(
 	ydbContext context.Context,
 	ydbClient table.Client,
@@ -858,14 +1292,11 @@ func (ydbCluster *YandexDBCluster) InsertAccount(acc model.Account) error {
 	if err = ydbCluster.ydbConnection.Table().Do(
 		ydbContext,
 		func(ydbContext context.Context, ydbSession table.Session) error {
+			query, params := ydbCluster.accountInsertQuery(acc)
 			if _, _, err = ydbSession.Execute(
 				ydbContext, table.DefaultTxControl(),
-				ydbCluster.yqlInsertAccount,
-				table.NewQueryParameters(
-					table.ValueParam("bic", types.BytesValueFromString(acc.Bic)),
-					table.ValueParam("ban", types.BytesValueFromString(acc.Ban)),
-					table.ValueParam("balance", types.Int64Value(acc.Balance.UnscaledBig().Int64())),
-				),
+				query,
+				params,
 				options.WithKeepInCache(true),
 			); err != nil {
 				return errors.Wrap(err, "failed to execute 'Do' procedure")
@@ -884,6 +1315,29 @@ func (ydbCluster *YandexDBCluster) InsertAccount(acc model.Account) error {
 	return nil
 }
 
+// accountInsertQuery - запрос и параметры вставки счета, атрибуты пишутся, только если
+// они сгенерированы, поэтому пользовательские схемы без этих колонок продолжают работать.
+func (ydbCluster *YandexDBCluster) accountInsertQuery(acc model.Account) (string, *table.QueryParameters) {
+	params := []table.ParameterOption{
+		table.ValueParam("bic", types.BytesValueFromString(acc.Bic)),
+		table.ValueParam("ban", types.BytesValueFromString(acc.Ban)),
+		table.ValueParam("balance", types.Int64Value(acc.Balance.UnscaledBig().Int64())),
+	}
+
+	if acc.Payload == nil {
+		return ydbCluster.yqlInsertAccount, table.NewQueryParameters(params...)
+	}
+
+	params = append(params,
+		table.ValueParam("name", types.UTF8Value(acc.Payload.Name)),
+		table.ValueParam("currency", types.UTF8Value(acc.Payload.Currency)),
+		table.ValueParam("status", types.UTF8Value(acc.Payload.Status)),
+		table.ValueParam("padding", types.UTF8Value(acc.Payload.Padding)),
+	)
+
+	return ydbCluster.yqlInsertPayload, table.NewQueryParameters(params...)
+}
+
 func (ydbCluster *YandexDBCluster) InsertTransfer(transfer *model.Transfer) error {
 	panic("unimplemented!")
 }
@@ -959,13 +1413,451 @@ func (ydbCluster *YandexDBCluster) UnlockAccount(
 	panic("unimplemented!")
 }
 
-// TODO: check possibility of collecting statistics for YDB.
-func (ydbCluster *YandexDBCluster) StartStatisticsCollect(_ time.Duration) error {
-	llog.Debugln("statistic for YDB not implemeted yet, watch grafana metrics, please")
+// ydbTableStat - срез статистики таблицы каталога stroppy.
+type ydbTableStat struct {
+	RowsEstimate     uint64                   `json:"rows_estimate"`
+	StoreSize        uint64                   `json:"store_size"`
+	Partitions       uint64                   `json:"partitions"`
+	ModificationTime time.Time                `json:"modification_time"`
+	PartitionStats   []options.PartitionStats `json:"partition_stats"`
+}
+
+// BootstrapKV - пересоздать таблицу записей нагрузки ключ-значение в каталоге stroppy.
+func (ydbCluster *YandexDBCluster) BootstrapKV() error {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	prefix := path.Join(ydbCluster.ydbConnection.Name(), stroppyDir)
+	if err := ydbCluster.ydbConnection.Scheme().MakeDirectory(ydbContext, prefix); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Error creating directory %s", prefix))
+	}
+
+	return createKVTable(ydbContext, ydbCluster.ydbConnection.Table(), prefix)
+}
+
+// KVInsert - добавить запись, существующий ключ возвращается как ErrDuplicateKey.
+func (ydbCluster *YandexDBCluster) KVInsert(key string, value []byte) error {
+	if err := ydbCluster.executeKV(ydbCluster.yqlKVInsert, key, value); err != nil {
+		if ydb.IsOperationError(err, Ydb.StatusIds_PRECONDITION_FAILED) { //nolint
+			return ErrDuplicateKey
+		}
+
+		return errors.Wrap(err, "failed to insert record")
+	}
+
+	return nil
+}
+
+// KVUpdate - записать значение через UPSERT без предварительного чтения.
+func (ydbCluster *YandexDBCluster) KVUpdate(key string, value []byte) error {
+	if err := ydbCluster.executeKV(ydbCluster.yqlKVUpsert, key, value); err != nil {
+		return errors.Wrap(err, "failed to update record")
+	}
+
+	return nil
+}
+
+// executeKV - выполнить изменяющий запрос записи в отдельной транзакции.
+func (ydbCluster *YandexDBCluster) executeKV(query string, key string, value []byte) error {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	return ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			_, _, err := session.Execute(
+				ctx, table.DefaultTxControl(),
+				query,
+				table.NewQueryParameters(
+					table.ValueParam("key", types.BytesValueFromString(key)),
+					table.ValueParam("value", types.BytesValue(value)),
+				),
+				options.WithKeepInCache(true),
+			)
+
+			return err
+		},
+		table.WithIdempotent(),
+	)
+}
+
+// KVRead - прочитать значение записи.
+func (ydbCluster *YandexDBCluster) KVRead(key string) ([]byte, error) {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	var value []byte
+
+	if err := ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			_, queryResult, err := session.Execute(
+				ctx, table.OnlineReadOnlyTxControl(),
+				ydbCluster.yqlKVRead,
+				table.NewQueryParameters(
+					table.ValueParam("key", types.BytesValueFromString(key)),
+				),
+				options.WithKeepInCache(true),
+			)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = queryResult.Close()
+			}()
+
+			value, err = scanKVValue(ctx, queryResult)
+
+			return err
+		},
+		table.WithIdempotent(),
+	); err != nil {
+		if errors.Is(err, ErrNoRows) {
+			return nil, ErrNoRows
+		}
+
+		return nil, errors.Wrap(err, "failed to read record")
+	}
+
+	return value, nil
+}
+
+// scanKVValue - значение единственной строки результата чтения записи.
+func scanKVValue(ctx context.Context, queryResult result.Result) ([]byte, error) {
+	var value []byte
+
+	for queryResult.NextResultSet(ctx) {
+		if !queryResult.NextRow() {
+			return nil, ErrNoRows
+		}
+
+		if err := queryResult.ScanNamed(named.OptionalWithDefault("field0", &value)); err != nil {
+			return nil, err
+		}
+	}
+
+	return value, queryResult.Err()
+}
+
+// KVScan - прочитать до count записей по порядку ключей, начиная с startKey.
+func (ydbCluster *YandexDBCluster) KVScan(startKey string, count int) (int, error) {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	scanned := 0
+
+	if err := ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			_, queryResult, err := session.Execute(
+				ctx, table.OnlineReadOnlyTxControl(),
+				ydbCluster.yqlKVScan,
+				table.NewQueryParameters(
+					table.ValueParam("key", types.BytesValueFromString(startKey)),
+					table.ValueParam("limit", types.Uint64Value(uint64(count))),
+				),
+				options.WithKeepInCache(true),
+			)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = queryResult.Close()
+			}()
+
+			scanned = 0
+			for queryResult.NextResultSet(ctx) {
+				for queryResult.NextRow() {
+					var (
+						key   string
+						value []byte
+					)
+					if err = queryResult.ScanNamed(
+						named.OptionalWithDefault("ycsb_key", &key),
+						named.OptionalWithDefault("field0", &value),
+					); err != nil {
+						return err
+					}
+					scanned++
+				}
+			}
+
+			return queryResult.Err()
+		},
+		table.WithIdempotent(),
+	); err != nil {
+		return 0, errors.Wrap(err, "failed to scan records")
+	}
+
+	return scanned, nil
+}
+
+// KVReadModifyWrite - прочитать запись и заменить ее значение в одной транзакции.
+func (ydbCluster *YandexDBCluster) KVReadModifyWrite(key string, value []byte) error {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	if err := ydbCluster.ydbConnection.Table().DoTx(
+		ydbContext,
+		func(ctx context.Context, tx table.TransactionActor) error {
+			queryResult, err := tx.Execute(
+				ctx, ydbCluster.yqlKVRead,
+				table.NewQueryParameters(
+					table.ValueParam("key", types.BytesValueFromString(key)),
+				),
+				options.WithKeepInCache(true),
+			)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = queryResult.Close()
+			}()
+
+			if _, err = scanKVValue(ctx, queryResult); err != nil {
+				return err
+			}
+
+			_, err = tx.Execute(
+				ctx, ydbCluster.yqlKVUpsert,
+				table.NewQueryParameters(
+					table.ValueParam("key", types.BytesValueFromString(key)),
+					table.ValueParam("value", types.BytesValue(value)),
+				),
+				options.WithKeepInCache(true),
+			)
+
+			return err
+		},
+		table.WithIdempotent(),
+	); err != nil {
+		if errors.Is(err, ErrNoRows) {
+			return ErrNoRows
+		}
+
+		return errors.Wrap(err, "failed to read-modify-write record")
+	}
+
+	return nil
+}
+
+// EnableHistory - записывать последующие переводы в историю счетов.
+func (ydbCluster *YandexDBCluster) EnableHistory() error {
+	ydbCluster.history = true
 
 	return nil
 }
 
+// FetchStatement - последние limit записей истории счета.
+func (ydbCluster *YandexDBCluster) FetchStatement(bic string, ban string, limit int) ([]HistoryEntry, error) {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	var entries []HistoryEntry
+
+	if err := ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			_, queryResult, err := session.Execute(
+				ctx, table.OnlineReadOnlyTxControl(),
+				ydbCluster.yqlFetchStatement,
+				table.NewQueryParameters(
+					table.ValueParam("bic", types.BytesValueFromString(bic)),
+					table.ValueParam("ban", types.BytesValueFromString(ban)),
+					table.ValueParam("limit", types.Uint64Value(uint64(limit))),
+				),
+				options.WithKeepInCache(true),
+			)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = queryResult.Close()
+			}()
+
+			entries = entries[:0]
+			for queryResult.NextResultSet(ctx) {
+				for queryResult.NextRow() {
+					var (
+						entry      HistoryEntry
+						transferID string
+					)
+					if err = queryResult.ScanNamed(
+						named.OptionalWithDefault("transfer_id", &transferID),
+						named.OptionalWithDefault("amount", &entry.Amount),
+						named.OptionalWithDefault("ts", &entry.Timestamp),
+					); err != nil {
+						return err
+					}
+
+					if entry.TransferId, err = uuid.Parse(transferID); err != nil {
+						return errors.Wrap(err, "failed to parse transfer id")
+					}
+					entries = append(entries, entry)
+				}
+			}
+
+			return queryResult.Err()
+		},
+		table.WithIdempotent(),
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch statement")
+	}
+
+	return entries, nil
+}
+
+// ScanBankAccounts - страница счетов банка по первичному ключу (bic, ban).
+func (ydbCluster *YandexDBCluster) ScanBankAccounts(bic string, afterBan string, limit int) ([]model.Account, error) {
+	ydbContext, ctxCloseFn := context.WithCancel(context.Background())
+	defer ctxCloseFn()
+
+	var accounts []model.Account
+
+	if err := ydbCluster.ydbConnection.Table().Do(
+		ydbContext,
+		func(ctx context.Context, session table.Session) error {
+			_, queryResult, err := session.Execute(
+				ctx, table.OnlineReadOnlyTxControl(),
+				ydbCluster.yqlScanBankAccounts,
+				table.NewQueryParameters(
+					table.ValueParam("bic", types.BytesValueFromString(bic)),
+					table.ValueParam("after_ban", types.BytesValueFromString(afterBan)),
+					table.ValueParam("limit", types.Uint64Value(uint64(limit))),
+				),
+				options.WithKeepInCache(true),
+			)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = queryResult.Close()
+			}()
+
+			accounts = accounts[:0]
+			for queryResult.NextResultSet(ctx) {
+				for queryResult.NextRow() {
+					var (
+						ban     string
+						balance int64
+					)
+					if err = queryResult.ScanNamed(
+						named.OptionalWithDefault("ban", &ban),
+						named.OptionalWithDefault("balance", &balance),
+					); err != nil {
+						return err
+					}
+
+					accounts = append(accounts, model.Account{ //nolint:exhaustivestruct
+						Bic:     bic,
+						Ban:     ban,
+						Balance: inf.NewDec(balance, 0),
+						Found:   true,
+					})
+				}
+			}
+
+			return queryResult.Err()
+		},
+		table.WithIdempotent(),
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to scan bank accounts")
+	}
+
+	return accounts, nil
+}
+
+// StartStatisticsCollect - периодически сохранять схему каталога stroppy
+// и статистику таблиц по партициям (таблеткам), пока не будет отменен контекст.
+func (ydbCluster *YandexDBCluster) StartStatisticsCollect(
+	ctx context.Context,
+	statInterval time.Duration,
+) error {
+	return startStatisticsCollect(ctx, statInterval, statSource{
+		database:     YandexDB,
+		fileTemplate: ydbStatJsonFileTemplate,
+		collect:      ydbCluster.collectStatistics,
+		extract:      extractYdbMetrics,
+	})
+}
+
+// extractYdbMetrics - суммарные оценка числа строк, размер и число партиций таблиц stroppy.
+func extractYdbMetrics(data interface{}) map[string]float64 {
+	values := make(map[string]float64)
+
+	root, _ := data.(map[string]interface{})
+	tables, _ := root["tables"].(map[string]interface{})
+
+	for _, tableStat := range tables {
+		for _, name := range []string{"rows_estimate", "store_size", "partitions"} {
+			if value, ok := statValue(tableStat, name); ok {
+				values["ydb."+name] += value
+			}
+		}
+	}
+
+	return values
+}
+
+func (ydbCluster *YandexDBCluster) collectStatistics() (interface{}, error) {
+	ydbContext, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	prefix := path.Join(ydbCluster.ydbConnection.Name(), stroppyDir)
+
+	directory, err := ydbCluster.ydbConnection.Scheme().ListDirectory(ydbContext, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to list directory %s", prefix))
+	}
+
+	scheme := make(map[string]string, len(directory.Children))
+	tables := make(map[string]ydbTableStat, len(directory.Children))
+
+	for _, entry := range directory.Children {
+		scheme[entry.Name] = entry.Type.String()
+
+		if !entry.IsTable() {
+			continue
+		}
+
+		var description options.Description
+
+		if err = ydbCluster.ydbConnection.Table().Do(
+			ydbContext,
+			func(ctx context.Context, session table.Session) (err error) {
+				description, err = session.DescribeTable(
+					ctx,
+					path.Join(prefix, entry.Name),
+					options.WithTableStats(),
+					options.WithPartitionStats(),
+				)
+
+				return err
+			},
+			table.WithIdempotent(),
+		); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to describe table %s", entry.Name))
+		}
+
+		if description.Stats == nil {
+			continue
+		}
+
+		tables[entry.Name] = ydbTableStat{
+			RowsEstimate:     description.Stats.RowsEstimate,
+			StoreSize:        description.Stats.StoreSize,
+			Partitions:       description.Stats.Partitions,
+			ModificationTime: description.Stats.ModificationTime,
+			PartitionStats:   description.Stats.PartitionStats,
+		}
+	}
+
+	return map[string]interface{}{
+		"scheme": scheme,
+		"tables": tables,
+	}, nil
+}
+
 // Substitute directory path into the YQL template,
 // replacing the double quote characters with backticks.
 func expandYql(query string) string {
@@ -974,3 +1866,13 @@ func expandYql(query string) string {
 
 	return retval
 }
+
+// Close - закрыть соединение с YandexDB.
+func (ydbCluster *YandexDBCluster) Close() {
+	ydbContext, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if err := ydbCluster.ydbConnection.Close(ydbContext); err != nil {
+		llog.Warnf("failed to close ydb connection: %v", err)
+	}
+}
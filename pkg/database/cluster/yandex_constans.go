@@ -4,6 +4,13 @@ const (
 	yqlInsertAccount = `
 DECLARE $bic AS String; DECLARE $ban AS String; DECLARE $balance AS Int64;
 INSERT INTO "&{stroppyDir}/account" (bic, ban, balance) VALUES ($bic, $ban, $balance);
+`
+
+	yqlInsertAccountPayload = `
+DECLARE $bic AS String; DECLARE $ban AS String; DECLARE $balance AS Int64;
+DECLARE $name AS Utf8; DECLARE $currency AS Utf8; DECLARE $status AS Utf8; DECLARE $padding AS Utf8;
+INSERT INTO "&{stroppyDir}/account" (bic, ban, balance, name, currency, status, padding)
+VALUES ($bic, $ban, $balance, $name, $currency, $status, $padding);
 `
 
 	yqlUpsertTransfer = `
@@ -80,3 +87,108 @@ FROM "&{stroppyDir}/account"
 WHERE bic = $bic AND ban = $ban
 `
 )
+
+// Запросы журналируемого перевода.
+const (
+	yqlJournalTransfer = `
+DECLARE $transfer_id AS String;
+DECLARE $src_bic AS String;
+DECLARE $src_ban AS String;
+DECLARE $dst_bic AS String;
+DECLARE $dst_ban AS String;
+DECLARE $amount AS Int64;
+UPSERT INTO "&{stroppyDir}/journal" (transfer_id, leg, bic, ban, amount)
+VALUES
+    ($transfer_id, 'debit', $src_bic, $src_ban, -$amount),
+    ($transfer_id, 'credit', $dst_bic, $dst_ban, $amount);
+
+$deltas = AsList(
+    AsStruct($src_bic AS bic, -$amount AS delta),
+    AsStruct($dst_bic AS bic, $amount AS delta)
+);
+
+UPDATE "&{stroppyDir}/bank_total" ON
+SELECT t.bic AS bic, t.balance + d.delta AS balance
+FROM "&{stroppyDir}/bank_total" AS t
+INNER JOIN (SELECT bic, SUM(delta) AS delta FROM AS_TABLE($deltas) GROUP BY bic) AS d
+ON t.bic = d.bic;
+`
+
+	yqlFillBankTotals = `
+UPSERT INTO "&{stroppyDir}/bank_total" (bic, initial_balance, balance)
+SELECT bic, SUM(balance) AS initial_balance, SUM(balance) AS balance
+FROM "&{stroppyDir}/account"
+GROUP BY bic;
+`
+
+	yqlSelectBankTotals = `
+SELECT bic, initial_balance, balance FROM "&{stroppyDir}/bank_total";
+`
+
+	yqlSumAccountsByBic = `
+SELECT bic, SUM(balance) AS total FROM "&{stroppyDir}/account" GROUP BY bic;
+`
+
+	yqlSumJournalByBic = `
+SELECT bic, SUM(amount) AS total FROM "&{stroppyDir}/journal" GROUP BY bic;
+`
+)
+
+// Запросы нагрузки ключ-значение.
+const (
+	yqlKVInsert = `
+DECLARE $key AS String; DECLARE $value AS String;
+INSERT INTO "&{stroppyDir}/usertable" (ycsb_key, field0) VALUES ($key, $value);
+`
+
+	yqlKVUpsert = `
+DECLARE $key AS String; DECLARE $value AS String;
+UPSERT INTO "&{stroppyDir}/usertable" (ycsb_key, field0) VALUES ($key, $value);
+`
+
+	yqlKVRead = `
+DECLARE $key AS String;
+SELECT field0 FROM "&{stroppyDir}/usertable" WHERE ycsb_key = $key;
+`
+
+	yqlKVScan = `
+DECLARE $key AS String; DECLARE $limit AS Uint64;
+SELECT ycsb_key, field0 FROM "&{stroppyDir}/usertable"
+WHERE ycsb_key >= $key
+ORDER BY ycsb_key
+LIMIT $limit;
+`
+)
+
+// Запросы истории переводов и выписки по счету.
+const (
+	yqlHistoryTransfer = `
+DECLARE $transfer_id AS String;
+DECLARE $src_bic AS String;
+DECLARE $src_ban AS String;
+DECLARE $dst_bic AS String;
+DECLARE $dst_ban AS String;
+DECLARE $ts AS Timestamp;
+DECLARE $amount AS Int64;
+UPSERT INTO "&{stroppyDir}/history" (bic, ban, ts, transfer_id, amount)
+VALUES
+    ($src_bic, $src_ban, $ts, $transfer_id, -$amount),
+    ($dst_bic, $dst_ban, $ts, $transfer_id, $amount);
+`
+
+	yqlFetchStatement = `
+DECLARE $bic AS String; DECLARE $ban AS String; DECLARE $limit AS Uint64;
+SELECT transfer_id, amount, ts FROM "&{stroppyDir}/history"
+WHERE bic = $bic AND ban = $ban
+ORDER BY ts DESC
+LIMIT $limit;
+`
+
+	yqlScanBankAccounts = `
+DECLARE $bic AS String; DECLARE $after_ban AS String; DECLARE $limit AS Uint64;
+SELECT ban, balance FROM "&{stroppyDir}/account"
+WHERE bic = $bic AND ban > $after_ban
+ORDER BY ban
+LIMIT $limit;
+`
+)
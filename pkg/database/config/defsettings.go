@@ -5,8 +5,10 @@
 package config
 
 import (
+	"runtime"
 	"time"
 
+	"gitlab.com/picodata/stroppy/internal/fixed_random_source"
 	"gitlab.com/picodata/stroppy/pkg/database/cluster"
 	"gitlab.com/picodata/stroppy/pkg/engine/provider"
 
@@ -19,23 +21,31 @@ const (
 	chaosParameterPg  = "pg-pod-kill-first,pg-pod-kill-second"
 )
 
+// Settings - все параметры stroppy, теги yaml задают ключи файла конфигурации
+// и имена переменных окружения STROPPY_*, см. Load
 type Settings struct {
-	WorkingDirectory string
-	CmdCallDirectory string
-	LogLevel         string
+	WorkingDirectory string `yaml:"dir"`
+	CmdCallDirectory string `yaml:"-"`
+	LogLevel         string `yaml:"log_level"`
 
-	Local bool
+	// каталог результатов запусков, по умолчанию results в рабочем каталоге
+	ResultsDirectory string `yaml:"results_dir"`
 
-	UseChaos       bool
-	ChaosParameter string
+	Local bool `yaml:"local"`
 
-	TestSettings *TestSettings
+	// подключаться к БД напрямую по url, без ssh, kubernetes и провайдера
+	Direct bool `yaml:"direct"`
 
-	DatabaseSettings   *DatabaseSettings
-	DeploymentSettings *DeploymentSettings
+	UseChaos       bool   `yaml:"use_chaos"`
+	ChaosParameter string `yaml:"chaos_parameter"`
 
-	DestroyOnExit bool
-	EnableProfile bool
+	TestSettings *TestSettings `yaml:"test"`
+
+	DatabaseSettings   *DatabaseSettings   `yaml:"database"`
+	DeploymentSettings *DeploymentSettings `yaml:"deployment"`
+
+	DestroyOnExit bool `yaml:"auto_destroy"`
+	EnableProfile bool `yaml:"enable_profile"`
 }
 
 func DefaultSettings() (s *Settings) {
@@ -45,6 +55,8 @@ func DefaultSettings() (s *Settings) {
 
 		Local: false,
 
+		Direct: false,
+
 		DeploymentSettings: deployDefaults(),
 		DatabaseSettings:   DatabaseDefaults(),
 
@@ -66,9 +78,12 @@ func DefaultSettings() (s *Settings) {
 }
 
 type TestSettings struct {
-	KubernetesMasterAddress string
-	UseCloudStroppy         bool
-	RunAsPod                bool
+	KubernetesMasterAddress string `yaml:"kube_master_addr"`
+	UseCloudStroppy         bool   `yaml:"use_cloud_stroppy"`
+	RunAsPod                bool   `yaml:"run_as_pod"`
+
+	// путь к файлу сценария, по умолчанию third_party/tests/scenario.yaml в рабочем каталоге
+	Scenario string `yaml:"scenario"`
 }
 
 func TestDefaults() *TestSettings {
@@ -76,74 +91,209 @@ func TestDefaults() *TestSettings {
 		KubernetesMasterAddress: "",
 		UseCloudStroppy:         false,
 		RunAsPod:                false,
+		Scenario:                "",
 	}
 }
 
 type DatabaseSettings struct {
-	DBType   string
-	Workers  int
-	Count    int
-	User     string
-	Password string
-	Seed     int64
+	DBType   string `yaml:"dbtype"`
+	Workers  int    `yaml:"workers"`
+	Count    int    `yaml:"count"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Seed     int64  `yaml:"seed"`
 
 	// long story short - enabled Zipfian distribution means that some of bic/ban compositions
 	// are used much much more often than others
-	Zipfian bool
-	Oracle  bool
-	Check   bool
+	Zipfian bool `yaml:"zipfian"`
+	Oracle  bool `yaml:"oracle"`
+
+	// распределение обращений к счетам в тесте переводов и его параметры,
+	// Zipfian равносилен Distribution = zipfian
+	Distribution string  `yaml:"distribution"`
+	ZipfTheta    float64 `yaml:"zipf_theta"`
+	HotspotKeys  float64 `yaml:"hotspot_keys"`
+	HotspotOps   float64 `yaml:"hotspot_ops"`
+
+	Check bool `yaml:"check"`
+
+	// ограничение длительности теста переводов: воркеры завершаются по истечении Duration,
+	// даже если Count переводов еще не сделано, при Count = 0 число переводов не ограничено
+	Duration time.Duration `yaml:"duration"`
 
 	// TODO: add type validation in cli
-	DBURL              string
-	UseCustomTx        bool
-	BanRangeMultiplier float64
-	StatInterval       time.Duration
-	ConnectPoolSize    int
-	Sharded            bool
+	DBURL              string        `yaml:"url"`
+	UseCustomTx        bool          `yaml:"tx"`
+	BanRangeMultiplier float64       `yaml:"ban_range_multiplier"`
+	StatInterval       time.Duration `yaml:"stat_interval"`
+	ConnectPoolSize    int           `yaml:"pool_size"`
+	Sharded            bool          `yaml:"sharded"`
+
+	// уровень изоляции и стратегия блокировки счетов для postgres и cockroach
+	Isolation string `yaml:"isolation"`
+	Locking   string `yaml:"locking"`
+
+	// путь к пользовательскому шаблону схемы, по умолчанию <dir>/<dbtype>/schema.tmpl
+	SchemaTemplate string `yaml:"schema_template"`
+
+	// дополнительные адреса кластера и политика маршрутизации запросов между ними
+	Endpoints []string `yaml:"endpoints"`
+	Routing   string   `yaml:"routing"`
+
+	// журналируемый перевод: проводки в журнал и агрегаты по БИК в той же транзакции
+	Journal bool `yaml:"journal"`
+
+	// загрузка счетов с дополнительными атрибутами: имя клиента, валюта, статус
+	// и заполнитель PaddingSize байт, чтобы размер записи был близок к реальному
+	AccountPayload bool `yaml:"account_payload"`
+	PaddingSize    int  `yaml:"padding_size"`
+
+	// мультивалютный режим: счета загружаются с валютой, переводы пересчитываются
+	// по курсу из таблицы курсов, а проверка баланса выполняется по каждой валюте
+	MultiCurrency bool `yaml:"multi_currency"`
+
+	// перечисление счетов: номер счета взаимно однозначно отображается в пару БИК/BAN,
+	// загрузка обходится без повторов на дубликатах, а переводы выбирают существующие
+	// счета, кроме доли MissRatio обращений к заведомо отсутствующим
+	Enumerate bool    `yaml:"enumerate"`
+	MissRatio float64 `yaml:"miss_ratio"`
+
+	// нагрузка ключ-значение: стандартная смесь YCSB (a-f) или custom с долями операций,
+	// число загружаемых записей, размер значений и максимальная длина сканирования.
+	// Count задает число операций, KVDistribution по умолчанию берется из смеси.
+	KVWorkload                  string  `yaml:"kv_workload"`
+	KVRecords                   int     `yaml:"kv_records"`
+	KVValueSize                 int     `yaml:"kv_value_size"`
+	KVValueSizeMax              int     `yaml:"kv_value_size_max"`
+	KVMaxScanLength             int     `yaml:"kv_max_scan_length"`
+	KVSkipLoad                  bool    `yaml:"kv_skip_load"`
+	KVDistribution              string  `yaml:"kv_distribution"`
+	KVReadProportion            float64 `yaml:"kv_read_proportion"`
+	KVUpdateProportion          float64 `yaml:"kv_update_proportion"`
+	KVInsertProportion          float64 `yaml:"kv_insert_proportion"`
+	KVScanProportion            float64 `yaml:"kv_scan_proportion"`
+	KVReadModifyWriteProportion float64 `yaml:"kv_read_modify_write_proportion"`
+
+	// история переводов по счетам и чтение выписок параллельно с переводами: StatementWorkers
+	// воркеров читают последние StatementLength записей истории счета, а доля BankScanShare
+	// их операций - обход всех счетов БИК страницами по ScanPageSize счетов
+	History          bool    `yaml:"history"`
+	StatementWorkers int     `yaml:"statement_workers"`
+	StatementLength  int     `yaml:"statement_length"`
+	BankScanShare    float64 `yaml:"bank_scan_share"`
+	ScanPageSize     int     `yaml:"scan_page_size"`
+
+	// горячие счета: доля HotFraction переводов затрагивает один из HotAccounts первых
+	// перечисленных счетов, в итогах выводятся ContentionTop самых конфликтных счетов
+	HotAccounts   int     `yaml:"hot_accounts"`
+	HotFraction   float64 `yaml:"hot_fraction"`
+	ContentionTop int     `yaml:"contention_top"`
+
+	// совместный запуск нескольких клиентов: клиент ClientIndex из Clients выполняет свою
+	// долю Count, Workers и StatementWorkers с seed, смещенным на номер клиента. Клиенты
+	// начинают одновременно, согласуясь через файлы каталога Barrier.
+	Clients     int    `yaml:"clients"`
+	ClientIndex int    `yaml:"client_index"`
+	Barrier     string `yaml:"barrier"`
 }
 
 // DatabaseDefaults заполняет параметры для запуска тестов значениями по умолчанию
 // линтер требует указания всех полей структуры при присвоении переменной
+// KeyDistribution - распределение обращений к счетам из параметров теста переводов.
+func (settings *DatabaseSettings) KeyDistribution() fixed_random_source.Distribution {
+	distribution := fixed_random_source.Distribution{
+		Name:        settings.Distribution,
+		ZipfTheta:   settings.ZipfTheta,
+		HotspotKeys: settings.HotspotKeys,
+		HotspotOps:  settings.HotspotOps,
+	}
+
+	if settings.Zipfian {
+		distribution.Name = fixed_random_source.DistributionZipfian
+	}
+
+	return distribution
+}
+
 func DatabaseDefaults() *DatabaseSettings {
 	return &DatabaseSettings{ //nolint
 		DBType:             cluster.Postgres,
+		Workers:            4 * runtime.NumCPU(), //nolint
 		Count:              10000,
 		User:               "",
 		Password:           "",
 		Seed:               time.Now().UnixNano(),
 		Zipfian:            false,
+		Distribution:       "uniform",
+		ZipfTheta:          0.99,
+		HotspotKeys:        0.2,
+		HotspotOps:         0.8,
+		AccountPayload:     false,
+		PaddingSize:        256,
+		MultiCurrency:      false,
+		Enumerate:          false,
+		MissRatio:          0,
+		KVWorkload:         "a",
+		KVRecords:          100000,
+		KVValueSize:        1000,
+		KVValueSizeMax:     0,
+		KVMaxScanLength:    100,
+		KVSkipLoad:         false,
+		KVDistribution:     "",
+		History:            false,
+		StatementWorkers:   0,
+		StatementLength:    10,
+		BankScanShare:      0.1,
+		ScanPageSize:       100,
+		HotAccounts:        0,
+		HotFraction:        0.5,
+		ContentionTop:      0,
+		Clients:            1,
+		ClientIndex:        0,
+		Barrier:            "",
 		Oracle:             false,
 		Check:              false,
+		Duration:           0,
 		DBURL:              "",
 		UseCustomTx:        false,
 		BanRangeMultiplier: 1.1,
-		StatInterval:       10,
+		StatInterval:       10 * time.Second,
 		ConnectPoolSize:    0,
 		Sharded:            false,
+		Isolation:          cluster.IsolationRepeatableRead,
+		Locking:            cluster.LockingUpdate,
+		SchemaTemplate:     "",
+		Endpoints:          nil,
+		Routing:            cluster.RoutingPrimaryFailover,
+		Journal:            false,
 	}
 }
 
 type DeploymentSettings struct {
-	Provider         string
-	Flavor           string
-	Nodes            int
-	AnsibleVerbosity string
-	ForceReinstall   bool
-	GrUser           string
-	GrPassword       string
-	GrPort           uint16
-	PromPort         uint16
-	PromSPort        uint16
-	AllWorkers       bool
-	AllMasters       bool
+	Provider         string `yaml:"cloud"`
+	Flavor           string `yaml:"flavor"`
+	Nodes            int    `yaml:"nodes"`
+	AnsibleVerbosity string `yaml:"ansible_verbosity"`
+	ForceReinstall   bool   `yaml:"force_reinstall"`
+	GrUser           string `yaml:"grafana_user"`
+	GrPassword       string `yaml:"grafana_password"`
+	GrPort           uint16 `yaml:"grafana_port"`
+	PromPort         uint16 `yaml:"prometheus_port"`
+	PromSPort        uint16 `yaml:"prometheus_secure_port"`
+	AllWorkers       bool   `yaml:"all_workers"`
+	AllMasters       bool   `yaml:"all_masters"`
 }
 
 // DefaultsDeploy заполняет параметры развертывания значениями по умолчанию.
 func deployDefaults() *DeploymentSettings {
-	d := DeploymentSettings{
-		Provider: provider.Neutral,
-		Flavor:   "small",
-		Nodes:    3,
+	d := DeploymentSettings{ //nolint
+		Provider:  provider.Neutral,
+		Flavor:    "small",
+		Nodes:     3,
+		GrUser:    "stroppy",
+		GrPort:    3000,  //nolint
+		PromPort:  30080, //nolint
+		PromSPort: 30443, //nolint
 	}
 	return &d
 }
@@ -0,0 +1,345 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ansel1/merry"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+	"gitlab.com/picodata/stroppy/pkg/engine/provider"
+)
+
+// problems - найденные при проверке ошибки, выводятся все сразу
+type problems []string
+
+func (p *problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	return merry.Errorf("invalid settings:\n  - %s", strings.Join(p, "\n  - "))
+}
+
+// Validate - проверить параметры запуска теста до подключения к БД: сочетания параметров
+// и их поддержку драйвером выбранной БД. Адрес БД обязателен для драйверов без адреса
+// по умолчанию, если тест выполняется не в поде stroppy развернутого кластера.
+func (s *Settings) Validate() error {
+	var found problems
+
+	s.validateTest(&found)
+	s.validateDatabase(&found, !s.TestSettings.UseCloudStroppy)
+	s.validateDirect(&found)
+
+	return found.err()
+}
+
+// ValidateKV - проверить параметры команды kv: кроме общих проверок драйвер выбранной БД
+// должен поддерживать операции ключ-значение
+func (s *Settings) ValidateKV() error {
+	var found problems
+
+	s.validateTest(&found)
+	s.validateDatabase(&found, !s.TestSettings.UseCloudStroppy)
+	s.validateDirect(&found)
+
+	capabilities, err := cluster.GetCapabilities(s.DatabaseSettings.DBType, s.DatabaseSettings.DBURL)
+	if err == nil && !capabilities.KV {
+		found.add("key-value workload is not supported for %s, set --dbtype", capabilities.Name)
+	}
+
+	return found.err()
+}
+
+// ValidateStep - проверить параметры шага сценария или сочетания матрицы теми же
+// правилами, что и параметры запуска. Параметры подключения шаг не меняет, поэтому
+// адрес БД проверяется только вместе с параметрами запуска.
+func (s *Settings) ValidateStep(database *DatabaseSettings) error {
+	var found problems
+
+	step := *s
+	step.DatabaseSettings = database
+
+	step.validateDatabase(&found, false)
+
+	return found.err()
+}
+
+// ValidateDeployment - проверить параметры до развертывания кластера, адрес БД
+// при развертывании не нужен
+func (s *Settings) ValidateDeployment() error {
+	var found problems
+
+	s.validateTest(&found)
+	s.validateDatabase(&found, false)
+
+	deploySettings := s.DeploymentSettings
+
+	switch deploySettings.Provider {
+	case provider.Neutral, provider.Yandex, provider.Oracle:
+	default:
+		found.add("unknown cloud provider '%s', set --cloud to %s, %s or %s",
+			deploySettings.Provider, provider.Yandex, provider.Oracle, provider.Neutral)
+	}
+
+	if deploySettings.Nodes <= 0 {
+		found.add("nodes count must be positive, got %d, set --nodes", deploySettings.Nodes)
+	}
+
+	return found.err()
+}
+
+func (s *Settings) validateTest(found *problems) {
+	if s.TestSettings.UseCloudStroppy && s.TestSettings.RunAsPod {
+		found.add("--use-cloud-stroppy starts the test in the stroppy pod and --run-as-pod " +
+			"means this process already is that pod, set only one of them")
+	}
+
+	if s.Local && s.TestSettings.RunAsPod {
+		found.add("--local and --run-as-pod can not be set at the same time")
+	}
+
+	if s.UseChaos && s.ChaosParameter == "" {
+		found.add("--use-chaos requires chaos scenarios, set --chaos-parameter")
+	}
+}
+
+// validateDirect - при прямом подключении нет ни кластера kubernetes, ни пода stroppy,
+// поэтому нужен адрес БД, а хаос и удаленный запуск недоступны
+func (s *Settings) validateDirect(found *problems) {
+	if !s.Direct {
+		return
+	}
+
+	if s.DatabaseSettings.DBURL == "" {
+		found.add("--direct connects to the database by its connection string only, set --url")
+	}
+
+	if s.TestSettings.UseCloudStroppy {
+		found.add("--direct runs the test in this process without a deployed cluster, " +
+			"unset --use-cloud-stroppy")
+	}
+
+	if s.UseChaos {
+		found.add("--direct does not create the kubernetes client chaos-mesh needs, unset --use-chaos")
+	}
+}
+
+//nolint:gocyclo
+func (s *Settings) validateDatabase(found *problems, requireURL bool) {
+	settings := s.DatabaseSettings
+
+	capabilities, err := cluster.GetCapabilities(settings.DBType, settings.DBURL)
+	if err != nil {
+		found.add("%v, set --dbtype", err)
+
+		return
+	}
+
+	if requireURL && capabilities.RequiresURL && settings.DBURL == "" {
+		found.add("%s requires a connection string, set --url", capabilities.Name)
+	}
+
+	if settings.Workers <= 0 {
+		found.add("workers count must be positive, got %d, set --workers", settings.Workers)
+	}
+
+	if settings.Count < 0 || settings.ConnectPoolSize < 0 || settings.Duration < 0 {
+		found.add("count, pool size and duration must not be negative, got %d, %d and %v",
+			settings.Count, settings.ConnectPoolSize, settings.Duration)
+	}
+
+	// случайные пары БИК/BAN без перечисления не покрывают все счета при brm < 1
+	if !settings.Enumerate && settings.BanRangeMultiplier < 1 {
+		found.add("ban range multiplier below 1 never generates enough unique accounts "+
+			"for pop, got %v, set --banRangeMultiplier to 1.01..1.1 or use --enumerate",
+			settings.BanRangeMultiplier)
+	}
+
+	if settings.StatInterval < time.Second {
+		found.add("stat interval must be at least 1s, got %v, give it with a unit, "+
+			"for example --stat-interval 10s", settings.StatInterval)
+	}
+
+	if err = settings.KeyDistribution().Validate(); err != nil {
+		found.add("%v, set --distribution", err)
+	}
+
+	if settings.MissRatio < 0 || settings.MissRatio > 1 {
+		found.add("miss ratio must be between 0 and 1, got %v, set --miss-ratio", settings.MissRatio)
+	}
+
+	if settings.MissRatio > 0 && !settings.Enumerate {
+		found.add("miss ratio requires enumerated accounts, set --enumerate")
+	}
+
+	if settings.AccountPayload && settings.PaddingSize < 0 {
+		found.add("padding size must not be negative, got %d, set --padding-size", settings.PaddingSize)
+	}
+
+	validateHotAccounts(found, settings)
+	validateStatements(found, settings)
+
+	if settings.Oracle && !capabilities.Oracle {
+		found.add("oracle is not supported for %s, unset --oracle", capabilities.Name)
+	}
+
+	history := settings.History || settings.StatementWorkers > 0
+
+	for _, feature := range []struct {
+		name      string
+		enabled   bool
+		supported bool
+		flag      string
+	}{
+		{"transfer journal", settings.Journal, capabilities.Journal, "--journal"},
+		{"multi-currency mode", settings.MultiCurrency, capabilities.MultiCurrency, "--multi-currency"},
+		{"transfer history", history, capabilities.History, "--history and --statement-workers"},
+	} {
+		if !feature.enabled {
+			continue
+		}
+
+		if !feature.supported {
+			found.add("%s is not supported for %s, unset %s", feature.name, capabilities.Name, feature.flag)
+		}
+
+		if settings.UseCustomTx {
+			found.add("%s requires builtin transactions, unset --tx", feature.name)
+		}
+	}
+
+	if capabilities.SQLTx {
+		txSettings := cluster.SQLTxSettings{Isolation: settings.Isolation, Locking: settings.Locking}
+		if err = txSettings.Validate(); err != nil {
+			found.add("%v, set --isolation and --locking", err)
+		}
+	}
+
+	if capabilities.Routing {
+		routing := cluster.RoutingSettings{Endpoints: settings.Endpoints, Policy: settings.Routing}
+		if err = routing.Validate(); err != nil {
+			found.add("%v, set --routing", err)
+		}
+	} else if len(settings.Endpoints) > 0 {
+		found.add("additional endpoints are not supported for %s, unset --endpoints", capabilities.Name)
+	}
+
+	if settings.Sharded && !capabilities.Sharded {
+		found.add("sharded cluster is not supported for %s, unset --sharded", capabilities.Name)
+	}
+
+	s.validateClients(found)
+}
+
+// validateHotAccounts - проверить параметры горячих счетов. Горячими становятся первые
+// перечисленные счета, поэтому они гарантированно загружены только перечислением.
+func validateHotAccounts(found *problems, settings *DatabaseSettings) {
+	if settings.HotAccounts < 0 || settings.ContentionTop < 0 {
+		found.add("hot accounts and contention top must not be negative, got %d and %d, "+
+			"set --hot-accounts and --contention-top", settings.HotAccounts, settings.ContentionTop)
+	}
+
+	if settings.HotAccounts <= 0 {
+		return
+	}
+
+	if !settings.Enumerate {
+		found.add("hot accounts require enumerated accounts, set --enumerate")
+	}
+
+	if settings.HotFraction < 0 || settings.HotFraction > 1 {
+		found.add("hot fraction must be between 0 and 1, got %v, set --hot-fraction", settings.HotFraction)
+	}
+}
+
+// validateStatements - проверить параметры чтения выписок.
+func validateStatements(found *problems, settings *DatabaseSettings) {
+	if settings.StatementWorkers < 0 {
+		found.add("statement workers must not be negative, got %d, set --statement-workers",
+			settings.StatementWorkers)
+	}
+
+	if settings.StatementWorkers <= 0 {
+		return
+	}
+
+	if settings.StatementLength < 1 || settings.ScanPageSize < 1 {
+		found.add("statement length and scan page size must be positive, got %d and %d, "+
+			"set --statement-length and --scan-page-size", settings.StatementLength, settings.ScanPageSize)
+	}
+
+	if settings.BankScanShare < 0 || settings.BankScanShare > 1 {
+		found.add("bank scan share must be between 0 and 1, got %v, set --bank-scan-share",
+			settings.BankScanShare)
+	}
+}
+
+// validateClients - проверить параметры совместного запуска нескольких клиентов. Журнал,
+// курсы валют и оракул готовятся и ведутся одним процессом, поэтому с ними клиент один.
+func (s *Settings) validateClients(found *problems) {
+	settings := s.DatabaseSettings
+
+	if settings.Clients <= 0 {
+		found.add("clients count must be positive, got %d, set --clients", settings.Clients)
+
+		return
+	}
+
+	if settings.ClientIndex < 0 || settings.ClientIndex >= settings.Clients {
+		found.add("client index must be from 0 to %d, got %d, set --client-index",
+			settings.Clients-1, settings.ClientIndex)
+	}
+
+	if settings.Clients == 1 {
+		return
+	}
+
+	if settings.Workers < settings.Clients {
+		found.add("every client needs a worker, got %d workers for %d clients, set --workers",
+			settings.Workers, settings.Clients)
+	}
+
+	// клиент без своей доли переводов работал бы без ограничения числа переводов
+	if settings.Count > 0 && settings.Count < settings.Clients {
+		found.add("every client needs a part of count, got %d for %d clients, set --count",
+			settings.Count, settings.Clients)
+	}
+
+	for _, feature := range []struct {
+		name    string
+		enabled bool
+		flag    string
+	}{
+		{"transfer journal", settings.Journal, "--journal"},
+		{"multi-currency mode", settings.MultiCurrency, "--multi-currency"},
+		{"oracle", settings.Oracle, "--oracle"},
+	} {
+		if feature.enabled {
+			found.add("%s is not supported with several clients, unset %s or set --clients 1",
+				feature.name, feature.flag)
+		}
+	}
+
+	// в поде stroppy каталог согласования и проверку баланса после всех клиентов
+	// задает запустивший их stroppy
+	if s.TestSettings.UseCloudStroppy {
+		return
+	}
+
+	if settings.Barrier == "" {
+		found.add("several clients start together through a directory shared by them, set --barrier")
+	}
+
+	if settings.Check {
+		found.add("balance is checked when all clients are done, unset --check and run check after them")
+	}
+}
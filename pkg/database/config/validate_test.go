@@ -0,0 +1,303 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
+)
+
+// оракул поддерживают все драйверы, поэтому правило "oracle is not supported" не проверяется
+func TestValidateRules(t *testing.T) {
+	validateKV := func(s *Settings) error { return s.ValidateKV() }
+	validateDeployment := func(s *Settings) error { return s.ValidateDeployment() }
+	validateStep := func(s *Settings) error { return s.ValidateStep(s.DatabaseSettings) }
+
+	for _, test := range []struct {
+		name     string
+		change   func(s *Settings, d *DatabaseSettings)
+		validate func(s *Settings) error
+		expected []string
+	}{
+		{name: "defaults", change: func(*Settings, *DatabaseSettings) {}},
+		{
+			name: "cloud stroppy and run as pod",
+			change: func(s *Settings, _ *DatabaseSettings) {
+				s.TestSettings.UseCloudStroppy, s.TestSettings.RunAsPod = true, true
+			},
+			expected: []string{"--use-cloud-stroppy starts the test in the stroppy pod and --run-as-pod " +
+				"means this process already is that pod, set only one of them"},
+		},
+		{
+			name:     "local and run as pod",
+			change:   func(s *Settings, _ *DatabaseSettings) { s.Local, s.TestSettings.RunAsPod = true, true },
+			expected: []string{"--local and --run-as-pod can not be set at the same time"},
+		},
+		{
+			name:     "chaos without scenarios",
+			change:   func(s *Settings, _ *DatabaseSettings) { s.UseChaos, s.ChaosParameter = true, "" },
+			expected: []string{"--use-chaos requires chaos scenarios, set --chaos-parameter"},
+		},
+		{
+			name:     "direct without url",
+			change:   func(s *Settings, _ *DatabaseSettings) { s.Direct = true },
+			expected: []string{"--direct connects to the database by its connection string only, set --url"},
+		},
+		{
+			name: "direct with cloud stroppy",
+			change: func(s *Settings, d *DatabaseSettings) {
+				s.Direct, s.TestSettings.UseCloudStroppy, d.DBURL = true, true, "postgres://db"
+			},
+			expected: []string{"--direct runs the test in this process without a deployed cluster, unset --use-cloud-stroppy"},
+		},
+		{
+			name: "direct with chaos",
+			change: func(s *Settings, d *DatabaseSettings) {
+				s.Direct, s.UseChaos, s.ChaosParameter, d.DBURL = true, true, "pod-kill", "postgres://db"
+			},
+			expected: []string{"--direct does not create the kubernetes client chaos-mesh needs, unset --use-chaos"},
+		},
+		{
+			name:   "unknown database",
+			change: func(_ *Settings, d *DatabaseSettings) { d.DBType = "oracle" },
+			expected: []string{"unknown database type 'oracle', " +
+				"expected one of postgres, cockroach, fdb, mongodb, cartridge, ydb, set --dbtype"},
+		},
+		{
+			name:     "database requires url",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.DBType = cluster.Cockroach },
+			expected: []string{"cockroach requires a connection string, set --url"},
+		},
+		{
+			name:     "step does not require url",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.DBType = cluster.Cockroach },
+			validate: validateStep,
+		},
+		{
+			name:     "deployment does not require url",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.DBType = cluster.Cockroach },
+			validate: validateDeployment,
+		},
+		{
+			name:     "workers",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Workers = 0 },
+			expected: []string{"workers count must be positive, got 0, set --workers"},
+		},
+		{
+			name:     "negative count",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Count, d.ConnectPoolSize = -1, 10 },
+			expected: []string{"count, pool size and duration must not be negative, got -1, 10 and 0s"},
+		},
+		{
+			name: "ban range multiplier",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.BanRangeMultiplier = 0.9
+			},
+			expected: []string{"ban range multiplier below 1 never generates enough unique accounts for pop, " +
+				"got 0.9, set --banRangeMultiplier to 1.01..1.1 or use --enumerate"},
+		},
+		{
+			name: "ban range multiplier with enumerate",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.BanRangeMultiplier, d.Enumerate = 0.9, true
+			},
+		},
+		{
+			name:     "stat interval",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.StatInterval = 10 * time.Millisecond },
+			expected: []string{"stat interval must be at least 1s, got 10ms, give it with a unit, for example --stat-interval 10s"},
+		},
+		{
+			name:     "distribution",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Distribution, d.ZipfTheta = "zipfian", 3 },
+			expected: []string{"zipf theta must be between 0 and 1, got 3, set --distribution"},
+		},
+		{
+			name:     "miss ratio range",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.MissRatio, d.Enumerate = 1.5, true },
+			expected: []string{"miss ratio must be between 0 and 1, got 1.5, set --miss-ratio"},
+		},
+		{
+			name:     "miss ratio without enumerate",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.MissRatio = 0.1 },
+			expected: []string{"miss ratio requires enumerated accounts, set --enumerate"},
+		},
+		{
+			name:     "padding size",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.AccountPayload, d.PaddingSize = true, -1 },
+			expected: []string{"padding size must not be negative, got -1, set --padding-size"},
+		},
+		{
+			name:   "negative hot accounts",
+			change: func(_ *Settings, d *DatabaseSettings) { d.ContentionTop = -1 },
+			expected: []string{"hot accounts and contention top must not be negative, got 0 and -1, " +
+				"set --hot-accounts and --contention-top"},
+		},
+		{
+			name:     "hot accounts without enumerate",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.HotAccounts = 10 },
+			expected: []string{"hot accounts require enumerated accounts, set --enumerate"},
+		},
+		{
+			name:     "hot fraction",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.HotAccounts, d.Enumerate, d.HotFraction = 10, true, 2 },
+			expected: []string{"hot fraction must be between 0 and 1, got 2, set --hot-fraction"},
+		},
+		{
+			name:     "negative statement workers",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.StatementWorkers = -1 },
+			expected: []string{"statement workers must not be negative, got -1, set --statement-workers"},
+		},
+		{
+			name:   "statement length",
+			change: func(_ *Settings, d *DatabaseSettings) { d.StatementWorkers, d.StatementLength = 2, 0 },
+			expected: []string{"statement length and scan page size must be positive, got 0 and 100, " +
+				"set --statement-length and --scan-page-size"},
+		},
+		{
+			name:     "bank scan share",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.StatementWorkers, d.BankScanShare = 2, 1.5 },
+			expected: []string{"bank scan share must be between 0 and 1, got 1.5, set --bank-scan-share"},
+		},
+		{
+			name:     "unsupported feature",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.DBType, d.MultiCurrency = cluster.Foundation, true },
+			expected: []string{"multi-currency mode is not supported for fdb, unset --multi-currency"},
+		},
+		{
+			name:     "feature with custom transactions",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Journal, d.UseCustomTx = true, true },
+			expected: []string{"transfer journal requires builtin transactions, unset --tx"},
+		},
+		{
+			name:   "isolation",
+			change: func(_ *Settings, d *DatabaseSettings) { d.Isolation = "snapshot" },
+			expected: []string{"unknown isolation level 'snapshot', expected one of 'read committed', " +
+				"'repeatable read', 'serializable', set --isolation and --locking"},
+		},
+		{
+			name:   "isolation of a database without sql transactions",
+			change: func(_ *Settings, d *DatabaseSettings) { d.DBType, d.Isolation = cluster.Foundation, "snapshot" },
+		},
+		{
+			name:   "routing",
+			change: func(_ *Settings, d *DatabaseSettings) { d.Routing = "random" },
+			expected: []string{"unknown routing policy 'random', expected one of 'round-robin', " +
+				"'primary-failover', 'read-replica', set --routing"},
+		},
+		{
+			name: "endpoints",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.DBType, d.Endpoints = cluster.Foundation, []string{"fdb.cluster"}
+			},
+			expected: []string{"additional endpoints are not supported for fdb, unset --endpoints"},
+		},
+		{
+			name:     "sharded",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Sharded = true },
+			expected: []string{"sharded cluster is not supported for postgres, unset --sharded"},
+		},
+		{
+			name:     "clients",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Clients = 0 },
+			expected: []string{"clients count must be positive, got 0, set --clients"},
+		},
+		{
+			name:     "client index",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.ClientIndex = 1 },
+			expected: []string{"client index must be from 0 to 0, got 1, set --client-index"},
+		},
+		{
+			name: "workers of clients",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.Clients, d.Workers, d.Barrier = 4, 2, "/shared"
+			},
+			expected: []string{"every client needs a worker, got 2 workers for 4 clients, set --workers"},
+		},
+		{
+			name: "count of clients",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.Clients, d.Workers, d.Count, d.Barrier = 4, 4, 3, "/shared"
+			},
+			expected: []string{"every client needs a part of count, got 3 for 4 clients, set --count"},
+		},
+		{
+			name: "journal of clients",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.Clients, d.Workers, d.Journal, d.Barrier = 2, 4, true, "/shared"
+			},
+			expected: []string{"transfer journal is not supported with several clients, unset --journal or set --clients 1"},
+		},
+		{
+			name:     "barrier of clients",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.Clients, d.Workers = 2, 4 },
+			expected: []string{"several clients start together through a directory shared by them, set --barrier"},
+		},
+		{
+			name: "check of clients",
+			change: func(_ *Settings, d *DatabaseSettings) {
+				d.Clients, d.Workers, d.Check, d.Barrier = 2, 4, true, "/shared"
+			},
+			expected: []string{"balance is checked when all clients are done, unset --check and run check after them"},
+		},
+		{
+			name: "clients in the stroppy pod",
+			change: func(s *Settings, d *DatabaseSettings) {
+				s.TestSettings.UseCloudStroppy, d.Clients, d.Workers, d.Check = true, 2, 4, true
+			},
+		},
+		{
+			name:     "key-value workload",
+			change:   func(_ *Settings, d *DatabaseSettings) { d.DBType = cluster.Cartridge },
+			validate: validateKV,
+			expected: []string{"key-value workload is not supported for cartridge (http), set --dbtype"},
+		},
+		{
+			name:     "cloud provider",
+			change:   func(s *Settings, _ *DatabaseSettings) { s.DeploymentSettings.Provider = "aws" },
+			validate: validateDeployment,
+			expected: []string{"unknown cloud provider 'aws', set --cloud to yandex, oracle or neutral"},
+		},
+		{
+			name:     "nodes",
+			change:   func(s *Settings, _ *DatabaseSettings) { s.DeploymentSettings.Nodes = 0 },
+			validate: validateDeployment,
+			expected: []string{"nodes count must be positive, got 0, set --nodes"},
+		},
+		{
+			name: "all problems at once",
+			change: func(s *Settings, d *DatabaseSettings) {
+				s.UseChaos, s.ChaosParameter, d.Workers, d.Sharded = true, "", 0, true
+			},
+			expected: []string{
+				"--use-chaos requires chaos scenarios, set --chaos-parameter",
+				"workers count must be positive, got 0, set --workers",
+				"sharded cluster is not supported for postgres, unset --sharded",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			settings := DefaultSettings()
+			settings.DatabaseSettings.Workers = 4
+			test.change(settings, settings.DatabaseSettings)
+
+			validate := test.validate
+			if validate == nil {
+				validate = func(s *Settings) error { return s.Validate() }
+			}
+
+			err := validate(settings)
+			if len(test.expected) == 0 {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			assert.Equal(t, "invalid settings:\n  - "+strings.Join(test.expected, "\n  - "), err.Error())
+		})
+	}
+}
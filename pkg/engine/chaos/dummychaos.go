@@ -9,6 +9,11 @@ import (
 	"gitlab.com/picodata/stroppy/pkg/state"
 )
 
+// CreateDummyController - контроллер без хаоса для запуска без kubernetes
+func CreateDummyController() Controller {
+	return createDummyChaos()
+}
+
 func createDummyChaos() (c Controller) {
 	c = &dummyChaos{}
 	return
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	clusterImplementation "gitlab.com/picodata/stroppy/pkg/database/cluster"
 	"gitlab.com/picodata/stroppy/pkg/engine/kubeengine"
 	engineSsh "gitlab.com/picodata/stroppy/pkg/engine/ssh"
@@ -45,11 +47,21 @@ func (cartridge *cartridgeCluster) Connect() (cluster interface{}, err error) {
 	) + uint64(
 		cartridge.commonCluster.addPool,
 	)
-	cluster, err = clusterImplementation.NewCartridgeCluster(
-		cartridge.DBUrl,
-		connectionPool,
-		cartridge.commonCluster.sharded,
-	)
+
+	// url вида tarantool://host:port выбирает драйвер на бинарном протоколе,
+	// иначе используется http-драйвер
+	if strings.HasPrefix(cartridge.DBUrl, clusterImplementation.CartridgeIprotoScheme+"://") {
+		cluster, err = clusterImplementation.NewCartridgeIprotoCluster(
+			cartridge.DBUrl,
+			connectionPool,
+		)
+	} else {
+		cluster, err = clusterImplementation.NewCartridgeCluster(
+			cartridge.DBUrl,
+			connectionPool,
+			cartridge.commonCluster.sharded,
+		)
+	}
 	if err != nil {
 		return nil, merry.Prepend(err, "failed to init connect to cartridge cluster")
 	}
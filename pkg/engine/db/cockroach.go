@@ -37,7 +37,16 @@ type cockroachCluster struct {
 }
 
 func (cc *cockroachCluster) Connect() (interface{}, error) {
-	return cluster.NewCockroachCluster(cc.DBUrl, cc.connectionPoolSize) //nolint
+	crCluster, err := cluster.NewCockroachCluster(cc.DBUrl, cc.connectionPoolSize, cc.txSettings, cc.routing)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create cockroach cluster")
+	}
+
+	if err = cc.customizeSchema(crCluster); err != nil {
+		return nil, merry.Prepend(err, "failed to customize cockroach schema")
+	}
+
+	return crCluster, nil
 }
 
 func (cc *cockroachCluster) Deploy(
@@ -6,10 +6,13 @@ package db
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/url"
+	"os"
 	"path"
 	"strings"
 
+	"gitlab.com/picodata/stroppy/pkg/database/cluster"
 	"gitlab.com/picodata/stroppy/pkg/engine/kubeengine"
 	engineSsh "gitlab.com/picodata/stroppy/pkg/engine/ssh"
 	"gitlab.com/picodata/stroppy/pkg/state"
@@ -43,6 +46,15 @@ func createCommonCluster(
 		connectionPoolSize:     shellState.Settings.DatabaseSettings.ConnectPoolSize,
 		addPool:                0,
 		sharded:                shellState.Settings.DatabaseSettings.Sharded,
+		schemaTemplatePath:     shellState.Settings.DatabaseSettings.SchemaTemplate,
+		routing: cluster.RoutingSettings{
+			Endpoints: shellState.Settings.DatabaseSettings.Endpoints,
+			Policy:    shellState.Settings.DatabaseSettings.Routing,
+		},
+		txSettings: cluster.SQLTxSettings{
+			Isolation: shellState.Settings.DatabaseSettings.Isolation,
+			Locking:   shellState.Settings.DatabaseSettings.Locking,
+		},
 	}
 }
 
@@ -61,6 +73,39 @@ type commonCluster struct {
 	addPool            int
 
 	sharded bool
+
+	txSettings cluster.SQLTxSettings
+	routing    cluster.RoutingSettings
+
+	schemaTemplatePath string
+}
+
+// customizeSchema загружает пользовательский шаблон схемы, если он задан явно или
+// найден в каталоге настроек БД, и передает его драйверу.
+func (cc *commonCluster) customizeSchema(dbCluster interface{}) error {
+	schemaPath := cc.schemaTemplatePath
+	explicit := schemaPath != ""
+	if !explicit {
+		schemaPath = path.Join(cc.wd, cluster.SchemaTemplateFileName)
+	}
+
+	schemaTemplate, err := ioutil.ReadFile(schemaPath)
+	if err != nil {
+		if !explicit && os.IsNotExist(err) {
+			return nil
+		}
+		return merry.Prepend(err, "failed to read schema template")
+	}
+
+	customizable, ok := dbCluster.(cluster.SchemaCustomizable)
+	if !ok {
+		return merry.Errorf("schema templates are not supported for %s", cc.tg)
+	}
+
+	llog.Infof("Schema template '%s' will be used to bootstrap %s", schemaPath, cc.tg)
+	customizable.SetSchemaTemplate(string(schemaTemplate))
+
+	return nil
 }
 
 func (cc *commonCluster) deploy(shellState *state.State) error {
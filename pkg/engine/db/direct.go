@@ -0,0 +1,30 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package db
+
+import (
+	"github.com/ansel1/merry"
+	"gitlab.com/picodata/stroppy/pkg/kubernetes"
+	"gitlab.com/picodata/stroppy/pkg/state"
+)
+
+// CreateDirectCluster - кластер БД для прямого подключения по url без ssh, kubernetes
+// и провайдера. Создается только драйвер БД при подключении, развернуть такой кластер нельзя.
+func CreateDirectCluster(shellState *state.State) (Cluster, error) {
+	dbCluster, err := CreateCluster(nil, nil, shellState)
+	if err != nil {
+		return nil, err
+	}
+
+	return &directCluster{Cluster: dbCluster}, nil
+}
+
+type directCluster struct {
+	Cluster
+}
+
+func (*directCluster) Deploy(_ *kubernetes.Kubernetes, _ *state.State) error {
+	return merry.New("database can not be deployed with a direct connection")
+}
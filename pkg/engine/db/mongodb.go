@@ -62,6 +62,10 @@ func (mongo *mongoCluster) Connect() (cluster interface{}, err error) {
 	if err != nil {
 		return nil, merry.Prepend(err, "failed to init connect to  mongo cluster")
 	}
+
+	if err = mongo.customizeSchema(cluster); err != nil {
+		return nil, merry.Prepend(err, "failed to customize mongo schema")
+	}
 	return
 }
 
@@ -55,10 +55,14 @@ func (pc *postgresCluster) Connect() (interface{}, error) {
 		llog.Infoln("changed DBURL on", pc.DBUrl)
 	}
 
-	if pgCluster, err = cluster.NewPostgresCluster(pc.DBUrl, pc.connectionPoolSize); err != nil {
+	if pgCluster, err = cluster.NewPostgresCluster(pc.DBUrl, pc.connectionPoolSize, pc.txSettings, pc.routing); err != nil {
 		return nil, merry.Prepend(err, "Error then creating postgres cluster")
 	}
 
+	if err = pc.customizeSchema(pgCluster); err != nil {
+		return nil, merry.Prepend(err, "failed to customize postgres schema")
+	}
+
 	return pgCluster, nil
 }
 
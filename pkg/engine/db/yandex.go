@@ -192,6 +192,10 @@ func (yc *yandexCluster) Connect() (interface{}, error) {
 		return nil, merry.Prepend(err, "Error then creating new YDB cluster")
 	}
 
+	if err = yc.commonCluster.customizeSchema(connection); err != nil {
+		return nil, merry.Prepend(err, "failed to customize YDB schema")
+	}
+
 	llog.Debugln("Connection to YDB successfully created")
 
 	return connection, nil
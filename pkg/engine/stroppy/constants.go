@@ -4,6 +4,8 @@
 
 package stroppy
 
+import "fmt"
+
 const (
 	stroppyClientNSManifestFile = "namespace.yaml"
 	stoppyClientManifestFile    = "deployment.yaml"
@@ -13,3 +15,13 @@ const (
 	StroppyClientNSName  = "stroppy"
 	StroppyClientPodName = "stroppy-client"
 )
+
+// ClientPodName - имя пода клиента stroppy: первый клиент - StroppyClientPodName,
+// остальные с номером клиента
+func ClientPodName(client int) string {
+	if client == 0 {
+		return StroppyClientPodName
+	}
+
+	return fmt.Sprintf("%s-%d", StroppyClientPodName, client)
+}
@@ -101,11 +101,27 @@ func (pod *Pod) DeployNamespace(shellState *state.State) error {
 	return nil
 }
 
+// DeployPod - развернуть по поду stroppy из манифеста на каждого клиента совместного запуска
 func (pod *Pod) DeployPod(shellState *state.State) error {
+	clients := shellState.Settings.DatabaseSettings.Clients
+	if clients < 1 {
+		clients = 1
+	}
+
+	for client := 0; client < clients; client++ {
+		if err := pod.deployClientPod(shellState, ClientPodName(client)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (pod *Pod) deployClientPod(shellState *state.State, podName string) error {
 	var err error
 
 	stroppyClientPodConfig := applyconfig.Pod(
-		StroppyClientPodName,
+		podName,
 		engine.ResourceDefaultNamespace,
 	)
 
@@ -120,6 +136,9 @@ func (pod *Pod) DeployPod(shellState *state.State) error {
 		return merry.Prepend(err, "failed to cast to k8s engine object")
 	}
 
+	// манифест описывает первый под, имена остальных задаются по номеру клиента
+	stroppyClientPodConfig.WithName(podName)
+
 	deployContext, cancel := context.WithCancel(context.Background())
 
 	defer cancel()
@@ -128,7 +147,7 @@ func (pod *Pod) DeployPod(shellState *state.State) error {
 
 	if err = pod.e.DeployAndWaitObject(
 		deployContext,
-		StroppyClientPodName,
+		podName,
 		engine.ResourceDefaultNamespace,
 		func(clientSet *kubernetes.Clientset) error {
 			if podResult, err = clientSet.CoreV1().Pods(StroppyClientNSName).Apply(
@@ -143,7 +162,7 @@ func (pod *Pod) DeployPod(shellState *state.State) error {
 		},
 		func(clientSet *kubernetes.Clientset) error {
 			if err = clientSet.CoreV1().Pods(StroppyClientNSName).Delete(
-				deployContext, StroppyClientPodName, pod.e.GenerateDefaultDeleteOptions(),
+				deployContext, podName, pod.e.GenerateDefaultDeleteOptions(),
 			); err != nil {
 				return merry.Prepend(err, "failed to delete pod")
 			}
@@ -153,12 +172,12 @@ func (pod *Pod) DeployPod(shellState *state.State) error {
 	); err != nil {
 		return merry.Prepend(
 			err,
-			fmt.Sprintf("Error then deploying pod %s", StroppyClientNSName),
+			fmt.Sprintf("Error then deploying pod %s", podName),
 		)
 	}
 
-	llog.Debugf("Pod %s status %v", StroppyClientPodName, podResult.Status.Phase)
-	llog.Infof("Applying stroppy pod '%s': success", StroppyClientPodName)
+	llog.Debugf("Pod %s status %v", podName, podResult.Status.Phase)
+	llog.Infof("Applying stroppy pod '%s': success", podName)
 
 	return nil
 }
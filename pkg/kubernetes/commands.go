@@ -5,8 +5,11 @@
 package kubernetes
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	engine "gitlab.com/picodata/stroppy/pkg/engine/kubeengine"
@@ -28,6 +31,19 @@ func (k *Kubernetes) ExecuteRemoteCommand(
 	testCmd []string,
 	logFileName string,
 	shellState *state.State,
+) (int64, int64, error) {
+	return k.ExecuteClientCommand(stroppy.StroppyClientPodName, containerName, testCmd, logFileName, shellState)
+}
+
+// ExecuteClientCommand - выполнить команду в поде клиента stroppy podName, вывод
+// команды пишется в журнал logFileName
+//
+//nolint:gocritic // because here two conflicting lint rules nonamedreturns and unnamedResult
+func (k *Kubernetes) ExecuteClientCommand(
+	podName, containerName string,
+	testCmd []string,
+	logFileName string,
+	shellState *state.State,
 ) (int64, int64, error) {
 	var (
 		beginTime int64
@@ -52,13 +68,13 @@ func (k *Kubernetes) ExecuteRemoteCommand(
 	// формируем запрос для API k8s
 	executeRequest := clientSet.CoreV1().RESTClient().Post().
 		Resource(engine.ResourcePodName).
-		Name(stroppy.StroppyClientPodName).
+		Name(podName).
 		Namespace(stroppy.StroppyClientNSName).
 		SubResource(engine.SubresourceExec).Timeout(60)
 
 	option := &v1.PodExecOptions{
 		TypeMeta:  metav1.TypeMeta{},
-		Stdin:     true,
+		Stdin:     false,
 		Stdout:    true,
 		Stderr:    true,
 		TTY:       true,
@@ -77,7 +93,11 @@ func (k *Kubernetes) ExecuteRemoteCommand(
 		return beginTime, endTime, merry.Prepend(err, "failed to execute remote test")
 	}
 
-	logFilePath := filepath.Join(shellState.Settings.WorkingDirectory, logFileName)
+	// относительный путь журнала отсчитывается от рабочего каталога
+	logFilePath := logFileName
+	if !filepath.IsAbs(logFilePath) {
+		logFilePath = filepath.Join(shellState.Settings.WorkingDirectory, logFileName)
+	}
 
 	var logFile *os.File
 	if logFile, err = os.Create(logFilePath); err != nil {
@@ -85,8 +105,9 @@ func (k *Kubernetes) ExecuteRemoteCommand(
 	}
 	defer logFile.Close()
 
+	// тест не читает ввод, стандартный ввод остается сценарию оболочки
 	streamOptions := remotecommand.StreamOptions{
-		Stdin:             os.Stdin,
+		Stdin:             nil,
 		Stdout:            logFile,
 		Stderr:            os.Stderr,
 		Tty:               true,
@@ -106,3 +127,80 @@ func (k *Kubernetes) ExecuteRemoteCommand(
 
 	return beginTime, endTime, nil
 }
+
+// WriteFileToPod - записать файл в под stroppy podName, каталог файла создается
+func (k *Kubernetes) WriteFileToPod(podName, path string, data []byte) error {
+	command := []string{"sh", "-c", `mkdir -p "$(dirname "$0")" && cat > "$0"`, path}
+
+	if err := k.execInPod(podName, command, bytes.NewReader(data), io.Discard); err != nil {
+		return merry.Prependf(err, "failed to write '%s' to stroppy pod %s", path, podName)
+	}
+
+	return nil
+}
+
+// ReadFileFromPod - прочитать файл из пода stroppy podName в destination
+func (k *Kubernetes) ReadFileFromPod(podName, path string, destination io.Writer) error {
+	if err := k.execInPod(podName, []string{"cat", path}, nil, destination); err != nil {
+		return merry.Prependf(err, "failed to read '%s' from stroppy pod %s", path, podName)
+	}
+
+	return nil
+}
+
+// ListPodDirectory - имена файлов каталога в поде stroppy podName, отсутствующий
+// каталог считается пустым
+func (k *Kubernetes) ListPodDirectory(podName, path string) ([]string, error) {
+	var output bytes.Buffer
+
+	command := []string{"sh", "-c", `ls -1 "$0" 2>/dev/null || true`, path}
+	if err := k.execInPod(podName, command, nil, &output); err != nil {
+		return nil, merry.Prependf(err, "failed to list '%s' in stroppy pod %s", path, podName)
+	}
+
+	return strings.Fields(output.String()), nil
+}
+
+// execInPod - выполнить команду в поде stroppy без терминала, ошибки команды
+// возвращаются вместе с ее выводом в stderr
+func (k *Kubernetes) execInPod(podName string, command []string, stdin io.Reader, stdout io.Writer) error {
+	config, err := k.Engine.GetKubeConfig()
+	if err != nil {
+		return merry.Prepend(err, "failed to get kubeconfig")
+	}
+
+	var clientSet *kubernetes.Clientset
+	if clientSet, err = k.Engine.GetClientSet(); err != nil {
+		return merry.Prepend(err, "failed to get clientset")
+	}
+
+	executeRequest := clientSet.CoreV1().RESTClient().Post().
+		Resource(engine.ResourcePodName).
+		Name(podName).
+		Namespace(stroppy.StroppyClientNSName).
+		SubResource(engine.SubresourceExec)
+
+	executeRequest.VersionedParams(&v1.PodExecOptions{ //nolint
+		Stdin:   stdin != nil,
+		Stdout:  true,
+		Stderr:  true,
+		Command: command,
+	}, scheme.ParameterCodec)
+
+	var _exec remotecommand.Executor
+	if _exec, err = remotecommand.NewSPDYExecutor(config, "POST", executeRequest.URL()); err != nil {
+		return merry.Prepend(err, "failed to create executor")
+	}
+
+	var stderr bytes.Buffer
+
+	if err = _exec.Stream(remotecommand.StreamOptions{ //nolint
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	}); err != nil {
+		return merry.Prependf(err, "command failed, stderr: '%s'", stderr.String())
+	}
+
+	return nil
+}
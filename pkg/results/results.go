@@ -0,0 +1,282 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansel1/merry"
+	llog "github.com/sirupsen/logrus"
+	"gitlab.com/picodata/stroppy/pkg/statistics"
+)
+
+const (
+	// RunFileName - описание запуска в каталоге результатов
+	RunFileName = "run.json"
+	// ConfigFileName - снимок параметров запуска
+	ConfigFileName = "config.yaml"
+	// LogFileName - журнал stroppy
+	LogFileName = "stroppy.log"
+
+	idDateFormat = "20060102-150405"
+	dirMode      = 0o755
+	fileMode     = 0o644
+)
+
+// состояния запуска
+const (
+	StatusRunning = "running"
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+)
+
+// Run - запуск stroppy: идентификатор, команда и итог. Все файлы запуска
+// сохраняются в его каталог результатов.
+type Run struct {
+	ID      string              `json:"id"`
+	Command string              `json:"command"`
+	Args    []string            `json:"args"`
+	DBType  string              `json:"dbtype"`
+	Start   time.Time           `json:"start"`
+	End     time.Time           `json:"end"`
+	Status  string              `json:"status"`
+	Error   string              `json:"error,omitempty"`
+	Summary *statistics.Summary `json:"summary,omitempty"`
+
+	Dir string `json:"-"`
+}
+
+var (
+	currentMutex sync.Mutex
+	current      *Run
+)
+
+// Start - начать запуск: создать каталог <root>/<id> со снимком параметров и описанием
+// запуска. Фатальная ошибка в журнале завершает запуск с ее текстом.
+func Start(root, command string, args []string, dbType string, config []byte) (*Run, error) {
+	run := &Run{
+		Command: command,
+		Args:    args,
+		DBType:  dbType,
+		Start:   time.Now(),
+		Status:  StatusRunning,
+	}
+
+	if err := os.MkdirAll(root, dirMode); err != nil {
+		return nil, merry.Prepend(err, "failed to create results directory")
+	}
+
+	// запуски одной команды в одну секунду получают порядковый номер
+	id := fmt.Sprintf("%s_%s_%s", run.Start.Format(idDateFormat), command, dbType)
+	for i := 2; ; i++ {
+		run.ID, run.Dir = id, filepath.Join(root, id)
+
+		err := os.Mkdir(run.Dir, dirMode)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return nil, merry.Prepend(err, "failed to create run directory")
+		}
+
+		id = fmt.Sprintf("%s_%s_%s-%d", run.Start.Format(idDateFormat), command, dbType, i)
+	}
+
+	if err := os.WriteFile(filepath.Join(run.Dir, ConfigFileName), config, fileMode); err != nil {
+		return nil, merry.Prepend(err, "failed to write config snapshot")
+	}
+
+	if err := run.save(); err != nil {
+		return nil, err
+	}
+
+	currentMutex.Lock()
+	current = run
+	currentMutex.Unlock()
+
+	llog.AddHook(fatalHook{})
+
+	return run, nil
+}
+
+// Current - текущий запуск, nil, если запуск не начат
+func Current() *Run {
+	currentMutex.Lock()
+	defer currentMutex.Unlock()
+
+	return current
+}
+
+// Dir - каталог результатов текущего запуска, без запуска - fallback
+func Dir(fallback string) string {
+	if run := Current(); run != nil {
+		return run.Dir
+	}
+
+	return fallback
+}
+
+// Finish - завершить текущий запуск: сохранить время окончания, итог и
+// итоги последнего теста этого процесса
+func Finish(err error) {
+	currentMutex.Lock()
+	run := current
+	current = nil
+	currentMutex.Unlock()
+
+	if run == nil {
+		return
+	}
+
+	run.End = time.Now()
+	run.Status = StatusPassed
+
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+
+	if summary := statistics.StatsLastSummary(); summary.Requests > 0 {
+		// t-digest нужен только для объединения итогов клиентов, в описании запуска он лишний
+		summary.Digest = nil
+		run.Summary = &summary
+	}
+
+	if saveErr := run.save(); saveErr != nil {
+		llog.Warnf("%v", saveErr)
+	}
+}
+
+func (run *Run) save() error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return merry.Prepend(err, "failed to marshal run")
+	}
+
+	if err = os.WriteFile(filepath.Join(run.Dir, RunFileName), data, fileMode); err != nil {
+		return merry.Prepend(err, "failed to write run")
+	}
+
+	return nil
+}
+
+// Duration - длительность запуска, для незавершенного - до текущего момента
+func (run *Run) Duration() time.Duration {
+	if run.End.IsZero() {
+		return time.Since(run.Start)
+	}
+
+	return run.End.Sub(run.Start)
+}
+
+// fatalHook - записать итог запуска до выхода по llog.Fatal
+type fatalHook struct{}
+
+func (fatalHook) Levels() []llog.Level {
+	return []llog.Level{llog.FatalLevel, llog.PanicLevel}
+}
+
+func (fatalHook) Fire(entry *llog.Entry) error {
+	Finish(errors.New(entry.Message))
+
+	return nil
+}
+
+// List - запуски каталога результатов root от старых к новым
+func List(root string) ([]*Run, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read results directory")
+	}
+
+	runs := make([]*Run, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		run, err := load(filepath.Join(root, entry.Name()))
+		if err != nil {
+			llog.Debugf("skipping %s: %v", entry.Name(), err)
+
+			continue
+		}
+
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	return runs, nil
+}
+
+// Find - запуск по идентификатору, его уникальному началу или last для последнего
+func Find(root, id string) (*Run, error) {
+	runs, err := List(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(runs) == 0 {
+		return nil, merry.Errorf("no runs in %s", root)
+	}
+
+	if id == "last" {
+		return runs[len(runs)-1], nil
+	}
+
+	var found []*Run
+
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+
+		if strings.HasPrefix(run.ID, id) {
+			found = append(found, run)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, merry.Errorf("run '%s' not found in %s", id, root)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, merry.Errorf("'%s' matches %d runs, give more of the run id", id, len(found))
+	}
+}
+
+func load(dir string) (*Run, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RunFileName))
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	var run Run
+	if err = json.Unmarshal(data, &run); err != nil {
+		return nil, merry.Prepend(err, "failed to parse run")
+	}
+
+	run.Dir = dir
+
+	return &run, nil
+}
@@ -0,0 +1,92 @@
+package results
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIndex(t *testing.T) {
+	root := t.TempDir()
+
+	// запуски одной команды в одну секунду различаются порядковым номером
+	first, err := Start(root, "pay", []string{"pay", "--password", "******"}, "postgres", []byte("count: 1\n"))
+	require.NoError(t, err)
+	Finish(nil)
+
+	second, err := Start(root, "pay", nil, "postgres", nil)
+	require.NoError(t, err)
+	Finish(errors.New("connection refused"))
+
+	third, err := Start(root, "pop", nil, "fdb", nil)
+	require.NoError(t, err)
+	assert.Equal(t, third, Current())
+	assert.Equal(t, third.Dir, Dir("fallback"))
+
+	// каталог без описания запуска не попадает в список
+	require.NoError(t, os.Mkdir(filepath.Join(root, "broken"), dirMode))
+
+	if second.Start.Truncate(time.Second).Equal(first.Start.Truncate(time.Second)) {
+		assert.Equal(t, first.ID+"-2", second.ID)
+	}
+
+	runs, err := List(root)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+
+	assert.Equal(t, []string{first.ID, second.ID, third.ID}, []string{runs[0].ID, runs[1].ID, runs[2].ID})
+	assert.Equal(t, []string{"pay", "--password", "******"}, runs[0].Args)
+	assert.Equal(t, StatusPassed, runs[0].Status)
+	assert.Equal(t, StatusFailed, runs[1].Status)
+	assert.Equal(t, "connection refused", runs[1].Error)
+	assert.Equal(t, StatusRunning, runs[2].Status)
+
+	snapshot, err := os.ReadFile(filepath.Join(runs[0].Dir, ConfigFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "count: 1\n", string(snapshot))
+
+	Finish(nil)
+	assert.Nil(t, Current())
+	assert.Equal(t, "fallback", Dir("fallback"))
+
+	for _, test := range []struct {
+		name     string
+		id       string
+		expected string
+		err      string
+	}{
+		{name: "last", id: "last", expected: third.ID},
+		{name: "full id", id: first.ID, expected: first.ID},
+		{name: "unique beginning", id: third.ID[:len(third.ID)-2], expected: third.ID},
+		{name: "ambiguous beginning", id: first.ID[:8], err: "matches 3 runs"},
+		{name: "unknown", id: "19700101", err: "run '19700101' not found"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			run, err := Find(root, test.id)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, run.ID)
+		})
+	}
+}
+
+func TestListEmpty(t *testing.T) {
+	runs, err := List(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	_, err = Find(t.TempDir(), "last")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no runs in")
+}
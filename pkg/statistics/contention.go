@@ -0,0 +1,134 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	llog "github.com/sirupsen/logrus"
+)
+
+// accountContention - переводы одного счета: число переводов, повторов после конфликтов
+// и временных ошибок, переводов, завершившихся ошибкой, и задержки с учетом повторов.
+type accountContention struct {
+	account    string
+	transfers  uint64
+	retries    uint64
+	aborts     uint64
+	latency    time.Duration
+	maxLatency time.Duration
+}
+
+// contention - счетчики по счетам, ведутся только после StatsEnableContention,
+// чтобы не хранить запись на каждый счет в обычных запусках.
+type contention struct {
+	sync.Mutex
+	enabled  uint32
+	top      int
+	accounts map[string]*accountContention
+}
+
+var c contention
+
+func contentionReset() {
+	c.Lock()
+	defer c.Unlock()
+
+	atomic.StoreUint32(&c.enabled, 0)
+	c.top = 0
+	c.accounts = nil
+}
+
+// StatsEnableContention - вести счетчики переводов по счетам и вывести в итогах
+// top самых конфликтных счетов.
+func StatsEnableContention(top int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.top = top
+	c.accounts = make(map[string]*accountContention)
+	atomic.StoreUint32(&c.enabled, 1)
+}
+
+// StatsAccountTransfer - учесть перевод со счетом account: retries повторов,
+// aborted - перевод не выполнен из-за ошибки, elapsed - время перевода вместе с повторами.
+func StatsAccountTransfer(account string, retries int, aborted bool, elapsed time.Duration) {
+	// без счетчиков по счетам переводы не конкурируют за общую блокировку
+	if atomic.LoadUint32(&c.enabled) == 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.accounts == nil {
+		return
+	}
+
+	stats, ok := c.accounts[account]
+	if !ok {
+		stats = &accountContention{account: account} //nolint:exhaustivestruct
+		c.accounts[account] = stats
+	}
+
+	stats.transfers++
+	stats.retries += uint64(retries)
+	if aborted {
+		stats.aborts++
+	}
+	stats.latency += elapsed
+	if elapsed > stats.maxLatency {
+		stats.maxLatency = elapsed
+	}
+}
+
+// contentionReportSummary - вывести таблицу самых конфликтных счетов: по числу повторов,
+// затем по числу ошибок и суммарному времени переводов.
+func contentionReportSummary() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.accounts == nil || c.top <= 0 {
+		return
+	}
+
+	accounts := make([]*accountContention, 0, len(c.accounts))
+	for _, stats := range c.accounts {
+		accounts = append(accounts, stats)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].retries != accounts[j].retries {
+			return accounts[i].retries > accounts[j].retries
+		}
+		if accounts[i].aborts != accounts[j].aborts {
+			return accounts[i].aborts > accounts[j].aborts
+		}
+
+		return accounts[i].latency > accounts[j].latency
+	})
+
+	if len(accounts) > c.top {
+		accounts = accounts[:c.top]
+	}
+
+	llog.Infof("Top %d contended accounts of %d:", len(accounts), len(c.accounts))
+	llog.Infof("%-24s %10s %10s %8s %10s %12s %12s",
+		"ACCOUNT", "TRANSFERS", "RETRIES", "ABORTS", "RETRIES/TX", "AVG LATENCY", "MAX LATENCY")
+
+	for _, stats := range accounts {
+		llog.Infof("%-24s %10d %10d %8d %10.2f %11.3fs %11.3fs",
+			stats.account,
+			stats.transfers,
+			stats.retries,
+			stats.aborts,
+			float64(stats.retries)/float64(stats.transfers),
+			stats.latency.Seconds()/float64(stats.transfers),
+			stats.maxLatency.Seconds(),
+		)
+	}
+}
@@ -0,0 +1,86 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DBMetricsSample - выбранные метрики одного среза внутренней статистики БД.
+type DBMetricsSample struct {
+	Time     time.Time
+	Database string
+	Values   map[string]float64
+}
+
+// dbMetrics - временной ряд метрик БД, собранных за время запуска.
+type dbMetrics struct {
+	sync.Mutex
+	series  []DBMetricsSample
+	printed time.Time
+}
+
+var d dbMetrics
+
+// StatsDBMetrics - добавить в ряд запуска метрики, извлеченные из статистики БД.
+func StatsDBMetrics(database string, values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.series = append(d.series, DBMetricsSample{
+		Time:     time.Now(),
+		Database: database,
+		Values:   values,
+	})
+}
+
+// DBMetricsSeries - получить копию ряда метрик БД.
+func DBMetricsSeries() []DBMetricsSample {
+	d.Lock()
+	defer d.Unlock()
+
+	series := make([]DBMetricsSample, len(d.series))
+	copy(series, d.series)
+
+	return series
+}
+
+// dbMetricsProgress - строка с последним еще не выведенным срезом метрик БД,
+// пустая, если новых срезов не было.
+func dbMetricsProgress() string {
+	d.Lock()
+	defer d.Unlock()
+
+	if len(d.series) == 0 {
+		return ""
+	}
+
+	last := d.series[len(d.series)-1]
+	if !last.Time.After(d.printed) {
+		return ""
+	}
+	d.printed = last.Time
+
+	names := make([]string, 0, len(last.Values))
+	for name := range last.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%s=%g", name, last.Values[name]))
+	}
+
+	return fmt.Sprintf("%s metrics: %s", last.Database, strings.Join(values, ", "))
+}
@@ -0,0 +1,95 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/ansel1/merry"
+	"github.com/spenczar/tdigest"
+)
+
+// заголовок сериализованного t-digest: магическое число, версия формата, сжатие
+// и число центроидов, за ним центроиды парами число значений - среднее
+const (
+	digestMagic   = int16(0xc80)
+	digestVersion = int32(1)
+)
+
+type digestCentroid struct {
+	Count int64
+	Mean  float64
+}
+
+// mergeDigests - объединить сериализованные t-digest. TDigest.MergeInto учитывает каждый
+// центроид дважды и искажает квантили, поэтому центроиды всех t-digest собираются в один
+// упорядоченный по среднему список, который сам является корректным t-digest. Центроиды
+// с одинаковым средним объединяются: на паре таких центроидов Quantile не интерполирует.
+func mergeDigests(digests [][]byte) (*tdigest.TDigest, error) {
+	var (
+		centroids   []digestCentroid
+		compression float64
+	)
+
+	for i, data := range digests {
+		// проверка целостности средствами библиотеки
+		if err := tdigest.New().UnmarshalBinary(data); err != nil {
+			return nil, merry.Prependf(err, "failed to parse latency digest %d", i)
+		}
+
+		var header struct {
+			Magic       int16
+			Version     int32
+			Compression float64
+			Count       int32
+		}
+
+		reader := bytes.NewReader(data)
+		if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+			return nil, merry.Prependf(err, "failed to read latency digest %d", i)
+		}
+
+		digestCentroids := make([]digestCentroid, header.Count)
+		if err := binary.Read(reader, binary.LittleEndian, digestCentroids); err != nil {
+			return nil, merry.Prependf(err, "failed to read latency digest %d", i)
+		}
+
+		centroids = append(centroids, digestCentroids...)
+		compression = header.Compression
+	}
+
+	sort.SliceStable(centroids, func(i, j int) bool {
+		return centroids[i].Mean < centroids[j].Mean
+	})
+
+	merged := centroids[:0]
+	for _, centroid := range centroids {
+		if last := len(merged) - 1; last >= 0 && merged[last].Mean == centroid.Mean {
+			merged[last].Count += centroid.Count
+
+			continue
+		}
+
+		merged = append(merged, centroid)
+	}
+
+	centroids = merged
+
+	var buffer bytes.Buffer
+	for _, value := range []interface{}{digestMagic, digestVersion, compression, int32(len(centroids)), centroids} {
+		if err := binary.Write(&buffer, binary.LittleEndian, value); err != nil {
+			return nil, merry.Prepend(err, "failed to build merged latency digest")
+		}
+	}
+
+	digest := tdigest.New()
+	if err := digest.UnmarshalBinary(buffer.Bytes()); err != nil {
+		return nil, merry.Prepend(err, "failed to build merged latency digest")
+	}
+
+	return digest, nil
+}
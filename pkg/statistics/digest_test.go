@@ -0,0 +1,98 @@
+package statistics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/spenczar/tdigest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// marshalDigest - сериализованный t-digest значений from..to с весом 1
+func marshalDigest(t *testing.T, from, to int) []byte {
+	t.Helper()
+
+	digest := tdigest.New()
+	for value := from; value <= to; value++ {
+		digest.Add(float64(value), 1)
+	}
+
+	data, err := digest.MarshalBinary()
+	require.NoError(t, err)
+
+	return data
+}
+
+// digestCount - число значений t-digest по сумме весов его центроидов
+func digestCount(t *testing.T, digest *tdigest.TDigest) int64 {
+	t.Helper()
+
+	data, err := digest.MarshalBinary()
+	require.NoError(t, err)
+
+	var header struct {
+		Magic       int16
+		Version     int32
+		Compression float64
+		Count       int32
+	}
+
+	reader := bytes.NewReader(data)
+	require.NoError(t, binary.Read(reader, binary.LittleEndian, &header))
+	assert.Equal(t, digestMagic, header.Magic)
+	assert.Equal(t, digestVersion, header.Version)
+
+	centroids := make([]digestCentroid, header.Count)
+	require.NoError(t, binary.Read(reader, binary.LittleEndian, centroids))
+
+	var count int64
+	for _, centroid := range centroids {
+		count += centroid.Count
+	}
+
+	return count
+}
+
+func TestMergeDigests(t *testing.T) {
+	merged, err := mergeDigests([][]byte{
+		marshalDigest(t, 1, 1000),
+		marshalDigest(t, 1001, 2000),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2000), digestCount(t, merged))
+
+	for _, test := range []struct {
+		quantile float64
+		expected float64
+	}{
+		{0.01, 20},
+		{0.25, 500},
+		{0.5, 1000},
+		{0.75, 1500},
+		{0.99, 1980},
+	} {
+		assert.InDelta(t, test.expected, merged.Quantile(test.quantile), 10,
+			"quantile %v", test.quantile)
+	}
+}
+
+func TestMergeDigestsOverlapping(t *testing.T) {
+	// одинаковые t-digest не смещают квантили, а только удваивают число значений
+	merged, err := mergeDigests([][]byte{
+		marshalDigest(t, 1, 1000),
+		marshalDigest(t, 1, 1000),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2000), digestCount(t, merged))
+	assert.InDelta(t, 500, merged.Quantile(0.5), 10)
+	assert.InDelta(t, 990, merged.Quantile(0.99), 10)
+}
+
+func TestMergeDigestsInvalid(t *testing.T) {
+	_, err := mergeDigests([][]byte{marshalDigest(t, 1, 10), []byte("broken")})
+	assert.Error(t, err)
+}
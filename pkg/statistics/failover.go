@@ -0,0 +1,85 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"sync"
+	"time"
+
+	llog "github.com/sirupsen/logrus"
+)
+
+// failoverStats - события потери соединения с адресами кластера и время восстановления,
+// наблюдаемое клиентом.
+type failoverStats struct {
+	sync.Mutex
+	reconnects map[string]int64
+	recoveries []time.Duration
+}
+
+var f failoverStats
+
+func failoverReset() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.reconnects = make(map[string]int64)
+	f.recoveries = nil
+}
+
+// StatsReconnect - зафиксировать потерю соединения с адресом кластера.
+func StatsReconnect(endpoint string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.reconnects == nil {
+		f.reconnects = make(map[string]int64)
+	}
+	f.reconnects[endpoint]++
+}
+
+// StatsRecovered - зафиксировать время от первой ошибки соединения до первого успешного запроса.
+func StatsRecovered(recoveryTime time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.recoveries = append(f.recoveries, recoveryTime)
+}
+
+func failoverReportSummary() {
+	f.Lock()
+	defer f.Unlock()
+
+	if len(f.reconnects) == 0 && len(f.recoveries) == 0 {
+		return
+	}
+
+	for endpoint, count := range f.reconnects {
+		llog.Infof("Reconnect events for %s: %d", endpoint, count)
+	}
+
+	if len(f.recoveries) == 0 {
+		llog.Infof("Connection to the cluster was not recovered")
+		return
+	}
+
+	var minTime, maxTime, totalTime time.Duration
+	for _, recoveryTime := range f.recoveries {
+		if minTime == 0 || recoveryTime < minTime {
+			minTime = recoveryTime
+		}
+		if recoveryTime > maxTime {
+			maxTime = recoveryTime
+		}
+		totalTime += recoveryTime
+	}
+
+	llog.Infof("Recoveries: %d, time to recovery min/max/avg: %.3fs/%.3fs/%.3fs",
+		len(f.recoveries),
+		minTime.Seconds(),
+		maxTime.Seconds(),
+		totalTime.Seconds()/float64(len(f.recoveries)),
+	)
+}
@@ -0,0 +1,131 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	llog "github.com/sirupsen/logrus"
+)
+
+// keyHistogramBuckets - на сколько равных диапазонов номеров ключей делится гистограмма отчета.
+const keyHistogramBuckets = 10
+
+// keyHistogramBarWidth - длина полосы гистограммы для 100% обращений.
+const keyHistogramBarWidth = 50
+
+// KeyHistogram - число обращений к каждому ключу одного измерения, например к номеру БИК.
+type KeyHistogram struct {
+	name string
+	hits []uint64
+}
+
+// Add - учесть обращение к ключу с номером key.
+func (h *KeyHistogram) Add(key int) {
+	if h == nil {
+		return
+	}
+
+	atomic.AddUint64(&h.hits[key], 1)
+}
+
+type keyHistograms struct {
+	sync.Mutex
+	dimensions []*KeyHistogram
+}
+
+var k keyHistograms
+
+func keyHistogramsReset() {
+	k.Lock()
+	defer k.Unlock()
+
+	k.dimensions = nil
+}
+
+// StatsKeyHistogram - гистограмма измерения name из size ключей, общая для всех воркеров.
+func StatsKeyHistogram(name string, size int) *KeyHistogram {
+	k.Lock()
+	defer k.Unlock()
+
+	for _, histogram := range k.dimensions {
+		if histogram.name == name && len(histogram.hits) == size {
+			return histogram
+		}
+	}
+
+	histogram := &KeyHistogram{
+		name: name,
+		hits: make([]uint64, size),
+	}
+	k.dimensions = append(k.dimensions, histogram)
+
+	return histogram
+}
+
+// keyHistogramsReportSummary - вывести фактическое распределение обращений по диапазонам
+// номеров ключей и долю обращений к самым частым ключам.
+func keyHistogramsReportSummary() {
+	k.Lock()
+	defer k.Unlock()
+
+	for _, histogram := range k.dimensions {
+		hits := make([]uint64, len(histogram.hits))
+
+		var total uint64
+		for i := range histogram.hits {
+			hits[i] = atomic.LoadUint64(&histogram.hits[i])
+			total += hits[i]
+		}
+
+		if total == 0 {
+			continue
+		}
+
+		llog.Infof("Key distribution for %s: %d operations over %d keys",
+			histogram.name, total, len(hits))
+
+		for bucket := 0; bucket < keyHistogramBuckets; bucket++ {
+			first := bucket * len(hits) / keyHistogramBuckets
+			last := (bucket + 1) * len(hits) / keyHistogramBuckets
+			if first == last {
+				continue
+			}
+
+			var bucketHits uint64
+			for _, keyHits := range hits[first:last] {
+				bucketHits += keyHits
+			}
+
+			share := float64(bucketHits) / float64(total)
+			llog.Infof("  keys %7d-%-7d %6.2f%% %s",
+				first, last-1, share*100, strings.Repeat("#", int(share*keyHistogramBarWidth)))
+		}
+
+		sort.Slice(hits, func(i, j int) bool {
+			return hits[i] > hits[j]
+		})
+
+		shares := make([]float64, 0, 3)
+		for _, topPercent := range []int{1, 10, 50} {
+			top := len(hits) * topPercent / 100
+			if top == 0 {
+				top = 1
+			}
+
+			var topHits uint64
+			for _, keyHits := range hits[:top] {
+				topHits += keyHits
+			}
+			shares = append(shares, float64(topHits)/float64(total)*100)
+		}
+
+		llog.Infof("  hottest 1%%/10%%/50%% of keys: %.2f%%/%.2f%%/%.2f%% of operations",
+			shares[0], shares[1], shares[2])
+	}
+}
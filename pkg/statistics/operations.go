@@ -0,0 +1,87 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	llog "github.com/sirupsen/logrus"
+)
+
+// operationMetrics - задержки запросов по видам операций, например read и update
+// нагрузки ключ-значение, для итогового отчета в разрезе операций, как в YCSB.
+type operationMetrics struct {
+	sync.Mutex
+	operations map[string]*Metrics
+}
+
+var o operationMetrics
+
+func operationsReset() {
+	o.Lock()
+	defer o.Unlock()
+
+	o.operations = make(map[string]*Metrics)
+}
+
+// StatsOperationEnd - учесть завершение запроса операции operation
+// в общей статистике и в статистике этой операции.
+func StatsOperationEnd(operation string, c Cookie) {
+	elapsed := time.Since(c.time)
+	s.queue <- elapsed
+
+	operationUpdate(operation, elapsed)
+}
+
+// StatsBackgroundOperationEnd - учесть завершение запроса фоновой операции только в статистике
+// этой операции: фоновые запросы, например чтение выписок параллельно с переводами,
+// не входят в общую пропускную способность и прогресс теста.
+func StatsBackgroundOperationEnd(operation string, c Cookie) {
+	operationUpdate(operation, time.Since(c.time))
+}
+
+func operationUpdate(operation string, elapsed time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+
+	metrics, ok := o.operations[operation]
+	if !ok {
+		metrics = new(Metrics)
+		metrics.Reset()
+		o.operations[operation] = metrics
+	}
+	metrics.Update(elapsed)
+}
+
+// operationsReportSummary - вывести число запросов, пропускную способность
+// и задержки каждой операции.
+func operationsReportSummary(wallclocktime float64) {
+	o.Lock()
+	defer o.Unlock()
+
+	names := make([]string, 0, len(o.operations))
+	for name := range o.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metrics := o.operations[name]
+		llog.Infof("[%s] operations: %d, %v op/sec, latency min/avg/max: %.3fs/%.3fs/%.3fs, "+
+			"50/95/99%%: %.3fs/%.3fs/%.3fs",
+			name,
+			metrics.n_requests,
+			int(float64(metrics.n_requests)/wallclocktime),
+			metrics.latency_min.Seconds(),
+			metrics.cputime.Seconds()/float64(metrics.n_requests),
+			metrics.latency_max.Seconds(),
+			metrics.tdigest.Quantile(0.5),  //nolint
+			metrics.tdigest.Quantile(0.95), //nolint
+			metrics.tdigest.Quantile(0.99), //nolint
+		)
+	}
+}
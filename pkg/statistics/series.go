@@ -0,0 +1,57 @@
+/* Copyright 2021 The Stroppy Authors. All rights reserved         *
+ * Use of this source code is governed by the 2-Clause BSD License *
+ * that can be found in the LICENSE file.                          */
+
+package statistics
+
+import (
+	"sync"
+	"time"
+)
+
+// Sample - точка ряда статистики, те же значения, что выводятся в журнал каждые 10 секунд
+type Sample struct {
+	Time          time.Time `json:"time"`
+	Requests      int64     `json:"requests"`
+	RPS           int64     `json:"rps"`
+	LatencyMin    float64   `json:"latency_min"`
+	LatencyP99    float64   `json:"latency_p99"`
+	LatencyMax    float64   `json:"latency_max"`
+	LatencyMedian float64   `json:"latency_median"`
+}
+
+// ряд статистики сохраняется через все тесты, пока не запрошен заново,
+// чтобы сценарий из нескольких шагов получил единый ряд
+type series struct {
+	sync.Mutex
+	enabled bool
+	samples []Sample
+}
+
+var ts series
+
+// StatsRecordSeries - начать запись ряда статистики заново
+func StatsRecordSeries() {
+	ts.Lock()
+	defer ts.Unlock()
+
+	ts.enabled = true
+	ts.samples = nil
+}
+
+// StatsSeries - записанные точки ряда статистики
+func StatsSeries() []Sample {
+	ts.Lock()
+	defer ts.Unlock()
+
+	return append([]Sample(nil), ts.samples...)
+}
+
+func seriesUpdate(sample Sample) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if ts.enabled {
+		ts.samples = append(ts.samples, sample)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ansel1/merry"
 	llog "github.com/sirupsen/logrus"
 	"github.com/spenczar/tdigest"
 )
@@ -76,15 +77,32 @@ loop:
 					progress = fmt.Sprintf("Done %10d requests", s.summary.n_requests)
 				}
 
+				sample := Sample{
+					Time:     time.Now(),
+					Requests: s.periodic.n_requests,
+					RPS:      s.periodic.n_requests / 10,
+				}
+				// без запросов за интервал (например, при недоступности БД) квантили не определены
+				if s.periodic.n_requests > 0 {
+					sample.LatencyMin = s.periodic.latency_min.Seconds()
+					sample.LatencyP99 = s.periodic.tdigest.Quantile(0.99) //nolint
+					sample.LatencyMax = s.periodic.latency_max.Seconds()
+					sample.LatencyMedian = s.periodic.tdigest.Quantile(0.55) //nolint
+				}
+				seriesUpdate(sample)
+
 				llog.Infof("%s, Latency min/99%%/max/med: %.3fs/%.3fs/%.3fs/%.3fs",
 					progress,
-					s.periodic.latency_min.Seconds(),
-					s.periodic.tdigest.Quantile(0.99), //nolint
-					s.periodic.latency_max.Seconds(),
-					s.periodic.tdigest.Quantile(0.55), //nolint
+					sample.LatencyMin,
+					sample.LatencyP99,
+					sample.LatencyMax,
+					sample.LatencyMedian,
 				)
 				s.periodic.Reset()
 			}
+			if progress := dbMetricsProgress(); progress != "" {
+				llog.Infoln(progress)
+			}
 		case elapsed, more = <-s.queue:
 			if !more {
 				break loop
@@ -106,6 +124,10 @@ func StatsInit() {
 	s.summary.Reset()
 	s.queue = make(chan time.Duration, 1000)
 	s.done = make(chan bool, 1)
+	failoverReset()
+	keyHistogramsReset()
+	operationsReset()
+	contentionReset()
 
 	go statsWorker()
 }
@@ -120,16 +142,65 @@ func StatsRequestEnd(c Cookie) {
 	s.queue <- time.Since(c.time)
 }
 
+// Summary - итоги последнего теста: число запросов, длительность в секундах,
+// пропускная способность в запросах в секунду и задержки в секундах. Digest - t-digest
+// задержек, по нему итоги нескольких клиентов объединяются без потери квантилей.
+type Summary struct {
+	Requests    int64   `json:"requests"`
+	Duration    float64 `json:"duration"`
+	Throughput  float64 `json:"throughput"`
+	LatencyMin  float64 `json:"latency_min"`
+	LatencyAvg  float64 `json:"latency_avg"`
+	LatencyP50  float64 `json:"latency_p50"`
+	LatencyP95  float64 `json:"latency_p95"`
+	LatencyP99  float64 `json:"latency_p99"`
+	LatencyP999 float64 `json:"latency_p999"`
+	LatencyMax  float64 `json:"latency_max"`
+	Digest      []byte  `json:"digest,omitempty"`
+}
+
+var lastSummary Summary
+
+// StatsLastSummary - итоги последнего завершенного теста
+func StatsLastSummary() Summary {
+	return lastSummary
+}
+
 func StatsReportSummary() {
 	// Stop background work
 	close(s.queue)
 	<-s.done
 
+	defer failoverReportSummary()
+	defer contentionReportSummary()
+	defer keyHistogramsReportSummary()
+
+	lastSummary = Summary{} //nolint
+
 	if s.summary.n_requests == 0 {
 		return
 	}
 
 	wallclocktime := time.Since(s.starttime).Seconds()
+	lastSummary = Summary{
+		Requests:    s.summary.n_requests,
+		Duration:    wallclocktime,
+		Throughput:  float64(s.summary.n_requests) / wallclocktime,
+		LatencyMin:  s.summary.latency_min.Seconds(),
+		LatencyAvg:  s.summary.cputime.Seconds() / float64(s.summary.n_requests),
+		LatencyP50:  s.summary.tdigest.Quantile(0.5),   //nolint
+		LatencyP95:  s.summary.tdigest.Quantile(0.95),  //nolint
+		LatencyP99:  s.summary.tdigest.Quantile(0.99),  //nolint
+		LatencyP999: s.summary.tdigest.Quantile(0.999), //nolint
+		LatencyMax:  s.summary.latency_max.Seconds(),
+	}
+
+	if digest, err := s.summary.tdigest.MarshalBinary(); err == nil {
+		lastSummary.Digest = digest
+	} else {
+		llog.Warnf("failed to serialize latency digest: %v", err)
+	}
+
 	llog.Infof("Total time: %.3fs, %v t/sec",
 		wallclocktime,
 		int(float64(s.summary.n_requests)/wallclocktime),
@@ -144,4 +215,65 @@ func StatsReportSummary() {
 		s.summary.tdigest.Quantile(0.99),
 		s.summary.tdigest.Quantile(0.999),
 	)
+	operationsReportSummary(wallclocktime)
+}
+
+// MergeSummaries - объединить итоги клиентов, выполнявших один тест одновременно: запросы
+// складываются, длительность - самая долгая из клиентов, квантили считаются по объединенному
+// t-digest, поэтому итоги без Digest объединить нельзя
+func MergeSummaries(summaries []Summary) (Summary, error) {
+	var (
+		merged  Summary
+		digests [][]byte
+	)
+
+	for i := range summaries {
+		summary := &summaries[i]
+		if summary.Requests == 0 {
+			continue
+		}
+
+		if len(summary.Digest) == 0 {
+			return Summary{}, merry.Errorf("summary %d has no latency digest", i) //nolint
+		}
+
+		digests = append(digests, summary.Digest)
+
+		if merged.Requests == 0 || summary.LatencyMin < merged.LatencyMin {
+			merged.LatencyMin = summary.LatencyMin
+		}
+
+		if summary.LatencyMax > merged.LatencyMax {
+			merged.LatencyMax = summary.LatencyMax
+		}
+
+		if summary.Duration > merged.Duration {
+			merged.Duration = summary.Duration
+		}
+
+		merged.LatencyAvg += summary.LatencyAvg * float64(summary.Requests)
+		merged.Requests += summary.Requests
+	}
+
+	if merged.Requests == 0 {
+		return merged, nil
+	}
+
+	digest, err := mergeDigests(digests)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	merged.LatencyAvg /= float64(merged.Requests)
+	merged.Throughput = float64(merged.Requests) / merged.Duration
+	merged.LatencyP50 = digest.Quantile(0.5)    //nolint
+	merged.LatencyP95 = digest.Quantile(0.95)   //nolint
+	merged.LatencyP99 = digest.Quantile(0.99)   //nolint
+	merged.LatencyP999 = digest.Quantile(0.999) //nolint
+
+	if merged.Digest, err = digest.MarshalBinary(); err != nil {
+		return Summary{}, merry.Prepend(err, "failed to serialize merged latency digest") //nolint
+	}
+
+	return merged, nil
 }
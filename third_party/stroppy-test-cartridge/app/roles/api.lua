@@ -4,6 +4,7 @@ local errors = require("errors")
 local decimal = require("decimal")
 local uuid = require("uuid")
 local fiber = require("fiber")
+local metrics_json = require("metrics.plugins.json")
 local custom_errors = require("app.custom_errors")
 
 local err_vshard_router = errors.new_class("Vshard routing error")
@@ -725,6 +726,35 @@ local function http_make_atomic_transfer(req)
 	return json_response(req, { info = "Successfully transfer execution" }, 200)
 end
 
+-- Адаптер для вызова http-обработчиков через бинарный протокол (iproto):
+-- тело запроса передается аргументом, ответ возвращается таблицей {status, body}
+local function iproto_request(body)
+	return {
+		json = function()
+			return body
+		end,
+		render = function(_, opts)
+			return { json = opts.json }
+		end,
+	}
+end
+
+local function iproto_handler(handler)
+	return function(body)
+		local resp = handler(iproto_request(body or {}))
+		local resp_body = {}
+		-- decimal не поддерживается go-коннектором, передаем его строкой, как и в json
+		for k, v in pairs(resp.json or {}) do
+			if decimal.is_decimal(v) then
+				resp_body[k] = tostring(v)
+			else
+				resp_body[k] = v
+			end
+		end
+		return { status = resp.status, body = resp_body }
+	end
+end
+
 local function init(opts)
 	if opts.is_master then
 		box.schema.user.create("stroppy", { if_not_exists = true })
@@ -754,6 +784,17 @@ local function init(opts)
 	httpd:route({ path = "/transfer/custom/fetch", method = "POST", public = true }, http_fetch_transfer)
 
 	log.debug("Created httpd")
+
+	-- Те же обработчики доступны через iproto для драйвера stroppy на бинарном протоколе
+	rawset(_G, "stroppy_account_insert", iproto_handler(http_account_add))
+	rawset(_G, "stroppy_fetch_total", iproto_handler(http_fetch_total))
+	rawset(_G, "stroppy_persist_total", iproto_handler(http_persist_total))
+	rawset(_G, "stroppy_check_balance", iproto_handler(http_calculate_balance))
+	rawset(_G, "stroppy_fetch_settings", iproto_handler(http_fetch_settings))
+	rawset(_G, "stroppy_bootstrap_db", iproto_handler(http_bootstrap_db))
+	rawset(_G, "stroppy_make_atomic_transfer", iproto_handler(http_make_atomic_transfer))
+	-- метрики роли cartridge.roles.metrics в json, аналог http-адреса /metrics
+	rawset(_G, "stroppy_fetch_metrics", metrics_json.export)
 	return true
 end
 
@@ -32,6 +32,11 @@ local function account_add(account)
 			nil, --при загрузке счета должен быть пустой
 			account.pending_amount,
 			account.bucket_id,
+			-- атрибуты счета заданы, только если счета загружаются с ними
+			account.name or box.NULL,
+			account.currency or box.NULL,
+			account.status or box.NULL,
+			account.padding or box.NULL,
 		})
 	end)
 
@@ -264,6 +269,10 @@ local function init(opts)
 			{ name = "pending_transfer", type = "uuid", is_nullable = true },
 			{ name = "pending_amount", type = "decimal" },
 			{ name = "bucket_id", type = "unsigned" },
+			{ name = "name", type = "string", is_nullable = true },
+			{ name = "currency", type = "string", is_nullable = true },
+			{ name = "status", type = "string", is_nullable = true },
+			{ name = "padding", type = "string", is_nullable = true },
 		})
 		accounts:create_index("primary", { parts = { { field = "bic" }, { field = "ban" } }, if_not_exists = true })
 		accounts:create_index(